@@ -33,7 +33,7 @@ resource "customcrud" "file" {
     path = "secret.txt"
   }
 
-  input_wo = jsonencode({
+  input_wo = {
     content = ephemeral.customcrud.urandom.output.content
-  })
+  }
 }
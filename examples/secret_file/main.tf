@@ -43,9 +43,9 @@ resource "customcrud" "secret_file" {
     delete = "../../internal/provider/test_write_only/delete.sh"
   }
 
-  input_wo = jsonencode({
+  input_wo = {
     content = ephemeral.google_secret_manager_secret_version.password_version.secret_data
-  })
+  }
 
   input = {
     // Force an update when the password changes (ephemeral resources don't appear in plans)
@@ -0,0 +1,173 @@
+// Package hookdocs generates registry style documentation for the hook sets
+// of a self-test config from the descriptions the hooks print themselves.
+//
+// A read hook implements the describe handshake by printing, when its payload
+// phase is "describe", a JSON object such as
+//
+//	{
+//	  "description": "Manages a file on the local disk",
+//	  "input": {"path": {"type": "string", "description": "File path", "required": true}},
+//	  "output": {"content": {"type": "string", "description": "File content"}}
+//	}
+package hookdocs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/customcrud/terraform-provider-customcrud/internal/selftest"
+)
+
+// Description is what a hook set prints for the describe handshake.
+type Description struct {
+	Description string           `json:"description"`
+	Input       map[string]Field `json:"input"`
+	Output      map[string]Field `json:"output"`
+}
+
+// Field documents a single input or output key.
+type Field struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// Generate writes a markdown page to dir for every resource of config whose
+// read hook implements the describe handshake, writing a line per resource to
+// out. It returns the number of pages written.
+func Generate(ctx context.Context, config *selftest.Config, dir string, out io.Writer) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create docs directory: %w", err)
+	}
+	providerConfig := config.ProviderConfig()
+	written := 0
+	for i, res := range config.Resources {
+		if res.Name == "" {
+			res.Name = fmt.Sprintf("resources[%d]", i)
+		}
+		description, err := describe(ctx, providerConfig, res)
+		if err != nil {
+			fmt.Fprintf(out, "SKIP %s: %v\n", res.Name, err)
+			continue
+		}
+		path := filepath.Join(dir, res.Name+".md")
+		if err := os.WriteFile(path, render(res, description), 0o644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written++
+		fmt.Fprintf(out, "WROTE %s: %s\n", res.Name, path)
+	}
+	return written, nil
+}
+
+// describe runs the describe handshake against the read hook of res.
+func describe(ctx context.Context, config utils.CustomCRUDProviderConfig, res selftest.Resource) (*Description, error) {
+	if res.Hooks.Read == "" {
+		return nil, fmt.Errorf("a read hook is required")
+	}
+	result, err := res.Hooks.Run(ctx, config, res.Hooks.Read, utils.ExecutionPayload{Phase: utils.PhaseDescribe})
+	if err != nil {
+		return nil, fmt.Errorf("read hook doesn't implement the describe handshake: %w", err)
+	}
+	raw, err := json.Marshal(result.Result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode description: %w", err)
+	}
+	var description Description
+	if err := json.Unmarshal(raw, &description); err != nil {
+		return nil, fmt.Errorf("read hook printed an invalid description: %w", err)
+	}
+	if description.Description == "" && description.Input == nil && description.Output == nil {
+		return nil, fmt.Errorf("read hook doesn't implement the describe handshake")
+	}
+	return &description, nil
+}
+
+// render returns the markdown page documenting res.
+func render(res selftest.Resource, description *Description) []byte {
+	kind, block := "Resource", "resource"
+	if res.Hooks.Create == "" && res.Hooks.Delete == "" {
+		kind, block = "Data Source", "data"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "---\npage_title: %q\ndescription: |-\n  %s\n---\n\n", res.Name+" - customcrud hooks", description.Description)
+	fmt.Fprintf(&b, "# %s (%s)\n\n", res.Name, kind)
+	if description.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", description.Description)
+	}
+
+	fmt.Fprintf(&b, "## Example Usage\n\n```terraform\n%s \"customcrud\" %q {\n  hooks {\n", block, res.Name)
+	for _, hook := range [][2]string{
+		{utils.Create, res.Hooks.Create},
+		{utils.Read, res.Hooks.Read},
+		{utils.Update, res.Hooks.Update},
+		{utils.Delete, res.Hooks.Delete},
+		{utils.WorkingDirectory, res.Hooks.WorkingDirectory},
+		{utils.OutputFormat, res.Hooks.OutputFormat},
+	} {
+		if hook[1] != "" {
+			fmt.Fprintf(&b, "    %s = %q\n", hook[0], hook[1])
+		}
+	}
+	b.WriteString("  }\n")
+	if len(res.Input) > 0 {
+		b.WriteString("\n  input = {\n")
+		for _, key := range sortedKeys(res.Input) {
+			// JSON values are valid HCL expressions
+			value, _ := json.Marshal(res.Input[key])
+			fmt.Fprintf(&b, "    %s = %s\n", key, value)
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n```\n\n## Schema\n")
+
+	required, optional := map[string]Field{}, map[string]Field{}
+	for key, field := range description.Input {
+		if field.Required {
+			required[key] = field
+		} else {
+			optional[key] = field
+		}
+	}
+	writeFields(&b, "Required Input", required)
+	writeFields(&b, "Optional Input", optional)
+	writeFields(&b, "Output", description.Output)
+	return b.Bytes()
+}
+
+func writeFields(b *bytes.Buffer, title string, fields map[string]Field) {
+	if len(fields) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n### %s\n\n", title)
+	for _, key := range sortedKeys(fields) {
+		field := fields[key]
+		fieldType := field.Type
+		if fieldType == "" {
+			fieldType = "dynamic"
+		}
+		line := fmt.Sprintf("- `%s` (%s)", key, strings.ToUpper(fieldType[:1])+fieldType[1:])
+		if field.Description != "" {
+			line += " " + field.Description
+		}
+		b.WriteString(line + "\n")
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,59 @@
+package hookdocs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/selftest"
+)
+
+func TestUnitHookdocs_Generate(t *testing.T) {
+	config := &selftest.Config{
+		WorkingDirectory: "testdata",
+		Resources: []selftest.Resource{
+			{
+				Name:  "file_lookup",
+				Hooks: selftest.Hooks{Read: "./read.sh"},
+				Input: map[string]interface{}{"path": "read.sh"},
+			},
+			{
+				Name:  "undescribed",
+				Hooks: selftest.Hooks{Read: "echo '{}'"},
+			},
+		},
+	}
+	dir := t.TempDir()
+
+	var out bytes.Buffer
+	written, err := Generate(context.Background(), config, dir, &out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if written != 1 {
+		t.Fatalf("Expected a single page, got %d:\n%s", written, out.String())
+	}
+	if !strings.Contains(out.String(), "SKIP undescribed") {
+		t.Errorf("Expected the undescribed hooks to be skipped:\n%s", out.String())
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, "file_lookup.md"))
+	if err != nil {
+		t.Fatalf("Failed to read page: %v", err)
+	}
+	for _, want := range []string{
+		"# file_lookup (Data Source)",
+		`data "customcrud" "file_lookup" {`,
+		`    read = "./read.sh"`,
+		`    path = "read.sh"`,
+		"### Required Input\n\n- `path` (String) Path of the file",
+		"### Output\n\n- `content` (String) Content of the file",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("Expected %q in page:\n%s", want, page)
+		}
+	}
+}
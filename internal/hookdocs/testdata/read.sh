@@ -0,0 +1,14 @@
+#!/usr/bin/env bash
+set -e
+input="$(cat)"
+if [ "$(echo "$input" | jq -r '.phase')" = "describe" ]; then
+  jq -n '{
+    description: "Reads a file from the local disk",
+    input: {path: {type: "string", description: "Path of the file", required: true}},
+    output: {content: {type: "string", description: "Content of the file"}}
+  }'
+  exit 0
+fi
+path="$(echo "$input" | jq -r '.input.path')"
+content="$(cat "$path")" || exit 22
+jq -n --arg id "$path" --arg content "$content" '{id: $id, content: $content}'
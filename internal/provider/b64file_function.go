@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+var _ function.Function = &b64fileFunction{}
+
+// b64fileFunction reads a file into a base64 string, for passing binary
+// artifacts to hooks through input, which only holds text.
+type b64fileFunction struct{}
+
+func NewB64fileFunction() function.Function {
+	return &b64fileFunction{}
+}
+
+func (f *b64fileFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "b64file"
+}
+
+func (f *b64fileFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Reads a file and returns its contents encoded as base64",
+		Description: "Reads the file at path, relative to the directory Terraform runs in, and returns its contents as a standard base64 string. An optional max_bytes argument fails the call when the file is larger, which keeps unexpectedly large artifacts out of input and state.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "path",
+				Description: "Path of the file to read",
+			},
+		},
+		VariadicParameter: function.Int64Parameter{
+			Name:        "max_bytes",
+			Description: "Maximum size of the file in bytes, unlimited when omitted",
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *b64fileFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var path string
+	var limits []int64
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &path, &limits))
+	if resp.Error != nil {
+		return
+	}
+	if len(limits) > 1 {
+		resp.Error = function.NewArgumentFuncError(1, "Only one max_bytes argument may be given")
+		return
+	}
+	limit := int64(-1)
+	if len(limits) == 1 {
+		if limits[0] < 0 {
+			resp.Error = function.NewArgumentFuncError(1, "max_bytes must not be negative")
+			return
+		}
+		limit = limits[0]
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to read the file: %v", err))
+		return
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if limit >= 0 {
+		// Read one byte past the limit to tell a file at the limit from a larger one
+		reader = io.LimitReader(file, limit+1)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to read the file: %v", err))
+		return
+	}
+	if limit >= 0 && int64(len(content)) > limit {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("The file %s is larger than %d bytes", path, limit))
+		return
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, base64.StdEncoding.EncodeToString(content)))
+}
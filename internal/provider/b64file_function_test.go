@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// runB64file calls the b64file function with path and the max_bytes limits.
+func runB64file(path string, limits ...int64) (string, *function.FuncError) {
+	ctx := context.Background()
+	variadic := make([]attr.Value, len(limits))
+	for i, limit := range limits {
+		variadic[i] = types.Int64Value(limit)
+	}
+	elemTypes := make([]attr.Type, len(limits))
+	for i := range elemTypes {
+		elemTypes[i] = types.Int64Type
+	}
+	resp := &function.RunResponse{Result: function.NewResultData(types.StringUnknown())}
+	NewB64fileFunction().Run(ctx, function.RunRequest{
+		Arguments: function.NewArgumentsData([]attr.Value{
+			types.StringValue(path),
+			types.TupleValueMust(elemTypes, variadic),
+		}),
+	}, resp)
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	return resp.Result.Value().(types.String).ValueString(), nil
+}
+
+func TestUnitB64fileFunction(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "artifact.bin")
+	if err := os.WriteFile(file, []byte{0x00, 0xff, 'h', 'i'}, 0o600); err != nil {
+		t.Fatalf("Failed to write the file: %v", err)
+	}
+
+	if got, err := runB64file(file); err != nil || got != "AP9oaQ==" {
+		t.Errorf("Expected AP9oaQ==, got %q (%v)", got, err)
+	}
+	if got, err := runB64file(file, 4); err != nil || got != "AP9oaQ==" {
+		t.Errorf("Expected a file at the limit to be read, got %q (%v)", got, err)
+	}
+	if _, err := runB64file(file, 3); err == nil || !strings.Contains(err.Text, "larger than 3 bytes") {
+		t.Errorf("Expected a file over the limit to fail, got %v", err)
+	}
+	if _, err := runB64file(file, 3, 4); err == nil {
+		t.Error("Expected several max_bytes arguments to fail")
+	}
+	if _, err := runB64file(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected a missing file to fail")
+	}
+}
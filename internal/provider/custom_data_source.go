@@ -5,8 +5,11 @@ import (
 
 	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
 	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
@@ -19,6 +22,12 @@ type customCrudDataSourceModel struct {
 	Hooks  types.List    `tfsdk:"hooks"`
 	Input  types.Dynamic `tfsdk:"input"`
 	Output types.Dynamic `tfsdk:"output"`
+
+	OutputSensitive        types.Dynamic `tfsdk:"output_sensitive"`
+	SortOutputLists        types.List    `tfsdk:"sort_output_lists"`
+	OutputSchema           types.String  `tfsdk:"output_schema"`
+	OutputValidationSchema types.String  `tfsdk:"output_validation_schema"`
+	SharedReadKey          types.String  `tfsdk:"shared_read_key"`
 }
 
 func (m *customCrudDataSourceModel) GetHooks() types.List {
@@ -48,14 +57,97 @@ func (d *customCrudDataSource) Schema(ctx context.Context, req datasource.Schema
 				Computed:    true,
 				Description: "Output data from the data source",
 			},
+			"output_sensitive": schema.DynamicAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "Output values the read hook marked as sensitive with the __sensitive key",
+			},
+			"sort_output_lists": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Dot-separated output key paths (e.g. network.ips) whose lists of strings, numbers or booleans are sorted before being stored",
+			},
+			"output_schema": schema.StringAttribute{
+				Optional:    true,
+				Description: "Type of the output as a Terraform type expression, such as object({name = string, ports = list(number)}), or as JSON, such as [\"object\", {\"name\": \"string\"}]. Hook output is coerced to it so that output types stay stable across runs instead of being inferred from the values",
+				Validators: []validator.String{
+					outputSchemaValidator{},
+				},
+			},
+			"output_validation_schema": schema.StringAttribute{
+				Optional:    true,
+				Description: "JSON Schema document the read hook result is validated against before it is stored, failing the read with an error naming the offending output key",
+				Validators: []validator.String{
+					jsonSchemaValidator{summary: "Invalid Output Validation Schema"},
+				},
+			},
+			"shared_read_key": schema.StringAttribute{
+				Optional:    true,
+				Description: "Key identifying the backend object, shared with resources managing the same object. Resources and data sources with the same key share a single read hook result per Terraform operation instead of each running their read hook",
+			},
 		},
 		Blocks: map[string]schema.Block{
 			"hooks": schema.ListNestedBlock{
 				NestedObject: schema.NestedBlockObject{
 					Attributes: map[string]schema.Attribute{
 						utils.Read: schema.StringAttribute{
-							Required:    true,
-							Description: "Read command (space-separated command and arguments)",
+							Optional:    true,
+							Description: "Read command (space-separated command and arguments), required unless argv or read_script sets it",
+							Validators:  hookCommandValidators(utils.Read, true, true),
+						},
+						utils.Read + utils.ScriptSuffix: schema.StringAttribute{
+							Optional:    true,
+							Description: "Inline read script, e.g. a heredoc, written to a temporary executable file and run instead of a read command. Scripts starting with a shebang such as #!/bin/bash run with that interpreter, others with sh, or cmd on Windows",
+						},
+						utils.Validate: schema.StringAttribute{
+							Optional:    true,
+							Description: "Validate command run before the read hook, which receives the input and exits with a non-zero code to reject it without the read hook running. The errors it prints as JSON, such as {\"path\": \"filter.region\", \"detail\": \"...\"} or an object with an errors list, are reported on the input keys they name",
+							Validators:  hookCommandValidators(utils.Validate, false, false),
+						},
+						utils.WorkingDirectory: schema.StringAttribute{
+							Optional:    true,
+							Description: "Working directory for hook execution, overrides the provider working_directory",
+						},
+						utils.OutputFormat: schema.StringAttribute{
+							Optional:    true,
+							Description: "Format of the hook output, either json (default) or yaml",
+							Validators: []validator.String{
+								stringvalidator.OneOf(utils.OutputFormatJSON, utils.OutputFormatYAML),
+							},
+						},
+						utils.BypassParallelism: schema.BoolAttribute{
+							Optional:    true,
+							Description: "Run these hooks without waiting on the provider parallelism limit, for hooks that are local and instant",
+						},
+						utils.RawOutput: schema.BoolAttribute{
+							Optional:    true,
+							Description: "Store hook stdout verbatim under output.raw instead of parsing it, for wrapping commands that don't print JSON",
+						},
+						utils.Templates: schema.BoolAttribute{
+							Optional:    true,
+							Description: "Render Go template placeholders in the hook commands and argv entries, such as --bucket {{ .input.bucket }}, from the hook payload before they run. A literal {{ is written as {{ \"{{\" }}",
+						},
+						utils.Runtimes: schema.MapAttribute{
+							ElementType: types.StringType,
+							Optional:    true,
+							Description: "Names of provider runtimes by hook name, e.g. { create = \"builder\", delete = \"appliance\" }, to run those hooks with the executor of the runtime instead of the provider executor",
+						},
+						utils.Platforms: schema.MapAttribute{
+							ElementType: types.MapType{ElemType: types.StringType},
+							Optional:    true,
+							Description: "Hook commands and other string attributes by operating system, e.g. { windows = { create = \"pwsh -File create.ps1\" } }, used in place of the attributes above when the hooks run on that system: the one the provider runs on, or linux for hooks run by the docker, ssh and http executors. Operating systems are named like Go's runtime.GOOS: linux, darwin, windows, ...",
+							Validators: []validator.Map{
+								mapvalidator.KeysAre(stringvalidator.OneOf(utils.PlatformNames...)),
+							},
+						},
+						utils.Argv: schema.MapAttribute{
+							ElementType: types.ListType{ElemType: types.StringType},
+							Optional:    true,
+							Description: "Hook commands as argument lists by hook name, e.g. { read = [\"/usr/bin/python3\", \"manage.py\", \"show resource\"] }, passed to exec verbatim instead of the command attribute of the hook, so arguments may contain spaces and quotes. A hook is given either by its command attribute or here",
+							Validators: []validator.Map{
+								mapvalidator.KeysAre(stringvalidator.OneOf(utils.Read, utils.Validate)),
+								mapvalidator.ValueListsAre(listvalidator.SizeAtLeast(1)),
+							},
 						},
 					},
 				},
@@ -73,12 +165,24 @@ func (d *customCrudDataSource) Configure(ctx context.Context, req datasource.Con
 		return
 	}
 	if data, ok := req.ProviderData.(*CustomCRUDProvider); ok {
-		d.config = data.config
+		d.config = data.kindConfig(dataSourceKind)
 	}
 }
 
+// configFor returns the provider config with the data source level options of data applied.
+func (d *customCrudDataSource) configFor(ctx context.Context, data *customCrudDataSourceModel) utils.CustomCRUDProviderConfig {
+	config := d.config
+	if paths := stringList(ctx, data.SortOutputLists); paths != nil {
+		config.SortOutputPaths = paths
+	}
+	return config
+}
+
 func (d *customCrudDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
-	utils.WithSemaphore(d.config.Semaphore, func() {
+	ctx, profile := d.config.Profiler.Start(ctx, dataSourceKind, utils.Read)
+	defer profile.Finish()
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, d.config, req.Config.GetAttribute), func(ctx context.Context) {
+		profile.Mark(utils.ProfileWait)
 		var data customCrudDataSourceModel
 		resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
 		if resp.Diagnostics.HasError() {
@@ -87,13 +191,67 @@ func (d *customCrudDataSource) Read(ctx context.Context, req datasource.ReadRequ
 
 		payload := utils.ExecutionPayload{
 			Input: utils.MergeDefaultInputs(d.config, utils.AttrValueToInterface(data.Input.UnderlyingValue())),
+			Phase: utils.PhaseRefresh,
+		}
+		// A malformed query fails before the read hook gets to run
+		if d.validateInput(ctx, &data, payload, &resp.Diagnostics); resp.Diagnostics.HasError() {
+			return
 		}
-		result, ok := utils.RunCrudScript(ctx, d.config, &data, payload, &resp.Diagnostics, utils.CrudRead)
+		result, ok := d.config.ReadCache.Read(data.SharedReadKey.ValueString(), func() (*utils.ExecutionResult, bool) {
+			return utils.RunCrudScript(ctx, d.configFor(ctx, &data), &data, payload, &resp.Diagnostics, utils.CrudRead)
+		})
 		if !ok {
 			return
 		}
 
-		data.Output = utils.MapToDynamic(result.Result)
+		resp.Diagnostics.Append(checkOutputValidation(data.OutputValidationSchema, result.Result)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		outputSchema, diags := parseOutputSchema(data.OutputSchema)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		public, sensitive := utils.SplitSensitive(result.Result, result.Sensitive)
+		if public == nil {
+			public = map[string]interface{}{}
+		}
+		if outputSchema != nil && sensitive != nil {
+			// Check the whole output, each part may lack required attributes
+			_, diags := outputSchema.Convert(result.Result)
+			resp.Diagnostics.Append(diags...)
+		}
+		output, diags := convertOutput(outputSchema, public, sensitive != nil, d.config.CollectionTyping)
+		resp.Diagnostics.Append(diags...)
+		data.Output = output
+		data.OutputSensitive = types.DynamicNull()
+		if sensitive != nil {
+			outputSensitive, diags := convertOutput(outputSchema, sensitive, true, d.config.CollectionTyping)
+			resp.Diagnostics.Append(diags...)
+			data.OutputSensitive = outputSensitive
+		}
+		if resp.Diagnostics.HasError() {
+			return
+		}
 		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 	})
 }
+
+// validateInput runs the validate hook, when there is one, and reports the
+// errors it prints when it exits with a non-zero code on the input keys they
+// name.
+func (d *customCrudDataSource) validateInput(ctx context.Context, data *customCrudDataSourceModel, payload utils.ExecutionPayload, diagnostics *diag.Diagnostics) {
+	crud, err := utils.GetCrudCommands(data, utils.TargetOS(d.config.Executor))
+	if err != nil || !utils.HookDefined(crud.Validate, crud.Argv, utils.Validate) {
+		return
+	}
+	payload.Phase = utils.PhasePlan
+	result, ok := utils.RunCrudScript(ctx, d.configFor(ctx, data), data, payload, diagnostics, utils.CrudValidate)
+	if ok || result == nil || diagnostics.HasError() {
+		return
+	}
+	for _, e := range utils.ValidationErrors(result.Stdout, result.Stderr) {
+		diagnostics.AddAttributeError(inputPath(e.Path), e.Summary, result.Mask(e.Detail))
+	}
+}
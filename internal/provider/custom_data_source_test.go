@@ -1,10 +1,17 @@
 package provider
 
 import (
+	"context"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 )
 
@@ -51,3 +58,81 @@ func TestAccCustomCrudDataSource_File(t *testing.T) {
 		},
 	})
 }
+
+func TestAccCustomCrudDataSource_RawOutput(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+	data "customcrud" "raw" {
+	  hooks {
+	    read       = "printf 'not json'"
+	    raw_output = true
+	  }
+	}
+
+	output "raw" {
+	  value = data.customcrud.raw.output.raw
+	}
+	`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckOutput("raw", "not json"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccCustomCrudDataSource_Validate(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+	data "customcrud" "invalid" {
+	  hooks {
+	    read     = "false"
+	    validate = "test_validate/validate.sh"
+	  }
+	  input = {
+	    name = ""
+	  }
+	}
+	`,
+				ExpectError: regexp.MustCompile(`Missing Name`),
+			},
+		},
+	})
+}
+
+func TestUnitDataSourceValidate(t *testing.T) {
+	ctx := context.Background()
+	d := &customCrudDataSource{config: utils.CustomCRUDProviderConfigDefaults()}
+	hooksType := types.ObjectType{AttrTypes: map[string]attr.Type{
+		utils.Read:     types.StringType,
+		utils.Validate: types.StringType,
+	}}
+	data := &customCrudDataSourceModel{
+		Hooks: types.ListValueMust(hooksType, []attr.Value{types.ObjectValueMust(hooksType.AttrTypes, map[string]attr.Value{
+			utils.Read:     types.StringValue("test_passthrough/read.sh"),
+			utils.Validate: types.StringValue("test_validate/validate.sh"),
+		})}),
+	}
+
+	var diags diag.Diagnostics
+	d.validateInput(ctx, data, utils.ExecutionPayload{Input: map[string]interface{}{"name": "web", "size": 1}}, &diags)
+	if diags.HasError() {
+		t.Fatalf("Expected valid input to pass, got %v", diags)
+	}
+
+	d.validateInput(ctx, data, utils.ExecutionPayload{Input: map[string]interface{}{"name": "", "size": 0}}, &diags)
+	if diags.ErrorsCount() != 2 {
+		t.Fatalf("Expected an error per invalid key, got %v", diags)
+	}
+	if p := diags.Errors()[0].(diag.DiagnosticWithPath).Path(); !p.Equal(path.Root("input").AtName("name")) {
+		t.Errorf("Expected the error on input.name, got %v", p)
+	}
+}
@@ -7,13 +7,14 @@ import (
 
 	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
 	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
-	"mvdan.cc/sh/v3/shell"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
@@ -66,16 +67,70 @@ func (e *customCrudEphemeral) Schema(ctx context.Context, req ephemeral.SchemaRe
 				NestedObject: schema.NestedBlockObject{
 					Attributes: map[string]schema.Attribute{
 						utils.Open: schema.StringAttribute{
-							Required:    true,
-							Description: "Open command (space-separated command and arguments)",
+							Optional:    true,
+							Description: "Open command (space-separated command and arguments), required unless argv sets open",
+							Validators:  hookCommandValidators(utils.Open, true, false),
 						},
 						utils.Renew: schema.StringAttribute{
 							Optional:    true,
 							Description: "Renew command (space-separated command and arguments)",
+							Validators:  hookCommandValidators(utils.Renew, false, false),
 						},
 						utils.Close: schema.StringAttribute{
 							Optional:    true,
 							Description: "Close command (space-separated command and arguments)",
+							Validators:  hookCommandValidators(utils.Close, false, false),
+						},
+						utils.WorkingDirectory: schema.StringAttribute{
+							Optional:    true,
+							Description: "Working directory for hook execution, overrides the provider working_directory",
+						},
+						utils.OutputFormat: schema.StringAttribute{
+							Optional:    true,
+							Description: "Format of the hook output, either json (default) or yaml",
+							Validators: []validator.String{
+								stringvalidator.OneOf(utils.OutputFormatJSON, utils.OutputFormatYAML),
+							},
+						},
+						utils.BypassParallelism: schema.BoolAttribute{
+							Optional:    true,
+							Description: "Run these hooks without waiting on the provider parallelism limit, for hooks that are local and instant",
+						},
+						utils.RawOutput: schema.BoolAttribute{
+							Optional:    true,
+							Description: "Store hook stdout verbatim under output.raw instead of parsing it, for wrapping commands that don't print JSON",
+						},
+						utils.Runtimes: schema.MapAttribute{
+							ElementType: types.StringType,
+							Optional:    true,
+							Description: "Names of provider runtimes by hook name, e.g. { open = \"builder\", close = \"appliance\" }, to run those hooks with the executor of the runtime instead of the provider executor. Keys are open, renew and close",
+							Validators: []validator.Map{
+								mapvalidator.KeysAre(stringvalidator.OneOf(utils.Open, utils.Renew, utils.Close)),
+							},
+						},
+						utils.Platforms: schema.MapAttribute{
+							ElementType: types.MapType{ElemType: types.StringType},
+							Optional:    true,
+							Description: "Hook commands and other string attributes by operating system, e.g. { windows = { create = \"pwsh -File create.ps1\" } }, used in place of the attributes above when the hooks run on that system: the one the provider runs on, or linux for hooks run by the docker, ssh and http executors. Operating systems are named like Go's runtime.GOOS: linux, darwin, windows, ...",
+							Validators: []validator.Map{
+								mapvalidator.KeysAre(stringvalidator.OneOf(utils.PlatformNames...)),
+							},
+						},
+						utils.Argv: schema.MapAttribute{
+							ElementType: types.ListType{ElemType: types.StringType},
+							Optional:    true,
+							Description: "Hook commands as argument lists by hook name, e.g. { open = [\"/usr/bin/python3\", \"manage.py\", \"open lease\"] }, passed to exec verbatim instead of the command attribute of the hook, so arguments may contain spaces and quotes. A hook is given either by its command attribute or here",
+							Validators: []validator.Map{
+								mapvalidator.KeysAre(stringvalidator.OneOf(utils.Open, utils.Renew, utils.Close)),
+								mapvalidator.ValueListsAre(listvalidator.SizeAtLeast(1)),
+							},
+						},
+						utils.OnRenewFailure: schema.StringAttribute{
+							Optional:    true,
+							Description: "What a failed renew hook does: error (default) fails the run, warn reports a warning, and reopen runs the open hook again to mint a fresh lease",
+							Validators: []validator.String{
+								stringvalidator.OneOf(utils.OnRenewFailureWarn, utils.OnRenewFailureError, utils.OnRenewFailureReopen),
+							},
 						},
 					},
 				},
@@ -93,12 +148,15 @@ func (e *customCrudEphemeral) Configure(ctx context.Context, req ephemeral.Confi
 		return
 	}
 	if data, ok := req.ProviderData.(*CustomCRUDProvider); ok {
-		e.config = data.config
+		e.config = data.kindConfig(ephemeralKind)
 	}
 }
 
 func (e *customCrudEphemeral) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
-	utils.WithSemaphore(e.config.Semaphore, func() {
+	ctx, profile := e.config.Profiler.Start(ctx, ephemeralKind, utils.Open)
+	defer profile.Finish()
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, e.config, req.Config.GetAttribute), func(ctx context.Context) {
+		profile.Mark(utils.ProfileWait)
 		var data customCrudEphemeralModel
 		resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
 		if resp.Diagnostics.HasError() {
@@ -113,7 +171,12 @@ func (e *customCrudEphemeral) Open(ctx context.Context, req ephemeral.OpenReques
 			return
 		}
 
-		data.Output = utils.MapToDynamic(result.Result)
+		output, diags := utils.MapToDynamicWithTyping(result.Result, e.config.CollectionTyping)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.Output = output
 		resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
 		if resp.Diagnostics.HasError() {
 			return
@@ -151,8 +214,16 @@ func (e *customCrudEphemeral) Open(ctx context.Context, req ephemeral.OpenReques
 
 // privateStateHookData holds the parsed command and payload extracted from private state.
 type privateStateHookData struct {
-	cmd     []string
-	payload utils.ExecutionPayload
+	cmd               []string
+	payload           utils.ExecutionPayload
+	workingDirectory  string
+	outputFormat      string
+	bypassParallelism bool
+	rawOutput         bool
+	onRenewFailure    string
+	hooks             map[string]interface{}
+	// executor runs the hook, which its runtimes entry may select.
+	executor utils.Executor
 }
 
 // getHookFromPrivateState extracts a hook command and its associated payload from private state.
@@ -164,18 +235,20 @@ func (e *customCrudEphemeral) getHookFromPrivateState(ctx context.Context, priv
 		return nil, false
 	}
 
-	var hooks map[string]string
+	var hooks map[string]interface{}
 	if err := json.Unmarshal(hooksBytes, &hooks); err != nil {
 		diagnostics.AddError("Failed to unmarshal hooks from private state", err.Error())
 		return nil, false
 	}
 
-	hookCmd := hooks[hookName]
-	if hookCmd == "" {
+	executor, err := e.hookExecutor(hooks, hookName)
+	if err != nil {
+		diagnostics.AddError("Unknown Runtime", err.Error())
 		return nil, false
 	}
-
-	cmd, err := shell.Fields(hookCmd, nil)
+	goos := utils.TargetOS(executor)
+	attrs := platformHooks(hooks, goos)
+	cmd, err := hookArgs(attrs, hookName, goos)
 	if err != nil {
 		diagnostics.AddError(
 			fmt.Sprintf("Invalid %s Command", hookName),
@@ -201,10 +274,10 @@ func (e *customCrudEphemeral) getHookFromPrivateState(ctx context.Context, priv
 
 	var input, output interface{}
 	if len(inputBytes) > 0 {
-		_ = json.Unmarshal(inputBytes, &input)
+		_ = utils.DecodeJSON(inputBytes, &input)
 	}
 	if len(outputBytes) > 0 {
-		_ = json.Unmarshal(outputBytes, &output)
+		_ = utils.DecodeJSON(outputBytes, &output)
 	}
 
 	return &privateStateHookData{
@@ -213,39 +286,187 @@ func (e *customCrudEphemeral) getHookFromPrivateState(ctx context.Context, priv
 			Input:  input,
 			Output: output,
 		},
+		workingDirectory:  hookString(attrs, utils.WorkingDirectory),
+		outputFormat:      hookString(attrs, utils.OutputFormat),
+		bypassParallelism: hooks[utils.BypassParallelism] == true,
+		rawOutput:         hooks[utils.RawOutput] == true,
+		onRenewFailure:    hookString(attrs, utils.OnRenewFailure),
+		hooks:             hooks,
+		executor:          executor,
 	}, true
 }
 
-func (e *customCrudEphemeral) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
-	e.renew(ctx, req.Private, &resp.Diagnostics)
+// platformHooks returns the hooks block attributes saved in private state
+// with the string attributes its platforms entry for goos sets in place of
+// their defaults, like utils.PlatformAttributes.
+func platformHooks(hooks map[string]interface{}, goos string) map[string]interface{} {
+	platforms, _ := hooks[utils.Platforms].(map[string]interface{})
+	overrides, ok := platforms[goos].(map[string]interface{})
+	if !ok {
+		return hooks
+	}
+	result := make(map[string]interface{}, len(hooks))
+	for name, value := range hooks {
+		result[name] = value
+	}
+	for name, value := range overrides {
+		result[name] = value
+	}
+	return result
+}
+
+// hookString returns the string value of a hooks block attribute saved in private state.
+func hookString(hooks map[string]interface{}, name string) string {
+	value, _ := hooks[name].(string)
+	return value
 }
 
-func (e *customCrudEphemeral) renew(ctx context.Context, priv PrivateStateReader, diagnostics *diag.Diagnostics) {
-	utils.WithSemaphore(e.config.Semaphore, func() {
-		hook, ok := e.getHookFromPrivateState(ctx, priv, diagnostics, "renew")
+// hookArgs returns the program and arguments running a hook saved in private
+// state on goos, from its argv entry or its command.
+func hookArgs(hooks map[string]interface{}, name, goos string) ([]string, error) {
+	argv, _ := hooks[utils.Argv].(map[string]interface{})
+	args, ok := argv[name].([]interface{})
+	if !ok {
+		return utils.SplitCommandOn(hookString(hooks, name), goos)
+	}
+	fields := make([]string, 0, len(args))
+	for i, arg := range args {
+		s, ok := arg.(string)
 		if !ok {
-			return
+			return nil, fmt.Errorf("argument %d of the %s argv isn't a string", i, name)
 		}
+		fields = append(fields, s)
+	}
+	return fields, nil
+}
 
-		_, err := utils.Execute(ctx, e.config, hook.cmd, hook.payload)
-		if err != nil {
-			diagnostics.AddError("Renew Script Failed", err.Error())
-		}
+// hookExecutor returns the executor running a hook saved in private state:
+// the executor of the provider runtime named by its runtimes entry, like
+// RunCrudScript picks for open, or else the provider executor.
+func (e *customCrudEphemeral) hookExecutor(hooks map[string]interface{}, name string) (utils.Executor, error) {
+	runtimes, _ := hooks[utils.Runtimes].(map[string]interface{})
+	runtime, ok := runtimes[name].(string)
+	if !ok {
+		return e.config.Executor, nil
+	}
+	executor, ok := e.config.Runtimes[runtime]
+	if !ok {
+		return nil, fmt.Errorf("The %s hook runs in the runtime %q, which the provider runtimes don't define", name, runtime)
+	}
+	return executor, nil
+}
+
+// hookConfig returns the provider config with any hooks block overrides applied.
+func (e *customCrudEphemeral) hookConfig(hook *privateStateHookData) utils.CustomCRUDProviderConfig {
+	config := e.config
+	config.Executor = hook.executor
+	if hook.workingDirectory != "" {
+		config.WorkingDirectory = hook.workingDirectory
+	}
+	if hook.outputFormat != "" {
+		config.OutputFormat = hook.outputFormat
+	}
+	if hook.rawOutput {
+		config.RawOutput = true
+	}
+	return config
+}
+
+// hookSemaphore returns the semaphore to hold while running hook, if any.
+func (e *customCrudEphemeral) hookSemaphore(hook *privateStateHookData) chan struct{} {
+	if hook.bypassParallelism {
+		return nil
+	}
+	return e.config.Semaphore
+}
+
+func (e *customCrudEphemeral) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	ctx, profile := e.config.Profiler.Start(ctx, ephemeralKind, utils.Renew)
+	defer profile.Finish()
+	e.renew(ctx, req.Private, resp.Private, &resp.Diagnostics)
+}
+
+func (e *customCrudEphemeral) renew(ctx context.Context, priv PrivateStateReader, privOut privateStateWriter, diagnostics *diag.Diagnostics) {
+	hook, ok := e.getHookFromPrivateState(ctx, priv, diagnostics, "renew")
+	if !ok {
+		return
+	}
+
+	var err error
+	utils.WithSemaphore(ctx, e.hookSemaphore(hook), func(ctx context.Context) {
+		_, err = utils.Execute(ctx, e.hookConfig(hook), hook.cmd, hook.payload)
 	})
+	if err == nil {
+		return
+	}
+
+	switch hook.onRenewFailure {
+	case utils.OnRenewFailureWarn:
+		diagnostics.AddWarning("Renew Script Failed", err.Error())
+	case utils.OnRenewFailureReopen:
+		tflog.Warn(ctx, "Renew script failed, reopening", map[string]interface{}{
+			"error": err.Error(),
+		})
+		e.reopen(ctx, hook, privOut, diagnostics)
+	default:
+		diagnostics.AddError("Renew Script Failed", err.Error())
+	}
+}
+
+// reopen runs the open hook again after a failed renew and saves its output to
+// private state, so that later renew and close hooks see the fresh lease.
+func (e *customCrudEphemeral) reopen(ctx context.Context, hook *privateStateHookData, privOut privateStateWriter, diagnostics *diag.Diagnostics) {
+	executor, err := e.hookExecutor(hook.hooks, utils.Open)
+	if err != nil {
+		diagnostics.AddError("Unknown Runtime", err.Error())
+		return
+	}
+	goos := utils.TargetOS(executor)
+	cmd, err := hookArgs(platformHooks(hook.hooks, goos), utils.Open, goos)
+	if err == nil && len(cmd) == 0 {
+		err = fmt.Errorf("open command is empty")
+	}
+	if err != nil {
+		diagnostics.AddError("Invalid open Command", fmt.Sprintf("failed to parse open command: %v", err))
+		return
+	}
+
+	config := e.hookConfig(hook)
+	config.Executor = executor
+	var result *utils.ExecutionResult
+	utils.WithSemaphore(ctx, e.hookSemaphore(hook), func(ctx context.Context) {
+		result, err = utils.Execute(ctx, config, cmd, utils.ExecutionPayload{Input: hook.payload.Input})
+	})
+	if err != nil {
+		diagnostics.AddError("Reopen Script Failed", fmt.Sprintf("renew failed and the open hook could not mint a new lease: %v", err))
+		return
+	}
+
+	outputBytes, err := json.Marshal(result.Result)
+	if err != nil {
+		diagnostics.AddError("Failed to save output to private state", err.Error())
+		return
+	}
+	diagnostics.Append(privOut.SetKey(ctx, "output", outputBytes)...)
+	diagnostics.AddWarning("Ephemeral Resource Reopened",
+		"The renew hook failed, so the open hook was run again. Renew and close hooks will use the new output, "+
+			"but Terraform cannot update the result already handed to the configuration.")
 }
 
 func (e *customCrudEphemeral) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	ctx, profile := e.config.Profiler.Start(ctx, ephemeralKind, utils.Close)
+	defer profile.Finish()
 	e.close(ctx, req.Private, &resp.Diagnostics)
 }
 
 func (e *customCrudEphemeral) close(ctx context.Context, priv PrivateStateReader, diagnostics *diag.Diagnostics) {
-	utils.WithSemaphore(e.config.Semaphore, func() {
-		hook, ok := e.getHookFromPrivateState(ctx, priv, diagnostics, "close")
-		if !ok {
-			return
-		}
+	hook, ok := e.getHookFromPrivateState(ctx, priv, diagnostics, "close")
+	if !ok {
+		return
+	}
 
-		_, err := utils.Execute(ctx, e.config, hook.cmd, hook.payload)
+	utils.WithSemaphore(ctx, e.hookSemaphore(hook), func(ctx context.Context) {
+		_, err := utils.Execute(ctx, e.hookConfig(hook), hook.cmd, hook.payload)
 		if err != nil {
 			tflog.Warn(ctx, "Close script failed", map[string]interface{}{
 				"error": err.Error(),
@@ -2,6 +2,9 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"runtime"
 	"testing"
 
 	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
@@ -18,6 +21,18 @@ func (m *mockPrivate) GetKey(ctx context.Context, key string) ([]byte, diag.Diag
 	return m.data[key], nil
 }
 
+func (m *mockPrivate) SetKey(ctx context.Context, key string, value []byte) diag.Diagnostics {
+	if m.data == nil {
+		m.data = map[string][]byte{}
+	}
+	if len(value) == 0 {
+		delete(m.data, key)
+		return nil
+	}
+	m.data[key] = value
+	return nil
+}
+
 func TestUnitCustomCrudEphemeral_Metadata(t *testing.T) {
 	e := NewCustomCrudEphemeral()
 	req := ephemeral.MetadataRequest{}
@@ -80,7 +95,7 @@ func TestUnitCustomCrudEphemeral_Renew_NoHook(t *testing.T) {
 	}
 
 	diags := &diag.Diagnostics{}
-	e.renew(ctx, private, diags)
+	e.renew(ctx, private, private, diags)
 
 	if diags.HasError() {
 		t.Errorf("Unexpected error in Renew without hook: %v", diags)
@@ -120,7 +135,7 @@ func TestUnitCustomCrudEphemeral_Renew_Success(t *testing.T) {
 	}
 
 	diags := &diag.Diagnostics{}
-	e.renew(ctx, private, diags)
+	e.renew(ctx, private, private, diags)
 
 	if diags.HasError() {
 		t.Errorf("Unexpected error in Renew: %v", diags)
@@ -148,6 +163,178 @@ func TestUnitCustomCrudEphemeral_Close_Success(t *testing.T) {
 	}
 }
 
+func TestUnitCustomCrudEphemeral_Close_Argv(t *testing.T) {
+	e := &customCrudEphemeral{}
+	ctx := context.Background()
+
+	// The argument with a space only arrives whole when argv isn't split
+	private := &mockPrivate{
+		data: map[string][]byte{
+			"hooks": []byte(`{"open": "echo open", "argv": {"close": ["sh", "-c", "test \"$0\" = 'two words'", "two words"]}}`),
+		},
+	}
+
+	diags := &diag.Diagnostics{}
+	e.close(ctx, private, diags)
+
+	if diags.HasError() {
+		t.Errorf("Unexpected error in Close with argv: %v", diags)
+	}
+}
+
+func TestUnitCustomCrudEphemeral_Renew_WorkingDirectory(t *testing.T) {
+	e := &customCrudEphemeral{}
+	ctx := context.Background()
+
+	// The relative renew hook only resolves when run from test_ephemeral
+	private := &mockPrivate{
+		data: map[string][]byte{
+			"hooks": []byte(`{"open": "echo open", "renew": "./renew.sh", "working_directory": "test_ephemeral"}`),
+			"input": []byte(`{"foo": "bar"}`),
+		},
+	}
+
+	diags := &diag.Diagnostics{}
+	e.renew(ctx, private, private, diags)
+
+	if diags.HasError() {
+		t.Errorf("Unexpected error in Renew with working_directory: %v", diags)
+	}
+}
+
+func TestUnitCustomCrudEphemeral_Renew_BypassParallelism(t *testing.T) {
+	// A full semaphore would block forever unless the hooks bypass it
+	e := &customCrudEphemeral{config: utils.CustomCRUDProviderConfig{Semaphore: make(chan struct{}, 1)}}
+	e.config.Semaphore <- struct{}{}
+	ctx := context.Background()
+
+	private := &mockPrivate{
+		data: map[string][]byte{
+			"hooks": []byte(`{"open": "echo open", "renew": "true", "bypass_parallelism": true}`),
+		},
+	}
+
+	diags := &diag.Diagnostics{}
+	e.renew(ctx, private, private, diags)
+
+	if diags.HasError() {
+		t.Errorf("Unexpected error in Renew with bypass_parallelism: %v", diags)
+	}
+}
+
+func TestUnitCustomCrudEphemeral_Renew_OnRenewFailure(t *testing.T) {
+	e := &customCrudEphemeral{}
+	ctx := context.Background()
+
+	for policy, wantError := range map[string]bool{"": true, "error": true, "warn": false} {
+		private := &mockPrivate{
+			data: map[string][]byte{
+				"hooks": []byte(`{"open": "echo open", "renew": "false", "on_renew_failure": "` + policy + `"}`),
+			},
+		}
+
+		diags := &diag.Diagnostics{}
+		e.renew(ctx, private, private, diags)
+
+		if diags.HasError() != wantError {
+			t.Errorf("on_renew_failure %q: expected error %v, got %v", policy, wantError, diags)
+		}
+		if !wantError && diags.WarningsCount() != 1 {
+			t.Errorf("on_renew_failure %q: expected a warning, got %v", policy, diags)
+		}
+	}
+}
+
+func TestUnitCustomCrudEphemeral_Renew_Reopen(t *testing.T) {
+	e := &customCrudEphemeral{}
+	ctx := context.Background()
+
+	private := &mockPrivate{
+		data: map[string][]byte{
+			"hooks":  []byte(`{"open": "echo '{\"token\": \"fresh\"}'", "renew": "false", "on_renew_failure": "reopen"}`),
+			"input":  []byte(`{"foo": "bar"}`),
+			"output": []byte(`{"token": "stale"}`),
+		},
+	}
+
+	diags := &diag.Diagnostics{}
+	e.renew(ctx, private, private, diags)
+
+	if diags.HasError() {
+		t.Fatalf("Unexpected error in Renew with reopen: %v", diags)
+	}
+	if got := string(private.data["output"]); got != `{"token":"fresh"}` {
+		t.Errorf("expected the reopened output in private state, got %s", got)
+	}
+
+	// A failing open hook fails the renew
+	private.data["hooks"] = []byte(`{"open": "false", "renew": "false", "on_renew_failure": "reopen"}`)
+	diags = &diag.Diagnostics{}
+	e.renew(ctx, private, private, diags)
+
+	if !diags.HasError() {
+		t.Error("Expected error in Renew when the reopen fails")
+	}
+}
+
+func TestUnitCustomCrudEphemeral_Runtimes(t *testing.T) {
+	var ran []string
+	runtime := func(name string) utils.Executor {
+		return &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			ran = append(ran, name+" "+req.Command[0])
+			if req.Command[0] == "renew" {
+				return &utils.ExecResponse{ExitCode: 1}, fmt.Errorf("exit status 1")
+			}
+			return &utils.ExecResponse{Stdout: []byte(`{}`)}, nil
+		}}
+	}
+	e := &customCrudEphemeral{config: utils.CustomCRUDProviderConfig{
+		Executor: runtime("provider"),
+		Runtimes: map[string]utils.Executor{"builder": runtime("builder"), "vault": runtime("vault")},
+	}}
+	ctx := context.Background()
+
+	private := &mockPrivate{
+		data: map[string][]byte{
+			"hooks": []byte(`{"open": "open", "renew": "renew", "close": "close", "on_renew_failure": "reopen", "runtimes": {"open": "builder", "renew": "vault"}}`),
+		},
+	}
+	diags := &diag.Diagnostics{}
+	e.renew(ctx, private, private, diags)
+	e.close(ctx, private, diags)
+	if diags.HasError() {
+		t.Fatalf("Unexpected error: %v", diags)
+	}
+	expected := []string{"vault renew", "builder open", "provider close"}
+	if !reflect.DeepEqual(ran, expected) {
+		t.Errorf("Expected the hooks to run in their runtimes %v, got %v", expected, ran)
+	}
+
+	private.data["hooks"] = []byte(`{"open": "open", "close": "close", "runtimes": {"close": "missing"}}`)
+	diags = &diag.Diagnostics{}
+	e.close(ctx, private, diags)
+	if !diags.HasError() {
+		t.Error("Expected an unknown runtime to fail the close")
+	}
+}
+
+func TestUnitCustomCrudEphemeral_Platforms(t *testing.T) {
+	e := &customCrudEphemeral{}
+	ctx := context.Background()
+
+	// The renew hook of the system running it replaces the failing default
+	private := &mockPrivate{
+		data: map[string][]byte{
+			"hooks": []byte(`{"open": "echo open", "renew": "false", "platforms": {"` + runtime.GOOS + `": {"renew": "true"}}}`),
+		},
+	}
+	diags := &diag.Diagnostics{}
+	e.renew(ctx, private, private, diags)
+	if diags.HasError() {
+		t.Errorf("Expected the %s renew hook to run, got %v", runtime.GOOS, diags)
+	}
+}
+
 func TestUnitCustomCrudEphemeral_Renew_UnmarshalError(t *testing.T) {
 	e := &customCrudEphemeral{}
 	ctx := context.Background()
@@ -160,7 +347,7 @@ func TestUnitCustomCrudEphemeral_Renew_UnmarshalError(t *testing.T) {
 	}
 
 	diags := &diag.Diagnostics{}
-	e.renew(ctx, private, diags)
+	e.renew(ctx, private, private, diags)
 
 	if !diags.HasError() {
 		t.Error("Expected error in Renew with invalid hooks JSON")
@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/list"
+	"github.com/hashicorp/terraform-plugin-framework/list/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ list.ListResource = &customCrudListResource{}
+var _ list.ListResourceWithConfigure = &customCrudListResource{}
+
+type customCrudListResourceModel struct {
+	Hooks types.List    `tfsdk:"hooks"`
+	Input types.Dynamic `tfsdk:"input"`
+}
+
+func (m *customCrudListResourceModel) GetHooks() types.List {
+	return m.Hooks
+}
+
+type customCrudListResource struct {
+	config utils.CustomCRUDProviderConfig
+}
+
+func NewCustomCrudListResource() list.ListResource {
+	return &customCrudListResource{}
+}
+
+func (l *customCrudListResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = "customcrud"
+}
+
+func (l *customCrudListResource) ListResourceConfigSchema(ctx context.Context, req list.ListResourceSchemaRequest, resp *list.ListResourceSchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"input": schema.DynamicAttribute{
+				Optional:    true,
+				Description: "Input data passed to the import_list hook",
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"hooks": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						utils.ImportList: schema.StringAttribute{
+							Required:    true,
+							Description: "Command returning a JSON array of {id, input, output} objects for every existing resource",
+						},
+						utils.Create: schema.StringAttribute{
+							Required:    true,
+							Description: "Create command of the listed resources",
+						},
+						utils.Read: schema.StringAttribute{
+							Required:    true,
+							Description: "Read command of the listed resources",
+						},
+						utils.Update: schema.StringAttribute{
+							Optional:    true,
+							Description: "Update command of the listed resources",
+						},
+						utils.Delete: schema.StringAttribute{
+							Required:    true,
+							Description: "Delete command of the listed resources",
+						},
+						utils.WorkingDirectory: schema.StringAttribute{
+							Optional:    true,
+							Description: "Working directory for hook execution, overrides the provider working_directory",
+						},
+					},
+				},
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
+			},
+		},
+	}
+}
+
+func (l *customCrudListResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		l.config = utils.CustomCRUDProviderConfigDefaults()
+		return
+	}
+	if data, ok := req.ProviderData.(*CustomCRUDProvider); ok {
+		l.config = data.kindConfig(resourceKind)
+	}
+}
+
+// List runs the import_list hook once and streams an importable identity,
+// and optionally the resource state, for every object it returns.
+func (l *customCrudListResource) List(ctx context.Context, req list.ListRequest, stream *list.ListResultsStream) {
+	var diags diag.Diagnostics
+	var data customCrudListResourceModel
+	diags.Append(req.Config.Get(ctx, &data)...)
+	if diags.HasError() {
+		stream.Results = list.ListResultsStreamDiagnostics(diags)
+		return
+	}
+
+	crud, err := utils.GetCrudCommands(&data, utils.TargetOS(l.config.Executor))
+	if err != nil {
+		diags.AddError("Error getting CRUD commands", err.Error())
+		stream.Results = list.ListResultsStreamDiagnostics(diags)
+		return
+	}
+	cmd, err := utils.SplitCommandOn(crud.ImportList.ValueString(), utils.TargetOS(l.config.Executor))
+	if err != nil || len(cmd) == 0 {
+		diags.AddError("Invalid import_list Command", fmt.Sprintf("failed to parse import_list command: %v", err))
+		stream.Results = list.ListResultsStreamDiagnostics(diags)
+		return
+	}
+
+	config := l.config
+	if dir := crud.WorkingDirectory.ValueString(); dir != "" {
+		config.WorkingDirectory = dir
+	}
+	payload := utils.ExecutionPayload{
+		Input: utils.MergeDefaultInputs(l.config, utils.AttrValueToInterface(data.Input.UnderlyingValue())),
+	}
+	var items []utils.ListItem
+	var result *utils.ExecutionResult
+	utils.WithSemaphore(ctx, utils.SemaphoreFor(l.config, data.Hooks), func(ctx context.Context) {
+		items, result, err = utils.ExecuteList(ctx, config, cmd, payload)
+	})
+	if err != nil {
+		detail := err.Error()
+		if result != nil {
+			detail = fmt.Sprintf("%v\nExit Code: %d\nStdout: %s\nStderr: %s\nInput Payload: %s", err, result.ExitCode, result.Mask(result.Stdout), result.Mask(result.Stderr), result.Mask(result.Payload))
+		}
+		diags.AddError("Import List Script Failed", detail)
+		stream.Results = list.ListResultsStreamDiagnostics(diags)
+		return
+	}
+
+	hooks := map[string]string{}
+	for name, value := range map[string]types.String{
+		utils.Create:           crud.Create,
+		utils.Read:             crud.Read,
+		utils.Update:           crud.Update,
+		utils.Delete:           crud.Delete,
+		utils.WorkingDirectory: crud.WorkingDirectory,
+	} {
+		if !value.IsNull() && !value.IsUnknown() {
+			hooks[name] = value.ValueString()
+		}
+	}
+	hooksList, diags := importHooks(ctx, req.ResourceSchema, hooks)
+	if diags.HasError() {
+		stream.Results = list.ListResultsStreamDiagnostics(diags)
+		return
+	}
+
+	stream.Results = func(push func(list.ListResult) bool) {
+		for i, item := range items {
+			if req.Limit > 0 && int64(i) >= req.Limit {
+				return
+			}
+			resourceData := customCrudResourceModel{
+				Id:              types.StringValue(item.Id),
+				Hooks:           hooksList,
+				Input:           types.DynamicNull(),
+				Output:          types.DynamicNull(),
+				OutputSensitive: types.DynamicNull(),
+				SortOutputLists: types.ListNull(types.StringType),
+
+				StableOutputKeys:       types.ListNull(types.StringType),
+				ExpectedOutputKeys:     types.ListNull(types.StringType),
+				SharedReadKey:          types.StringNull(),
+				IgnoreOutputKeys:       types.ListNull(types.StringType),
+				ComputedInputKeys:      types.ListNull(types.StringType),
+				OutputSchema:           types.StringNull(),
+				InputSchema:            types.StringNull(),
+				OutputValidationSchema: types.StringNull(),
+				ReplaceOnChange:        types.ListNull(types.StringType),
+				Triggers:               types.MapNull(types.StringType),
+				MergeStrategy:          types.StringNull(),
+				ReadMode:               types.StringNull(),
+				PostCreateReadDelay:    types.Int64Null(),
+				PostCreateReadRetries:  types.Int64Null(),
+				SkipOperations:         types.ListNull(types.StringType),
+				SkipAction:             types.StringNull(),
+				HooksRef:               types.StringNull(),
+				UpdateStrategy:         types.StringNull(),
+				TransactionGroup:       types.StringNull(),
+				HooksDir:               types.StringNull(),
+				HooksFile:              types.StringNull(),
+				Hook:                   types.ObjectNull(hookAttributeType().AttrTypes),
+				ScriptHash:             types.StringNull(),
+				HashHookFiles:          types.BoolNull(),
+				ScriptChange:           types.StringNull(),
+				Wait:                   types.ListNull(waitBlockType()),
+			}
+
+			listResult := req.NewListResult(ctx)
+			if item.Input != nil {
+				input, diags := utils.MapToDynamic(item.Input)
+				listResult.Diagnostics.Append(diags...)
+				resourceData.Input = input
+			}
+			if item.Output != nil {
+				listResult.Diagnostics.Append(resourceData.storeOutput(item.Output, item.Sensitive, l.config.CollectionTyping)...)
+			}
+			if listResult.Diagnostics.HasError() {
+				push(listResult)
+				return
+			}
+			listResult.DisplayName = item.Id
+			listResult.Diagnostics.Append(listResult.Identity.Set(ctx, identityFor(&resourceData))...)
+			if req.IncludeResource {
+				listResult.Diagnostics.Append(listResult.Resource.Set(ctx, &resourceData)...)
+			}
+			if !push(listResult) {
+				return
+			}
+		}
+	}
+}
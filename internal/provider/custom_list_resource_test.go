@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/list"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// listRequest builds a list request for the customcrud list resource from the given config.
+func listRequest(t *testing.T, ctx context.Context, l *customCrudListResource, r *customCrudResource, data customCrudListResourceModel, includeResource bool) list.ListRequest {
+	t.Helper()
+	listSchemaResp := &list.ListResourceSchemaResponse{}
+	l.ListResourceConfigSchema(ctx, list.ListResourceSchemaRequest{}, listSchemaResp)
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	identityResp := &fwresource.IdentitySchemaResponse{}
+	r.IdentitySchema(ctx, fwresource.IdentitySchemaRequest{}, identityResp)
+
+	// Build the raw config through a state, which shares the same encoding
+	config := tfsdk.State{
+		Schema: listSchemaResp.Schema,
+		Raw:    tftypes.NewValue(listSchemaResp.Schema.Type().TerraformType(ctx), nil),
+	}
+	if diags := config.Set(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to build list config: %v", diags)
+	}
+	return list.ListRequest{
+		Config:                 tfsdk.Config{Schema: listSchemaResp.Schema, Raw: config.Raw},
+		IncludeResource:        includeResource,
+		ResourceSchema:         schemaResp.Schema,
+		ResourceIdentitySchema: identityResp.IdentitySchema,
+	}
+}
+
+func listHooks(t *testing.T, importList string) types.List {
+	t.Helper()
+	attrTypes := map[string]attr.Type{
+		utils.ImportList:       types.StringType,
+		utils.Create:           types.StringType,
+		utils.Read:             types.StringType,
+		utils.Update:           types.StringType,
+		utils.Delete:           types.StringType,
+		utils.WorkingDirectory: types.StringType,
+	}
+	obj, diags := types.ObjectValue(attrTypes, map[string]attr.Value{
+		utils.ImportList:       types.StringValue(importList),
+		utils.Create:           types.StringValue("test_passthrough/create.sh"),
+		utils.Read:             types.StringValue("test_passthrough/read.sh"),
+		utils.Update:           types.StringNull(),
+		utils.Delete:           types.StringValue("test_passthrough/delete.sh"),
+		utils.WorkingDirectory: types.StringNull(),
+	})
+	if diags.HasError() {
+		t.Fatalf("Failed to build hooks: %v", diags)
+	}
+	hooks, diags := types.ListValue(types.ObjectType{AttrTypes: attrTypes}, []attr.Value{obj})
+	if diags.HasError() {
+		t.Fatalf("Failed to build hooks: %v", diags)
+	}
+	return hooks
+}
+
+func TestUnitListResource_ImportList(t *testing.T) {
+	ctx := context.Background()
+	l := &customCrudListResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+
+	req := listRequest(t, ctx, l, r, customCrudListResourceModel{
+		Hooks: listHooks(t, "test_import_list/list.sh"),
+		Input: toDynamic(t, map[string]interface{}{"fleet": "web"}),
+	}, true)
+	stream := &list.ListResultsStream{}
+	l.List(ctx, req, stream)
+
+	var results []list.ListResult
+	for result := range stream.Results {
+		if result.Diagnostics.HasError() {
+			t.Fatalf("Unexpected diagnostics: %v", result.Diagnostics)
+		}
+		results = append(results, result)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+
+	var identity customCrudIdentityModel
+	if diags := results[1].Identity.Get(ctx, &identity); diags.HasError() {
+		t.Fatalf("Failed to read identity: %v", diags)
+	}
+	if identity.Id.ValueString() != "web-1" || identity.Read.ValueString() != "test_passthrough/read.sh" {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+	if results[1].DisplayName != "web-1" {
+		t.Errorf("Expected display name web-1, got %s", results[1].DisplayName)
+	}
+
+	var data customCrudResourceModel
+	if diags := results[1].Resource.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to read resource: %v", diags)
+	}
+	input, ok := utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if !ok || input["name"] != "web-1" {
+		t.Errorf("Expected listed input to be stored, got %v", data.Input)
+	}
+	crud, err := getCrudCommands(&data, runtime.GOOS)
+	if err != nil || crud.Delete.ValueString() != "test_passthrough/delete.sh" {
+		t.Errorf("Expected hooks to be copied from the list config, got %v (%v)", crud, err)
+	}
+
+	// The identity alone is enough to import the resource
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	importResp := &fwresource.ImportStateResponse{
+		State: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		},
+		Identity: &tfsdk.ResourceIdentity{Schema: results[1].Identity.Schema, Raw: results[1].Identity.Raw.Copy()},
+	}
+	r.ImportState(ctx, fwresource.ImportStateRequest{Identity: results[1].Identity}, importResp)
+	if importResp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected import diagnostics: %v", importResp.Diagnostics)
+	}
+	var imported customCrudResourceModel
+	if diags := importResp.State.Get(ctx, &imported); diags.HasError() {
+		t.Fatalf("Failed to read imported state: %v", diags)
+	}
+	if imported.Id.ValueString() != "web-1" {
+		t.Errorf("Expected imported id web-1, got %v", imported.Id)
+	}
+}
+
+func TestUnitListResource_Limit(t *testing.T) {
+	ctx := context.Background()
+	l := &customCrudListResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+
+	req := listRequest(t, ctx, l, r, customCrudListResourceModel{
+		Hooks: listHooks(t, "test_import_list/list.sh"),
+		Input: toDynamic(t, map[string]interface{}{"fleet": "db"}),
+	}, false)
+	req.Limit = 2
+	stream := &list.ListResultsStream{}
+	l.List(ctx, req, stream)
+
+	count := 0
+	for result := range stream.Results {
+		if result.Diagnostics.HasError() {
+			t.Fatalf("Unexpected diagnostics: %v", result.Diagnostics)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected the limit to cap results at 2, got %d", count)
+	}
+}
+
+func TestUnitListResource_MissingId(t *testing.T) {
+	ctx := context.Background()
+	l := &customCrudListResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+
+	req := listRequest(t, ctx, l, r, customCrudListResourceModel{
+		Hooks: listHooks(t, `echo '[{"input": {}}]'`),
+		Input: types.DynamicNull(),
+	}, false)
+	stream := &list.ListResultsStream{}
+	l.List(ctx, req, stream)
+
+	for result := range stream.Results {
+		if !result.Diagnostics.HasError() {
+			t.Fatal("Expected an error for an item without id")
+		}
+		return
+	}
+	t.Fatal("Expected a diagnostics result")
+}
@@ -4,23 +4,39 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
 	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/identityschema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
@@ -28,25 +44,322 @@ var _ resource.Resource = &customCrudResource{}
 var _ resource.ResourceWithImportState = &customCrudResource{}
 var _ resource.ResourceWithModifyPlan = &customCrudResource{}
 var _ resource.ResourceWithConfigure = &customCrudResource{}
+var _ resource.ResourceWithIdentity = &customCrudResource{}
+var _ resource.ResourceWithValidateConfig = &customCrudResource{}
 
 // CustomCrudResource implementation.
 type customCrudResourceModel struct {
 	Id      types.String  `tfsdk:"id"`
 	Hooks   types.List    `tfsdk:"hooks"`
 	Input   types.Dynamic `tfsdk:"input"`
-	InputWO types.String  `tfsdk:"input_wo"`
+	InputWO types.Dynamic `tfsdk:"input_wo"`
 	Output  types.Dynamic `tfsdk:"output"`
+
+	SensitiveOutput types.Bool    `tfsdk:"sensitive_output"`
+	OutputSensitive types.Dynamic `tfsdk:"output_sensitive"`
+
+	SortOutputLists        types.List   `tfsdk:"sort_output_lists"`
+	StableOutputKeys       types.List   `tfsdk:"stable_output_keys"`
+	ExpectedOutputKeys     types.List   `tfsdk:"expected_output_keys"`
+	SharedReadKey          types.String `tfsdk:"shared_read_key"`
+	IgnoreOutputKeys       types.List   `tfsdk:"ignore_output_keys"`
+	ComputedInputKeys      types.List   `tfsdk:"computed_input_keys"`
+	OutputSchema           types.String `tfsdk:"output_schema"`
+	InputSchema            types.String `tfsdk:"input_schema"`
+	OutputValidationSchema types.String `tfsdk:"output_validation_schema"`
+	ReplaceOnChange        types.List   `tfsdk:"replace_on_change"`
+	Triggers               types.Map    `tfsdk:"triggers"`
+	MergeStrategy          types.String `tfsdk:"merge_strategy"`
+	ReadMode               types.String `tfsdk:"read_mode"`
+	PostCreateReadDelay    types.Int64  `tfsdk:"post_create_read_delay"`
+	PostCreateReadRetries  types.Int64  `tfsdk:"post_create_read_retries"`
+	SkipOperations         types.List   `tfsdk:"skip_operations"`
+	SkipAction             types.String `tfsdk:"skip_action"`
+	HooksRef               types.String `tfsdk:"hooks_ref"`
+	UpdateStrategy         types.String `tfsdk:"update_strategy"`
+	TransactionGroup       types.String `tfsdk:"transaction_group"`
+	HooksDir               types.String `tfsdk:"hooks_dir"`
+	HooksFile              types.String `tfsdk:"hooks_file"`
+	Hook                   types.Object `tfsdk:"hook"`
+	ScriptHash             types.String `tfsdk:"script_hash"`
+	HashHookFiles          types.Bool   `tfsdk:"hash_hook_files"`
+	ScriptChange           types.String `tfsdk:"script_change"`
+	Wait                   types.List   `tfsdk:"wait"`
+
+	// refHooks holds the provider hook set named by hooks_ref, or the hooks
+	// found in hooks_dir or hooks_file, which are never stored in state.
+	refHooks types.List
+}
+
+// hooksByReference reports whether the hooks are given by hooks_ref,
+// hooks_dir or hooks_file rather than a hooks block.
+func (m *customCrudResourceModel) hooksByReference() bool {
+	return !m.HooksRef.IsNull() || !m.HooksDir.IsNull() || !m.HooksFile.IsNull()
 }
 
+// GetHooks returns the hooks block, the hook attribute as a hooks block, or
+// the hooks resolved from hooks_ref, hooks_dir or hooks_file.
 func (m *customCrudResourceModel) GetHooks() types.List {
+	if m.hooksByReference() {
+		return m.refHooks
+	}
+	if !m.Hook.IsNull() {
+		return types.ListValueMust(m.Hook.Type(context.Background()), []attr.Value{m.Hook})
+	}
 	return m.Hooks
 }
 
+// withoutHooks reports whether the state has neither hooks nor a reference
+// to them, as when it was moved from a null_resource, which had nothing to
+// run.
+func (m *customCrudResourceModel) withoutHooks() bool {
+	return !m.hooksByReference() && m.Hook.IsNull() && len(m.Hooks.Elements()) == 0
+}
+
+// unknownConfig reports whether the hooks, their reference or input aren't
+// fully known yet, as when they're derived from resources still to be
+// created.
+func (m *customCrudResourceModel) unknownConfig(ctx context.Context) bool {
+	if m.HooksRef.IsUnknown() || m.HooksDir.IsUnknown() || m.HooksFile.IsUnknown() {
+		return true
+	}
+	for _, v := range []attr.Value{m.Hooks, m.Hook, m.Input} {
+		value, err := v.ToTerraformValue(ctx)
+		if err != nil || !value.IsFullyKnown() {
+			return true
+		}
+	}
+	return false
+}
+
+// storedOutput returns the hook output held in state, merging back the values
+// kept in output_sensitive, which holds everything when sensitive_output is set.
+func (m *customCrudResourceModel) storedOutput() interface{} {
+	output := utils.AttrValueToInterface(m.Output.UnderlyingValue())
+	sensitive, ok := utils.AttrValueToInterface(m.OutputSensitive.UnderlyingValue()).(map[string]interface{})
+	if !ok {
+		return output
+	}
+	if outputMap, ok := output.(map[string]interface{}); ok {
+		return utils.MergeSensitive(outputMap, sensitive)
+	}
+	return sensitive
+}
+
+// sensitivePaths returns the key paths of the output values kept in
+// output_sensitive, which are masked in logs and diagnostics.
+func (m *customCrudResourceModel) sensitivePaths() []string {
+	return utils.LeafKeyPaths(utils.AttrValueToInterface(m.OutputSensitive.UnderlyingValue()))
+}
+
+// storeOutput stores the hook output in output, moving the values at the
+// sensitive key paths to output_sensitive. With sensitive_output set the whole
+// output is stored in output_sensitive. Arrays are converted according to the
+// collection typing policy.
+func (m *customCrudResourceModel) storeOutput(output map[string]interface{}, sensitive []string, typing string) diag.Diagnostics {
+	if diags := checkOutputValidation(m.OutputValidationSchema, output); diags.HasError() {
+		return diags
+	}
+	outputSchema, diags := parseOutputSchema(m.OutputSchema)
+	if diags.HasError() {
+		return diags
+	}
+	if m.SensitiveOutput.ValueBool() {
+		value, d := convertOutput(outputSchema, output, false, typing)
+		diags.Append(d...)
+		m.OutputSensitive = value
+		m.Output = types.DynamicNull()
+		return diags
+	}
+	public, private := utils.SplitSensitive(output, sensitive)
+	if private == nil {
+		value, d := convertOutput(outputSchema, output, false, typing)
+		diags.Append(d...)
+		m.Output = value
+		m.OutputSensitive = types.DynamicNull()
+		return diags
+	}
+	if outputSchema != nil {
+		// Check the whole output, each part may lack required attributes
+		_, d := outputSchema.Convert(output)
+		diags.Append(d...)
+	}
+	if public == nil {
+		public = map[string]interface{}{}
+	}
+	value, d := convertOutput(outputSchema, public, true, typing)
+	diags.Append(d...)
+	m.Output = value
+	value, d = convertOutput(outputSchema, private, true, typing)
+	diags.Append(d...)
+	m.OutputSensitive = value
+	return diags
+}
+
+// parseOutputSchema parses the output_schema attribute, returning nil when
+// it isn't set.
+func parseOutputSchema(value types.String) (*utils.OutputSchema, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if value.IsNull() || value.IsUnknown() || value.ValueString() == "" {
+		return nil, diags
+	}
+	outputSchema, err := utils.ParseOutputSchema(value.ValueString())
+	if err != nil {
+		diags.AddAttributeError(path.Root("output_schema"), "Invalid Output Schema", err.Error())
+	}
+	return outputSchema, diags
+}
+
+// convertOutput converts hook output, or a part of it, to a dynamic value,
+// coerced to outputSchema when it is set and otherwise converting arrays
+// according to the collection typing policy.
+func convertOutput(outputSchema *utils.OutputSchema, output map[string]interface{}, part bool, typing string) (types.Dynamic, diag.Diagnostics) {
+	switch {
+	case outputSchema == nil:
+		return utils.MapToDynamicWithTyping(output, typing)
+	case part:
+		return outputSchema.ConvertPart(output)
+	default:
+		return outputSchema.Convert(output)
+	}
+}
+
+// outputSchemaValidator checks that a string is a valid output_schema.
+type outputSchemaValidator struct{}
+
+func (v outputSchemaValidator) Description(ctx context.Context) string {
+	return "value must be an object or map type expression"
+}
+
+func (v outputSchemaValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v outputSchemaValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := utils.ParseOutputSchema(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid Output Schema", err.Error())
+	}
+}
+
+// jsonSchemaValidator checks that a string is a valid JSON Schema document,
+// reporting errors with the given summary.
+type jsonSchemaValidator struct {
+	summary string
+}
+
+func (v jsonSchemaValidator) Description(ctx context.Context) string {
+	return "value must be a supported JSON Schema document"
+}
+
+func (v jsonSchemaValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v jsonSchemaValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := utils.ParseJSONSchema(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, v.summary, err.Error())
+	}
+}
+
+// durationValidator checks that a string is a duration like "10s" or "5m".
+type durationValidator struct{}
+
+func (v durationValidator) Description(ctx context.Context) string {
+	return "value must be a duration such as 30s, 5m or 1h"
+}
+
+func (v durationValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v durationValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := utils.ParseDuration(req.ConfigValue, 0); err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid Duration", fmt.Sprintf("%s. Use a duration such as 30s, 5m or 1h.", err))
+	}
+}
+
+// Strategies for merging hook output back into input.
+const (
+	mergeStrategyShallow = "shallow"
+	mergeStrategyDeep    = "deep"
+)
+
+// Actions taken when a plan runs an operation listed in skip_operations.
+const (
+	skipActionError = "error"
+	skipActionWarn  = "warn"
+)
+
+// Modes of storing the read hook output on refresh.
+const (
+	readModeMerge   = "merge"
+	readModeReplace = "replace"
+)
+
+// Strategies for input changes when the hooks have no update hook.
+const (
+	updateStrategyReplace = "replace"
+	updateStrategyError   = "error"
+)
+
+// Actions taken when the script_hash of the hooks changes.
+const (
+	scriptChangeUpdate  = "update"
+	scriptChangeReplace = "replace"
+)
+
 type hooksBlockValue struct {
 	Create types.String `tfsdk:"create"`
 	Read   types.String `tfsdk:"read"`
 	Update types.String `tfsdk:"update"`
 	Delete types.String `tfsdk:"delete"`
+	Plan   types.String `tfsdk:"plan"`
+	Diff   types.String `tfsdk:"diff"`
+
+	Validate types.String `tfsdk:"validate"`
+	Import   types.String `tfsdk:"import"`
+	Upgrade  types.String `tfsdk:"upgrade"`
+	Exists   types.String `tfsdk:"exists"`
+	Status   types.String `tfsdk:"status"`
+	WaitFor  types.String `tfsdk:"wait_for"`
+	Verify   types.String `tfsdk:"verify"`
+
+	OnFailure types.String `tfsdk:"on_failure"`
+
+	RequiresReplace types.String `tfsdk:"requires_replace"`
+
+	WorkingDirectory types.String `tfsdk:"working_directory"`
+	OutputFormat     types.String `tfsdk:"output_format"`
+
+	BypassParallelism types.Bool `tfsdk:"bypass_parallelism"`
+	RawOutput         types.Bool `tfsdk:"raw_output"`
+	Templates         types.Bool `tfsdk:"templates"`
+	Runtimes          types.Map  `tfsdk:"runtimes"`
+	Platforms         types.Map  `tfsdk:"platforms"`
+	Argv              types.Map  `tfsdk:"argv"`
+
+	// Scripts holds the create_script, read_script, update_script and
+	// delete_script by hook name.
+	Scripts map[string]string `tfsdk:"-"`
+}
+
+// customCrudIdentityModel identifies a remote object together with the hooks
+// needed to manage it, so that it can be imported from identity alone.
+type customCrudIdentityModel struct {
+	Id     types.String `tfsdk:"id"`
+	Create types.String `tfsdk:"create"`
+	Read   types.String `tfsdk:"read"`
+	Update types.String `tfsdk:"update"`
+	Delete types.String `tfsdk:"delete"`
 }
 
 type customCrudResource struct {
@@ -59,10 +372,13 @@ func NewCustomCrudResource() resource.Resource {
 
 func (r *customCrudResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = "customcrud"
+	// Update hooks may return a new id, and the identity carries the hooks
+	resp.ResourceBehavior.MutableIdentity = true
 }
 
 func (r *customCrudResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
+		Version: resourceSchemaVersion,
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
 				Computed:    true,
@@ -75,36 +391,205 @@ func (r *customCrudResource) Schema(ctx context.Context, req resource.SchemaRequ
 				Optional:    true,
 				Description: "Input data for the resource",
 			},
-			"input_wo": schema.StringAttribute{
+			"input_wo": schema.DynamicAttribute{
 				Optional:    true,
 				Sensitive:   true,
 				WriteOnly:   true,
-				Description: "Write-only input data (JSON string) for the resource, merged with input",
+				Description: "Write-only input data for the resource, merged with input when running create and update hooks. Never stored in state or shown in plans. A JSON encoded string is also accepted",
 			},
 			"output": schema.DynamicAttribute{
 				Computed:    true,
 				Description: "Output data from the resource",
 			},
+			"sensitive_output": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Store the hook output in output_sensitive instead of output, so it is hidden in plans and CLI output. Use for scripts that return tokens or other secrets",
+			},
+			"output_sensitive": schema.DynamicAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "Output data from the resource when sensitive_output is set, otherwise the output values the hooks marked as sensitive with the __sensitive key",
+			},
+			"sort_output_lists": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Dot-separated output key paths (e.g. network.ips) whose lists of strings, numbers or booleans are sorted before being stored",
+			},
+			"stable_output_keys": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Top-level output keys that keep their prior value during plan instead of showing as known after apply, for identifiers that don't change on update. The update hook must return the same output keys and must not change the values of the listed keys",
+				Validators: []validator.List{
+					listvalidator.ConflictsWith(path.MatchRoot("expected_output_keys")),
+				},
+			},
+			"expected_output_keys": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Top-level output keys the update hook is expected to change. Only these keys show as known after apply during plan, every other key of the prior output keeps its value so that references to it stay known. The hook must return the keys of the prior output and the listed keys, and must not change the keys that aren't listed. The whole output of a create stays known after apply",
+			},
+			"ignore_output_keys": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Dot-separated output key paths (e.g. etag or metadata.last_seen_at) dropped from hook output before it is stored, so constantly changing server metadata doesn't show up as drift or sync into input",
+			},
+			"output_schema": schema.StringAttribute{
+				Optional:    true,
+				Description: "Type of the output as a Terraform type expression, such as object({name = string, ports = list(number)}), or as JSON, such as [\"object\", {\"name\": \"string\"}]. Hook output is coerced to it so that output types stay stable across runs instead of being inferred from the values",
+				Validators: []validator.String{
+					outputSchemaValidator{},
+				},
+			},
+			"input_schema": schema.StringAttribute{
+				Optional:    true,
+				Description: "JSON Schema document the input is validated against during plan, with an error for each violation on the input key it concerns. References ($ref) are limited to definitions within the document",
+				Validators: []validator.String{
+					jsonSchemaValidator{summary: "Invalid Input Schema"},
+				},
+			},
+			"output_validation_schema": schema.StringAttribute{
+				Optional:    true,
+				Description: "JSON Schema document every hook result is validated against before it is stored in state. A result that doesn't match fails the operation with an error naming the offending output key, so a misbehaving script can't corrupt state",
+				Validators: []validator.String{
+					jsonSchemaValidator{summary: "Invalid Output Validation Schema"},
+				},
+			},
+			"computed_input_keys": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Top-level input keys the backend may populate or normalize. When set, only these keys are synced from hook output into input on refresh, all other input keys keep their configured value. A plan that only differs from the prior input at these keys keeps the prior input, so their synced values don't show up as drift",
+			},
+			"shared_read_key": schema.StringAttribute{
+				Optional:    true,
+				Description: "Key identifying the backend object, shared with data sources reading the same object. Resources and data sources with the same key share a single read hook result per Terraform operation instead of each running their read hook",
+			},
+			"replace_on_change": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Dot-separated input key paths (e.g. name or network.region) whose changes force replacement, even when an update hook is set",
+			},
+			"triggers": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Arbitrary values, such as file hashes, whose changes force replacement. They aren't passed to the hooks",
+			},
+			"merge_strategy": schema.StringAttribute{
+				Optional:    true,
+				Description: "How hook output is merged back into input keys: shallow (default) replaces top-level input keys with the output values of the same keys, deep also merges nested objects key by key so only nested input keys are updated",
+				Validators: []validator.String{
+					stringvalidator.OneOf(mergeStrategyShallow, mergeStrategyDeep),
+				},
+			},
+			"read_mode": schema.StringAttribute{
+				Optional:    true,
+				Description: "How a refresh stores the read hook output: merge (default) syncs the input keys found in the output and keeps the rest, replace also drops the input keys the output no longer has so that removed attributes show up as drift",
+				Validators: []validator.String{
+					stringvalidator.OneOf(readModeMerge, readModeReplace),
+				},
+			},
+			"skip_operations": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+				Description: "Operations, update or delete, that Terraform must not run on the resource, e.g. during a migration freeze. A plan running one of them fails, or with skip_action set to warn gets a warning and the operation becomes a no-op",
+				Validators: []validator.List{
+					listvalidator.ValueStringsAre(stringvalidator.OneOf(utils.Update, utils.Delete)),
+				},
+			},
+			"skip_action": schema.StringAttribute{
+				Optional:    true,
+				Description: "What a plan running an operation listed in skip_operations does: error (default) fails the plan, warn plans the operation as a no-op with a warning. A skipped update keeps the prior output until update is no longer skipped, when it runs, and a skipped delete removes the resource from state without running the delete hook",
+				Validators: []validator.String{
+					stringvalidator.OneOf(skipActionError, skipActionWarn),
+				},
+			},
+			"hooks_ref": schema.StringAttribute{
+				Optional:    true,
+				Description: "Name of a provider hook_sets entry to run instead of a hooks block. Only the name is stored in state, so changing the commands of the hook set never shows up as a resource diff",
+			},
+			"hooks_dir": schema.StringAttribute{
+				Optional:    true,
+				Description: "Directory holding create.sh, read.sh, update.sh, delete.sh and upgrade.sh scripts to run instead of a hooks block, e.g. \"${path.module}/hooks\". create.sh is required. Only the directory is stored in state, and the scripts are looked up again by every operation",
+			},
+			"hooks_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "File defining the hooks to run instead of a hooks block, e.g. \"${path.module}/hooks.json\". Files ending in .json hold a JSON object, other files HCL attributes, mapping the string attributes of the hooks block to their values. create is required. Only the file name is stored in state, and the file is read again by every operation",
+			},
+			"hook": schema.SingleNestedAttribute{
+				Attributes:  hooksAttributes(),
+				Optional:    true,
+				Description: "Hooks to run given as an object, e.g. hook = { create = \"./create.sh\", ... }, with the attributes of a hooks block. An alternative to the hooks block for configurations generating the hooks, which then need no dynamic block",
+			},
+			"script_hash": schema.StringAttribute{
+				Computed:    true,
+				Description: "SHA-256 of the inline hook scripts, and with hash_hook_files of the files the hook commands run, so that changing hook logic shows up in the plan. Null when there is nothing to hash",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"hash_hook_files": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Include the content of the files named by the hook commands, e.g. ./create.sh or manage.py in python3 manage.py create, in script_hash",
+			},
+			"script_change": schema.StringAttribute{
+				Optional:    true,
+				Description: "What a change of script_hash does besides showing in the plan, which by default only updates the stored hash: update runs the update hook with the unchanged input, or replaces the resource when the hooks have no update hook, replace replaces the resource",
+				Validators: []validator.String{
+					stringvalidator.OneOf(scriptChangeUpdate, scriptChangeReplace),
+				},
+			},
+			"transaction_group": schema.StringAttribute{
+				Optional:    true,
+				Description: "Name of a group of resources created together. When a create of the group fails during an apply, the delete hooks of the members already created in that apply run, and the creates still to come fail without running, approximating all-or-nothing provisioning. The rolled back members are recreated by the next apply once their read hooks report them missing",
+			},
+			"update_strategy": schema.StringAttribute{
+				Optional:    true,
+				Description: "What an input change does when the hooks have no update hook: replace (default) replaces the resource with a warning naming the changed input keys, error fails the plan instead",
+				Validators: []validator.String{
+					stringvalidator.OneOf(updateStrategyReplace, updateStrategyError),
+				},
+			},
+			"post_create_read_delay": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Seconds after create before the first read may run, and between read retries, for backends that take a while to make new objects visible",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"post_create_read_retries": schema.Int64Attribute{
+				Optional:    true,
+				Description: "Number of times the first read after create is retried when it reports the resource as missing, instead of removing it from state",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
 		},
 		Blocks: map[string]schema.Block{
 			"hooks": schema.ListNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: hooksAttributes(),
+				},
+				Validators: []validator.List{
+					listvalidator.SizeAtMost(1),
+				},
+			},
+			"wait": schema.ListNestedBlock{
+				Description: "How long and how often the wait_for hook is polled after create and update, and the read hook after delete",
 				NestedObject: schema.NestedBlockObject{
 					Attributes: map[string]schema.Attribute{
-						utils.Create: schema.StringAttribute{
-							Required:    true,
-							Description: "Create command (space-separated command and arguments)",
-						},
-						utils.Read: schema.StringAttribute{
-							Required:    true,
-							Description: "Read command (space-separated command and arguments)",
+						"timeout": schema.StringAttribute{
+							Optional:    true,
+							Description: "Time after which an object the wait_for hook doesn't report ready fails, e.g. \"10m\". Defaults to 10m",
+							Validators:  []validator.String{durationValidator{}},
 						},
-						utils.Update: schema.StringAttribute{
+						"interval": schema.StringAttribute{
 							Optional:    true,
-							Description: "Update command (space-separated command and arguments)",
+							Description: "Time between polls of the wait_for hook, and of the read hook after delete, e.g. \"10s\". Defaults to 10s",
+							Validators:  []validator.String{durationValidator{}},
 						},
-						utils.Delete: schema.StringAttribute{
-							Required:    true,
-							Description: "Delete command (space-separated command and arguments)",
+						"delete_timeout": schema.StringAttribute{
+							Optional:    true,
+							Description: "When set, the read hook is polled after delete, every interval, until it exits with the provider missing_resource_exit_code, so that dependent deletes only start once the object is gone. The destroy fails when the object is still found after this time, e.g. \"5m\"",
+							Validators:  []validator.String{durationValidator{}},
 						},
 					},
 				},
@@ -116,164 +601,1790 @@ func (r *customCrudResource) Schema(ctx context.Context, req resource.SchemaRequ
 	}
 }
 
-// ModifyPlan implements resource.ResourceWithModifyPlan to force replacement
-// when update hook is not provided and input has changed.
-func (r *customCrudResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
-	// Only process during updates (not create or delete)
-	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
-		return
+// hookCommandValidators returns the validators of the command attribute of
+// hook, which is given either there, as an entry of argv or, when it's
+// scripted, as an inline script, and must be given when it's required. The
+// hooks standing in for it, such as apply for create, are alternatives too.
+func hookCommandValidators(hook string, required, scripted bool, substitutes ...string) []validator.String {
+	alternatives := []path.Expression{path.MatchRelative().AtParent().AtName(utils.Argv).AtMapKey(hook)}
+	if scripted {
+		alternatives = append(alternatives, path.MatchRelative().AtParent().AtName(hook+utils.ScriptSuffix))
 	}
-
-	var state, plan customCrudResourceModel
-	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
-	if resp.Diagnostics.HasError() {
-		return
+	for _, substitute := range substitutes {
+		alternatives = append(alternatives,
+			path.MatchRelative().AtParent().AtName(substitute),
+			path.MatchRelative().AtParent().AtName(utils.Argv).AtMapKey(substitute))
 	}
-
-	// Get CRUD commands from the plan
-	crud, err := getCrudCommands(&plan)
-	if err != nil {
-		// If we can't get CRUD commands, let the normal validation handle it
-		return
+	if required {
+		return []validator.String{stringvalidator.ExactlyOneOf(alternatives...)}
 	}
+	return []validator.String{stringvalidator.ConflictsWith(alternatives...)}
+}
 
-	// If update hook is not provided (null or empty), force replacement on any input change
-	if crud.Update.IsNull() || strings.TrimSpace(crud.Update.ValueString()) == "" {
-		// Check if input has changed
-		if !state.Input.Equal(plan.Input) {
-			tflog.Debug(ctx, "Update hook not provided and input changed, forcing replacement")
-			resp.RequiresReplace = append(resp.RequiresReplace, path.Root("input"))
-		}
+// hooksAttributes returns the attributes of the hooks block, which the hook
+// attribute shares.
+func hooksAttributes() map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		utils.Create: schema.StringAttribute{
+			Optional:    true,
+			Description: "Create command (space-separated command and arguments), required unless argv, create_script or apply sets it",
+			Validators:  hookCommandValidators(utils.Create, true, true, utils.Apply),
+		},
+		utils.Read: schema.StringAttribute{
+			Optional:    true,
+			Description: "Read command (space-separated command and arguments). Without a read hook the state is authoritative and refreshes keep it as is, e.g. for notifications or one-shot migrations with nothing to read back",
+			Validators:  hookCommandValidators(utils.Read, false, true),
+		},
+		utils.Update: schema.StringAttribute{
+			Optional:    true,
+			Description: "Update command (space-separated command and arguments)",
+			Validators:  hookCommandValidators(utils.Update, false, true, utils.Apply),
+		},
+		utils.Delete: schema.StringAttribute{
+			Optional:    true,
+			Description: "Delete command (space-separated command and arguments). Without a delete hook, or with delete = \"noop\", destroying the resource only removes it from state, e.g. for logically irreversible operations",
+			Validators:  hookCommandValidators(utils.Delete, false, true, utils.Destroy),
+		},
+		utils.Create + utils.ScriptSuffix: schema.StringAttribute{
+			Optional:    true,
+			Description: "Inline create script, e.g. a heredoc, written to a temporary executable file and run instead of a create command. Scripts starting with a shebang such as #!/bin/bash run with that interpreter, others with sh, or cmd on Windows",
+		},
+		utils.Read + utils.ScriptSuffix: schema.StringAttribute{
+			Optional:    true,
+			Description: "Inline read script, e.g. a heredoc, written to a temporary executable file and run instead of a read command. Scripts starting with a shebang such as #!/bin/bash run with that interpreter, others with sh, or cmd on Windows",
+		},
+		utils.Update + utils.ScriptSuffix: schema.StringAttribute{
+			Optional:    true,
+			Description: "Inline update script, e.g. a heredoc, written to a temporary executable file and run instead of a update command. Scripts starting with a shebang such as #!/bin/bash run with that interpreter, others with sh, or cmd on Windows",
+		},
+		utils.Delete + utils.ScriptSuffix: schema.StringAttribute{
+			Optional:    true,
+			Description: "Inline delete script, e.g. a heredoc, written to a temporary executable file and run instead of a delete command. Scripts starting with a shebang such as #!/bin/bash run with that interpreter, others with sh, or cmd on Windows",
+		},
+		utils.Plan: schema.StringAttribute{
+			Optional:    true,
+			Description: "Plan command run while planning a create or update, which receives the prior id and output with the proposed input and prints the planned output, or nothing to leave it known after apply. Values printed as \"__unknown__\" are known after apply. The planned output must match what the create or update hook returns",
+			Validators:  hookCommandValidators(utils.Plan, false, false),
+		},
+		utils.Diff: schema.StringAttribute{
+			Optional:    true,
+			Description: "Diff command run while planning a create or an input change, which receives the prior id and output with the proposed input and prints a human-readable description of the change. The description is shown as a plan warning",
+			Validators:  hookCommandValidators(utils.Diff, false, false),
+		},
+		utils.Validate: schema.StringAttribute{
+			Optional:    true,
+			Description: "Validate command run while planning a create or an input change, which receives the proposed input and exits with a non-zero code to reject it. The errors it prints as JSON, such as {\"path\": \"network.cidr\", \"detail\": \"...\"} or an object with an errors list, are reported on the input keys they name",
+			Validators:  hookCommandValidators(utils.Validate, false, false),
+		},
+		utils.Import: schema.StringAttribute{
+			Optional:    true,
+			Description: "Import command run instead of the read hook when importing, which receives the raw import id with the import input and output, resolves it, e.g. by looking the object up by name, and prints the id, input and output to seed state with",
+			Validators:  hookCommandValidators(utils.Import, false, false),
+		},
+		utils.Upgrade: schema.StringAttribute{
+			Optional:    true,
+			Description: "Upgrade command run when Terraform upgrades state written with an older schema version of the resource, which receives the stored id, input and output and prints the id, input and output to store instead. States written by older releases don't hold it, so it only runs from hooks_ref, hooks_dir or hooks_file",
+			Validators:  hookCommandValidators(utils.Upgrade, false, false),
+		},
+		utils.Exists: schema.StringAttribute{
+			Optional:    true,
+			Description: "Command run before create with the create payload to look for an existing object, for upserts against pre-existing infrastructure. When it prints an id, the object it describes is adopted into state like a create output instead of running create. It exits with the provider missing_resource_exit_code, or prints nothing, when there is no such object",
+			Validators:  hookCommandValidators(utils.Exists, false, false),
+		},
+		utils.Status: schema.StringAttribute{
+			Optional:    true,
+			Description: "Command polled when a create, update or delete hook prints an operation_id instead of waiting for a long-running operation. It receives the payload of that hook with operation.id, operation.hook and operation.attempt, and prints {\"status\": \"pending\"} while the operation runs, {\"status\": \"done\", ...} with the final output once it finished, or {\"status\": \"failed\", \"error\": \"...\"}",
+			Validators:  hookCommandValidators(utils.Status, false, false),
+		},
+		utils.StatusInterval: schema.StringAttribute{
+			Optional:    true,
+			Description: "Time between polls of the status hook, e.g. \"30s\". Defaults to 10s",
+			Validators:  []validator.String{durationValidator{}},
+		},
+		utils.StatusTimeout: schema.StringAttribute{
+			Optional:    true,
+			Description: "Time after which an operation the status hook still reports pending fails, e.g. \"1h\". Defaults to 30m",
+			Validators:  []validator.String{durationValidator{}},
+		},
+		utils.WaitFor: schema.StringAttribute{
+			Optional:    true,
+			Description: "Command polled after create and update, with the id, input and output they produced, until it exits 0, so that dependent resources only start once the object is ready. The wait block sets how often it's polled and for how long",
+			Validators:  hookCommandValidators(utils.WaitFor, false, false),
+		},
+		utils.Verify: schema.StringAttribute{
+			Optional:    true,
+			Description: "Command run right after create and update, with the desired input and the output they produced, which exits with a non-zero code to fail the apply when the backend didn't converge. The errors it prints as JSON, such as {\"path\": \"replicas\", \"detail\": \"...\"} or an object with an errors list, are reported on the output keys they name",
+			Validators:  hookCommandValidators(utils.Verify, false, false),
+		},
+		utils.OnFailure: schema.StringAttribute{
+			Optional:    true,
+			Description: "Command run when create or update fails, reports a partial create or returns a result that fails validation, verify or wait_for, with the payload of the failing hook and failure.hook, failure.exit_code and failure.stderr, to roll back partial work such as temporary resources or reservations. Its own failure is reported as a warning",
+			Validators:  hookCommandValidators(utils.OnFailure, false, false),
+		},
+		utils.Apply: schema.StringAttribute{
+			Optional:    true,
+			Description: "Idempotent command converging the resource to its input, run in place of create and update, and of read when there is no read hook, for converge-style scripts such as ansible playbooks or make targets. It prints the output like create does",
+			Validators:  hookCommandValidators(utils.Apply, false, false),
+		},
+		utils.Destroy: schema.StringAttribute{
+			Optional:    true,
+			Description: "Command run in place of delete, alongside apply",
+			Validators:  hookCommandValidators(utils.Destroy, false, false),
+		},
+		utils.RequiresReplace: schema.StringAttribute{
+			Optional:    true,
+			Description: "Command run while planning an input change, which receives the prior_input with the planned input and exits with the provider requires_replace_exit_code to force replacement instead of an update",
+			Validators:  hookCommandValidators(utils.RequiresReplace, false, false),
+		},
+		utils.WorkingDirectory: schema.StringAttribute{
+			Optional:    true,
+			Description: "Working directory for hook execution, overrides the provider working_directory",
+		},
+		utils.OutputFormat: schema.StringAttribute{
+			Optional:    true,
+			Description: "Format of the hook output, either json (default) or yaml",
+			Validators: []validator.String{
+				stringvalidator.OneOf(utils.OutputFormatJSON, utils.OutputFormatYAML),
+			},
+		},
+		utils.BypassParallelism: schema.BoolAttribute{
+			Optional:    true,
+			Description: "Run these hooks without waiting on the provider parallelism limit, for hooks that are local and instant",
+		},
+		utils.RawOutput: schema.BoolAttribute{
+			Optional:    true,
+			Description: "Store hook stdout verbatim under output.raw instead of parsing it, for wrapping commands that don't print JSON. The trimmed stdout of the create hook becomes the resource id",
+		},
+		utils.Templates: schema.BoolAttribute{
+			Optional:    true,
+			Description: "Render Go template placeholders in the hook commands and argv entries, such as --bucket {{ .input.bucket }}, from the hook payload before they run. A literal {{ is written as {{ \"{{\" }}",
+		},
+		utils.Runtimes: schema.MapAttribute{
+			ElementType: types.StringType,
+			Optional:    true,
+			Description: "Names of provider runtimes by hook name, e.g. { create = \"builder\", delete = \"appliance\" }, to run those hooks with the executor of the runtime instead of the provider executor",
+		},
+		utils.Platforms: schema.MapAttribute{
+			ElementType: types.MapType{ElemType: types.StringType},
+			Optional:    true,
+			Description: "Hook commands and other string attributes by operating system, e.g. { windows = { create = \"pwsh -File create.ps1\" } }, used in place of the attributes above when the hooks run on that system: the one the provider runs on, or linux for hooks run by the docker, ssh and http executors. Operating systems are named like Go's runtime.GOOS: linux, darwin, windows, ...",
+			Validators: []validator.Map{
+				mapvalidator.KeysAre(stringvalidator.OneOf(utils.PlatformNames...)),
+			},
+		},
+		utils.Argv: schema.MapAttribute{
+			ElementType: types.ListType{ElemType: types.StringType},
+			Optional:    true,
+			Description: "Hook commands as argument lists by hook name, e.g. { create = [\"/usr/bin/python3\", \"manage.py\", \"create resource\"] }, passed to exec verbatim instead of the command attribute of the hook, so arguments may contain spaces and quotes. A hook is given either by its command attribute or here",
+			Validators: []validator.Map{
+				mapvalidator.KeysAre(stringvalidator.OneOf(utils.Create, utils.Read, utils.Update, utils.Delete, utils.Apply, utils.Destroy, utils.Plan, utils.Diff, utils.Validate, utils.Import, utils.Upgrade, utils.Exists, utils.Status, utils.WaitFor, utils.Verify, utils.OnFailure, utils.RequiresReplace)),
+				mapvalidator.ValueListsAre(listvalidator.SizeAtLeast(1)),
+			},
+		},
 	}
 }
 
-func getCrudCommands(data *customCrudResourceModel) (*hooksBlockValue, error) {
-	if data.Hooks.IsNull() || data.Hooks.IsUnknown() {
-		return nil, fmt.Errorf("crud block is null or unknown")
+func (r *customCrudResource) IdentitySchema(ctx context.Context, req resource.IdentitySchemaRequest, resp *resource.IdentitySchemaResponse) {
+	resp.IdentitySchema = identityschema.Schema{
+		Attributes: map[string]identityschema.Attribute{
+			"id": identityschema.StringAttribute{
+				RequiredForImport: true,
+				Description:       "Resource identifier",
+			},
+			utils.Create: identityschema.StringAttribute{
+				OptionalForImport: true,
+				Description:       "Create command the resource was created with",
+			},
+			utils.Read: identityschema.StringAttribute{
+				OptionalForImport: true,
+				Description:       "Read command used to refresh the resource",
+			},
+			utils.Update: identityschema.StringAttribute{
+				OptionalForImport: true,
+				Description:       "Update command for the resource",
+			},
+			utils.Delete: identityschema.StringAttribute{
+				OptionalForImport: true,
+				Description:       "Delete command for the resource",
+			},
+		},
 	}
+}
 
-	elements := data.Hooks.Elements()
-	if len(elements) == 0 {
-		return nil, fmt.Errorf("crud block is empty")
+// identityFor returns the identity of the resource described by data.
+func identityFor(data *customCrudResourceModel) customCrudIdentityModel {
+	identity := customCrudIdentityModel{
+		Id:     data.Id,
+		Create: types.StringNull(),
+		Read:   types.StringNull(),
+		Update: types.StringNull(),
+		Delete: types.StringNull(),
 	}
-
-	obj, ok := elements[0].(types.Object)
-	if !ok {
-		return nil, fmt.Errorf("crud block element is not an object")
+	// Identities only carry literal hook commands, a hook set or directory
+	// may change at any time and argv and scripts have no place in them
+	if crud, err := getCrudCommands(data, runtime.GOOS); err == nil && !data.hooksByReference() && len(crud.Argv.Elements()) == 0 && len(crud.Scripts) == 0 {
+		identity.Create = crud.Create
+		identity.Read = crud.Read
+		identity.Update = crud.Update
+		identity.Delete = crud.Delete
 	}
+	return identity
+}
 
-	crud := &hooksBlockValue{}
-	attrs := obj.Attributes()
+// setIdentity records the identity of data on the response, if the request
+// supports identities.
+func setIdentity(ctx context.Context, identity *tfsdk.ResourceIdentity, data *customCrudResourceModel, diagnostics *diag.Diagnostics) {
+	if identity == nil {
+		return
+	}
+	diagnostics.Append(identity.Set(ctx, identityFor(data))...)
+}
 
-	if create, ok := attrs[utils.Create].(types.String); ok {
-		crud.Create = create
+// ValidateConfig checks that the hooks are given by exactly one of a hooks
+// block, hook, hooks_ref, hooks_dir and hooks_file.
+func (r *customCrudResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var hooks types.List
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("hooks"), &hooks)...)
+	if resp.Diagnostics.HasError() || hooks.IsUnknown() {
+		return
 	}
-	if read, ok := attrs[utils.Read].(types.String); ok {
-		crud.Read = read
+	var given []string
+	if !hooks.IsNull() && len(hooks.Elements()) > 0 {
+		given = append(given, "a hooks block")
 	}
-	if update, ok := attrs[utils.Update].(types.String); ok {
-		crud.Update = update
+	var hook types.Object
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("hook"), &hook)...)
+	if !hook.IsNull() {
+		given = append(given, "hook")
 	}
-	if destroy, ok := attrs[utils.Delete].(types.String); ok {
-		crud.Delete = destroy // delete is a reserved keyword in Go, so we use "destroy" here
+	for _, name := range []string{"hooks_ref", "hooks_dir", "hooks_file"} {
+		var value types.String
+		resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(name), &value)...)
+		if !value.IsNull() {
+			given = append(given, name)
+		}
+	}
+	switch {
+	case len(given) > 1:
+		resp.Diagnostics.AddAttributeError(path.Root(given[len(given)-1]), "Conflicting Hooks",
+			fmt.Sprintf("%s can't be combined with %s, use only one of them.", given[len(given)-1], strings.Join(given[:len(given)-1], " and ")))
+	case len(given) == 0:
+		resp.Diagnostics.AddAttributeError(path.Root("hooks"), "Missing Hooks",
+			"A hooks block, hook, hooks_ref naming a provider hook set, hooks_dir or hooks_file is required.")
 	}
-
-	return crud, nil
 }
 
-func (r *customCrudResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+// resolveHooksRef sets the hooks of data to the provider hook set named by
+// hooks_ref, the scripts of hooks_dir or the hooks of hooks_file, if any. It
+// reports false when they can't be found or aren't valid.
+func (r *customCrudResource) resolveHooksRef(ctx context.Context, s schemaTypeReader, data *customCrudResourceModel, diagnostics *diag.Diagnostics) bool {
+	var set map[string]string
+	switch {
+	case !data.HooksDir.IsNull() && !data.HooksDir.IsUnknown():
+		scripts, err := utils.DiscoverHooks(data.HooksDir.ValueString())
+		if err != nil {
+			diagnostics.AddAttributeError(path.Root("hooks_dir"), "Invalid Hooks Directory",
+				fmt.Sprintf("The hooks_dir scripts can't be loaded: %s.", err))
+			return false
+		}
+		set = scripts
+	case !data.HooksFile.IsNull() && !data.HooksFile.IsUnknown():
+		file := data.HooksFile.ValueString()
+		hooks, err := utils.LoadHooksFile(file)
+		if err == nil {
+			if err = checkHookSet(hooks); err != nil {
+				err = fmt.Errorf("%s %w", file, err)
+			}
+		}
+		if err != nil {
+			diagnostics.AddAttributeError(path.Root("hooks_file"), "Invalid Hooks File",
+				fmt.Sprintf("The hooks_file can't be loaded: %s.", strings.TrimSuffix(err.Error(), ".")))
+			return false
+		}
+		set = hooks
+	case !data.HooksRef.IsNull() && !data.HooksRef.IsUnknown():
+		name := data.HooksRef.ValueString()
+		var ok bool
+		if set, ok = r.config.HookSets[name]; !ok {
+			diagnostics.AddAttributeError(path.Root("hooks_ref"), "Unknown Hook Set",
+				fmt.Sprintf("The provider hook_sets has no entry named %q.", name))
+			return false
+		}
+	default:
+		return true
+	}
+	hooks, diags := importHooks(ctx, s, set)
+	diagnostics.Append(diags...)
+	data.refHooks = hooks
+	return !diags.HasError()
+}
+
+// ModifyPlan implements resource.ResourceWithModifyPlan to force replacement
+// when update hook is not provided and input has changed.
+func (r *customCrudResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing to plan on destroy
+	if req.Plan.Raw.IsNull() {
+		if !req.State.Raw.IsNull() {
+			var state customCrudResourceModel
+			resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+			if !resp.Diagnostics.HasError() {
+				planSkippedOperation(ctx, &state, utils.Delete, &resp.Diagnostics)
+			}
+		}
+		return
+	}
+
+	var plan customCrudResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !r.resolveHooksRef(ctx, req.Plan.Schema, &plan, &resp.Diagnostics) {
+		return
+	}
+
+	// Hooks can't run against values Terraform doesn't know yet, defer the
+	// resource to a later round when the client supports it
+	if req.ClientCapabilities.DeferralAllowed && plan.unknownConfig(ctx) {
+		tflog.Debug(ctx, "Deferring resource with unknown hooks or input")
+		resp.Deferred = &resource.Deferred{Reason: resource.DeferredReasonResourceConfigUnknown}
+		return
+	}
+
+	// Get CRUD commands from the plan
+	crud, err := getCrudCommands(&plan, r.targetOS())
+	if err != nil {
+		// If we can't get CRUD commands, let the normal validation handle it
+		return
+	}
+
+	var state *customCrudResourceModel
+	if !req.State.Raw.IsNull() {
+		state = &customCrudResourceModel{}
+		resp.Diagnostics.Append(req.State.Get(ctx, state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// Values the backend populated or normalized for computed_input_keys
+	// aren't drift, Terraform accepts the prior input in place of the config
+	if state != nil && keepComputedInput(ctx, req.Private, state.Input, plan.Input, plan.computedInputKeys(), &resp.Diagnostics) {
+		tflog.Debug(ctx, "Input only differs at computed_input_keys, keeping the prior input")
+		plan.Input = state.Input
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("input"), plan.Input)...)
+		// The output was only marked unknown for the configured input
+		plan.Output = state.Output
+		plan.OutputSensitive = state.OutputSensitive
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("output"), plan.Output)...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("output_sensitive"), plan.OutputSensitive)...)
+	}
+
+	if r.config.VerifyHooks {
+		if verifyHooks(crud, r.config.WorkingDirectory, &resp.Diagnostics); resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// Invalid input fails the plan before any hook sees it
+	if checkInputSchema(ctx, &plan, &resp.Diagnostics); resp.Diagnostics.HasError() {
+		return
+	}
+	if crud.defines(utils.Validate) && (state == nil || !state.Input.Equal(plan.Input)) {
+		if r.validateInput(ctx, req, state, &plan, resp); resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// Changed hook logic shows up as a script_hash change
+	if scriptHash := r.scriptHash(&plan, crud); !plan.ScriptHash.Equal(scriptHash) {
+		plan.ScriptHash = scriptHash
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("script_hash"), scriptHash)...)
+	}
+	rerun := false
+	if state != nil && !state.ScriptHash.IsNull() && !state.ScriptHash.Equal(plan.ScriptHash) {
+		switch plan.ScriptChange.ValueString() {
+		case scriptChangeReplace:
+			tflog.Debug(ctx, "Hook scripts changed, forcing replacement")
+			resp.RequiresReplace = append(resp.RequiresReplace, path.Root("script_hash"))
+		case scriptChangeUpdate:
+			if !crud.defines(utils.Update) {
+				tflog.Debug(ctx, "Hook scripts changed without an update hook, forcing replacement")
+				resp.RequiresReplace = append(resp.RequiresReplace, path.Root("script_hash"))
+			} else {
+				rerun = true
+			}
+		}
+	}
+	// An input change applied while the update was skipped still runs it
+	if state != nil && crud.defines(utils.Update) && updateSkipped(ctx, req.Private, &resp.Diagnostics) {
+		rerun = true
+	}
+
+	if state != nil {
+		if !state.Triggers.Equal(plan.Triggers) {
+			tflog.Debug(ctx, "Triggers changed, forcing replacement")
+			resp.RequiresReplace = append(resp.RequiresReplace, path.Root("triggers"))
+		}
+
+		// If update hook is not provided (null or empty), force replacement on any input change
+		if !crud.defines(utils.Update) {
+			// Check if input has changed
+			if !state.Input.Equal(plan.Input) {
+				if planMissingUpdate(state, &plan, &resp.Diagnostics); resp.Diagnostics.HasError() {
+					return
+				}
+				tflog.Debug(ctx, "Update hook not provided and input changed, forcing replacement")
+				resp.RequiresReplace = append(resp.RequiresReplace, path.Root("input"))
+			}
+		}
+		if changed := changedInputPaths(state.Input, plan.Input, stringList(ctx, plan.ReplaceOnChange)); len(changed) > 0 && !resp.RequiresReplace.Contains(path.Root("input")) {
+			tflog.Debug(ctx, "Input listed in replace_on_change changed, forcing replacement", map[string]interface{}{
+				"paths": changed,
+			})
+			resp.RequiresReplace = append(resp.RequiresReplace, path.Root("input"))
+		}
+		if len(resp.RequiresReplace) == 0 && crud.defines(utils.RequiresReplace) && !state.Input.Equal(plan.Input) {
+			r.checkReplacement(ctx, req, state, &plan, resp)
+		}
+
+		// A skipped update keeps the prior output, no hook runs on apply
+		if len(resp.RequiresReplace) > 0 {
+			planSkippedOperation(ctx, &plan, utils.Delete, &resp.Diagnostics)
+		} else if (!state.Input.Equal(plan.Input) || rerun) && planSkippedOperation(ctx, &plan, utils.Update, &resp.Diagnostics) {
+			return
+		}
+	}
+
+	// Hook-only changes keep the prior output without running a hook
+	inputChanged := state == nil || !state.Input.Equal(plan.Input) || rerun
+	if crud.defines(utils.Diff) && inputChanged {
+		r.describeChanges(ctx, req, state, &plan, resp)
+	}
+
+	// Changed input runs the update hook, so the prior output must not reach
+	// dependent resources until the hook has returned the new one
+	if state != nil && inputChanged && !crud.defines(utils.Plan) {
+		if !plan.Output.IsUnknown() {
+			plan.Output = types.DynamicUnknown()
+			resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("output"), plan.Output)...)
+		}
+		if !plan.OutputSensitive.IsUnknown() {
+			plan.OutputSensitive = types.DynamicUnknown()
+			resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("output_sensitive"), plan.OutputSensitive)...)
+		}
+	}
+
+	// Replacement runs create, which is planned separately and can return new values for every key
+	if len(resp.RequiresReplace) > 0 || (!plan.Output.IsUnknown() && !plan.OutputSensitive.IsUnknown()) {
+		return
+	}
+
+	if crud.defines(utils.Plan) && inputChanged {
+		r.planOutput(ctx, req, state, &plan, resp)
+		return
+	}
+
+	if keys := stringList(ctx, plan.ExpectedOutputKeys); keys != nil && plan.Output.IsUnknown() && !plan.SensitiveOutput.ValueBool() {
+		if output, ok := expectedOutput(ctx, state, keys, &resp.Diagnostics); ok {
+			resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("output"), output)...)
+		}
+		return
+	}
+
+	if state != nil && plan.Output.IsUnknown() {
+		if output, ok := stableOutput(ctx, state, stringList(ctx, plan.StableOutputKeys), &resp.Diagnostics); ok {
+			resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("output"), output)...)
+		}
+	}
+}
+
+// commands returns the hook commands by hook name.
+func (crud *hooksBlockValue) commands() map[string]types.String {
+	return map[string]types.String{
+		utils.Create:          crud.Create,
+		utils.Read:            crud.Read,
+		utils.Update:          crud.Update,
+		utils.Delete:          crud.Delete,
+		utils.Plan:            crud.Plan,
+		utils.Diff:            crud.Diff,
+		utils.Validate:        crud.Validate,
+		utils.Import:          crud.Import,
+		utils.Upgrade:         crud.Upgrade,
+		utils.Exists:          crud.Exists,
+		utils.Status:          crud.Status,
+		utils.WaitFor:         crud.WaitFor,
+		utils.OnFailure:       crud.OnFailure,
+		utils.Verify:          crud.Verify,
+		utils.RequiresReplace: crud.RequiresReplace,
+	}
+}
+
+// defines reports whether hook is given, as a command, an argv entry or an
+// inline script.
+func (crud *hooksBlockValue) defines(hook string) bool {
+	if _, ok := crud.Scripts[hook]; ok {
+		return true
+	}
+	return utils.HookDefined(crud.commands()[hook], crud.Argv, hook)
+}
+
+// scriptHash returns the script_hash of data run with crud: the hash of its
+// inline scripts, and with hash_hook_files of the files its commands name,
+// or null when there is nothing to hash.
+func (r *customCrudResource) scriptHash(data *customCrudResourceModel, crud *hooksBlockValue) types.String {
+	commands := map[string][]string{}
+	if data.HashHookFiles.ValueBool() {
+		for hook, command := range crud.commands() {
+			if _, scripted := crud.Scripts[hook]; scripted || !crud.defines(hook) {
+				continue
+			}
+			if args, err := utils.HookArgs(command, crud.Argv, hook, crud.Templates.ValueBool(), runtime.GOOS); err == nil {
+				commands[hook] = args
+			}
+		}
+	}
+	dir := r.config.WorkingDirectory
+	if d := crud.WorkingDirectory.ValueString(); d != "" {
+		dir = d
+	}
+	if hash := utils.ScriptHash(crud.Scripts, commands, dir); hash != "" {
+		return types.StringValue(hash)
+	}
+	return types.StringNull()
+}
+
+// planScriptHash sets the script_hash of plan when ModifyPlan didn't, e.g.
+// for resources planned before their hooks were known.
+func (r *customCrudResource) planScriptHash(plan *customCrudResourceModel) {
+	if !plan.ScriptHash.IsUnknown() {
+		return
+	}
+	plan.ScriptHash = types.StringNull()
+	if crud, err := getCrudCommands(plan, r.targetOS()); err == nil {
+		plan.ScriptHash = r.scriptHash(plan, crud)
+	}
+}
+
+// scriptRerun reports whether the update of state to plan runs the update
+// hook for a changed script_hash alone.
+func scriptRerun(state, plan *customCrudResourceModel) bool {
+	return plan.ScriptChange.ValueString() == scriptChangeUpdate && !state.ScriptHash.IsNull() &&
+		!plan.ScriptHash.IsUnknown() && !state.ScriptHash.Equal(plan.ScriptHash)
+}
+
+// verifyHooks reports an error on each hook whose executable is missing or
+// can't be run, so that it fails the plan instead of the apply.
+func verifyHooks(crud *hooksBlockValue, dir string, diagnostics *diag.Diagnostics) {
+	if d := crud.WorkingDirectory.ValueString(); d != "" {
+		dir = d
+	}
+	hooks := []struct {
+		name    string
+		command types.String
+	}{
+		{utils.Create, crud.Create},
+		{utils.Read, crud.Read},
+		{utils.Update, crud.Update},
+		{utils.Delete, crud.Delete},
+		{utils.Plan, crud.Plan},
+		{utils.Diff, crud.Diff},
+		{utils.Validate, crud.Validate},
+		{utils.Import, crud.Import},
+		{utils.Upgrade, crud.Upgrade},
+		{utils.Exists, crud.Exists},
+		{utils.Status, crud.Status},
+		{utils.WaitFor, crud.WaitFor},
+		{utils.OnFailure, crud.OnFailure},
+		{utils.Verify, crud.Verify},
+		{utils.RequiresReplace, crud.RequiresReplace},
+	}
+	runtimes := crud.Runtimes.Elements()
+	argv := crud.Argv.Elements()
+	for _, hook := range hooks {
+		_, hasArgv := argv[hook.name]
+		if !hasArgv && (hook.command.IsNull() || hook.command.IsUnknown()) {
+			continue
+		}
+		// Hooks run by a runtime don't run on this machine
+		if runtime, ok := runtimes[hook.name]; ok && !runtime.IsNull() {
+			continue
+		}
+		var err error
+		attribute := path.Root("hooks").AtListIndex(0).AtName(hook.name)
+		if hasArgv {
+			// argv that isn't known yet is verified by a later plan
+			if args, argsErr := utils.HookArgs(hook.command, crud.Argv, hook.name, crud.Templates.ValueBool(), runtime.GOOS); argsErr == nil {
+				err = utils.VerifyArgs(args, dir)
+			}
+			attribute = path.Root("hooks").AtListIndex(0).AtName(utils.Argv).AtMapKey(hook.name)
+		} else {
+			err = utils.VerifyCommand(hook.command.ValueString(), dir)
+		}
+		if err != nil {
+			diagnostics.AddAttributeError(attribute, "Invalid Hook Executable",
+				fmt.Sprintf("The %s hook can't be run: %s.", hook.name, err))
+		}
+	}
+}
+
+// skipsOperation reports whether op is listed in skip_operations.
+func skipsOperation(ctx context.Context, data *customCrudResourceModel, op string) bool {
+	return slices.Contains(stringList(ctx, data.SkipOperations), op)
+}
+
+// planSkippedOperation reports whether the planned op is listed in
+// skip_operations, failing the plan unless skip_action is warn.
+func planSkippedOperation(ctx context.Context, data *customCrudResourceModel, op string, diagnostics *diag.Diagnostics) bool {
+	if !skipsOperation(ctx, data, op) {
+		return false
+	}
+	summary := fmt.Sprintf("%s Skipped", cases.Title(language.English).String(op))
+	if data.SkipAction.ValueString() == skipActionWarn {
+		diagnostics.AddAttributeWarning(path.Root("skip_operations"), summary,
+			fmt.Sprintf("The %s operation is listed in skip_operations, it is planned as a no-op.", op))
+		return true
+	}
+	diagnostics.AddAttributeError(path.Root("skip_operations"), summary,
+		fmt.Sprintf("The plan runs the %s operation, which is listed in skip_operations. Remove it from skip_operations, or set skip_action to warn to plan it as a no-op.", op))
+	return true
+}
+
+// planMissingUpdate reports the input change of a resource without an update
+// hook, as a warning that it gets replaced or, with update_strategy set to
+// error, as an error failing the plan.
+func planMissingUpdate(state, plan *customCrudResourceModel, diagnostics *diag.Diagnostics) {
+	changed := "The input"
+	if keys := changedInputKeys(state.Input, plan.Input); len(keys) > 0 {
+		changed = fmt.Sprintf("The input keys %s", strings.Join(keys, ", "))
+	}
+	if plan.UpdateStrategy.ValueString() == updateStrategyError {
+		diagnostics.AddAttributeError(path.Root("input"), "Missing Update Hook",
+			fmt.Sprintf("%s changed, but the hooks have no update hook and update_strategy is error. Add an update hook, or revert the change.", changed))
+		return
+	}
+	diagnostics.AddAttributeWarning(path.Root("input"), "Replacement Without Update Hook",
+		fmt.Sprintf("%s changed and the hooks have no update hook, so the resource is replaced. Add an update hook to change it in place, or set update_strategy to error to fail such plans.", changed))
+}
+
+// changedInputKeys returns the sorted top-level input keys whose value differs
+// between the prior and planned input.
+func changedInputKeys(prior, planned types.Dynamic) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, input := range []types.Dynamic{prior, planned} {
+		values, _ := utils.AttrValueToInterface(input.UnderlyingValue()).(map[string]interface{})
+		for key := range values {
+			if !seen[key] && !strings.Contains(key, ".") {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	changed := changedInputPaths(prior, planned, keys)
+	slices.Sort(changed)
+	return changed
+}
+
+// changedInputPaths returns the key paths whose value differs between the
+// prior and planned input. Values that aren't known yet count as changed.
+func changedInputPaths(prior, planned types.Dynamic, keyPaths []string) []string {
+	var changed []string
+	for _, keyPath := range keyPaths {
+		before, after := inputValueAt(prior, keyPath), inputValueAt(planned, keyPath)
+		if before == nil && after == nil {
+			continue
+		}
+		if before == nil || after == nil || !before.Equal(after) {
+			changed = append(changed, keyPath)
+		}
+	}
+	return changed
+}
+
+// inputValueAt returns the value at the dot-separated key path of input, or
+// nil when it isn't set or isn't known.
+func inputValueAt(input types.Dynamic, keyPath string) attr.Value {
+	var value attr.Value = input
+	for _, key := range strings.Split(keyPath, ".") {
+		if dynamic, ok := value.(types.Dynamic); ok {
+			value = dynamic.UnderlyingValue()
+		}
+		switch v := value.(type) {
+		case types.Object:
+			value = v.Attributes()[key]
+		case types.Map:
+			value = v.Elements()[key]
+		default:
+			return nil
+		}
+		if value == nil || value.IsNull() || value.IsUnknown() {
+			return nil
+		}
+	}
+	return value
+}
+
+// planOutput runs the plan hook and stores the output it prints in the plan.
+// The output stays known after apply while the input isn't fully known yet
+// or when the hook prints nothing, and so do the values it prints as the
+// unknown sentinel.
+func (r *customCrudResource) planOutput(ctx context.Context, req resource.ModifyPlanRequest, state *customCrudResourceModel, plan *customCrudResourceModel, resp *resource.ModifyPlanResponse) {
+	payload, ok := r.planPayload(ctx, req, state, plan, resp)
+	if !ok {
+		return
+	}
+	var result *utils.ExecutionResult
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, r.config, req.Plan.GetAttribute), func(ctx context.Context) {
+		result, ok = utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, payload, &resp.Diagnostics, utils.CrudPlan)
+	})
+	if !ok || result.Result == nil {
+		return
+	}
+
+	if r.config.CompatibilityMode != utils.CompatibilityV1 {
+		// Private data is only stored by the hooks that apply changes
+		delete(result.Result, utils.PrivateKey)
+		result.Result = utils.MarkUnknown(result.Result)
+	}
+	resp.Diagnostics.Append(plan.storeOutput(result.Result, result.Sensitive, r.config.CollectionTyping)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("output"), plan.Output)...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("output_sensitive"), plan.OutputSensitive)...)
+}
+
+// describeChanges runs the diff hook and shows the description it prints as a
+// warning on the input, so that reviewers see what the apply would change.
+func (r *customCrudResource) describeChanges(ctx context.Context, req resource.ModifyPlanRequest, state *customCrudResourceModel, plan *customCrudResourceModel, resp *resource.ModifyPlanResponse) {
+	payload, ok := r.planPayload(ctx, req, state, plan, resp)
+	if !ok {
+		return
+	}
+	var result *utils.ExecutionResult
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, r.config, req.Plan.GetAttribute), func(ctx context.Context) {
+		result, ok = utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, payload, &resp.Diagnostics, utils.CrudDiff)
+	})
+	if !ok {
+		return
+	}
+	if description := strings.TrimSpace(result.Mask(result.Stdout)); description != "" {
+		resp.Diagnostics.AddAttributeWarning(path.Root("input"), "Planned Changes", description)
+	}
+}
+
+// validateInput runs the validate hook and reports the errors it prints when it
+// exits with a non-zero code on the input keys they name. The hook only runs
+// once the planned input is fully known.
+func (r *customCrudResource) validateInput(ctx context.Context, req resource.ModifyPlanRequest, state *customCrudResourceModel, plan *customCrudResourceModel, resp *resource.ModifyPlanResponse) {
+	payload, ok := r.planPayload(ctx, req, state, plan, resp)
+	if !ok {
+		return
+	}
+	var result *utils.ExecutionResult
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, r.config, req.Plan.GetAttribute), func(ctx context.Context) {
+		result, ok = utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, payload, &resp.Diagnostics, utils.CrudValidate)
+	})
+	if ok || result == nil || resp.Diagnostics.HasError() {
+		return
+	}
+	for _, e := range utils.ValidationErrors(result.Stdout, result.Stderr) {
+		resp.Diagnostics.AddAttributeError(inputPath(e.Path), e.Summary, result.Mask(e.Detail))
+	}
+}
+
+// checkInputSchema reports an error for each violation of input_schema by the
+// planned input, once the input is fully known.
+func checkInputSchema(ctx context.Context, plan *customCrudResourceModel, diagnostics *diag.Diagnostics) {
+	if plan.InputSchema.IsNull() || plan.InputSchema.IsUnknown() {
+		return
+	}
+	input, err := plan.Input.ToTerraformValue(ctx)
+	if err != nil || !input.IsFullyKnown() {
+		return
+	}
+	inputSchema, err := utils.ParseJSONSchema(plan.InputSchema.ValueString())
+	if err != nil {
+		diagnostics.AddAttributeError(path.Root("input_schema"), "Invalid Input Schema", err.Error())
+		return
+	}
+	for _, v := range inputSchema.Validate(utils.AttrValueToInterface(plan.Input.UnderlyingValue()), path.Root("input")) {
+		diagnostics.AddAttributeError(v.Path, "Input Schema Violation", fmt.Sprintf("The input doesn't match input_schema: %s", v.Message))
+	}
+}
+
+// checkOutputValidation returns an error for each violation of the
+// output_validation_schema by hook output.
+func checkOutputValidation(schema types.String, output map[string]interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if schema.IsNull() || schema.IsUnknown() {
+		return diags
+	}
+	outputSchema, err := utils.ParseJSONSchema(schema.ValueString())
+	if err != nil {
+		diags.AddAttributeError(path.Root("output_validation_schema"), "Invalid Output Validation Schema", err.Error())
+		return diags
+	}
+	var value interface{} = output
+	if output == nil {
+		value = map[string]interface{}{}
+	}
+	for _, v := range outputSchema.Validate(value, path.Root("output")) {
+		diags.AddAttributeError(v.Path, "Output Validation Failed", fmt.Sprintf("The hook output doesn't match output_validation_schema: %s", v.Message))
+	}
+	return diags
+}
+
+// inputPath returns the attribute path of the dot-separated input key path,
+// where numeric keys index lists.
+func inputPath(keyPath string) path.Path {
+	return attributePath("input", keyPath)
+}
+
+// attributePath returns the path of the dot-separated key path within the
+// root attribute, where numeric keys index lists.
+func attributePath(root, keyPath string) path.Path {
+	p := path.Root(root)
+	if keyPath == "" {
+		return p
+	}
+	for _, key := range strings.Split(keyPath, ".") {
+		if index, err := strconv.Atoi(key); err == nil && index >= 0 {
+			p = p.AtListIndex(index)
+		} else {
+			p = p.AtName(key)
+		}
+	}
+	return p
+}
+
+// checkReplacement runs the requires_replace hook and forces replacement when
+// it exits with the requires_replace_exit_code. The hook only runs once the
+// planned input is fully known.
+func (r *customCrudResource) checkReplacement(ctx context.Context, req resource.ModifyPlanRequest, state *customCrudResourceModel, plan *customCrudResourceModel, resp *resource.ModifyPlanResponse) {
+	payload, ok := r.planPayload(ctx, req, state, plan, resp)
+	if !ok {
+		return
+	}
+	payload.PriorInput = utils.MergeDefaultInputs(r.config, utils.AttrValueToInterface(state.Input.UnderlyingValue()))
+	var result *utils.ExecutionResult
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, r.config, req.Plan.GetAttribute), func(ctx context.Context) {
+		result, ok = utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, payload, &resp.Diagnostics, utils.CrudRequiresReplace)
+	})
+	if !ok && result != nil && result.ExitCode == r.config.RequiresReplaceExitCode {
+		tflog.Debug(ctx, "requires_replace hook asked for replacement")
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root("input"))
+	}
+}
+
+// planPayload returns the payload of the hooks run while planning, which
+// receive the proposed input with the prior id, output and private data. It
+// reports false while the input isn't fully known yet.
+func (r *customCrudResource) planPayload(ctx context.Context, req resource.ModifyPlanRequest, state *customCrudResourceModel, plan *customCrudResourceModel, resp *resource.ModifyPlanResponse) (utils.ExecutionPayload, bool) {
+	input, err := plan.Input.ToTerraformValue(ctx)
+	if err != nil || !input.IsFullyKnown() {
+		return utils.ExecutionPayload{}, false
+	}
+	var config customCrudResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return utils.ExecutionPayload{}, false
+	}
+
+	payload := utils.ExecutionPayload{
+		Input:     utils.MergeDefaultInputs(r.config, r.mergeInputWithWO(plan.Input, config.InputWO)),
+		Private:   privateData(ctx, req.Private, &resp.Diagnostics),
+		Phase:     utils.PhasePlan,
+		Sensitive: []string{utils.PrivateKey},
+	}
+	if state != nil {
+		payload.Id = state.Id.ValueString()
+		payload.Output = state.storedOutput()
+		payload.Sensitive = append(state.sensitivePaths(), utils.PrivateKey)
+	}
+	return payload, true
+}
+
+// stableOutput returns the planned output of an update, which carries the prior
+// values of the stable keys and leaves every other key of the prior output
+// unknown. The other keys may change type, so they are planned as dynamic
+// values. It reports false when no stable key is in the prior output.
+func stableOutput(ctx context.Context, state *customCrudResourceModel, keys []string, diagnostics *diag.Diagnostics) (types.Dynamic, bool) {
+	prior, ok := state.Output.UnderlyingValue().(types.Object)
+	if len(keys) == 0 || !ok || prior.IsNull() || prior.IsUnknown() {
+		return types.DynamicNull(), false
+	}
+	stable := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		stable[key] = true
+	}
+
+	found := false
+	attrTypes := make(map[string]attr.Type, len(prior.Attributes()))
+	attrs := make(map[string]attr.Value, len(prior.Attributes()))
+	for k, v := range prior.Attributes() {
+		if stable[k] {
+			attrTypes[k] = v.Type(ctx)
+			attrs[k] = v
+			found = true
+			continue
+		}
+		attrTypes[k] = types.DynamicType
+		attrs[k] = types.DynamicUnknown()
+	}
+	if !found {
+		return types.DynamicNull(), false
+	}
+	output, diags := types.ObjectValue(attrTypes, attrs)
+	diagnostics.Append(diags...)
+	if diags.HasError() {
+		return types.DynamicNull(), false
+	}
+	return types.DynamicValue(output), true
+}
+
+// checkStableOutput reports an error naming the first stable key whose value
+// the update hook changed, which Terraform would otherwise only report as an
+// inconsistent result after apply.
+func checkStableOutput(state, plan *customCrudResourceModel, keys []string, diagnostics *diag.Diagnostics) {
+	prior, ok := state.Output.UnderlyingValue().(types.Object)
+	if len(keys) == 0 || !ok || prior.IsNull() || prior.IsUnknown() {
+		return
+	}
+	updated, ok := plan.Output.UnderlyingValue().(types.Object)
+	if !ok {
+		return
+	}
+	for _, key := range keys {
+		before, exists := prior.Attributes()[key]
+		if !exists {
+			continue
+		}
+		if after := updated.Attributes()[key]; after == nil || !before.Equal(after) {
+			diagnostics.AddAttributeError(path.Root("stable_output_keys"), "Stable Output Changed",
+				fmt.Sprintf("The update hook changed the output key %q listed in stable_output_keys from %v to %v. Remove the key from stable_output_keys if it may change on update.", key, before, after))
+			return
+		}
+	}
+}
+
+// expectedOutput returns the planned output of an update with
+// expected_output_keys set, where the listed keys are unknown and every other
+// key of the prior output keeps its value. The listed keys may change type,
+// or be new, so they are planned as dynamic values. It reports false when the
+// output can't be planned key by key, which includes every create: its hook
+// may print keys that aren't listed, such as the id, so nothing is known about
+// the type of its output.
+func expectedOutput(ctx context.Context, state *customCrudResourceModel, keys []string, diagnostics *diag.Diagnostics) (types.Dynamic, bool) {
+	if state == nil {
+		return types.DynamicNull(), false
+	}
+	prior, ok := state.Output.UnderlyingValue().(types.Object)
+	if !ok || prior.IsNull() || prior.IsUnknown() {
+		return types.DynamicNull(), false
+	}
+	attrTypes := map[string]attr.Type{}
+	attrs := map[string]attr.Value{}
+	for k, v := range prior.Attributes() {
+		attrTypes[k] = v.Type(ctx)
+		attrs[k] = v
+	}
+	// Keys the hooks marked as sensitive are stored in output_sensitive
+	sensitive := map[string]bool{}
+	if object, ok := state.OutputSensitive.UnderlyingValue().(types.Object); ok {
+		for k := range object.Attributes() {
+			sensitive[k] = true
+		}
+	}
+	for _, key := range keys {
+		if sensitive[key] {
+			continue
+		}
+		attrTypes[key] = types.DynamicType
+		attrs[key] = types.DynamicUnknown()
+	}
+	output, diags := types.ObjectValue(attrTypes, attrs)
+	diagnostics.Append(diags...)
+	if diags.HasError() {
+		return types.DynamicNull(), false
+	}
+	return types.DynamicValue(output), true
+}
+
+// hookAttributes returns the attributes of the hooks block for goos, the
+// system the hooks run on, before the apply and destroy hooks stand in for
+// the hooks they replace.
+func hookAttributes(data *customCrudResourceModel, goos string) (map[string]attr.Value, error) {
+	hooks := data.GetHooks()
+	if hooks.IsNull() || hooks.IsUnknown() {
+		return nil, fmt.Errorf("crud block is null or unknown")
+	}
+
+	elements := hooks.Elements()
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("crud block is empty")
+	}
+
+	obj, ok := elements[0].(types.Object)
+	if !ok {
+		return nil, fmt.Errorf("crud block element is not an object")
+	}
+
+	return utils.PlatformAttributes(obj.Attributes(), goos)
+}
+
+// targetOS returns the system the provider executor runs hooks on, whose
+// platforms overrides apply.
+func (r *customCrudResource) targetOS() string {
+	return utils.TargetOS(r.config.Executor)
+}
+
+func getCrudCommands(data *customCrudResourceModel, goos string) (*hooksBlockValue, error) {
+	attrs, err := hookAttributes(data, goos)
+	if err != nil {
+		return nil, err
+	}
+	attrs = utils.ApplyAttributes(attrs)
+	crud := &hooksBlockValue{}
+
+	if create, ok := attrs[utils.Create].(types.String); ok {
+		crud.Create = create
+	}
+	if read, ok := attrs[utils.Read].(types.String); ok {
+		crud.Read = read
+	}
+	if update, ok := attrs[utils.Update].(types.String); ok {
+		crud.Update = update
+	}
+	if destroy, ok := attrs[utils.Delete].(types.String); ok {
+		crud.Delete = destroy // delete is a reserved keyword in Go, so we use "destroy" here
+		if strings.TrimSpace(destroy.ValueString()) == utils.Noop {
+			crud.Delete = types.StringNull()
+		}
+	}
+	if plan, ok := attrs[utils.Plan].(types.String); ok {
+		crud.Plan = plan
+	}
+	if diff, ok := attrs[utils.Diff].(types.String); ok {
+		crud.Diff = diff
+	}
+	if validate, ok := attrs[utils.Validate].(types.String); ok {
+		crud.Validate = validate
+	}
+	if importHook, ok := attrs[utils.Import].(types.String); ok {
+		crud.Import = importHook
+	}
+	if exists, ok := attrs[utils.Exists].(types.String); ok {
+		crud.Exists = exists
+	}
+	if status, ok := attrs[utils.Status].(types.String); ok {
+		crud.Status = status
+	}
+	if waitFor, ok := attrs[utils.WaitFor].(types.String); ok {
+		crud.WaitFor = waitFor
+	}
+	if verify, ok := attrs[utils.Verify].(types.String); ok {
+		crud.Verify = verify
+	}
+	if onFailure, ok := attrs[utils.OnFailure].(types.String); ok {
+		crud.OnFailure = onFailure
+	}
+	if upgrade, ok := attrs[utils.Upgrade].(types.String); ok {
+		crud.Upgrade = upgrade
+	}
+	if requiresReplace, ok := attrs[utils.RequiresReplace].(types.String); ok {
+		crud.RequiresReplace = requiresReplace
+	}
+	if workingDirectory, ok := attrs[utils.WorkingDirectory].(types.String); ok {
+		crud.WorkingDirectory = workingDirectory
+	}
+	if outputFormat, ok := attrs[utils.OutputFormat].(types.String); ok {
+		crud.OutputFormat = outputFormat
+	}
+	if bypass, ok := attrs[utils.BypassParallelism].(types.Bool); ok {
+		crud.BypassParallelism = bypass
+	}
+	if raw, ok := attrs[utils.RawOutput].(types.Bool); ok {
+		crud.RawOutput = raw
+	}
+	if templates, ok := attrs[utils.Templates].(types.Bool); ok {
+		crud.Templates = templates
+	}
+	if runtimes, ok := attrs[utils.Runtimes].(types.Map); ok {
+		crud.Runtimes = runtimes
+	}
+	if platforms, ok := attrs[utils.Platforms].(types.Map); ok {
+		crud.Platforms = platforms
+	}
+	if argv, ok := attrs[utils.Argv].(types.Map); ok {
+		crud.Argv = argv
+	}
+	crud.Scripts = utils.Scripts(attrs)
+
+	return crud, nil
+}
+
+func (r *customCrudResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
 	if req.ProviderData == nil {
 		r.config = utils.CustomCRUDProviderConfigDefaults()
 		return
 	}
-	if data, ok := req.ProviderData.(*CustomCRUDProvider); ok {
-		r.config = data.config
+	if data, ok := req.ProviderData.(*CustomCRUDProvider); ok {
+		r.config = data.kindConfig(resourceKind)
+	}
+}
+
+// configFor returns the provider config with the resource level options of data applied.
+func (r *customCrudResource) configFor(ctx context.Context, data *customCrudResourceModel) utils.CustomCRUDProviderConfig {
+	config := r.config
+	if paths := stringList(ctx, data.SortOutputLists); paths != nil {
+		config.SortOutputPaths = paths
+	}
+	config.IgnoreOutputPaths = stringList(ctx, data.IgnoreOutputKeys)
+	return config
+}
+
+// stringList converts a known list of strings to a slice, returning nil for
+// null or unknown lists.
+func stringList(ctx context.Context, list types.List) []string {
+	if list.IsNull() || list.IsUnknown() {
+		return nil
+	}
+	var values []string
+	if diags := list.ElementsAs(ctx, &values, false); diags.HasError() {
+		return nil
+	}
+	return values
+}
+
+// Helper to extract model from request and append diagnostics.
+func extractModel[T any](ctx context.Context, getFn func(context.Context, any) diag.Diagnostics, diagnostics *diag.Diagnostics) (*T, bool) {
+	var model T
+	*diagnostics = append(*diagnostics, getFn(ctx, &model)...)
+	if diagnostics.HasError() {
+		return nil, false
+	}
+	return &model, true
+}
+
+// hooksSemaphore returns the semaphore to hold while running the hooks found
+// through get, honouring bypass_parallelism on the hooks block.
+func hooksSemaphore(ctx context.Context, config utils.CustomCRUDProviderConfig, get func(context.Context, path.Path, interface{}) diag.Diagnostics) chan struct{} {
+	var hooks types.List
+	if diags := get(ctx, path.Root("hooks"), &hooks); diags.HasError() {
+		return config.Semaphore
+	}
+	var hook types.Object
+	if diags := get(ctx, path.Root("hook"), &hook); !diags.HasError() && !hook.IsNull() {
+		hooks = types.ListValueMust(hook.Type(ctx), []attr.Value{hook})
+	}
+	return utils.SemaphoreFor(config, hooks)
+}
+
+func (r *customCrudResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, profile := r.config.Profiler.Start(ctx, resourceKind, utils.Create)
+	defer profile.Finish()
+	var group types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("transaction_group"), &group)...)
+	if r.config.TransactionGroups.Failed(group.ValueString()) {
+		resp.Diagnostics.AddError("Transaction Group Failed",
+			fmt.Sprintf("A create of the transaction group %q failed during this apply, so this resource isn't created.", group.ValueString()))
+		return
+	}
+	sem := hooksSemaphore(ctx, r.config, req.Plan.GetAttribute)
+	var rollback utils.Rollback
+	utils.WithSemaphore(ctx, sem, func(ctx context.Context) {
+		profile.Mark(utils.ProfileWait)
+		plan, ok := extractModel[customCrudResourceModel](ctx, req.Plan.Get, &resp.Diagnostics)
+		if !ok || !r.resolveHooksRef(ctx, req.Plan.Schema, plan, &resp.Diagnostics) {
+			return
+		}
+		r.planScriptHash(plan)
+
+		var config customCrudResourceModel
+		resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		payload := utils.ExecutionPayload{
+			Id:        plan.Id.ValueString(),
+			Input:     utils.MergeDefaultInputs(r.config, r.mergeInputWithWO(plan.Input, config.InputWO)),
+			Output:    plan.storedOutput(),
+			Phase:     utils.PhaseApply,
+			Sensitive: []string{utils.PrivateKey},
+		}
+		result, adopted := r.findExisting(ctx, plan, payload, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		ok = true
+		if !adopted {
+			result, ok = utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, payload, &resp.Diagnostics, utils.CrudCreate)
+		}
+		r.config.ReadCache.Forget(plan.SharedReadKey.ValueString())
+		if !ok {
+			if !adopted {
+				r.onFailure(ctx, plan, payload, utils.CrudCreate, result, &resp.Diagnostics)
+			}
+			r.persistReportedState(ctx, plan, result, resp)
+			return
+		}
+		if partial, _ := result.Result[utils.PartialKey].(bool); partial {
+			delete(result.Result, utils.PartialKey)
+			message, _ := result.Result["error"].(string)
+			if message == "" {
+				message = "the create hook reported a partial create"
+			}
+			resp.Diagnostics.AddError("Create Partially Failed", fmt.Sprintf("%s. The resource is saved tainted, so the next apply replaces it.", result.Mask(message)))
+			result.State = result.Result
+			r.persistReportedState(ctx, plan, result, resp)
+			r.onFailure(ctx, plan, resultPayload(plan, payload, result), utils.CrudCreate, result, &resp.Diagnostics)
+			return
+		}
+		if id, exists := result.Result["id"]; exists {
+			if idStr, ok := id.(string); ok {
+				plan.Id = types.StringValue(idStr)
+			} else {
+				idStr = fmt.Sprintf("%v", id)
+				plan.Id = types.StringValue(idStr)
+			}
+		} else if crud, err := getCrudCommands(plan, r.targetOS()); err == nil && crud.RawOutput.ValueBool() {
+			// Commands that don't print JSON usually print the identifier of what they created
+			plan.Id = types.StringValue(strings.TrimSpace(result.Stdout))
+		}
+		if plan.Id.IsNull() || plan.Id.ValueString() == "" {
+			resp.Diagnostics.AddError(
+				"Create Execution Error",
+				fmt.Sprintf("Create script must return an 'id' field\nExit Code: %d\nStdout: %s\nStderr: %s\nInput Payload: %s", result.ExitCode, result.Mask(result.Stdout), result.Mask(result.Stderr), result.Mask(result.Payload)),
+			)
+			return
+		}
+		private, _ := result.Result[utils.PrivateKey].(map[string]interface{})
+		r.storeHookPrivate(ctx, resp.Private, result.Result, &resp.Diagnostics)
+		if diags := r.applyResult(plan, result.Result, result.Sensitive); diags.HasError() {
+			// The object exists even though its output can't be stored, so
+			// it's saved tainted without output instead of being orphaned
+			resp.Diagnostics.Append(diags...)
+			plan.Output = types.DynamicNull()
+			plan.OutputSensitive = types.DynamicNull()
+			resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+			setIdentity(ctx, resp.Identity, plan, &resp.Diagnostics)
+			r.onFailure(ctx, plan, resultPayload(plan, payload, result), utils.CrudCreate, result, &resp.Diagnostics)
+			return
+		}
+		storeOutputHash(ctx, resp.Private, plan, result.Result, result.Sensitive, &resp.Diagnostics)
+		storeComputedInput(ctx, resp.Private, req.Config, plan, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+			setIdentity(ctx, resp.Identity, plan, &resp.Diagnostics)
+			r.onFailure(ctx, plan, resultPayload(plan, payload, result), utils.CrudCreate, result, &resp.Diagnostics)
+			return
+		}
+		// An object that fails verification or never gets ready is still
+		// saved, tainted, so it isn't orphaned
+		ready := r.verifyOutput(ctx, plan, payload, result, &resp.Diagnostics) && r.waitReady(ctx, plan, payload, result, &resp.Diagnostics)
+		resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+		setIdentity(ctx, resp.Identity, plan, &resp.Diagnostics)
+		if !ready {
+			r.onFailure(ctx, plan, resultPayload(plan, payload, result), utils.CrudCreate, result, &resp.Diagnostics)
+			return
+		}
+		if !plan.PostCreateReadDelay.IsNull() || !plan.PostCreateReadRetries.IsNull() {
+			createdAt, _ := json.Marshal(utils.Now().UTC().Format(time.RFC3339Nano))
+			resp.Diagnostics.Append(resp.Private.SetKey(ctx, createdAtKey, createdAt)...)
+		}
+		// Adopted objects predate the apply, so a failed group leaves them be
+		if !adopted {
+			rollback = r.rollbackCreate(plan, private, sem)
+		}
+	})
+
+	// Hooks of the rolled back members may share the semaphore, so they
+	// run once it is released
+	name := group.ValueString()
+	switch {
+	case name == "":
+	case resp.Diagnostics.HasError():
+		rollbacks := r.config.TransactionGroups.Fail(name)
+		if len(rollbacks) > 0 {
+			tflog.Warn(ctx, "Create failed, rolling back the transaction group", map[string]interface{}{
+				"transaction_group": name,
+				"members":           len(rollbacks),
+			})
+		}
+		for _, rollback := range rollbacks {
+			rollback(ctx, &resp.Diagnostics)
+		}
+	case rollback != nil && !r.config.TransactionGroups.Join(name, rollback):
+		rollback(ctx, &resp.Diagnostics)
+		resp.State.RemoveResource(ctx)
+		resp.Diagnostics.AddError("Transaction Group Failed",
+			fmt.Sprintf("A create of the transaction group %q failed during this apply, so this resource was deleted again after its create.", name))
+	}
+}
+
+// findExisting runs the exists hook, if any, with the create payload and
+// reports whether it found an object to adopt instead of creating one, with
+// its output.
+func (r *customCrudResource) findExisting(ctx context.Context, plan *customCrudResourceModel, payload utils.ExecutionPayload, diagnostics *diag.Diagnostics) (*utils.ExecutionResult, bool) {
+	if crud, err := getCrudCommands(plan, r.targetOS()); err != nil || !crud.defines(utils.Exists) {
+		return nil, false
+	}
+	result, ok := utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, payload, diagnostics, utils.CrudExists)
+	if !ok || result.Result == nil {
+		return nil, false
+	}
+	if id, exists := result.Result["id"]; !exists || id == nil || fmt.Sprintf("%v", id) == "" {
+		return nil, false
+	}
+	tflog.Info(ctx, "Exists hook found an existing object, adopting it instead of running create", map[string]interface{}{
+		"id": fmt.Sprintf("%v", result.Result["id"]),
+	})
+	return result, true
+}
+
+// onFailure runs the on_failure hook, if any, with the payload of the op hook
+// that failed with result, or whose result failed to be stored, verified or
+// get ready. Its own failure is only a warning, the failure of op stays the
+// error.
+func (r *customCrudResource) onFailure(ctx context.Context, plan *customCrudResourceModel, payload utils.ExecutionPayload, op utils.CrudOp, result *utils.ExecutionResult, diagnostics *diag.Diagnostics) {
+	if crud, err := getCrudCommands(plan, r.targetOS()); err != nil || !crud.defines(utils.OnFailure) {
+		return
+	}
+	payload.Failure = &utils.FailureInfo{Hook: op.String(), ExitCode: -1}
+	if result != nil {
+		payload.Failure.ExitCode = result.ExitCode
+		payload.Failure.Stderr = result.Stderr
+	}
+	tflog.Info(ctx, "Running the on_failure hook", map[string]interface{}{
+		"hook":      op.String(),
+		"exit_code": payload.Failure.ExitCode,
+	})
+	var diags diag.Diagnostics
+	if _, ok := utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, payload, &diags, utils.CrudOnFailure); !ok {
+		for _, d := range diags.Errors() {
+			diagnostics.AddWarning("On Failure Hook Failed", fmt.Sprintf("%s: %s", d.Summary(), d.Detail()))
+		}
+	}
+}
+
+// waitReady polls the wait_for hook, if any, with the payload of the create
+// or update that produced result until the object is ready, as set by the
+// wait block, and reports whether it got ready.
+func (r *customCrudResource) waitReady(ctx context.Context, plan *customCrudResourceModel, payload utils.ExecutionPayload, result *utils.ExecutionResult, diagnostics *diag.Diagnostics) bool {
+	if crud, err := getCrudCommands(plan, r.targetOS()); err != nil || !crud.defines(utils.WaitFor) {
+		return true
+	}
+	waitTimeout, err := utils.ParseDuration(plan.waitAttribute("timeout"), utils.DefaultWaitTimeout)
+	if err != nil {
+		diagnostics.AddError("Invalid Wait Timeout", err.Error())
+		return false
+	}
+	waitInterval, err := utils.ParseDuration(plan.waitAttribute("interval"), utils.DefaultWaitInterval)
+	if err != nil {
+		diagnostics.AddError("Invalid Wait Interval", err.Error())
+		return false
+	}
+	return utils.WaitReady(ctx, r.configFor(ctx, plan), plan, resultPayload(plan, payload, result), diagnostics, waitInterval, waitTimeout)
+}
+
+// verifyOutput runs the verify hook, if any, with the payload of the create
+// or update that produced result, and reports whether the output passed it.
+// The errors it prints when it exits with a non-zero code are reported on the
+// output keys they name.
+func (r *customCrudResource) verifyOutput(ctx context.Context, plan *customCrudResourceModel, payload utils.ExecutionPayload, result *utils.ExecutionResult, diagnostics *diag.Diagnostics) bool {
+	if crud, err := getCrudCommands(plan, r.targetOS()); err != nil || !crud.defines(utils.Verify) {
+		return true
+	}
+	verified, ok := utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, resultPayload(plan, payload, result), diagnostics, utils.CrudVerify)
+	if ok || verified == nil || diagnostics.HasError() {
+		return ok
+	}
+	for _, e := range utils.VerificationErrors(verified.Stdout, verified.Stderr) {
+		diagnostics.AddAttributeError(attributePath("output", e.Path), e.Summary, verified.Mask(e.Detail))
+	}
+	return false
+}
+
+// resultPayload returns payload with the id and output of the create or
+// update result, for the hooks checking what it produced.
+func resultPayload(plan *customCrudResourceModel, payload utils.ExecutionPayload, result *utils.ExecutionResult) utils.ExecutionPayload {
+	payload.Id = plan.Id.ValueString()
+	payload.Output = result.Result
+	payload.Sensitive = append(append([]string{}, payload.Sensitive...), result.Sensitive...)
+	return payload
+}
+
+// rollbackCreate returns the rollback of a created transaction group member,
+// which runs its delete hook like a destroy would.
+func (r *customCrudResource) rollbackCreate(data *customCrudResourceModel, private map[string]interface{}, sem chan struct{}) utils.Rollback {
+	return func(ctx context.Context, diagnostics *diag.Diagnostics) {
+		if crud, err := getCrudCommands(data, r.targetOS()); err == nil && !crud.defines(utils.Delete) {
+			tflog.Warn(ctx, "Transaction group member has no delete hook, leaving it in place", map[string]interface{}{
+				"id": data.Id.ValueString(),
+			})
+			return
+		}
+		tflog.Warn(ctx, "Rolling back a transaction group member", map[string]interface{}{
+			"id": data.Id.ValueString(),
+		})
+		payload := utils.ExecutionPayload{
+			Id:        data.Id.ValueString(),
+			Input:     utils.MergeDefaultInputs(r.config, utils.AttrValueToInterface(data.Input.UnderlyingValue())),
+			Output:    data.storedOutput(),
+			Private:   private,
+			Phase:     utils.PhaseDestroy,
+			Sensitive: append(data.sensitivePaths(), utils.PrivateKey),
+		}
+		utils.WithSemaphore(ctx, sem, func(ctx context.Context) {
+			_, _ = utils.RunCrudScript(ctx, r.configFor(ctx, data), data, payload, diagnostics, utils.CrudDelete)
+		})
+		r.config.ReadCache.Forget(data.SharedReadKey.ValueString())
+	}
+}
+
+// persistReportedState saves the last state event of a failed or cancelled
+// create so the remote object isn't orphaned. Terraform marks the saved
+// resource as tainted because the create returned an error.
+func (r *customCrudResource) persistReportedState(ctx context.Context, plan *customCrudResourceModel, result *utils.ExecutionResult, resp *resource.CreateResponse) {
+	if result == nil || result.State == nil {
+		return
+	}
+	id, exists := result.State["id"]
+	if !exists || id == nil || fmt.Sprintf("%v", id) == "" {
+		return
+	}
+	tflog.Info(ctx, "Create failed after reporting state, persisting last reported state", map[string]interface{}{
+		"id": id,
+	})
+	plan.Id = types.StringValue(fmt.Sprintf("%v", id))
+	// The create already failed, so only the state failing to store stops it
+	// from being saved
+	var diags diag.Diagnostics
+	r.storeHookPrivate(ctx, resp.Private, result.State, &diags)
+	diags.Append(r.applyResult(plan, result.State, result.Sensitive)...)
+	storeOutputHash(ctx, resp.Private, plan, result.State, result.Sensitive, &diags)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+	setIdentity(ctx, resp.Identity, plan, &resp.Diagnostics)
+}
+
+// createdAtKey is the private state key recording when a resource with a
+// post-create read grace period was created, until its first successful read.
+const createdAtKey = "created_at"
+
+// createdAt returns the creation time recorded in private state, or the zero
+// time when the resource has already been read since it was created.
+func (r *customCrudResource) createdAt(ctx context.Context, priv PrivateStateReader, diagnostics *diag.Diagnostics) time.Time {
+	if priv == nil {
+		return time.Time{}
+	}
+	raw, diags := priv.GetKey(ctx, createdAtKey)
+	diagnostics.Append(diags...)
+	if len(raw) == 0 {
+		return time.Time{}
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return time.Time{}
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return createdAt
+}
+
+// skippedUpdateKey is the private state key marking a resource whose input
+// changed while update was listed in skip_operations with skip_action warn,
+// so that the update runs once it's no longer skipped.
+const skippedUpdateKey = "skipped_update"
+
+// updateSkipped reports whether private state marks an update as skipped.
+func updateSkipped(ctx context.Context, priv PrivateStateReader, diagnostics *diag.Diagnostics) bool {
+	if priv == nil {
+		return false
+	}
+	raw, diags := priv.GetKey(ctx, skippedUpdateKey)
+	diagnostics.Append(diags...)
+	return len(raw) > 0
+}
+
+// privateDataKey is the private state key holding the "private" object
+// returned by the hooks, which is passed back to them on later runs.
+const privateDataKey = "private"
+
+// privateStateWriter is implemented by the private state of responses.
+type privateStateWriter interface {
+	SetKey(ctx context.Context, key string, value []byte) diag.Diagnostics
+}
+
+// privateData returns the "private" object held in private state, if any.
+func privateData(ctx context.Context, priv PrivateStateReader, diagnostics *diag.Diagnostics) map[string]interface{} {
+	if priv == nil {
+		return nil
+	}
+	raw, diags := priv.GetKey(ctx, privateDataKey)
+	diagnostics.Append(diags...)
+	if len(raw) == 0 {
+		return nil
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		diagnostics.AddError("Invalid Private State", fmt.Sprintf("Failed to parse the private hook data: %v", err))
+		return nil
+	}
+	return value
+}
+
+// storeHookPrivate stores the private hook data of output, unless
+// compatibility_mode v1 makes "private" a regular output key.
+func (r *customCrudResource) storeHookPrivate(ctx context.Context, priv privateStateWriter, output map[string]interface{}, diagnostics *diag.Diagnostics) {
+	if r.config.CompatibilityMode == utils.CompatibilityV1 {
+		return
+	}
+	storePrivate(ctx, priv, output, diagnostics)
+}
+
+// storePrivate moves the "private" object out of the hook output into private
+// state. A null value clears it, while output without the key keeps it as is.
+func storePrivate(ctx context.Context, priv privateStateWriter, output map[string]interface{}, diagnostics *diag.Diagnostics) {
+	value, ok := output[utils.PrivateKey]
+	if !ok {
+		return
+	}
+	delete(output, utils.PrivateKey)
+	if value == nil {
+		diagnostics.Append(priv.SetKey(ctx, privateDataKey, nil)...)
+		return
+	}
+	if _, ok := value.(map[string]interface{}); !ok {
+		diagnostics.AddError("Invalid Private Output", fmt.Sprintf("The %q output key must be an object, got %T", utils.PrivateKey, value))
+		return
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		diagnostics.AddError("Invalid Private Output", fmt.Sprintf("Failed to encode the private hook data: %v", err))
+		return
+	}
+	diagnostics.Append(priv.SetKey(ctx, privateDataKey, raw)...)
+}
+
+// outputHashKey is the private state key holding the hash of the hook output
+// last stored in state, so that reads returning the same output can skip
+// converting it.
+const outputHashKey = "output_hash"
+
+// outputHash returns the private state value identifying the hook output, the
+// key paths it marks as sensitive and the options of data that change how it
+// is stored, or nil if they can't be encoded.
+func outputHash(data *customCrudResourceModel, output map[string]interface{}, sensitive []string) []byte {
+	raw, err := json.Marshal(map[string]interface{}{
+		"output":         output,
+		"sensitive":      sensitive,
+		"merge_strategy": data.MergeStrategy.ValueString(),
+		"read_mode":      data.ReadMode.ValueString(),
+	})
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(raw)
+	value, _ := json.Marshal(hex.EncodeToString(sum[:]))
+	return value
+}
+
+// storeOutputHash records the hash of the hook output stored in data.
+func storeOutputHash(ctx context.Context, priv privateStateWriter, data *customCrudResourceModel, output map[string]interface{}, sensitive []string, diagnostics *diag.Diagnostics) {
+	diagnostics.Append(priv.SetKey(ctx, outputHashKey, outputHash(data, output, sensitive))...)
+}
+
+// computedInputKey is the private state key holding the configured values of
+// computed_input_keys last applied, so that changing them in the
+// configuration still plans an update while the values the backend populated
+// or normalized don't.
+const computedInputKey = "computed_input"
+
+// computedInputValues returns the JSON encoded values of keys in input.
+func computedInputValues(input map[string]interface{}, keys []string) []byte {
+	values := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		if v, ok := input[k]; ok {
+			values[k] = v
+		}
 	}
+	raw, _ := json.Marshal(values)
+	return raw
 }
 
-// Helper to extract model from request and append diagnostics.
-func extractModel[T any](ctx context.Context, getFn func(context.Context, any) diag.Diagnostics, diagnostics *diag.Diagnostics) (*T, bool) {
-	var model T
-	*diagnostics = append(*diagnostics, getFn(ctx, &model)...)
-	if diagnostics.HasError() {
-		return nil, false
+// storeComputedInput records the configured values of the computed_input_keys
+// of data once they're applied.
+func storeComputedInput(ctx context.Context, priv privateStateWriter, config tfsdk.Config, data *customCrudResourceModel, diagnostics *diag.Diagnostics) {
+	keys := data.computedInputKeys()
+	var input types.Dynamic
+	if keys != nil {
+		diagnostics.Append(config.GetAttribute(ctx, path.Root("input"), &input)...)
 	}
-	return &model, true
+	inputMap, ok := utils.AttrValueToInterface(input.UnderlyingValue()).(map[string]interface{})
+	if keys == nil || !ok {
+		diagnostics.Append(priv.SetKey(ctx, computedInputKey, nil)...)
+		return
+	}
+	diagnostics.Append(priv.SetKey(ctx, computedInputKey, computedInputValues(inputMap, keys))...)
 }
 
-func (r *customCrudResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
-	utils.WithSemaphore(r.config.Semaphore, func() {
-		plan, ok := extractModel[customCrudResourceModel](ctx, req.Plan.Get, &resp.Diagnostics)
-		if !ok {
+func (r *customCrudResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, profile := r.config.Profiler.Start(ctx, resourceKind, utils.Read)
+	defer profile.Finish()
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, r.config, req.State.GetAttribute), func(ctx context.Context) {
+		profile.Mark(utils.ProfileWait)
+		state, ok := extractModel[customCrudResourceModel](ctx, req.State.Get, &resp.Diagnostics)
+		if !ok || !r.resolveHooksRef(ctx, req.State.Schema, state, &resp.Diagnostics) {
 			return
 		}
-
-		var config customCrudResourceModel
-		resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+		if state.withoutHooks() {
+			tflog.Debug(ctx, "No hooks in state, keeping the state until the configuration gives the resource hooks")
+			return
+		}
+		rewritten := r.rewriteHooks(ctx, state, &resp.Diagnostics)
 		if resp.Diagnostics.HasError() {
 			return
 		}
-
+		if crud, err := getCrudCommands(state, r.targetOS()); err == nil && !crud.defines(utils.Read) {
+			tflog.Debug(ctx, "No read hook, keeping the state as is")
+			if rewritten {
+				resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+			}
+			return
+		}
 		payload := utils.ExecutionPayload{
-			Id:     plan.Id.ValueString(),
-			Input:  utils.MergeDefaultInputs(r.config, r.mergeInputWithWO(plan.Input, config.InputWO)),
-			Output: utils.AttrValueToInterface(plan.Output.UnderlyingValue()),
+			Id:        state.Id.ValueString(),
+			Input:     utils.MergeDefaultInputs(r.config, utils.AttrValueToInterface(state.Input.UnderlyingValue())),
+			Output:    state.storedOutput(),
+			Private:   privateData(ctx, req.Private, &resp.Diagnostics),
+			Phase:     utils.PhaseRefresh,
+			Sensitive: append(state.sensitivePaths(), utils.PrivateKey),
+		}
+		createdAt := r.createdAt(ctx, req.Private, &resp.Diagnostics)
+		delay := time.Duration(state.PostCreateReadDelay.ValueInt64()) * time.Second
+		retries := 0
+		if !createdAt.IsZero() {
+			retries = int(state.PostCreateReadRetries.ValueInt64())
+			if !utils.Sleep(ctx, createdAt.Add(delay).Sub(utils.Now())) {
+				resp.Diagnostics.AddError("Read Cancelled", "Context cancelled while waiting for post_create_read_delay")
+				return
+			}
 		}
-		result, ok := utils.RunCrudScript(ctx, r.config, plan, payload, &resp.Diagnostics, utils.CrudCreate)
+		result, ok := r.readWithRetries(ctx, state, payload, retries, delay, &resp.Diagnostics)
 		if !ok {
+			// Special case: treat configured exit code as resource removed
+			if result != nil && r.config.MissingResourceExitCode != -1 && result.ExitCode == r.config.MissingResourceExitCode {
+				resp.State.RemoveResource(ctx)
+			}
 			return
 		}
-		if id, exists := result.Result["id"]; exists {
-			if idStr, ok := id.(string); ok {
-				plan.Id = types.StringValue(idStr)
-			} else {
-				idStr = fmt.Sprintf("%v", id)
-				plan.Id = types.StringValue(idStr)
+		r.storeHookPrivate(ctx, resp.Private, result.Result, &resp.Diagnostics)
+		hash := outputHash(state, result.Result, result.Sensitive)
+		if prior, _ := req.Private.GetKey(ctx, outputHashKey); hash != nil && bytes.Equal(prior, hash) && !rewritten && state.computedInputKeys() == nil {
+			// The state already holds this output, so skip converting it
+			// again. Computed input keys the apply didn't sync still are
+			tflog.Debug(ctx, "Read returned the stored output, keeping the state as is")
+		} else {
+			resp.Diagnostics.Append(r.applyResult(state, result.Result, result.Sensitive)...)
+			resp.Diagnostics.Append(r.syncComputedInput(state, result.Result)...)
+			if state.ReadMode.ValueString() == readModeReplace {
+				input, diags := pruneInput(state.Input, result.Result, state.MergeStrategy.ValueString() == mergeStrategyDeep, state.computedInputKeys())
+				resp.Diagnostics.Append(diags...)
+				state.Input = input
+			}
+			if resp.Diagnostics.HasError() {
+				return
 			}
+			resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+			resp.Diagnostics.Append(resp.Private.SetKey(ctx, outputHashKey, hash)...)
 		}
-		if plan.Id.IsNull() || plan.Id.ValueString() == "" {
-			resp.Diagnostics.AddError(
-				"Create Execution Error",
-				fmt.Sprintf("Create script must return an 'id' field\nExit Code: %d\nStdout: %s\nStderr: %s\nInput Payload: %s", result.ExitCode, result.Stdout, result.Stderr, result.Payload),
-			)
-			return
+		// Resources created before identity support get one on their next read
+		if resp.Identity != nil && resp.Identity.Raw.IsFullyNull() {
+			setIdentity(ctx, resp.Identity, state, &resp.Diagnostics)
+		}
+		if !createdAt.IsZero() {
+			// Only the first successful read after create gets the grace period
+			resp.Diagnostics.Append(resp.Private.SetKey(ctx, createdAtKey, nil)...)
 		}
-		plan.Output = utils.MapToDynamic(result.Result)
-		plan.Input = r.mergeInputWithOutput(plan.Input, result.Result)
-		resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
 	})
 }
 
-func (r *customCrudResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	utils.WithSemaphore(r.config.Semaphore, func() {
-		state, ok := extractModel[customCrudResourceModel](ctx, req.State.Get, &resp.Diagnostics)
+// rewriteHooks applies the provider hook_path_rewrites to the hooks stored in
+// state, reporting whether they changed.
+func (r *customCrudResource) rewriteHooks(ctx context.Context, data *customCrudResourceModel, diagnostics *diag.Diagnostics) bool {
+	hooks := data.Hooks
+	if !data.Hook.IsNull() {
+		hooks = data.GetHooks()
+	}
+	rewritten, changed := r.config.HookRewrites.Apply(ctx, hooks)
+	if !changed {
+		return false
+	}
+	tflog.Info(ctx, "Rewrote the hooks stored in state with hook_path_rewrites")
+	if !data.Hook.IsNull() {
+		elements := rewritten.Elements()
+		var hook types.Object
+		ok := len(elements) == 1
+		if ok {
+			hook, ok = elements[0].(types.Object)
+		}
 		if !ok {
-			return
+			diagnostics.AddAttributeError(path.Root("hook"), "Invalid Rewritten Hooks",
+				fmt.Sprintf("Rewriting the hook with hook_path_rewrites returned %d elements instead of one object.", len(elements)))
+			return false
 		}
-		payload := utils.ExecutionPayload{
-			Id:     state.Id.ValueString(),
-			Input:  utils.MergeDefaultInputs(r.config, utils.AttrValueToInterface(state.Input.UnderlyingValue())),
-			Output: utils.AttrValueToInterface(state.Output.UnderlyingValue()),
+		data.Hook = hook
+	} else {
+		data.Hooks = rewritten
+	}
+	return true
+}
+
+// readWithRetries runs the read hook, running it again up to retries times
+// after delay while it reports the resource as missing. Retried reads
+// receive the retry in their payload.
+func (r *customCrudResource) readWithRetries(ctx context.Context, state *customCrudResourceModel, payload utils.ExecutionPayload, retries int, delay time.Duration, diagnostics *diag.Diagnostics) (*utils.ExecutionResult, bool) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			payload.Retry = &utils.RetryInfo{Attempt: attempt, MaxAttempts: retries, BackoffSeconds: delay.Seconds()}
 		}
-		result, ok := utils.RunCrudScript(ctx, r.config, state, payload, &resp.Diagnostics, utils.CrudRead)
-		if !ok {
-			// Special case: treat configured exit code as resource removed
-			if result != nil && r.config.MissingResourceExitCode != -1 && result.ExitCode == r.config.MissingResourceExitCode {
-				resp.State.RemoveResource(ctx)
-			}
-			return
+		var diags diag.Diagnostics
+		result, ok := r.config.ReadCache.Read(state.SharedReadKey.ValueString(), func() (*utils.ExecutionResult, bool) {
+			return utils.RunCrudScript(ctx, r.configFor(ctx, state), state, payload, &diags, utils.CrudRead)
+		})
+		missing := !ok && result != nil && r.config.MissingResourceExitCode != -1 && result.ExitCode == r.config.MissingResourceExitCode
+		if !missing || attempt >= retries {
+			diagnostics.Append(diags...)
+			return result, ok
 		}
-		state.Output = utils.MapToDynamic(result.Result)
-		state.Input = r.mergeInputWithOutput(state.Input, result.Result)
-		resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
-	})
+		tflog.Info(ctx, "Newly created resource not found yet, retrying read", map[string]interface{}{
+			"id":      state.Id.ValueString(),
+			"attempt": attempt + 1,
+			"retries": retries,
+		})
+		if !utils.Sleep(ctx, delay) {
+			diagnostics.AddError("Read Cancelled", "Context cancelled while waiting to retry read")
+			return nil, false
+		}
+	}
 }
 
 func (r *customCrudResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	utils.WithSemaphore(r.config.Semaphore, func() {
+	ctx, profile := r.config.Profiler.Start(ctx, resourceKind, utils.Update)
+	defer profile.Finish()
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, r.config, req.Plan.GetAttribute), func(ctx context.Context) {
+		profile.Mark(utils.ProfileWait)
 		plan, ok := extractModel[customCrudResourceModel](ctx, req.Plan.Get, &resp.Diagnostics)
-		if !ok {
+		if !ok || !r.resolveHooksRef(ctx, req.Plan.Schema, plan, &resp.Diagnostics) {
 			return
 		}
 		state, ok := extractModel[customCrudResourceModel](ctx, req.State.Get, &resp.Diagnostics)
@@ -288,22 +2399,52 @@ func (r *customCrudResource) Update(ctx context.Context, req resource.UpdateRequ
 		}
 
 		payload := utils.ExecutionPayload{
-			Id:     plan.Id.ValueString(),
-			Input:  utils.MergeDefaultInputs(r.config, r.mergeInputWithWO(plan.Input, config.InputWO)),
-			Output: utils.AttrValueToInterface(state.Output.UnderlyingValue()),
-		}
-		// Only run crud script if input has changed, hook changes shouldn't trigger execution
-		if state.Input.Equal(plan.Input) {
-			tflog.Info(ctx, "Hook-only change, skipping update execution")
-			plan.Input = state.Input
-			plan.Output = state.Output
+			Id:        plan.Id.ValueString(),
+			Input:     utils.MergeDefaultInputs(r.config, r.mergeInputWithWO(plan.Input, config.InputWO)),
+			Output:    state.storedOutput(),
+			Private:   privateData(ctx, req.Private, &resp.Diagnostics),
+			Phase:     utils.PhaseApply,
+			Sensitive: append(state.sensitivePaths(), utils.PrivateKey),
+		}
+		// Only run crud script if input has changed, hook changes shouldn't
+		// trigger execution unless script_change is update
+		r.planScriptHash(plan)
+		skipped := skipsOperation(ctx, plan, utils.Update)
+		pending := updateSkipped(ctx, req.Private, &resp.Diagnostics)
+		if (state.Input.Equal(plan.Input) && !scriptRerun(state, plan) && !pending) || skipped {
+			if skipped {
+				tflog.Warn(ctx, "Update listed in skip_operations, keeping the prior output")
+				// Input is stored as configured, so the skipped change is
+				// recorded to run the update once it's no longer skipped
+				if pending || !state.Input.Equal(plan.Input) || scriptRerun(state, plan) {
+					resp.Diagnostics.Append(resp.Private.SetKey(ctx, skippedUpdateKey, []byte("true"))...)
+				}
+			} else {
+				tflog.Info(ctx, "Hook-only change, skipping update execution")
+				plan.Input = state.Input
+			}
+			var sensitive []string
+			if !state.SensitiveOutput.ValueBool() {
+				sensitive = state.sensitivePaths()
+			}
+			output, _ := state.storedOutput().(map[string]interface{})
+			resp.Diagnostics.Append(plan.storeOutput(output, sensitive, r.config.CollectionTyping)...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
 			resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+			setIdentity(ctx, resp.Identity, plan, &resp.Diagnostics)
 			return
 		}
-		result, ok := utils.RunCrudScript(ctx, r.config, plan, payload, &resp.Diagnostics, utils.CrudUpdate)
+		result, ok := utils.RunCrudScript(ctx, r.configFor(ctx, plan), plan, payload, &resp.Diagnostics, utils.CrudUpdate)
+		r.config.ReadCache.Forget(plan.SharedReadKey.ValueString())
 		if !ok {
+			r.onFailure(ctx, plan, payload, utils.CrudUpdate, result, &resp.Diagnostics)
 			return
 		}
+		if pending {
+			resp.Diagnostics.Append(resp.Private.SetKey(ctx, skippedUpdateKey, nil)...)
+		}
 		if id, exists := result.Result["id"]; exists {
 			if idStr, ok := id.(string); ok {
 				plan.Id = types.StringValue(idStr)
@@ -314,37 +2455,134 @@ func (r *customCrudResource) Update(ctx context.Context, req resource.UpdateRequ
 		} else {
 			plan.Id = state.Id
 		}
-		plan.Output = utils.MapToDynamic(result.Result)
-		plan.Input = r.mergeInputWithOutput(plan.Input, result.Result)
+		r.storeHookPrivate(ctx, resp.Private, result.Result, &resp.Diagnostics)
+		resp.Diagnostics.Append(r.applyResult(plan, result.Result, result.Sensitive)...)
+		checkStableOutput(state, plan, stringList(ctx, plan.StableOutputKeys), &resp.Diagnostics)
+		storeOutputHash(ctx, resp.Private, plan, result.Result, result.Sensitive, &resp.Diagnostics)
+		storeComputedInput(ctx, resp.Private, req.Config, plan, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			r.onFailure(ctx, plan, resultPayload(plan, payload, result), utils.CrudUpdate, result, &resp.Diagnostics)
+			return
+		}
+		if !r.verifyOutput(ctx, plan, payload, result, &resp.Diagnostics) || !r.waitReady(ctx, plan, payload, result, &resp.Diagnostics) {
+			r.onFailure(ctx, plan, resultPayload(plan, payload, result), utils.CrudUpdate, result, &resp.Diagnostics)
+		}
 		resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+		setIdentity(ctx, resp.Identity, plan, &resp.Diagnostics)
 	})
 }
 
 func (r *customCrudResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	utils.WithSemaphore(r.config.Semaphore, func() {
+	ctx, profile := r.config.Profiler.Start(ctx, resourceKind, utils.Delete)
+	defer profile.Finish()
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, r.config, req.State.GetAttribute), func(ctx context.Context) {
+		profile.Mark(utils.ProfileWait)
 		data, ok := extractModel[customCrudResourceModel](ctx, req.State.Get, &resp.Diagnostics)
 		if !ok {
 			return
 		}
+		if skipsOperation(ctx, data, utils.Delete) {
+			tflog.Warn(ctx, "Delete listed in skip_operations, removing the resource from state only")
+			return
+		}
+		if !r.resolveHooksRef(ctx, req.State.Schema, data, &resp.Diagnostics) {
+			return
+		}
+		if data.withoutHooks() {
+			tflog.Warn(ctx, "No hooks in state, removing the resource from state only")
+			return
+		}
+		if r.rewriteHooks(ctx, data, &resp.Diagnostics); resp.Diagnostics.HasError() {
+			return
+		}
+		if crud, err := getCrudCommands(data, r.targetOS()); err == nil && !crud.defines(utils.Delete) {
+			tflog.Info(ctx, "No delete hook, removing the resource from state only")
+			return
+		}
 		payload := utils.ExecutionPayload{
-			Id:     data.Id.ValueString(),
-			Input:  utils.MergeDefaultInputs(r.config, utils.AttrValueToInterface(data.Input.UnderlyingValue())),
-			Output: utils.AttrValueToInterface(data.Output.UnderlyingValue()),
+			Id:        data.Id.ValueString(),
+			Input:     utils.MergeDefaultInputs(r.config, utils.AttrValueToInterface(data.Input.UnderlyingValue())),
+			Output:    data.storedOutput(),
+			Private:   privateData(ctx, req.Private, &resp.Diagnostics),
+			Phase:     utils.PhaseDestroy,
+			Sensitive: append(data.sensitivePaths(), utils.PrivateKey),
+		}
+		_, ok = utils.RunCrudScript(ctx, r.configFor(ctx, data), data, payload, &resp.Diagnostics, utils.CrudDelete)
+		r.config.ReadCache.Forget(data.SharedReadKey.ValueString())
+		if ok && !data.waitAttribute("delete_timeout").IsNull() {
+			r.verifyDeleted(ctx, data, payload, &resp.Diagnostics)
 		}
-		_, _ = utils.RunCrudScript(ctx, r.config, data, payload, &resp.Diagnostics, utils.CrudDelete)
 	})
 }
 
+// verifyDeleted polls the read hook after delete until it reports the object
+// missing, failing when it's still found after the delete_timeout of the wait
+// block.
+func (r *customCrudResource) verifyDeleted(ctx context.Context, data *customCrudResourceModel, payload utils.ExecutionPayload, diagnostics *diag.Diagnostics) {
+	config := r.configFor(ctx, data)
+	// An apply hook standing in for read would converge the object again
+	// rather than report it missing
+	if attrs, err := hookAttributes(data, r.targetOS()); err != nil || !utils.DefinesHook(attrs, utils.Read) || config.MissingResourceExitCode == -1 {
+		diagnostics.AddWarning("Delete Not Verified",
+			"wait.delete_timeout needs a read hook of its own, not the apply hook, and a missing_resource_exit_code to check that the object is gone, so the delete isn't verified.")
+		return
+	}
+	timeout, err := utils.ParseDuration(data.waitAttribute("delete_timeout"), 0)
+	if err != nil {
+		diagnostics.AddError("Invalid Wait Delete Timeout", err.Error())
+		return
+	}
+	interval, err := utils.ParseDuration(data.waitAttribute("interval"), utils.DefaultWaitInterval)
+	if err != nil {
+		diagnostics.AddError("Invalid Wait Interval", err.Error())
+		return
+	}
+	deadline := utils.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		var diags diag.Diagnostics
+		result, ok := utils.RunCrudScript(ctx, config, data, payload, &diags, utils.CrudRead)
+		if !ok && result != nil && result.ExitCode == config.MissingResourceExitCode {
+			return
+		}
+		tflog.Info(ctx, "Deleted object still found, polling the read hook", map[string]interface{}{
+			"id":      data.Id.ValueString(),
+			"attempt": attempt,
+		})
+		if !utils.Now().Add(interval).Before(deadline) {
+			detail := fmt.Sprintf("The read hook still found %s %s after the delete.", data.Id.ValueString(), timeout)
+			if errs := diags.Errors(); len(errs) > 0 {
+				detail += fmt.Sprintf(" Its last run failed with: %s: %s", errs[0].Summary(), errs[0].Detail())
+			}
+			diagnostics.AddError("Delete Not Verified", detail)
+			return
+		}
+		if !utils.Sleep(ctx, interval) {
+			diagnostics.AddError("Delete Not Verified", "Context cancelled while waiting for the object to be gone")
+			return
+		}
+	}
+}
+
 type importStateData struct {
-	Id     string                 `json:"id"`
-	Hooks  map[string]string      `json:"hooks"`
-	Input  map[string]interface{} `json:"input"`
-	Output map[string]interface{} `json:"output"`
+	Id        string                 `json:"id"`
+	Hooks     map[string]string      `json:"hooks"`
+	HooksRef  string                 `json:"hooks_ref"`
+	HooksDir  string                 `json:"hooks_dir"`
+	HooksFile string                 `json:"hooks_file"`
+	Input     map[string]interface{} `json:"input"`
+	Output    map[string]interface{} `json:"output"`
 }
 
 func (r *customCrudResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
 	var importData importStateData
-	if err := json.Unmarshal([]byte(req.ID), &importData); err != nil {
+	if req.ID == "" && req.Identity != nil {
+		var identity customCrudIdentityModel
+		resp.Diagnostics.Append(req.Identity.Get(ctx, &identity)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		importData = identity.importData()
+	} else if err := utils.DecodeJSON([]byte(req.ID), &importData); err != nil {
 		resp.Diagnostics.AddError("Invalid Import JSON", fmt.Sprintf("Failed to parse import JSON: %v. Import ID must be a JSON string containing id, hooks, input, and output fields.", err))
 		return
 	}
@@ -354,71 +2592,80 @@ func (r *customCrudResource) ImportState(ctx context.Context, req resource.Impor
 		return
 	}
 
-	if len(importData.Hooks) < 3 {
-		resp.Diagnostics.AddError("Invalid Import JSON", "Import JSON must contain hooks with at least create, read, and delete commands")
-		return
-	}
-
-	hooksAttrs := map[string]attr.Value{
-		utils.Create: types.StringValue(importData.Hooks[utils.Create]),
-		utils.Read:   types.StringValue(importData.Hooks[utils.Read]),
-		utils.Delete: types.StringValue(importData.Hooks[utils.Delete]),
+	var hooksList types.List
+	var diags diag.Diagnostics
+	references := 0
+	for _, reference := range []string{importData.HooksRef, importData.HooksDir, importData.HooksFile} {
+		if reference != "" {
+			references++
+		}
 	}
-
-	// Add update command if provided
-	if updateCmd, ok := importData.Hooks[utils.Update]; ok {
-		hooksAttrs[utils.Update] = types.StringValue(updateCmd)
+	if references > 0 {
+		if len(importData.Hooks) > 0 || references > 1 {
+			resp.Diagnostics.AddError("Invalid Import JSON", "Import JSON must contain only one of hooks, hooks_ref, hooks_dir and hooks_file")
+			return
+		}
+		hooksList, diags = emptyHooks(ctx, resp.State.Schema)
 	} else {
-		hooksAttrs[utils.Update] = types.StringNull()
-	}
-
-	hooksType := map[string]attr.Type{
-		utils.Create: types.StringType,
-		utils.Read:   types.StringType,
-		utils.Update: types.StringType,
-		utils.Delete: types.StringType,
+		if importData.Hooks[utils.Create] == "" && importData.Hooks[utils.Apply] == "" {
+			resp.Diagnostics.AddError("Invalid Import JSON", "Import JSON must contain hooks with at least a create or apply command, a hooks_ref, a hooks_dir or a hooks_file")
+			return
+		}
+		hooksList, diags = importHooks(ctx, resp.State.Schema, importData.Hooks)
 	}
-	hooksObj, diags := types.ObjectValue(
-		hooksType,
-		hooksAttrs,
-	)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	hooksList, diags := types.ListValue(
-		types.ObjectType{
-			AttrTypes: hooksType,
-		},
-		[]attr.Value{hooksObj},
-	)
-	resp.Diagnostics.Append(diags...)
-	if resp.Diagnostics.HasError() {
-		return
+	data := nullResourceModel()
+	data.Id = types.StringValue(importData.Id)
+	data.Hooks = hooksList
+	if importData.HooksRef != "" {
+		data.HooksRef = types.StringValue(importData.HooksRef)
 	}
-
-	data := customCrudResourceModel{
-		Id:    types.StringValue(importData.Id),
-		Hooks: hooksList,
+	if importData.HooksDir != "" {
+		data.HooksDir = types.StringValue(importData.HooksDir)
+	}
+	if importData.HooksFile != "" {
+		data.HooksFile = types.StringValue(importData.HooksFile)
+	}
+	if !r.resolveHooksRef(ctx, resp.State.Schema, &data, &resp.Diagnostics) {
+		return
 	}
 
 	if importData.Input != nil {
-		data.Input = utils.MapToDynamic(importData.Input)
+		input, diags := utils.MapToDynamic(importData.Input)
+		resp.Diagnostics.Append(diags...)
+		data.Input = input
 	}
 
 	if importData.Output != nil {
-		data.Output = utils.MapToDynamic(importData.Output)
+		output, diags := utils.MapToDynamicWithTyping(importData.Output, r.config.CollectionTyping)
+		resp.Diagnostics.Append(diags...)
+		data.Output = output
+	}
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	payload := utils.ExecutionPayload{
-		Id:     importData.Id,
-		Input:  utils.MergeDefaultInputs(r.config, importData.Input),
-		Output: importData.Output,
+		Id:        importData.Id,
+		Input:     utils.MergeDefaultInputs(r.config, importData.Input),
+		Output:    importData.Output,
+		Phase:     utils.PhaseRefresh,
+		Sensitive: []string{utils.PrivateKey},
 	}
 
-	// Use read to populate the state
-	result, ok := utils.RunCrudScript(ctx, r.config, &data, payload, &resp.Diagnostics, utils.CrudRead)
+	// Use read to populate the state, unless a dedicated import hook resolves the id
+	op := utils.CrudRead
+	if crud, err := getCrudCommands(&data, r.targetOS()); err == nil && crud.defines(utils.Import) {
+		op = utils.CrudImport
+	} else if err == nil && !crud.defines(utils.Read) {
+		resp.Diagnostics.AddError("Import Not Supported", "Importing needs a read or import hook to look the resource up, and the hooks have neither.")
+		return
+	}
+	result, ok := utils.RunCrudScript(ctx, r.configFor(ctx, &data), &data, payload, &resp.Diagnostics, op)
 	if !ok {
 		return
 	}
@@ -428,42 +2675,382 @@ func (r *customCrudResource) ImportState(ctx context.Context, req resource.Impor
 		return
 	}
 
-	outputValue := utils.MapToDynamic(result.Result)
-	data.Output = outputValue
-	data.Input = r.mergeInputWithOutput(data.Input, result.Result)
+	output := result.Result
+	if op == utils.CrudImport {
+		if output, ok = seedState(&data, result.Result, utils.Import, &resp.Diagnostics); !ok {
+			return
+		}
+	}
+	r.storeHookPrivate(ctx, resp.Private, result.Result, &resp.Diagnostics)
+	// Configuration generated for the import needs an input, so a missing
+	// one is derived from the output
+	if data.Input.IsNull() {
+		input, diags := utils.MapToDynamic(importedInput(output, result.Sensitive))
+		resp.Diagnostics.Append(diags...)
+		data.Input = input
+	}
+	resp.Diagnostics.Append(r.applyResult(&data, output, result.Sensitive)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	setIdentity(ctx, resp.Identity, &data, &resp.Diagnostics)
 }
 
-func (r *customCrudResource) mergeInputWithOutput(input types.Dynamic, output map[string]interface{}) types.Dynamic {
-	if input.IsNull() || input.IsUnknown() {
-		return input
+// nullResourceModel returns a model with every optional attribute null, the
+// base of the states built by imports and moves.
+func nullResourceModel() customCrudResourceModel {
+	return customCrudResourceModel{
+		SortOutputLists:        types.ListNull(types.StringType),
+		StableOutputKeys:       types.ListNull(types.StringType),
+		ExpectedOutputKeys:     types.ListNull(types.StringType),
+		SharedReadKey:          types.StringNull(),
+		IgnoreOutputKeys:       types.ListNull(types.StringType),
+		ComputedInputKeys:      types.ListNull(types.StringType),
+		OutputSchema:           types.StringNull(),
+		InputSchema:            types.StringNull(),
+		OutputValidationSchema: types.StringNull(),
+		ReplaceOnChange:        types.ListNull(types.StringType),
+		Triggers:               types.MapNull(types.StringType),
+		MergeStrategy:          types.StringNull(),
+		ReadMode:               types.StringNull(),
+		PostCreateReadDelay:    types.Int64Null(),
+		PostCreateReadRetries:  types.Int64Null(),
+		SkipOperations:         types.ListNull(types.StringType),
+		SkipAction:             types.StringNull(),
+		HooksRef:               types.StringNull(),
+		UpdateStrategy:         types.StringNull(),
+		TransactionGroup:       types.StringNull(),
+		HooksDir:               types.StringNull(),
+		HooksFile:              types.StringNull(),
+		Hook:                   types.ObjectNull(hookAttributeType().AttrTypes),
+		HashHookFiles:          types.BoolNull(),
+		ScriptChange:           types.StringNull(),
+		Wait:                   types.ListNull(waitBlockType()),
 	}
+}
 
-	// Convert input to map[string]interface{} via JSON marshaling/unmarshaling
-	inputMap := utils.AttrValueToInterface(input.UnderlyingValue())
-	inputMapTyped, ok := inputMap.(map[string]interface{})
+// waitBlockType returns the object type of the wait block.
+func waitBlockType() types.ObjectType {
+	return types.ObjectType{AttrTypes: map[string]attr.Type{"timeout": types.StringType, "interval": types.StringType, "delete_timeout": types.StringType}}
+}
+
+// waitAttribute returns the named attribute of the wait block, null when
+// there is no wait block.
+func (m *customCrudResourceModel) waitAttribute(name string) types.String {
+	if elements := m.Wait.Elements(); len(elements) > 0 {
+		if wait, ok := elements[0].(types.Object); ok {
+			if value, ok := wait.Attributes()[name].(types.String); ok {
+				return value
+			}
+		}
+	}
+	return types.StringNull()
+}
+
+// hookAttributeType returns the object type of the hook attribute.
+func hookAttributeType() types.ObjectType {
+	return schema.SingleNestedAttribute{Attributes: hooksAttributes()}.GetType().(types.ObjectType)
+}
+
+// seedState sets the id and input of data from the result of an import or
+// upgrade hook, {"id": ..., "input": {...}, "output": {...}}, and returns the
+// output to store. The id and input of data are kept when the hook doesn't
+// print them.
+func seedState(data *customCrudResourceModel, result map[string]interface{}, hook string, diagnostics *diag.Diagnostics) (map[string]interface{}, bool) {
+	name := cases.Title(language.English).String(hook)
+	summary := name + " Hook Failed"
+	if id, exists := result["id"]; exists && id != nil {
+		idStr := fmt.Sprintf("%v", id)
+		if idStr == "" {
+			diagnostics.AddError(summary, name+" hook returned an empty 'id'")
+			return nil, false
+		}
+		data.Id = types.StringValue(idStr)
+	}
+	if raw, exists := result["input"]; exists && raw != nil {
+		input, ok := raw.(map[string]interface{})
+		if !ok {
+			diagnostics.AddError(summary, fmt.Sprintf("%s hook 'input' must be an object, got %T", name, raw))
+			return nil, false
+		}
+		value, diags := utils.MapToDynamic(input)
+		diagnostics.Append(diags...)
+		data.Input = value
+	}
+	output := map[string]interface{}{}
+	if raw, exists := result["output"]; exists && raw != nil {
+		var ok bool
+		if output, ok = raw.(map[string]interface{}); !ok {
+			diagnostics.AddError(summary, fmt.Sprintf("%s hook 'output' must be an object, got %T", name, raw))
+			return nil, false
+		}
+	}
+	return output, !diagnostics.HasError()
+}
+
+// importedInput returns the input of a resource imported without one: its
+// output without the id and the values marked as sensitive, which don't
+// belong in configuration.
+func importedInput(output map[string]interface{}, sensitive []string) map[string]interface{} {
+	public, _ := utils.SplitSensitive(output, sensitive)
+	input := make(map[string]interface{}, len(public))
+	for k, v := range public {
+		if k != "id" {
+			input[k] = v
+		}
+	}
+	return input
+}
+
+// importData converts an import identity into the equivalent import JSON.
+func (m customCrudIdentityModel) importData() importStateData {
+	hooks := map[string]string{}
+	for name, value := range map[string]types.String{
+		utils.Create: m.Create,
+		utils.Read:   m.Read,
+		utils.Update: m.Update,
+		utils.Delete: m.Delete,
+	} {
+		if !value.IsNull() && !value.IsUnknown() {
+			hooks[name] = value.ValueString()
+		}
+	}
+	return importStateData{
+		Id:    m.Id.ValueString(),
+		Hooks: hooks,
+	}
+}
+
+// schemaTypeReader is implemented by the framework schema held in tfsdk.State.
+type schemaTypeReader interface {
+	TypeAtPath(context.Context, path.Path) (attr.Type, diag.Diagnostics)
+}
+
+// emptyHooks returns a hooks block list without blocks, as stored for
+// resources using hooks_ref.
+func emptyHooks(ctx context.Context, s schemaTypeReader) (types.List, diag.Diagnostics) {
+	hooksType, diags := s.TypeAtPath(ctx, path.Root("hooks").AtListIndex(0))
+	if diags.HasError() {
+		return types.ListNull(types.ObjectType{}), diags
+	}
+	return types.ListValue(hooksType, []attr.Value{})
+}
+
+// importHooks builds the hooks block from the import JSON. Attributes missing
+// from the import are set to null, using the schema to keep the block type in
+// sync with the hooks block definition.
+func importHooks(ctx context.Context, s schemaTypeReader, hooks map[string]string) (types.List, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	hooksType, d := s.TypeAtPath(ctx, path.Root("hooks").AtListIndex(0))
+	diags.Append(d...)
+	if diags.HasError() {
+		return types.ListNull(types.ObjectType{}), diags
+	}
+	objType, ok := hooksType.(types.ObjectType)
 	if !ok {
-		return input
+		diags.AddError("Invalid Hooks Schema", "hooks block element is not an object")
+		return types.ListNull(types.ObjectType{}), diags
 	}
 
-	merged := make(map[string]interface{})
-	for k, v := range inputMapTyped {
-		merged[k] = v
+	hooksAttrs := make(map[string]attr.Value, len(objType.AttrTypes))
+	for name, attrType := range objType.AttrTypes {
+		if value, ok := hooks[name]; ok && attrType.Equal(types.StringType) {
+			hooksAttrs[name] = types.StringValue(value)
+			continue
+		}
+		nullValue, err := attrType.ValueFromTerraform(ctx, tftypes.NewValue(attrType.TerraformType(ctx), nil))
+		if err != nil {
+			diags.AddError("Invalid Hooks Schema", fmt.Sprintf("failed to build null value for hooks attribute %s: %v", name, err))
+			return types.ListNull(objType), diags
+		}
+		hooksAttrs[name] = nullValue
+	}
+
+	hooksObj, d := types.ObjectValue(objType.AttrTypes, hooksAttrs)
+	diags.Append(d...)
+	if diags.HasError() {
+		return types.ListNull(objType), diags
 	}
+	hooksList, d := types.ListValue(objType, []attr.Value{hooksObj})
+	diags.Append(d...)
+	return hooksList, diags
+}
 
-	// Update input values with matching output keys
+// mergeOutputKeys returns input with its values replaced by the output values
+// of the same keys. With deep set, objects found in both are merged the same
+// way instead of being replaced. Encrypted input values are kept, so the
+// plaintext a hook returns for them never reaches input.
+func mergeOutputKeys(input, output map[string]interface{}, deep bool) map[string]interface{} {
+	merged := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		merged[k] = v
+	}
 	for k, v := range output {
-		if _, exists := merged[k]; exists {
-			merged[k] = v
+		existing, exists := merged[k]
+		if !exists {
+			continue
 		}
+		if s, ok := existing.(string); ok && utils.IsEncrypted(s) {
+			continue
+		}
+		if deep {
+			inputObject, inputOk := existing.(map[string]interface{})
+			outputObject, outputOk := v.(map[string]interface{})
+			if inputOk && outputOk {
+				merged[k] = mergeOutputKeys(inputObject, outputObject, true)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// computedInputKeys returns the input keys synced from hook output, or nil
+// if every input key is.
+func (m *customCrudResourceModel) computedInputKeys() []string {
+	if m.ComputedInputKeys.IsNull() || m.ComputedInputKeys.IsUnknown() {
+		return nil
+	}
+	keys := []string{}
+	for _, elem := range m.ComputedInputKeys.Elements() {
+		if key, ok := elem.(types.String); ok && !key.IsNull() && !key.IsUnknown() {
+			keys = append(keys, key.ValueString())
+		}
+	}
+	return keys
+}
+
+// pruneInput drops the keys of input that output doesn't have, recursing into
+// objects found in both when deep is set. When keys is non-nil only those
+// top-level keys can be dropped.
+func pruneInput(input types.Dynamic, output map[string]interface{}, deep bool, keys []string) (types.Dynamic, diag.Diagnostics) {
+	if input.IsNull() || input.IsUnknown() {
+		return input, nil
+	}
+	inputMap, ok := utils.AttrValueToInterface(input.UnderlyingValue()).(map[string]interface{})
+	if !ok {
+		return input, nil
+	}
+	pruned := pruneKeys(inputMap, output, deep)
+	if keys != nil {
+		for k, v := range inputMap {
+			if !slices.Contains(keys, k) {
+				pruned[k] = v
+			}
+		}
+	}
+	value, diags := utils.InterfaceToAttrValueWithTypeHint(pruned, input.UnderlyingValue(), utils.CollectionTypingTuple)
+	return types.DynamicValue(value), diags
+}
+
+func pruneKeys(input, output map[string]interface{}, deep bool) map[string]interface{} {
+	pruned := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		outputValue, exists := output[k]
+		if !exists {
+			continue
+		}
+		if deep {
+			inputObject, inputOk := v.(map[string]interface{})
+			outputObject, outputOk := outputValue.(map[string]interface{})
+			if inputOk && outputOk {
+				v = pruneKeys(inputObject, outputObject, true)
+			}
+		}
+		pruned[k] = v
+	}
+	return pruned
+}
+
+// applyResult stores the hook output on data and syncs matching input keys.
+// Values at the sensitive key paths are stored in output_sensitive. With
+// computed_input_keys set the planned input is kept as is, those keys are
+// only synced by syncComputedInput on refresh.
+func (r *customCrudResource) applyResult(data *customCrudResourceModel, output map[string]interface{}, sensitive []string) diag.Diagnostics {
+	diags := data.storeOutput(output, sensitive, r.config.CollectionTyping)
+	if diags.HasError() || data.computedInputKeys() != nil {
+		return diags
+	}
+	input, d := r.mergeInputWithOutput(data.Input, output, data.MergeStrategy.ValueString())
+	diags.Append(d...)
+	data.Input = input
+	return diags
+}
+
+// syncComputedInput syncs the computed_input_keys found in the hook output
+// into input.
+func (r *customCrudResource) syncComputedInput(data *customCrudResourceModel, output map[string]interface{}) diag.Diagnostics {
+	keys := data.computedInputKeys()
+	if keys == nil {
+		return nil
+	}
+	computed := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		if v, ok := output[k]; ok {
+			computed[k] = v
+		}
+	}
+	input, diags := r.mergeInputWithOutput(data.Input, computed, data.MergeStrategy.ValueString())
+	data.Input = input
+	return diags
+}
+
+// keepComputedInput reports whether the planned input only differs from the
+// prior input at keys, which are still configured as when last applied, so
+// the prior input holding the values the backend populated or normalized can
+// be planned instead of the configured one.
+func keepComputedInput(ctx context.Context, priv PrivateStateReader, prior, planned types.Dynamic, keys []string, diagnostics *diag.Diagnostics) bool {
+	if keys == nil || prior.IsNull() || prior.IsUnknown() || planned.IsNull() || planned.IsUnknown() || prior.Equal(planned) {
+		return false
+	}
+	priorMap, ok := utils.AttrValueToInterface(prior.UnderlyingValue()).(map[string]interface{})
+	if !ok {
+		return false
+	}
+	plannedMap, ok := utils.AttrValueToInterface(planned.UnderlyingValue()).(map[string]interface{})
+	if !ok {
+		return false
+	}
+	configured := computedInputValues(plannedMap, keys)
+	for _, k := range keys {
+		delete(priorMap, k)
+		delete(plannedMap, k)
+	}
+	if !reflect.DeepEqual(priorMap, plannedMap) {
+		return false
+	}
+	// Resources applied before computed_input_keys was set have no record
+	if priv == nil {
+		return true
+	}
+	applied, diags := priv.GetKey(ctx, computedInputKey)
+	diagnostics.Append(diags...)
+	return applied == nil || bytes.Equal(applied, configured)
+}
+
+func (r *customCrudResource) mergeInputWithOutput(input types.Dynamic, output map[string]interface{}, strategy string) (types.Dynamic, diag.Diagnostics) {
+	if input.IsNull() || input.IsUnknown() {
+		return input, nil
+	}
+
+	// Convert input to map[string]interface{} via JSON marshaling/unmarshaling
+	inputMap := utils.AttrValueToInterface(input.UnderlyingValue())
+	inputMapTyped, ok := inputMap.(map[string]interface{})
+	if !ok {
+		return input, nil
 	}
 
+	merged := mergeOutputKeys(inputMapTyped, output, strategy == mergeStrategyDeep)
+
 	// Use type-hinted conversion to preserve Set types from original input
-	return types.DynamicValue(utils.InterfaceToAttrValueWithTypeHint(merged, input.UnderlyingValue()))
+	value, diags := utils.InterfaceToAttrValueWithTypeHint(merged, input.UnderlyingValue(), r.config.CollectionTyping)
+	return types.DynamicValue(value), diags
 }
 
-func (r *customCrudResource) mergeInputWithWO(input types.Dynamic, inputWO types.String) interface{} {
+func (r *customCrudResource) mergeInputWithWO(input types.Dynamic, inputWO types.Dynamic) interface{} {
 	var inputMap map[string]interface{}
 	if !input.IsNull() && !input.IsUnknown() {
 		if m, ok := utils.AttrValueToInterface(input.UnderlyingValue()).(map[string]interface{}); ok {
@@ -479,14 +3066,27 @@ func (r *customCrudResource) mergeInputWithWO(input types.Dynamic, inputWO types
 		merged[k] = v
 	}
 
-	if !inputWO.IsNull() && !inputWO.IsUnknown() {
-		var woMap map[string]interface{}
-		if err := json.Unmarshal([]byte(inputWO.ValueString()), &woMap); err == nil {
-			for k, v := range woMap {
-				merged[k] = v
-			}
-		}
+	for k, v := range writeOnlyInput(inputWO) {
+		merged[k] = v
 	}
 
 	return merged
 }
+
+// writeOnlyInput converts input_wo to a map. Objects are used as is, while
+// strings are decoded as JSON for configurations written before input_wo
+// accepted objects.
+func writeOnlyInput(inputWO types.Dynamic) map[string]interface{} {
+	if inputWO.IsNull() || inputWO.IsUnknown() || inputWO.IsUnderlyingValueNull() || inputWO.IsUnderlyingValueUnknown() {
+		return nil
+	}
+	if str, ok := inputWO.UnderlyingValue().(types.String); ok {
+		var woMap map[string]interface{}
+		if err := json.Unmarshal([]byte(str.ValueString()), &woMap); err != nil {
+			return nil
+		}
+		return woMap
+	}
+	woMap, _ := utils.AttrValueToInterface(inputWO.UnderlyingValue()).(map[string]interface{})
+	return woMap
+}
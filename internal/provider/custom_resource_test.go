@@ -4,20 +4,32 @@
 package provider
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
 )
 
 func TestAccExampleResource(t *testing.T) {
@@ -186,7 +198,7 @@ func TestAccResourceScriptFailures(t *testing.T) {
 		data.Hooks = hooksList
 
 		// Try to delete the resource
-		crud, err := getCrudCommands(&data)
+		crud, err := getCrudCommands(&data, runtime.GOOS)
 		if err != nil {
 			t.Fatalf("Failed to get CRUD commands: %v", err)
 		}
@@ -374,6 +386,68 @@ resource "customcrud" "locktest_serial" {
 	})
 }
 
+func TestAccParallelism_BypassParallelism(t *testing.T) {
+	dir, err := filepath.Abs("test_parallel")
+	if err != nil {
+		t.Fatalf("Failed to resolve test_parallel dir: %v", err)
+	}
+	createScript := filepath.Join(dir, "create.sh")
+	readScript := filepath.Join(dir, "read.sh")
+	deleteScript := filepath.Join(dir, "delete.sh")
+
+	// Bypassing the limit lets both creates run at once and collide on the lock
+	config := fmt.Sprintf(`
+provider "customcrud" {
+  parallelism = 1
+}
+resource "customcrud" "locktest_bypass" {
+  count = 2
+  hooks {
+    bypass_parallelism = true
+    create             = %q
+    read               = %q
+    delete             = %q
+  }
+  input = { name = "lock_parallel_bypass" }
+}
+`, createScript, readScript, deleteScript)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      config,
+				ExpectError: regexp.MustCompile(`(?s)Create Script Failed.*lock \[lock_parallel_bypass\] already held`),
+			},
+		},
+	})
+}
+
+func TestUnitSemaphoreFor(t *testing.T) {
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Semaphore = make(chan struct{}, 1)
+
+	hooksType := map[string]attr.Type{
+		utils.Read:              types.StringType,
+		utils.BypassParallelism: types.BoolType,
+	}
+	hooksFor := func(bypass attr.Value) types.List {
+		obj := types.ObjectValueMust(hooksType, map[string]attr.Value{
+			utils.Read:              types.StringValue("read.sh"),
+			utils.BypassParallelism: bypass,
+		})
+		return types.ListValueMust(types.ObjectType{AttrTypes: hooksType}, []attr.Value{obj})
+	}
+
+	if utils.SemaphoreFor(config, hooksFor(types.BoolNull())) == nil {
+		t.Error("Expected hooks without bypass_parallelism to use the provider semaphore")
+	}
+	if utils.SemaphoreFor(config, hooksFor(types.BoolValue(true))) != nil {
+		t.Error("Expected bypass_parallelism hooks to skip the provider semaphore")
+	}
+}
+
 // Helper function to generate import state ID.
 func testAccResourceImportStateIdFunc(resourceName, importString string, createScript, readScript, updateScript, deleteScript string) resource.ImportStateIdFunc {
 	return func(s *terraform.State) (string, error) {
@@ -606,6 +680,73 @@ resource "customcrud" "test_wo" {
 	})
 }
 
+func TestAccResourceWithInputWOObject(t *testing.T) {
+	createScript := "test_write_only/create.sh"
+	readScript := "test_write_only/read.sh"
+	updateScript := "test_write_only/update.sh"
+	deleteScript := "test_write_only/delete.sh"
+	content := "hidden-object"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+resource "customcrud" "test_wo_object" {
+  hooks {
+    create = %q
+    read   = %q
+    update = %q
+    delete = %q
+  }
+  input_wo = {
+    content = %q
+  }
+}
+`, createScript, readScript, updateScript, deleteScript, content),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("customcrud.test_wo_object", "input_wo"),
+					func(s *terraform.State) error {
+						rs, ok := s.RootModule().Resources["customcrud.test_wo_object"]
+						if !ok {
+							return fmt.Errorf("Not found: customcrud.test_wo_object")
+						}
+						fileContent, err := os.ReadFile(rs.Primary.ID)
+						if err != nil {
+							return fmt.Errorf("Failed to read file %s: %v", rs.Primary.ID, err)
+						}
+						if string(fileContent) != content {
+							return fmt.Errorf("File content '%s' does not match '%s'", string(fileContent), content)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestUnitMergeInputWithWO(t *testing.T) {
+	r := &customCrudResource{}
+	input := toDynamic(t, map[string]interface{}{"name": "app", "password": "placeholder"})
+
+	merged := r.mergeInputWithWO(input, toDynamic(t, map[string]interface{}{"password": "secret"}))
+	if !reflect.DeepEqual(merged, map[string]interface{}{"name": "app", "password": "secret"}) {
+		t.Errorf("Expected object input_wo to be merged, got %v", merged)
+	}
+
+	merged = r.mergeInputWithWO(input, types.DynamicValue(types.StringValue(`{"password": "from-json"}`)))
+	if !reflect.DeepEqual(merged, map[string]interface{}{"name": "app", "password": "from-json"}) {
+		t.Errorf("Expected JSON string input_wo to be merged, got %v", merged)
+	}
+
+	merged = r.mergeInputWithWO(input, types.DynamicNull())
+	if !reflect.DeepEqual(merged, map[string]interface{}{"name": "app", "password": "placeholder"}) {
+		t.Errorf("Expected null input_wo to leave input unchanged, got %v", merged)
+	}
+}
+
 func TestAccResourceWithFloat(t *testing.T) {
 	createScript := "test_precision/create.sh"
 	readScript := "test_precision/read.sh"
@@ -678,6 +819,122 @@ resource "customcrud" "test_float" {
 	})
 }
 
+func TestUnitWindowsOutput(t *testing.T) {
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Executor = &utils.MockExecutor{Stdout: "\xEF\xBB\xBF{\r\n  \"id\": \"vm-1\",\r\n  \"tags\": [\"a\"]\r\n}\r\n"}
+	result, err := utils.Execute(context.Background(), config, []string{"create"}, utils.ExecutionPayload{})
+	if err != nil {
+		t.Fatalf("Expected output with a byte order mark and CRLF line endings to parse, got %v", err)
+	}
+	if result.Result["id"] != "vm-1" {
+		t.Errorf("Expected the id to be parsed, got %v", result.Result)
+	}
+	if strings.HasPrefix(result.Stdout, "\xEF\xBB\xBF") {
+		t.Error("Expected the byte order mark to be stripped from stdout")
+	}
+
+	config.RawOutput = true
+	result, err = utils.Execute(context.Background(), config, []string{"create"}, utils.ExecutionPayload{})
+	if err != nil || result.Result[utils.RawOutputKey] != "{\r\n  \"id\": \"vm-1\",\r\n  \"tags\": [\"a\"]\r\n}\r\n" {
+		t.Errorf("Expected raw output to keep its line endings, got %q, %v", result.Result[utils.RawOutputKey], err)
+	}
+
+	config.RawOutput = false
+	config.Executor = &utils.MockExecutor{Stdout: "\xFF\xFE{\x00}\x00"}
+	if _, err := utils.Execute(context.Background(), config, []string{"create"}, utils.ExecutionPayload{}); err == nil || !strings.Contains(err.Error(), "UTF-16") {
+		t.Errorf("Expected UTF-16 output to be reported, got %v", err)
+	}
+}
+
+func TestUnitCommandTemplates(t *testing.T) {
+	var command []string
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		command = req.Command
+		return &utils.ExecResponse{Stdout: []byte(`{}`)}, nil
+	}}
+	payload := utils.ExecutionPayload{Id: "obj-1", Input: map[string]interface{}{"bucket": "my bucket", "max": json.Number("3")}}
+
+	// Without templates placeholders are passed on as written
+	cmd, _ := utils.SplitCommand(`docker inspect -f '{{.Id}}' obj-1`)
+	if _, err := utils.Execute(context.Background(), config, cmd, payload); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"docker", "inspect", "-f", "{{.Id}}", "obj-1"}; !reflect.DeepEqual(command, expected) {
+		t.Errorf("Expected the command to run verbatim, got %q", command)
+	}
+
+	config.Templates = true
+	cmd, err := utils.SplitTemplateCommandOn(`aws s3api head-object --bucket {{.input.bucket}} --key={{.id}} --max {{ .input.max }} -f '{{"{{"}}.Id}}'`, "linux")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := utils.Execute(context.Background(), config, cmd, payload); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"aws", "s3api", "head-object", "--bucket", "my bucket", "--key=obj-1", "--max", "3", "-f", "{{.Id}}"}
+	if !reflect.DeepEqual(command, expected) {
+		t.Errorf("Expected the placeholders to be rendered per argument, got %q", command)
+	}
+
+	for cmd, want := range map[string]string{
+		"head {{.input.missing}}": `map has no entry for key "missing"`,
+		"head {{.input.bucket":    "unclosed action",
+	} {
+		fields, _ := utils.SplitTemplateCommandOn(cmd, "linux")
+		if _, err := utils.Execute(context.Background(), config, fields, payload); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q, got %v", cmd, want, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		goos     string
+		command  string
+		expected []string
+	}{
+		{"linux", `head --bucket {{ .input.bucket }} '{{ $x := "a b" }}{{ $x }}'`, []string{"head", "--bucket", "{{ .input.bucket }}", `{{ $x := "a b" }}{{ $x }}`}},
+		{"windows", `head.exe --dir "C:\{{ .input.dir }}"`, []string{"head.exe", "--dir", `C:\{{ .input.dir }}`}},
+	} {
+		got, err := utils.SplitTemplateCommandOn(tt.command, tt.goos)
+		if err != nil || !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s %s: expected %q, got %q (%v)", tt.goos, tt.command, tt.expected, got, err)
+		}
+	}
+}
+
+func TestUnitIntegerPrecision(t *testing.T) {
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Executor = &utils.MockExecutor{Stdout: `{"id": 9007199254740993, "serial": 1000000, "ratio": 0.5, "nested": {"big": 123456789012345678901234567890}}`}
+	result, err := utils.Execute(context.Background(), config, []string{"create"}, utils.ExecutionPayload{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if id := fmt.Sprintf("%v", result.Result["id"]); id != "9007199254740993" {
+		t.Errorf("Expected the id to keep every digit, got %s", id)
+	}
+	if serial := fmt.Sprintf("%v", result.Result["serial"]); serial != "1000000" {
+		t.Errorf("Expected integers not to be rendered in exponent form, got %s", serial)
+	}
+	if _, ok := result.Result["ratio"].(float64); !ok {
+		t.Errorf("Expected fractions to stay float64 without high_precision_numbers, got %T", result.Result["ratio"])
+	}
+
+	output := toDynamic(t, result.Result)
+	big := output.UnderlyingValue().(types.Object).Attributes()["nested"].(types.Object).Attributes()["big"].(types.Number)
+	if text := big.ValueBigFloat().Text('f', -1); text != "123456789012345678901234567890" {
+		t.Errorf("Expected the stored number to keep every digit, got %s", text)
+	}
+
+	// Values passed back to hooks keep their digits too
+	payload, err := json.Marshal(utils.AttrValueToInterface(output.UnderlyingValue()))
+	if err != nil {
+		t.Fatalf("Failed to encode payload: %v", err)
+	}
+	if !strings.Contains(string(payload), `"id":9007199254740993`) {
+		t.Errorf("Expected the payload to keep every digit, got %s", payload)
+	}
+}
+
 func TestAccResourceHooksWhitespaceArgs(t *testing.T) {
 	createScript := `test_whitespace_args/create.sh --label="hello world"`
 	readScript := `test_whitespace_args/read.sh --label="hello world"`
@@ -922,3 +1179,4028 @@ resource "customcrud" "test_set" {
 		},
 	})
 }
+
+func TestAccResourceWithWorkingDirectory(t *testing.T) {
+	dir, err := filepath.Abs("test_passthrough")
+	if err != nil {
+		t.Fatalf("Failed to resolve test_passthrough dir: %v", err)
+	}
+
+	t.Run("hooks working_directory", func(t *testing.T) {
+		resource.Test(t, resource.TestCase{
+			PreCheck:                 func() { testAccPreCheck(t) },
+			ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+			Steps: []resource.TestStep{
+				{
+					Config: fmt.Sprintf(`
+resource "customcrud" "test_cwd" {
+  hooks {
+    working_directory = %q
+    create            = "./create.sh"
+    read              = "./read.sh"
+    delete            = "./delete.sh"
+  }
+  input = {
+    name = "cwd-test"
+  }
+}
+`, dir),
+					Check: resource.ComposeAggregateTestCheckFunc(
+						resource.TestCheckResourceAttr("customcrud.test_cwd", "output.name", "cwd-test"),
+					),
+				},
+			},
+		})
+	})
+
+	t.Run("provider working_directory", func(t *testing.T) {
+		resource.Test(t, resource.TestCase{
+			PreCheck:                 func() { testAccPreCheck(t) },
+			ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+			Steps: []resource.TestStep{
+				{
+					Config: fmt.Sprintf(`
+provider "customcrud" {
+  working_directory = %q
+}
+
+resource "customcrud" "test_cwd" {
+  hooks {
+    create = "./create.sh"
+    read   = "./read.sh"
+    delete = "./delete.sh"
+  }
+  input = {
+    name = "provider-cwd-test"
+  }
+}
+`, dir),
+					Check: resource.ComposeAggregateTestCheckFunc(
+						resource.TestCheckResourceAttr("customcrud.test_cwd", "output.name", "provider-cwd-test"),
+					),
+				},
+			},
+		})
+	})
+}
+
+func TestAccResourceStateEvents(t *testing.T) {
+	createScript := "test_state_events/create.sh"
+	createFailScript := "test_state_events/create_fail.sh"
+	readScript := "test_state_events/read.sh"
+	deleteScript := "test_state_events/delete.sh"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccExampleResourceConfig(createFailScript, readScript, "", deleteScript, "events"),
+				ExpectError: regexp.MustCompile(`(?s)Create Script Failed.*Failed to finish provisioning`),
+			},
+			// The partially created resource was saved as tainted and is replaced
+			{
+				Config: testAccExampleResourceConfig(createScript, readScript, "", deleteScript, "events"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("customcrud.test", plancheck.ResourceActionDestroyBeforeCreate),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test", "id", "state-events"),
+					resource.TestCheckResourceAttr("customcrud.test", "output.status", "ready"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitExecuteStateEvents(t *testing.T) {
+	ctx := context.Background()
+	config := utils.CustomCRUDProviderConfigDefaults()
+
+	result, err := utils.Execute(ctx, config, []string{"test_state_events/create.sh"}, utils.ExecutionPayload{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Result["status"] != "ready" {
+		t.Errorf("Expected final result status ready, got %v", result.Result["status"])
+	}
+	if result.State["status"] != "configuring" {
+		t.Errorf("Expected last state event status configuring, got %v", result.State["status"])
+	}
+
+	result, err = utils.Execute(ctx, config, []string{"test_state_events/create_fail.sh"}, utils.ExecutionPayload{})
+	if err == nil {
+		t.Fatal("Expected create to fail")
+	}
+	if result.State["id"] != "state-events" {
+		t.Errorf("Expected state event id to survive failure, got %v", result.State["id"])
+	}
+}
+
+func TestAccResourcePartialCreate(t *testing.T) {
+	createScript := "test_state_events/create.sh"
+	readScript := "test_state_events/read.sh"
+	deleteScript := "test_state_events/delete.sh"
+
+	for _, tt := range []struct {
+		script string
+		err    string
+	}{
+		{"test_state_events/create_partial.sh", `(?s)Create Partially Failed.*Disk attach failed`},
+		{"test_state_events/create_fail_output.sh", `(?s)Create Script Failed.*Failed to finish provisioning`},
+	} {
+		resource.Test(t, resource.TestCase{
+			PreCheck:                 func() { testAccPreCheck(t) },
+			ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+			Steps: []resource.TestStep{
+				{
+					Config:      testAccExampleResourceConfig(tt.script, readScript, "", deleteScript, "events"),
+					ExpectError: regexp.MustCompile(tt.err),
+				},
+				// The allocated resource was saved as tainted and is replaced
+				{
+					Config: testAccExampleResourceConfig(createScript, readScript, "", deleteScript, "events"),
+					ConfigPlanChecks: resource.ConfigPlanChecks{
+						PreApply: []plancheck.PlanCheck{
+							plancheck.ExpectResourceAction("customcrud.test", plancheck.ResourceActionDestroyBeforeCreate),
+						},
+					},
+					Check: resource.ComposeAggregateTestCheckFunc(
+						resource.TestCheckResourceAttr("customcrud.test", "id", "state-events"),
+						resource.TestCheckResourceAttr("customcrud.test", "output.status", "ready"),
+					),
+				},
+			},
+		})
+	}
+}
+
+func TestUnitPartialCreate(t *testing.T) {
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	(&customCrudResource{}).Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	model := nullResourceModel()
+	model.Hooks = hooks
+
+	for _, tt := range []struct {
+		name    string
+		resp    *utils.ExecResponse
+		summary string
+	}{
+		{"partial", &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1", "partial": true, "error": "disk attach failed"}`)}, "Create Partially Failed"},
+		{"failed with output", &utils.ExecResponse{ExitCode: 1, Stdout: []byte(`{"id": "vm-1"}`)}, "Create Script Failed"},
+	} {
+		resp, state := applyCreate(t, &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			if tt.resp.ExitCode != 0 {
+				return tt.resp, fmt.Errorf("exit status %d", tt.resp.ExitCode)
+			}
+			return tt.resp, nil
+		}}, model)
+		if len(resp.Diagnostics) == 0 || resp.Diagnostics[0].Summary != tt.summary {
+			t.Errorf("%s: expected %q, got %s", tt.name, tt.summary, protoDiags(resp.Diagnostics))
+		}
+		var attrs map[string]tftypes.Value
+		var id string
+		if state.IsNull() || state.As(&attrs) != nil || attrs["id"].As(&id) != nil || id != "vm-1" {
+			t.Errorf("%s: expected vm-1 to be saved, got %v", tt.name, state)
+		}
+	}
+}
+
+func TestUnitExecuteFailedOutput(t *testing.T) {
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		return &utils.ExecResponse{
+			Stdout:   []byte(`{"state": {"id": "vm-1", "status": "allocating"}}` + "\n" + `{"id": "vm-1", "status": "attaching"}`),
+			ExitCode: 1,
+		}, fmt.Errorf("exit status 1")
+	}}
+
+	result, err := utils.Execute(context.Background(), config, []string{"./create.sh"}, utils.ExecutionPayload{})
+	if err == nil {
+		t.Fatal("Expected create to fail")
+	}
+	if result.State["id"] != "vm-1" || result.State["status"] != "attaching" {
+		t.Errorf("Expected the output printed before failing as the state, got %v", result.State)
+	}
+}
+
+func TestUnitCreateFailurePersistsState(t *testing.T) {
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	(&customCrudResource{}).Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	model := nullResourceModel()
+	model.Hooks = hooks
+
+	resp, state := applyCreate(t, &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		return &utils.ExecResponse{ExitCode: 1, Stdout: []byte(`{"state": {"id": "vm-1", "status": "allocating"}}`)}, fmt.Errorf("exit status 1")
+	}}, model)
+	if !diagsHaveError(resp.Diagnostics) {
+		t.Fatal("Expected the create to fail")
+	}
+	if state.IsNull() {
+		t.Fatal("Expected the reported state to be saved")
+	}
+	var attrs map[string]tftypes.Value
+	var id string
+	if err := state.As(&attrs); err != nil || attrs["id"].As(&id) != nil || id != "vm-1" {
+		t.Errorf("Expected the reported id vm-1 in state, got %v", state)
+	}
+}
+
+func TestAccResourceYAMLOutput(t *testing.T) {
+	createScript := "test_yaml/create.sh"
+	readScript := "test_yaml/read.sh"
+	deleteScript := "test_yaml/delete.sh"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+resource "customcrud" "test_yaml" {
+  hooks {
+    output_format = "yaml"
+    create        = %q
+    read          = %q
+    delete        = %q
+  }
+  input = {
+    name = "kube"
+  }
+}
+`, createScript, readScript, deleteScript),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_yaml", "id", "yaml-kube"),
+					resource.TestCheckResourceAttr("customcrud.test_yaml", "output.replicas", "3"),
+					resource.TestCheckResourceAttr("customcrud.test_yaml", "output.labels.#", "2"),
+					resource.TestCheckResourceAttr("customcrud.test_yaml", "output.labels.1", "web"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitExecuteYAMLOutput(t *testing.T) {
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.OutputFormat = utils.OutputFormatYAML
+
+	result, err := utils.Execute(context.Background(), config, []string{"test_yaml/create.sh"}, utils.ExecutionPayload{
+		Input: map[string]interface{}{"name": "unit"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Result["id"] != "yaml-unit" {
+		t.Errorf("Expected id yaml-unit, got %v", result.Result["id"])
+	}
+	if labels, ok := result.Result["labels"].([]interface{}); !ok || len(labels) != 2 {
+		t.Errorf("Expected two labels, got %v", result.Result["labels"])
+	}
+}
+
+func TestUnitExecuteDuplicateKeys(t *testing.T) {
+	config := utils.CustomCRUDProviderConfigDefaults()
+	tests := []struct {
+		stdout   string
+		expected string
+	}{
+		{`{"id": "1", "region": "eu", "region": "us"}`, `duplicate key region in script output, first with the value "eu" and then "us"`},
+		{`{"id": "1", "db": {"hosts": [{"name": "a"}, {"name": "b", "name": {"x": 1}}]}}`, `duplicate key db.hosts[1].name in script output, first with the value "b" and then {"x":1}`},
+		{`{"state": {"step": 1, "step": 2}}` + "\n" + `{"id": "1"}`, `duplicate key state.step in script output`},
+		{`{"id": "1", "grid": [[{"k": [1]}], [{"k": 1}, {"k": {"v": [1, 2]}, "k": 2}]]}`, `duplicate key grid[1][1].k in script output, first with the value {"v":[1,2]} and then 2`},
+		{`{"id": "1", "credentials": {"token": "old-s3cr3t", "token": "new-s3cr3t"}, "__sensitive": ["credentials"]}`, `duplicate key credentials.token in script output, first with the value (sensitive value) and then (sensitive value)`},
+	}
+	for _, tt := range tests {
+		config.Executor = &utils.MockExecutor{Stdout: tt.stdout}
+		result, err := utils.Execute(context.Background(), config, []string{"create"}, utils.ExecutionPayload{})
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected %q for %s, got %v", tt.expected, tt.stdout, err)
+			continue
+		}
+		if stdout := result.Mask(result.Stdout); strings.Contains(stdout, "s3cr3t") {
+			t.Errorf("Expected the duplicate sensitive values to be masked, got %s", stdout)
+		}
+	}
+
+	config.Executor = &utils.MockExecutor{Stdout: `{"id": "1", "tags": {"a": 1, "b": 2}, "items": [{"a": 1}, {"a": 2}]}`}
+	if _, err := utils.Execute(context.Background(), config, []string{"create"}, utils.ExecutionPayload{}); err != nil {
+		t.Errorf("Expected keys repeated across objects to be accepted, got %v", err)
+	}
+
+	config.OutputFormat = utils.OutputFormatYAML
+	config.Executor = &utils.MockExecutor{Stdout: "id: 1\nid: 2\n"}
+	if _, err := utils.Execute(context.Background(), config, []string{"create"}, utils.ExecutionPayload{}); err == nil {
+		t.Error("Expected duplicate YAML keys to fail")
+	}
+}
+
+func TestAccResourceSortOutputLists(t *testing.T) {
+	createScript := "test_sort_lists/create.sh"
+	readScript := "test_sort_lists/read.sh"
+	deleteScript := "test_sort_lists/delete.sh"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+resource "customcrud" "test_sort" {
+  hooks {
+    create = %q
+    read   = %q
+    delete = %q
+  }
+  sort_output_lists = ["network.ips", "network.ports"]
+  input = {
+    name = "sorted"
+  }
+}
+`, createScript, readScript, deleteScript),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_sort", "output.network.ips.0", "10.0.0.1"),
+					resource.TestCheckResourceAttr("customcrud.test_sort", "output.network.ips.2", "10.0.0.3"),
+					resource.TestCheckResourceAttr("customcrud.test_sort", "output.network.ports.0", "80"),
+					resource.TestCheckResourceAttr("customcrud.test_sort", "output.network.ports.2", "8080"),
+				),
+			},
+			{
+				// Reads return a different order each time, which must not show up as drift
+				RefreshState: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceIgnoreOutputKeys(t *testing.T) {
+	createScript := "test_ignore_output/create.sh"
+	readScript := "test_ignore_output/read.sh"
+	deleteScript := "test_ignore_output/delete.sh"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+resource "customcrud" "test_ignore" {
+  hooks {
+    create = %q
+    read   = %q
+    delete = %q
+  }
+  ignore_output_keys = ["etag", "metadata.last_seen_at"]
+  input = {
+    name = "ignored"
+  }
+}
+`, createScript, readScript, deleteScript),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_ignore", "output.metadata.owner", "ops"),
+					resource.TestCheckNoResourceAttr("customcrud.test_ignore", "output.etag"),
+					resource.TestCheckNoResourceAttr("customcrud.test_ignore", "output.metadata.last_seen_at"),
+				),
+			},
+			{
+				// Reads return new metadata each time, which must not show up as drift
+				RefreshState: true,
+			},
+		},
+	})
+}
+
+func TestUnitIgnoreOutputKeys(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	data := &customCrudResourceModel{
+		IgnoreOutputKeys: types.ListValueMust(types.StringType, []attr.Value{
+			types.StringValue("etag"),
+			types.StringValue("metadata.last_seen_at"),
+			types.StringValue("missing.key"),
+		}),
+	}
+
+	result, err := utils.Execute(ctx, r.configFor(ctx, data), []string{"test_ignore_output/read.sh"}, utils.ExecutionPayload{
+		Input: map[string]interface{}{"name": "unit"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := result.Result["etag"]; ok {
+		t.Errorf("Expected etag to be dropped, got %v", result.Result)
+	}
+	metadata := result.Result["metadata"].(map[string]interface{})
+	if _, ok := metadata["last_seen_at"]; ok || metadata["owner"] != "ops" {
+		t.Errorf("Expected only metadata.last_seen_at to be dropped, got %v", metadata)
+	}
+	if result.Result["name"] != "unit" {
+		t.Errorf("Expected other keys to be kept, got %v", result.Result)
+	}
+}
+
+func TestUnitSortOutputLists(t *testing.T) {
+	newOutput := func() map[string]interface{} {
+		return map[string]interface{}{
+			"tags":  []interface{}{"web", "app", "db"},
+			"mixed": []interface{}{"b", float64(1), "a"},
+			"network": map[string]interface{}{
+				"ports":   []interface{}{json.Number("8080"), json.Number("443"), json.Number("80")},
+				"enabled": []interface{}{true, false},
+			},
+		}
+	}
+
+	sorted := utils.SortOutputLists(newOutput(), true, nil)
+	if !reflect.DeepEqual(sorted["tags"], []interface{}{"app", "db", "web"}) {
+		t.Errorf("Expected tags to be sorted, got %v", sorted["tags"])
+	}
+	if !reflect.DeepEqual(sorted["mixed"], []interface{}{"b", float64(1), "a"}) {
+		t.Errorf("Expected mixed list to be left alone, got %v", sorted["mixed"])
+	}
+	network := sorted["network"].(map[string]interface{})
+	if !reflect.DeepEqual(network["ports"], []interface{}{json.Number("80"), json.Number("443"), json.Number("8080")}) {
+		t.Errorf("Expected ports to be sorted numerically, got %v", network["ports"])
+	}
+	if !reflect.DeepEqual(network["enabled"], []interface{}{false, true}) {
+		t.Errorf("Expected booleans to be sorted, got %v", network["enabled"])
+	}
+
+	sorted = utils.SortOutputLists(newOutput(), false, []string{"network.ports", "missing.path"})
+	if !reflect.DeepEqual(sorted["tags"], []interface{}{"web", "app", "db"}) {
+		t.Errorf("Expected tags to keep their order, got %v", sorted["tags"])
+	}
+	network = sorted["network"].(map[string]interface{})
+	if !reflect.DeepEqual(network["ports"], []interface{}{json.Number("80"), json.Number("443"), json.Number("8080")}) {
+		t.Errorf("Expected ports to be sorted numerically, got %v", network["ports"])
+	}
+}
+
+func TestAccResourceRawOutput(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "customcrud" "test_raw" {
+  hooks {
+    raw_output = true
+    create     = "echo raw-id"
+    read       = "echo raw-id"
+    delete     = "true"
+  }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_raw", "id", "raw-id"),
+					resource.TestCheckResourceAttr("customcrud.test_raw", "output.raw", "raw-id\n"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitExecuteRawOutput(t *testing.T) {
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.RawOutput = true
+
+	result, err := utils.Execute(context.Background(), config, []string{"printf", "plain text"}, utils.ExecutionPayload{})
+	if err != nil {
+		t.Fatalf("Expected raw output to be accepted, got error: %v", err)
+	}
+	if result.Result[utils.RawOutputKey] != "plain text" {
+		t.Errorf("Expected stdout to be stored verbatim, got %v", result.Result)
+	}
+
+	result, err = utils.Execute(context.Background(), config, []string{"true"}, utils.ExecutionPayload{})
+	if err != nil {
+		t.Fatalf("Expected empty raw output to be accepted, got error: %v", err)
+	}
+	if raw, ok := result.Result[utils.RawOutputKey]; !ok || raw != "" {
+		t.Errorf("Expected empty stdout to be stored as an empty string, got %v", result.Result)
+	}
+}
+
+func TestAccResourcePostCreateReadRetries(t *testing.T) {
+	createScript := "test_read_after_create/create.sh"
+	readScript := "test_read_after_create/read.sh"
+	deleteScript := "test_read_after_create/delete.sh"
+	dir := t.TempDir()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+resource "customcrud" "test_eventual" {
+  hooks {
+    create = %q
+    read   = %q
+    delete = %q
+  }
+  post_create_read_delay   = 1
+  post_create_read_retries = 3
+  input = {
+    dir             = %q
+    invisible_reads = 2
+  }
+}
+`, createScript, readScript, deleteScript, dir),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_eventual", "id", "eventual"),
+				),
+			},
+			{
+				// The first read after create went through the retries, so the resource is still tracked
+				RefreshState: true,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_eventual", "id", "eventual"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitInputSchema(t *testing.T) {
+	hooks := map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Update: "test_passthrough/create.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+	}
+	inputSchema := `{
+		"type": "object",
+		"required": ["name"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "pattern": "^[a-z]+$"},
+			"size": {"type": "integer", "minimum": 1},
+			"ports": {"type": "array", "items": {"type": "integer", "maximum": 65535}, "uniqueItems": true}
+		}
+	}`
+	withSchema := func(prior, planned *customCrudResourceModel) {
+		planned.InputSchema = types.StringValue(inputSchema)
+	}
+	prior := map[string]interface{}{"name": "web"}
+
+	valid := map[string]interface{}{"name": "web", "size": 2, "ports": []interface{}{80, 443}}
+	if diags := planUpdate(t, hooks, prior, valid, withSchema).Diagnostics; diags.HasError() {
+		t.Errorf("Expected valid input to plan, got %v", diags)
+	}
+
+	invalid := map[string]interface{}{"name": "Web", "size": 1.5, "ports": []interface{}{80, 70000}, "extra": true}
+	diags := planUpdate(t, hooks, prior, invalid, withSchema).Diagnostics
+	expected := map[string]bool{"input.name": true, "input.size": true, "input.ports[1]": true, "input.extra": true}
+	if diags.ErrorsCount() != len(expected) {
+		t.Fatalf("Expected an error per violation, got %v", diags)
+	}
+	for _, d := range diags.Errors() {
+		withPath, ok := d.(diag.DiagnosticWithPath)
+		if !ok || !expected[withPath.Path().String()] {
+			t.Errorf("Unexpected error %v", d)
+		}
+	}
+
+	diags = planUpdate(t, hooks, prior, map[string]interface{}{"size": 1}, withSchema).Diagnostics
+	if diags.ErrorsCount() != 1 || !strings.Contains(diags.Errors()[0].Detail(), "required") {
+		t.Errorf("Expected a missing name to be reported, got %v", diags)
+	}
+
+	for _, doc := range []string{`[]`, `{"type": "text"}`, `{"$ref": "#/$defs/name"}`, `{"$ref": "https://example.com/schema.json"}`, `{"minimum": "1"}`, `{"pattern": "("}`} {
+		if _, err := utils.ParseJSONSchema(doc); err == nil {
+			t.Errorf("Expected %s to be rejected", doc)
+		}
+	}
+	// References within the document are resolved
+	schema, err := utils.ParseJSONSchema(`{"$defs": {"port": {"type": "integer", "maximum": 65535}}, "properties": {"ports": {"items": {"$ref": "#/$defs/port"}}}}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := schema.Validate(map[string]interface{}{"ports": []interface{}{json.Number("80"), json.Number("70000")}}, path.Root("input")); len(got) != 1 || got[0].Path.String() != "input.ports[1]" {
+		t.Errorf("Expected the referenced definition to reject input.ports[1], got %v", got)
+	}
+	schema, err = utils.ParseJSONSchema(`{"oneOf": [{"type": "integer"}, {"type": "number", "multipleOf": 0.5}], "not": {"const": 2}}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for value, violations := range map[string]int{"2.5": 0, "1.5": 0, "1": 1, "2": 2, "0.3": 1, `"1"`: 1} {
+		var decoded interface{}
+		if err := utils.DecodeJSON([]byte(value), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if got := schema.Validate(decoded, path.Root("input")); len(got) != violations {
+			t.Errorf("Expected %d violations for %s, got %v", violations, value, got)
+		}
+	}
+}
+
+func TestUnitOutputValidationSchema(t *testing.T) {
+	data := customCrudResourceModel{
+		Output:                 types.DynamicNull(),
+		OutputSensitive:        types.DynamicNull(),
+		OutputValidationSchema: types.StringValue(`{"type": "object", "required": ["id"], "properties": {"id": {"type": "string"}, "ips": {"type": "array", "items": {"type": "string"}}}}`),
+	}
+	if diags := data.storeOutput(map[string]interface{}{"id": "vm-1", "ips": []interface{}{"10.0.0.1"}}, nil, ""); diags.HasError() {
+		t.Fatalf("Expected valid output to be stored, got %v", diags)
+	}
+	stored := data.Output
+
+	diags := data.storeOutput(map[string]interface{}{"ips": []interface{}{"10.0.0.1", 7}}, nil, "")
+	expected := map[string]bool{"output.id": true, "output.ips[1]": true}
+	if diags.ErrorsCount() != len(expected) {
+		t.Fatalf("Expected an error per violation, got %v", diags)
+	}
+	for _, d := range diags.Errors() {
+		withPath, ok := d.(diag.DiagnosticWithPath)
+		if !ok || !expected[withPath.Path().String()] || d.Summary() != "Output Validation Failed" {
+			t.Errorf("Unexpected error %v", d)
+		}
+	}
+	if !data.Output.Equal(stored) {
+		t.Errorf("Expected invalid output not to be stored, got %v", data.Output)
+	}
+
+	// Values only known after apply are checked once they are known
+	output := utils.MarkUnknown(map[string]interface{}{"id": utils.UnknownSentinel})
+	if diags := data.storeOutput(output, nil, ""); diags.HasError() {
+		t.Errorf("Expected unknown values to pass, got %v", diags)
+	}
+}
+
+func TestUnitOutputValidationFailedCreate(t *testing.T) {
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	(&customCrudResource{}).Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	model := nullResourceModel()
+	model.Hooks = hooks
+	model.OutputValidationSchema = types.StringValue(`{"type": "object", "required": ["ip"]}`)
+
+	resp, state := applyCreate(t, &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		return &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1"}`)}, nil
+	}}, model)
+	if !strings.Contains(protoDiags(resp.Diagnostics), "Output Validation Failed") {
+		t.Fatalf("Expected the output validation to fail, got %s", protoDiags(resp.Diagnostics))
+	}
+	// The created object is saved, Terraform taints it for the error
+	var attrs map[string]tftypes.Value
+	var id string
+	if state.IsNull() || state.As(&attrs) != nil || attrs["id"].As(&id) != nil || id != "vm-1" {
+		t.Fatalf("Expected the created object vm-1 to be saved, got %v", state)
+	}
+	if !attrs["output"].IsNull() {
+		t.Errorf("Expected the invalid output not to be stored, got %v", attrs["output"])
+	}
+}
+
+func TestUnitSkipOperations(t *testing.T) {
+	hooks := map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Update: "test_passthrough/create.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+	}
+	prior := map[string]interface{}{"name": "old"}
+	skip := func(action string, ops ...string) func(prior, planned *customCrudResourceModel) {
+		return func(prior, planned *customCrudResourceModel) {
+			list, _ := types.ListValueFrom(context.Background(), types.StringType, ops)
+			prior.SkipOperations = list
+			planned.SkipOperations = list
+			planned.SkipAction = types.StringValue(action)
+		}
+	}
+
+	resp := planUpdate(t, hooks, prior, map[string]interface{}{"name": "new"}, skip(skipActionError, "update"))
+	if resp.Diagnostics.ErrorsCount() != 1 || resp.Diagnostics.Errors()[0].Summary() != "Update Skipped" {
+		t.Errorf("Expected a skipped update to fail the plan, got %v", resp.Diagnostics)
+	}
+
+	resp = planUpdate(t, hooks, prior, map[string]interface{}{"name": "new"}, skip(skipActionWarn, "update"))
+	if resp.Diagnostics.HasError() || resp.Diagnostics.WarningsCount() != 1 {
+		t.Fatalf("Expected a warning for the skipped update, got %v", resp.Diagnostics)
+	}
+	var planned customCrudResourceModel
+	resp.Plan.Get(context.Background(), &planned)
+	if planned.Output.IsUnknown() {
+		t.Error("Expected a skipped update to keep the prior output")
+	}
+
+	// Unchanged input and other operations plan as usual
+	if diags := planUpdate(t, hooks, prior, prior, skip(skipActionError, "update")).Diagnostics; diags.HasError() {
+		t.Errorf("Expected unchanged input to plan, got %v", diags)
+	}
+	if diags := planUpdate(t, hooks, prior, map[string]interface{}{"name": "new"}, skip(skipActionError, "delete")).Diagnostics; diags.HasError() {
+		t.Errorf("Expected an update to plan, got %v", diags)
+	}
+
+	replace := func(prior, planned *customCrudResourceModel) {
+		planned.Triggers = types.MapValueMust(types.StringType, map[string]attr.Value{"version": types.StringValue("2")})
+	}
+	resp = planUpdate(t, hooks, prior, prior, skip(skipActionError, "delete"), replace)
+	if resp.Diagnostics.ErrorsCount() != 1 || resp.Diagnostics.Errors()[0].Summary() != "Delete Skipped" {
+		t.Errorf("Expected a skipped delete to fail a replacement, got %v", resp.Diagnostics)
+	}
+}
+
+func TestUnitSkippedUpdateRuns(t *testing.T) {
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	(&customCrudResource{}).Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Read:   "./read.sh",
+		utils.Update: "./update.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	var ran []string
+	server := protocolServer(t, &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		ran = append(ran, req.Command[0])
+		var payload struct {
+			Input map[string]interface{} `json:"input"`
+		}
+		_ = json.Unmarshal(req.Stdin, &payload)
+		output, _ := json.Marshal(map[string]interface{}{"id": "vm-1", "name": payload.Input["name"]})
+		return &utils.ExecResponse{Stdout: output}, nil
+	}})
+	model := nullResourceModel()
+	model.Hooks = hooks
+	model.Input = toDynamic(t, map[string]interface{}{"name": "old"})
+	_, resp, state := applyChange(t, server, tftypes.Value{}, nil, model)
+
+	// The frozen update is planned as a no-op, storing the configured input
+	frozen := model
+	frozen.Input = toDynamic(t, map[string]interface{}{"name": "new"})
+	frozen.SkipOperations = types.ListValueMust(types.StringType, []attr.Value{types.StringValue(utils.Update)})
+	frozen.SkipAction = types.StringValue(skipActionWarn)
+	ran = nil
+	_, resp, state = applyChange(t, server, state, resp.Private, frozen)
+	if diagsHaveError(resp.Diagnostics) || len(ran) != 0 {
+		t.Fatalf("Expected the skipped update not to run a hook, ran %v: %s", ran, protoDiags(resp.Diagnostics))
+	}
+
+	// Once update is no longer skipped, the skipped change runs it
+	unfrozen := frozen
+	unfrozen.SkipOperations = types.ListNull(types.StringType)
+	unfrozen.SkipAction = types.StringNull()
+	planResp, resp, state := applyChange(t, server, state, resp.Private, unfrozen)
+	if diagsHaveError(resp.Diagnostics) || len(ran) != 1 || ran[0] != "./update.sh" {
+		t.Fatalf("Expected the update to run, ran %v: %s", ran, protoDiags(resp.Diagnostics))
+	}
+	planned, _ := planResp.PlannedState.Unmarshal(state.Type())
+	var attrs map[string]tftypes.Value
+	if _ = planned.As(&attrs); attrs["output"].IsKnown() {
+		t.Errorf("Expected the update to plan the output as unknown, got %v", attrs["output"])
+	}
+	var output map[string]tftypes.Value
+	var name string
+	_ = state.As(&attrs)
+	if attrs["output"].As(&output) != nil || output["name"].As(&name) != nil || name != "new" {
+		t.Errorf("Expected the output of the update, got %v", attrs["output"])
+	}
+
+	// The update only runs once
+	ran = nil
+	if _, resp, _ = applyChange(t, server, state, resp.Private, unfrozen); diagsHaveError(resp.Diagnostics) || len(ran) != 0 {
+		t.Errorf("Expected no hook to run, ran %v: %s", ran, protoDiags(resp.Diagnostics))
+	}
+}
+
+func TestUnitUpdateStrategy(t *testing.T) {
+	hooks := map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+	}
+	prior := map[string]interface{}{"name": "old", "size": 1, "zone": "a"}
+	planned := map[string]interface{}{"name": "new", "size": 2, "zone": "a"}
+
+	resp := planUpdate(t, hooks, prior, planned)
+	if resp.Diagnostics.HasError() || resp.Diagnostics.WarningsCount() != 1 {
+		t.Fatalf("Expected a warning for the replacement, got %v", resp.Diagnostics)
+	}
+	if detail := resp.Diagnostics.Warnings()[0].Detail(); !strings.HasPrefix(detail, "The input keys name, size changed") {
+		t.Errorf("Expected the warning to name the changed keys, got %q", detail)
+	}
+	if !resp.RequiresReplace.Contains(path.Root("input")) {
+		t.Errorf("Expected the input change to force replacement, got %v", resp.RequiresReplace)
+	}
+
+	strategy := func(prior, planned *customCrudResourceModel) {
+		planned.UpdateStrategy = types.StringValue(updateStrategyError)
+	}
+	resp = planUpdate(t, hooks, prior, planned, strategy)
+	if resp.Diagnostics.ErrorsCount() != 1 || resp.Diagnostics.Errors()[0].Summary() != "Missing Update Hook" {
+		t.Errorf("Expected update_strategy error to fail the plan, got %v", resp.Diagnostics)
+	}
+
+	// Unchanged input plans as usual
+	if diags := planUpdate(t, hooks, prior, prior, strategy).Diagnostics; diags.HasError() || diags.WarningsCount() != 0 {
+		t.Errorf("Expected unchanged input to plan without diagnostics, got %v", diags)
+	}
+}
+
+func TestUnitVerifyHooks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "read.sh"), []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	crud := &hooksBlockValue{
+		Create:           types.StringValue("../test_passthrough/create.sh --verbose"),
+		Read:             types.StringValue("./read.sh"),
+		Update:           types.StringValue("sh -c 'exit 0'"),
+		Delete:           types.StringValue("customcrud-no-such-command"),
+		Plan:             types.StringValue("./missing.sh"),
+		WorkingDirectory: types.StringNull(),
+	}
+	var diags diag.Diagnostics
+	verifyHooks(crud, dir, &diags)
+	if diags.ErrorsCount() != 4 {
+		t.Fatalf("Expected an error per hook that can't be run, got %v", diags)
+	}
+	for i, want := range []string{"does not exist", "not executable", "not found in PATH", "does not exist"} {
+		if !strings.Contains(diags.Errors()[i].Detail(), want) {
+			t.Errorf("Expected error %d to contain %q, got %v", i, want, diags.Errors()[i])
+		}
+	}
+
+	// Relative paths are resolved against the hooks working directory
+	crud = &hooksBlockValue{Create: types.StringValue("test_passthrough/create.sh")}
+	diags = nil
+	verifyHooks(crud, dir, &diags)
+	if !diags.HasError() {
+		t.Error("Expected the hook to be resolved against the provider working directory")
+	}
+	cwd, _ := os.Getwd()
+	crud.WorkingDirectory = types.StringValue(cwd)
+	diags = nil
+	if verifyHooks(crud, dir, &diags); diags.HasError() {
+		t.Errorf("Expected the hooks working directory to take precedence, got %v", diags)
+	}
+
+	p := configureProvider(t, map[string]tftypes.Value{"verify_hooks": tftypes.NewValue(tftypes.Bool, true)})
+	if !p.config.VerifyHooks {
+		t.Error("Expected verify_hooks to be enabled")
+	}
+	p = configureProvider(t, map[string]tftypes.Value{
+		"verify_hooks": tftypes.NewValue(tftypes.Bool, true),
+		"executor":     tftypes.NewValue(tftypes.String, "mock"),
+	})
+	if p.config.VerifyHooks {
+		t.Error("Expected verify_hooks to be ignored with a remote executor")
+	}
+}
+
+func TestUnitSplitCommand(t *testing.T) {
+	tests := []struct {
+		goos     string
+		command  string
+		expected []string
+	}{
+		{"linux", `./create.sh --name 'my app'`, []string{"./create.sh", "--name", "my app"}},
+		{"linux", `./scripts/create.ps1 -Name web`, []string{"./scripts/create.ps1", "-Name", "web"}},
+		{"windows", `C:\hooks\create.exe --dir "C:\Program Files\app"`, []string{`C:\hooks\create.exe`, "--dir", `C:\Program Files\app`}},
+		{"windows", `.\scripts\Create.PS1 -Name "my app"`, []string{"powershell", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", `.\scripts\Create.PS1`, "-Name", "my app"}},
+		{"windows", `scripts\read.cmd`, []string{"cmd", "/c", `scripts\read.cmd`}},
+		{"windows", `echo {}`, []string{"cmd", "/c", "echo", "{}"}},
+		{"windows", `tool.exe "say \"hi\"" C:\dir\\`, []string{"tool.exe", `say "hi"`, `C:\dir\\`}},
+	}
+	for _, tt := range tests {
+		got, err := utils.SplitCommandOn(tt.command, tt.goos)
+		if err != nil || !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s %s: expected %q, got %q (%v)", tt.goos, tt.command, tt.expected, got, err)
+		}
+	}
+	if _, err := utils.SplitCommandOn(`tool.exe "unterminated`, "windows"); err == nil {
+		t.Error("Expected an unterminated quote to fail")
+	}
+
+	// Commands are split for the system the executor runs them on
+	ssh, err := utils.NewExecutor("ssh", map[string]string{"host": "runner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goos := utils.TargetOS(ssh); goos != "linux" {
+		t.Errorf("Expected ssh hooks to run on linux, got %s", goos)
+	}
+	local, err := utils.NewExecutor(utils.LocalExecutor, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goos := utils.TargetOS(local); goos != runtime.GOOS {
+		t.Errorf("Expected local hooks to run on %s, got %s", runtime.GOOS, goos)
+	}
+}
+
+func TestUnitReadRetryPayload(t *testing.T) {
+	ctx := context.Background()
+	var retries []*utils.RetryInfo
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		var payload utils.ExecutionPayload
+		if err := json.Unmarshal(req.Stdin, &payload); err != nil {
+			return nil, err
+		}
+		retries = append(retries, payload.Retry)
+		if len(retries) < 3 {
+			return &utils.ExecResponse{ExitCode: 22}, fmt.Errorf("exit status 22")
+		}
+		return &utils.ExecResponse{Stdout: []byte(`{"id": "eventual"}`)}, nil
+	}}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "create",
+		utils.Read:   "read",
+		utils.Delete: "delete",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	state := &customCrudResourceModel{Hooks: hooks, SharedReadKey: types.StringNull()}
+
+	result, ok := r.readWithRetries(ctx, state, utils.ExecutionPayload{Id: "eventual"}, 3, 2*time.Millisecond, &diags)
+	if !ok || diags.HasError() || result.Result["id"] != "eventual" {
+		t.Fatalf("Expected the third read to succeed, got %v: %v", result, diags)
+	}
+	if len(retries) != 3 || retries[0] != nil {
+		t.Fatalf("Expected the first read to run without retry info, got %v", retries)
+	}
+	for i, retry := range retries[1:] {
+		if retry.Attempt != i+1 || retry.MaxAttempts != 3 || retry.BackoffSeconds != 0.002 {
+			t.Errorf("Unexpected retry info for attempt %d: %+v", i+1, retry)
+		}
+	}
+}
+
+func TestUnitCreatedAt(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	var diags diag.Diagnostics
+
+	if createdAt := r.createdAt(ctx, &mockPrivate{}, &diags); !createdAt.IsZero() {
+		t.Errorf("Expected zero time without private state, got %v", createdAt)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	raw, _ := json.Marshal(now.Format(time.RFC3339Nano))
+	createdAt := r.createdAt(ctx, &mockPrivate{data: map[string][]byte{createdAtKey: raw}}, &diags)
+	if !createdAt.Equal(now) {
+		t.Errorf("Expected %v, got %v", now, createdAt)
+	}
+	if diags.HasError() {
+		t.Errorf("Unexpected diagnostics: %v", diags)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if utils.Sleep(cancelled, time.Minute) {
+		t.Error("Expected sleep to be interrupted by the cancelled context")
+	}
+	if !utils.Sleep(ctx, 0) {
+		t.Error("Expected a zero sleep to complete")
+	}
+}
+
+func TestUnitSleepReleasesSemaphore(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer utils.SetClock(clock)()
+	sem := make(chan struct{}, 1)
+
+	slept := make(chan bool)
+	go utils.WithSemaphore(context.Background(), sem, func(ctx context.Context) {
+		slept <- utils.Sleep(ctx, time.Minute)
+	})
+	deadline := time.Now().Add(10 * time.Second)
+	for clock.Waiters() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the sleep to wait on the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Another hook runs while the first one waits
+	ran := make(chan struct{})
+	go utils.WithSemaphore(context.Background(), sem, func(ctx context.Context) {
+		close(ran)
+	})
+	select {
+	case <-ran:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the semaphore to be released while sleeping")
+	}
+
+	clock.Advance(time.Minute)
+	if !<-slept {
+		t.Error("Expected the sleep to complete")
+	}
+}
+
+func TestAccResourcePrivateData(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "customcrud" "test_private" {
+  hooks {
+    create = "test_private/create.sh"
+    read   = "test_private/read.sh"
+    delete = "test_private/delete.sh"
+  }
+  input = {
+    name = "key"
+  }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("customcrud.test_private", "output.private"),
+					resource.TestCheckResourceAttr("customcrud.test_private", "output.name", "key"),
+				),
+			},
+			{
+				// The read hook only sees the key through the private state
+				RefreshState: true,
+				Check:        resource.TestCheckResourceAttr("customcrud.test_private", "output.has_key", "true"),
+			},
+		},
+	})
+}
+
+func TestUnitPrivateData(t *testing.T) {
+	ctx := context.Background()
+	priv := &mockPrivate{}
+	var diags diag.Diagnostics
+
+	output := map[string]interface{}{"id": "1", "private": map[string]interface{}{"key": "s3cr3t"}}
+	storePrivate(ctx, priv, output, &diags)
+	if _, ok := output["private"]; ok {
+		t.Error("Expected private to be removed from the output")
+	}
+	if data := privateData(ctx, priv, &diags); !reflect.DeepEqual(data, map[string]interface{}{"key": "s3cr3t"}) {
+		t.Errorf("Expected private data to round-trip, got %v", data)
+	}
+
+	// Output without the key keeps the stored data, a null value clears it
+	storePrivate(ctx, priv, map[string]interface{}{"id": "1"}, &diags)
+	if data := privateData(ctx, priv, &diags); data == nil {
+		t.Error("Expected private data to be kept")
+	}
+	storePrivate(ctx, priv, map[string]interface{}{"private": nil}, &diags)
+	if data := privateData(ctx, priv, &diags); data != nil {
+		t.Errorf("Expected private data to be cleared, got %v", data)
+	}
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+
+	storePrivate(ctx, priv, map[string]interface{}{"private": "not-an-object"}, &diags)
+	if !diags.HasError() {
+		t.Error("Expected an error for a private value that isn't an object")
+	}
+
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Executor = &utils.MockExecutor{Stdout: `{"id": "1", "private": {"key": "n3w"}}`}
+	payload := utils.ExecutionPayload{Id: "1", Private: map[string]interface{}{"key": "s3cr3t"}, Sensitive: []string{utils.PrivateKey}}
+	result, err := utils.Execute(ctx, config, []string{"read"}, payload)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result.Payload, "s3cr3t") {
+		t.Errorf("Expected the hook to receive the private data, got: %s", result.Payload)
+	}
+	if masked := result.Mask(result.Payload) + result.Mask(result.Stdout); strings.Contains(masked, "s3cr3t") || strings.Contains(masked, "n3w") {
+		t.Errorf("Expected private data to be masked, got: %s", masked)
+	}
+}
+
+func TestAccResourceSensitiveOutput(t *testing.T) {
+	createScript := "test_passthrough/create.sh"
+	readScript := "test_passthrough/read.sh"
+	deleteScript := "test_passthrough/delete.sh"
+
+	config := func(sensitive bool) string {
+		return fmt.Sprintf(`
+resource "customcrud" "test_sensitive" {
+  hooks {
+    create = %q
+    read   = %q
+    delete = %q
+  }
+  sensitive_output = %t
+  input = {
+    token = "s3cr3t"
+  }
+}
+`, createScript, readScript, deleteScript, sensitive)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config(true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_sensitive", "output_sensitive.token", "s3cr3t"),
+					resource.TestCheckNoResourceAttr("customcrud.test_sensitive", "output.token"),
+				),
+			},
+			{
+				// Turning the flag off moves the existing output back without running a hook
+				Config: config(false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_sensitive", "output.token", "s3cr3t"),
+					resource.TestCheckNoResourceAttr("customcrud.test_sensitive", "output_sensitive.token"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitSensitiveOutputModel(t *testing.T) {
+	output := map[string]interface{}{"token": "s3cr3t"}
+	value := toDynamic(t, output)
+
+	data := customCrudResourceModel{SensitiveOutput: types.BoolValue(true)}
+	if diags := data.storeOutput(output, nil, utils.CollectionTypingTuple); diags.HasError() {
+		t.Fatalf("Failed to store output: %v", diags)
+	}
+	if !data.Output.IsNull() || !data.OutputSensitive.Equal(value) {
+		t.Errorf("Expected output to be stored in output_sensitive, got output=%v output_sensitive=%v", data.Output, data.OutputSensitive)
+	}
+	if !reflect.DeepEqual(data.storedOutput(), output) {
+		t.Errorf("Expected stored output to come from output_sensitive, got %v", data.storedOutput())
+	}
+
+	data.SensitiveOutput = types.BoolValue(false)
+	if diags := data.storeOutput(output, nil, utils.CollectionTypingTuple); diags.HasError() {
+		t.Fatalf("Failed to store output: %v", diags)
+	}
+	if !data.OutputSensitive.IsNull() || !data.Output.Equal(value) {
+		t.Errorf("Expected output to move back to output, got output=%v output_sensitive=%v", data.Output, data.OutputSensitive)
+	}
+}
+
+func TestAccResourceSensitiveKeys(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "customcrud" "test_sensitive_keys" {
+  hooks {
+    create = "test_passthrough/create.sh"
+    read   = "test_passthrough/read.sh"
+    delete = "test_passthrough/delete.sh"
+  }
+  input = {
+    name        = "app"
+    token       = "s3cr3t"
+    __sensitive = ["token"]
+  }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test_sensitive_keys", "output.name", "app"),
+					resource.TestCheckNoResourceAttr("customcrud.test_sensitive_keys", "output.token"),
+					resource.TestCheckNoResourceAttr("customcrud.test_sensitive_keys", "output.__sensitive"),
+					resource.TestCheckResourceAttr("customcrud.test_sensitive_keys", "output_sensitive.token", "s3cr3t"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitSensitiveKeys(t *testing.T) {
+	stdout := `{"id": "1", "name": "app", "token": "s3cr3t", "db": {"host": "db1", "password": "hunter2"}, "__sensitive": ["token", "db.password"]}`
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Executor = &utils.MockExecutor{Stdout: stdout, Stderr: "connected with s3cr3t"}
+
+	result, err := utils.Execute(context.Background(), config, []string{"create"}, utils.ExecutionPayload{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if _, ok := result.Result[utils.SensitiveKey]; ok {
+		t.Errorf("Expected %s to be removed from the output", utils.SensitiveKey)
+	}
+	for _, s := range []string{result.Mask(result.Stdout), result.Mask(result.Stderr)} {
+		if strings.Contains(s, "s3cr3t") || strings.Contains(s, "hunter2") {
+			t.Errorf("Expected sensitive values to be masked, got: %s", s)
+		}
+	}
+
+	r := &customCrudResource{}
+	data := customCrudResourceModel{Input: types.DynamicNull()}
+	if diags := r.applyResult(&data, result.Result, result.Sensitive); diags.HasError() {
+		t.Fatalf("Failed to apply result: %v", diags)
+	}
+	public := utils.AttrValueToInterface(data.Output.UnderlyingValue())
+	expected := map[string]interface{}{"id": "1", "name": "app", "db": map[string]interface{}{"host": "db1"}}
+	if !reflect.DeepEqual(public, expected) {
+		t.Errorf("Expected output %v, got %v", expected, public)
+	}
+	sensitive := utils.AttrValueToInterface(data.OutputSensitive.UnderlyingValue())
+	expected = map[string]interface{}{"token": "s3cr3t", "db": map[string]interface{}{"password": "hunter2"}}
+	if !reflect.DeepEqual(sensitive, expected) {
+		t.Errorf("Expected output_sensitive %v, got %v", expected, sensitive)
+	}
+	if paths := data.sensitivePaths(); !reflect.DeepEqual(paths, []string{"db.password", "token"}) {
+		t.Errorf("Expected sensitive paths [db.password token], got %v", paths)
+	}
+
+	// Hooks receive the full output, while diagnostics only show it masked
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		if !strings.Contains(string(req.Stdin), "hunter2") {
+			t.Errorf("Expected the payload to include the sensitive output, got: %s", req.Stdin)
+		}
+		return &utils.ExecResponse{Stderr: []byte("auth failed for hunter2"), ExitCode: 1}, fmt.Errorf("exit status 1")
+	}}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(context.Background(), fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(context.Background(), schemaResp.Schema, map[string]string{
+		utils.Create: "create.sh",
+		utils.Read:   "read.sh",
+		utils.Delete: "delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	data.Hooks = hooks
+	payload := utils.ExecutionPayload{Id: "1", Output: data.storedOutput(), Sensitive: data.sensitivePaths()}
+	utils.RunCrudScript(context.Background(), config, &data, payload, &diags, utils.CrudRead)
+	if !diags.HasError() || diags.Errors()[0].Summary() != "Read Script Failed" {
+		t.Fatalf("Expected the read to fail, got: %v", diags)
+	}
+	if detail := diags.Errors()[0].Detail(); strings.Contains(detail, "hunter2") || strings.Contains(detail, "s3cr3t") {
+		t.Errorf("Expected sensitive values to be masked in diagnostics, got: %s", detail)
+	}
+}
+
+// toDynamic converts data to a dynamic value, failing the test on conversion errors.
+func toDynamic(t *testing.T, data interface{}) types.Dynamic {
+	t.Helper()
+	value, diags := utils.MapToDynamic(data)
+	if diags.HasError() {
+		t.Fatalf("Failed to convert %v: %v", data, diags)
+	}
+	return value
+}
+
+func TestUnitConversionErrors(t *testing.T) {
+	r := &customCrudResource{}
+	tags, diags := types.SetValue(types.StringType, []attr.Value{types.StringValue("a"), types.StringValue("b")})
+	if diags.HasError() {
+		t.Fatalf("Failed to build set: %v", diags)
+	}
+	input := types.DynamicValue(types.ObjectValueMust(
+		map[string]attr.Type{"tags": types.SetType{ElemType: types.StringType}},
+		map[string]attr.Value{"tags": tags},
+	))
+
+	// A set can't hold elements of different types, which used to silently null the input
+	_, diags = r.mergeInputWithOutput(input, map[string]interface{}{"tags": []interface{}{"a", float64(1)}}, mergeStrategyShallow)
+	if !diags.HasError() {
+		t.Fatal("Expected a conversion error for a set with mixed element types")
+	}
+	if detail := diags.Errors()[0].Detail(); !strings.Contains(detail, "key path tags") {
+		t.Errorf("Expected the error to name the key path, got: %s", detail)
+	}
+
+	_, diags = utils.MapToDynamic(map[string]interface{}{"nested": map[string]interface{}{"n": json.Number("not-a-number")}})
+	if !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), "key path nested.n") {
+		t.Errorf("Expected an invalid number error naming nested.n, got: %v", diags)
+	}
+
+	if _, diags := utils.MapToDynamic(map[string]interface{}{"list": []interface{}{"a", float64(1), nil}}); diags.HasError() {
+		t.Errorf("Expected mixed lists to convert to tuples, got: %v", diags)
+	}
+}
+
+func TestUnitCollectionTyping(t *testing.T) {
+	p := configureProvider(t, map[string]tftypes.Value{
+		"collection_typing": tftypes.NewValue(tftypes.String, utils.CollectionTypingList),
+	})
+	r := &customCrudResource{config: p.kindConfig(resourceKind)}
+	data := &customCrudResourceModel{
+		Input: toDynamic(t, map[string]interface{}{"tags": []interface{}{"a"}}),
+	}
+	output := map[string]interface{}{
+		"tags":  []interface{}{"a", "b"},
+		"ports": []interface{}{float64(80), float64(443)},
+		"mixed": []interface{}{"a", float64(1)},
+		"empty": []interface{}{},
+	}
+	if diags := r.applyResult(data, output, nil); diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+
+	attrs := data.Output.UnderlyingValue().(types.Object).Attributes()
+	for key, want := range map[string]string{"tags": "list", "ports": "list", "mixed": "tuple", "empty": "tuple"} {
+		got := "tuple"
+		if _, ok := attrs[key].(types.List); ok {
+			got = "list"
+		}
+		if got != want {
+			t.Errorf("Expected %s to be a %s, got %s", key, want, attrs[key].Type(context.Background()))
+		}
+	}
+
+	// Input keeps the tuple type of the configured value
+	tags := data.Input.UnderlyingValue().(types.Object).Attributes()["tags"]
+	if _, ok := tags.(types.Tuple); !ok {
+		t.Errorf("Expected the merged input to stay a tuple, got %s", tags.Type(context.Background()))
+	}
+
+	// The default policy converts every array to a tuple
+	r.config.CollectionTyping = utils.CollectionTypingTuple
+	if diags := r.applyResult(data, output, nil); diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	if _, ok := data.Output.UnderlyingValue().(types.Object).Attributes()["tags"].(types.Tuple); !ok {
+		t.Errorf("Expected tuples with the default policy, got %s", data.Output.UnderlyingValue().Type(context.Background()))
+	}
+}
+
+func TestUnitImportHooks(t *testing.T) {
+	ctx := context.Background()
+	r := NewCustomCrudResource()
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "create.sh",
+		utils.Read:   "read.sh",
+		utils.Delete: "delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+
+	data := customCrudResourceModel{Hooks: hooks}
+	crud, err := getCrudCommands(&data, runtime.GOOS)
+	if err != nil {
+		t.Fatalf("Failed to get CRUD commands: %v", err)
+	}
+	if crud.Create.ValueString() != "create.sh" {
+		t.Errorf("Expected create hook create.sh, got %v", crud.Create)
+	}
+	if !crud.Update.IsNull() {
+		t.Errorf("Expected missing update hook to be null, got %v", crud.Update)
+	}
+}
+
+func TestUnitImportState(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	resp := &fwresource.ImportStateResponse{
+		State: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		},
+	}
+	r.ImportState(ctx, fwresource.ImportStateRequest{
+		ID: `{"id": "imported", "hooks": {"create": "test_passthrough/create.sh", "read": "test_passthrough/read.sh", "delete": "test_passthrough/delete.sh"}, "input": {"name": "imported"}}`,
+	}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var data customCrudResourceModel
+	if diags := resp.State.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to read imported state: %v", diags)
+	}
+	if data.Id.ValueString() != "imported" {
+		t.Errorf("Expected id imported, got %v", data.Id)
+	}
+	output, ok := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{})
+	if !ok || output["name"] != "imported" {
+		t.Errorf("Expected read output to be stored, got %v", data.Output)
+	}
+}
+
+func TestUnitHookPathRewrites(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create:                      "./scripts/create.sh",
+		utils.Read:                        "./scripts/vm/read.sh --verbose",
+		utils.Update:                      "bash ./scripts/update.sh",
+		utils.Plan:                        "./other/plan.sh",
+		utils.Delete + utils.ScriptSuffix: "#!/bin/sh\n./scripts/delete.sh\n",
+		utils.WorkingDirectory:            "./scripts/",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	obj := hooks.Elements()[0].(types.Object)
+	attrs := obj.Attributes()
+	attrs[utils.Argv] = types.MapValueMust(types.ListType{ElemType: types.StringType}, map[string]attr.Value{
+		utils.Status: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("./scripts/status.sh"), types.StringValue("./scripts/x")}),
+	})
+	hooks = types.ListValueMust(obj.Type(ctx), []attr.Value{types.ObjectValueMust(obj.AttributeTypes(ctx), attrs)})
+	data := &customCrudResourceModel{Hooks: hooks, Hook: types.ObjectNull(hookAttributeType().AttrTypes)}
+	if r.rewriteHooks(ctx, data, &diag.Diagnostics{}) {
+		t.Error("Expected no rewrite without hook_path_rewrites")
+	}
+
+	p := configureProvider(t, map[string]tftypes.Value{
+		"hook_path_rewrites": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+			"./scripts/":    tftypes.NewValue(tftypes.String, "./hooks/scripts/"),
+			"./scripts/vm/": tftypes.NewValue(tftypes.String, "./hooks/vm/"),
+		}),
+	})
+	r.config = p.config
+	if !r.rewriteHooks(ctx, data, &diag.Diagnostics{}) {
+		t.Fatal("Expected the hooks to be rewritten")
+	}
+	crud, err := getCrudCommands(data, runtime.GOOS)
+	if err != nil {
+		t.Fatalf("Failed to get CRUD commands: %v", err)
+	}
+	for want, got := range map[string]types.String{
+		"./hooks/scripts/create.sh":        crud.Create,
+		"./hooks/vm/read.sh --verbose":     crud.Read,
+		"bash ./scripts/update.sh":         crud.Update,
+		"./other/plan.sh":                  crud.Plan,
+		"./hooks/scripts/":                 crud.WorkingDirectory,
+		"#!/bin/sh\n./scripts/delete.sh\n": types.StringValue(crud.Scripts[utils.Delete]),
+	} {
+		if got.ValueString() != want {
+			t.Errorf("Expected %q, got %v", want, got)
+		}
+	}
+	status := crud.Argv.Elements()[utils.Status].(types.List).Elements()
+	if status[0].(types.String).ValueString() != "./hooks/scripts/status.sh" || status[1].(types.String).ValueString() != "./scripts/x" {
+		t.Errorf("Expected only the program of the argv entry to be rewritten, got %v", status)
+	}
+	if !crud.Diff.IsNull() {
+		t.Errorf("Expected the missing diff hook to stay null, got %v", crud.Diff)
+	}
+	if r.rewriteHooks(ctx, data, &diag.Diagnostics{}) {
+		t.Error("Expected rewritten hooks not to be rewritten again")
+	}
+
+	// A replacement starting with the prefix it replaces isn't applied again
+	r.config.HookRewrites = utils.NewHookRewrites(map[string]string{"./scripts/": "./scripts/v2/"})
+	data.Hooks, _ = importHooks(ctx, schemaResp.Schema, map[string]string{utils.Create: "./scripts/create.sh"})
+	for i := 0; i < 2; i++ {
+		r.rewriteHooks(ctx, data, &diag.Diagnostics{})
+	}
+	if crud, _ := getCrudCommands(data, runtime.GOOS); crud.Create.ValueString() != "./scripts/v2/create.sh" {
+		t.Errorf("Expected the rewrite to be applied once, got %v", crud.Create)
+	}
+}
+
+func TestUnitHooksRef(t *testing.T) {
+	ctx := context.Background()
+	p := configureProvider(t, map[string]tftypes.Value{
+		"hook_sets": tftypes.NewValue(tftypes.Map{ElementType: tftypes.Map{ElementType: tftypes.String}}, map[string]tftypes.Value{
+			"passthrough": tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, map[string]tftypes.Value{
+				utils.Create: tftypes.NewValue(tftypes.String, "test_passthrough/create.sh"),
+				utils.Read:   tftypes.NewValue(tftypes.String, "test_passthrough/read.sh"),
+				utils.Delete: tftypes.NewValue(tftypes.String, "test_passthrough/delete.sh"),
+			}),
+		}),
+	})
+	r := &customCrudResource{config: p.config}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	newState := func() tfsdk.State {
+		return tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	}
+
+	resp := &fwresource.ImportStateResponse{State: newState()}
+	r.ImportState(ctx, fwresource.ImportStateRequest{
+		ID: `{"id": "imported", "hooks_ref": "passthrough", "input": {"name": "imported"}}`,
+	}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	var data customCrudResourceModel
+	if diags := resp.State.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to read imported state: %v", diags)
+	}
+	if data.HooksRef.ValueString() != "passthrough" || len(data.Hooks.Elements()) != 0 {
+		t.Errorf("Expected only the hook set name to be stored, got %v and %v", data.HooksRef, data.Hooks)
+	}
+	output, _ := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{})
+	if output["name"] != "imported" {
+		t.Errorf("Expected the read hook of the hook set to run, got %v", data.Output)
+	}
+
+	resp = &fwresource.ImportStateResponse{State: newState()}
+	r.ImportState(ctx, fwresource.ImportStateRequest{ID: `{"id": "imported", "hooks_ref": "missing"}`}, resp)
+	if resp.Diagnostics.ErrorsCount() != 1 || resp.Diagnostics.Errors()[0].Summary() != "Unknown Hook Set" {
+		t.Errorf("Expected an unknown hook set to be reported, got %v", resp.Diagnostics)
+	}
+
+	// A hooks block and hooks_ref exclude each other
+	hooks, _ := importHooks(ctx, schemaResp.Schema, map[string]string{utils.Create: "create.sh", utils.Read: "read.sh", utils.Delete: "delete.sh"})
+	data.Hooks = hooks
+	config := newState()
+	if diags := config.Set(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to build config: %v", diags)
+	}
+	validateResp := &fwresource.ValidateConfigResponse{}
+	r.ValidateConfig(ctx, fwresource.ValidateConfigRequest{Config: tfsdk.Config{Schema: config.Schema, Raw: config.Raw}}, validateResp)
+	if validateResp.Diagnostics.ErrorsCount() != 1 || validateResp.Diagnostics.Errors()[0].Summary() != "Conflicting Hooks" {
+		t.Errorf("Expected hooks and hooks_ref to conflict, got %v", validateResp.Diagnostics)
+	}
+
+	for sets, want := range map[string]string{
+		`{"vm": {"read": "r", "delete": "d"}}`:                            "must set the create or apply hook",
+		`{"vm": {"create": "c", "read": "r", "delete": "d", "run": "x"}}`: "unsupported attribute",
+	} {
+		var decoded map[string]map[string]string
+		if err := json.Unmarshal([]byte(sets), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkHookSets(decoded); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %s to be rejected with %q, got %v", sets, want, err)
+		}
+	}
+}
+
+func TestUnitHooksDir(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	newState := func() tfsdk.State {
+		return tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	}
+
+	resp := &fwresource.ImportStateResponse{State: newState()}
+	r.ImportState(ctx, fwresource.ImportStateRequest{
+		ID: `{"id": "imported", "hooks_dir": "test_passthrough", "input": {"name": "imported"}}`,
+	}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	var data customCrudResourceModel
+	if diags := resp.State.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to read imported state: %v", diags)
+	}
+	if data.HooksDir.ValueString() != "test_passthrough" || len(data.Hooks.Elements()) != 0 {
+		t.Errorf("Expected only the directory to be stored, got %v and %v", data.HooksDir, data.Hooks)
+	}
+	output, _ := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{})
+	if output["name"] != "imported" {
+		t.Errorf("Expected the read.sh of the directory to run, got %v", data.Output)
+	}
+	var diags diag.Diagnostics
+	if !r.resolveHooksRef(ctx, schemaResp.Schema, &data, &diags) {
+		t.Fatalf("Failed to resolve hooks_dir: %v", diags)
+	}
+	crud, err := getCrudCommands(&data, runtime.GOOS)
+	if err != nil {
+		t.Fatalf("Failed to get CRUD commands: %v", err)
+	}
+	if !filepath.IsAbs(crud.Create.ValueString()) || !strings.HasSuffix(crud.Create.ValueString(), "test_passthrough/create.sh") || !crud.Update.IsNull() {
+		t.Errorf("Expected the absolute create.sh and no update hook, got %v and %v", crud.Create, crud.Update)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "delete.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data.HooksDir = types.StringValue(dir)
+	diags = nil
+	if r.resolveHooksRef(ctx, schemaResp.Schema, &data, &diags) || !strings.Contains(diags.Errors()[0].Detail(), "missing the required create.sh") {
+		t.Errorf("Expected the missing scripts to be reported, got %v", diags)
+	}
+
+	// A hooks block and hooks_dir exclude each other
+	hooks, _ := importHooks(ctx, schemaResp.Schema, map[string]string{utils.Create: "create.sh", utils.Read: "read.sh", utils.Delete: "delete.sh"})
+	data.Hooks = hooks
+	config := newState()
+	if diags := config.Set(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to build config: %v", diags)
+	}
+	validateResp := &fwresource.ValidateConfigResponse{}
+	r.ValidateConfig(ctx, fwresource.ValidateConfigRequest{Config: tfsdk.Config{Schema: config.Schema, Raw: config.Raw}}, validateResp)
+	if validateResp.Diagnostics.ErrorsCount() != 1 || validateResp.Diagnostics.Errors()[0].Summary() != "Conflicting Hooks" {
+		t.Errorf("Expected hooks and hooks_dir to conflict, got %v", validateResp.Diagnostics)
+	}
+}
+
+func TestUnitHooksFile(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	dir := t.TempDir()
+	files := map[string]string{
+		"hooks.json": `{"create": "test_passthrough/create.sh", "read": "test_passthrough/read.sh", "delete": "test_passthrough/delete.sh", "update": null}`,
+		"hooks.hcl": `
+create = "test_passthrough/create.sh"
+read   = "test_passthrough/read.sh"
+delete = "test_passthrough/delete.sh"
+`,
+		"missing.json":  `{"read": "read.sh", "delete": "delete.sh"}`,
+		"unknown.hcl":   "create = \"c\"\nread = \"r\"\ndelete = \"d\"\nrun = \"x\"\n",
+		"variable.hcl":  "create = var.create\n",
+		"number.json":   `{"create": 1}`,
+		"malformed.hcl": "create = \n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"hooks.json", "hooks.hcl"} {
+		resp := &fwresource.ImportStateResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}}
+		id, _ := json.Marshal(map[string]interface{}{"id": "imported", "hooks_file": filepath.Join(dir, name), "input": map[string]string{"name": name}})
+		r.ImportState(ctx, fwresource.ImportStateRequest{ID: string(id)}, resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("%s: unexpected diagnostics: %v", name, resp.Diagnostics)
+		}
+		var data customCrudResourceModel
+		if diags := resp.State.Get(ctx, &data); diags.HasError() {
+			t.Fatalf("Failed to read imported state: %v", diags)
+		}
+		output, _ := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{})
+		if output["name"] != name || len(data.Hooks.Elements()) != 0 {
+			t.Errorf("%s: expected the read hook of the file to run and only the file to be stored, got %v and %v", name, data.Output, data.Hooks)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"missing.json":  "must set the create or apply hook",
+		"unknown.hcl":   `unsupported attribute "run"`,
+		"variable.hcl":  "Variables not allowed",
+		"number.json":   "create in",
+		"malformed.hcl": "Invalid expression",
+		"absent.json":   "no such file",
+	} {
+		data := nullResourceModel()
+		data.HooksFile = types.StringValue(filepath.Join(dir, name))
+		var diags diag.Diagnostics
+		if r.resolveHooksRef(ctx, schemaResp.Schema, &data, &diags) || diags.Errors()[0].Summary() != "Invalid Hooks File" || !strings.Contains(diags.Errors()[0].Detail(), want) {
+			t.Errorf("%s: expected %q, got %v", name, want, diags)
+		}
+	}
+}
+
+func TestUnitImportHook(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	resp := &fwresource.ImportStateResponse{
+		State: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		},
+	}
+	// Only the import hook runs, the read hook would return another id
+	r.ImportState(ctx, fwresource.ImportStateRequest{
+		ID: `{"id": "web", "hooks": {"create": "test_passthrough/create.sh", "read": "test_failures/read.sh", "delete": "test_passthrough/delete.sh", "import": "test_import/import.sh"}}`,
+	}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var data customCrudResourceModel
+	if diags := resp.State.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to read imported state: %v", diags)
+	}
+	if data.Id.ValueString() != "vm-web" {
+		t.Errorf("Expected the id resolved by the import hook, got %v", data.Id)
+	}
+	input, _ := utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if input["name"] != "web" {
+		t.Errorf("Expected the input printed by the import hook, got %v", data.Input)
+	}
+	output, _ := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{})
+	if output["status"] != "running" || output["id"] != nil {
+		t.Errorf("Expected the output printed by the import hook, got %v", data.Output)
+	}
+}
+
+func TestUnitImportDerivesInput(t *testing.T) {
+	ctx := context.Background()
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Executor = &utils.MockExecutor{Stdout: `{"id": "vm-1", "name": "web", "size": 2, "password": "s3cr3t", "__sensitive": ["password"]}`}
+	r := &customCrudResource{config: config}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	importState := func(id string) customCrudResourceModel {
+		resp := &fwresource.ImportStateResponse{
+			State: tfsdk.State{
+				Schema: schemaResp.Schema,
+				Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+			},
+		}
+		r.ImportState(ctx, fwresource.ImportStateRequest{ID: id}, resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var data customCrudResourceModel
+		if diags := resp.State.Get(ctx, &data); diags.HasError() {
+			t.Fatalf("Failed to read imported state: %v", diags)
+		}
+		return data
+	}
+
+	data := importState(`{"id": "vm-1", "hooks": {"create": "create.sh", "read": "read.sh", "delete": "delete.sh"}}`)
+	input, _ := utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if len(input) != 2 || input["name"] != "web" || input["size"] == nil {
+		t.Errorf("Expected the input derived from the read output without id and sensitive values, got %v", data.Input)
+	}
+
+	data = importState(`{"id": "vm-1", "hooks": {"create": "create.sh", "read": "read.sh", "delete": "delete.sh"}, "input": {"name": "given"}}`)
+	input, _ = utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if len(input) != 1 || input["name"] == nil {
+		t.Errorf("Expected the keys of the import ID input to be kept, got %v", data.Input)
+	}
+}
+
+func TestAccResourceStableOutputKeys(t *testing.T) {
+	config := func(name string) string {
+		return fmt.Sprintf(`
+resource "customcrud" "test_stable" {
+  hooks {
+    create = "test_passthrough/create.sh"
+    read   = "test_passthrough/read.sh"
+    update = "test_passthrough/create.sh"
+    delete = "test_passthrough/delete.sh"
+  }
+  stable_output_keys = ["id"]
+  input = {
+    name = %q
+  }
+}
+`, name)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("first"),
+			},
+			{
+				Config: config("second"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("customcrud.test_stable", tfjsonpath.New("output").AtMapKey("id"), knownvalue.StringExact("test-passthrough")),
+						plancheck.ExpectUnknownValue("customcrud.test_stable", tfjsonpath.New("output").AtMapKey("name")),
+					},
+				},
+				Check: resource.TestCheckResourceAttr("customcrud.test_stable", "output.name", "second"),
+			},
+		},
+	})
+}
+
+func TestUnitStableOutput(t *testing.T) {
+	ctx := context.Background()
+	state := customCrudResourceModel{
+		Output: toDynamic(t, map[string]interface{}{"id": "vm-1", "arn": "arn:vm-1", "status": "running"}),
+	}
+
+	var diags diag.Diagnostics
+	if _, ok := stableOutput(ctx, &state, nil, &diags); ok {
+		t.Error("Expected no planned output without stable keys")
+	}
+	if _, ok := stableOutput(ctx, &state, []string{"missing"}, &diags); ok {
+		t.Error("Expected no planned output when no stable key is in the prior output")
+	}
+
+	output, ok := stableOutput(ctx, &state, []string{"id", "arn"}, &diags)
+	if diags.HasError() || !ok {
+		t.Fatalf("Expected a planned output, got diagnostics: %v", diags)
+	}
+	attrs := output.UnderlyingValue().(types.Object).Attributes()
+	if !attrs["id"].Equal(types.StringValue("vm-1")) || !attrs["arn"].Equal(types.StringValue("arn:vm-1")) {
+		t.Errorf("Expected stable keys to keep their prior values, got %v", attrs)
+	}
+	if !attrs["status"].IsUnknown() {
+		t.Errorf("Expected other keys to be unknown, got %v", attrs["status"])
+	}
+	if _, ok := attrs["status"].(types.Dynamic); !ok {
+		t.Errorf("Expected other keys to be planned as dynamic values, got %T", attrs["status"])
+	}
+
+	unchanged := customCrudResourceModel{
+		Output: toDynamic(t, map[string]interface{}{"id": "vm-1", "arn": "arn:vm-1", "status": "stopped"}),
+	}
+	checkStableOutput(&state, &unchanged, []string{"id", "arn"}, &diags)
+	if diags.HasError() {
+		t.Errorf("Expected no error when only other keys change, got: %v", diags)
+	}
+	changed := customCrudResourceModel{
+		Output: toDynamic(t, map[string]interface{}{"id": "vm-2", "arn": "arn:vm-1", "status": "running"}),
+	}
+	checkStableOutput(&state, &changed, []string{"id", "arn"}, &diags)
+	if !diags.HasError() || !strings.Contains(summaryOf(diags), `"id"`) {
+		t.Errorf("Expected an error when a stable key changes, got: %v", diags)
+	}
+}
+
+func TestAccResourceExpectedOutputKeys(t *testing.T) {
+	config := func(name string) string {
+		return fmt.Sprintf(`
+resource "customcrud" "test_expected" {
+  hooks {
+    create = "test_passthrough/create.sh"
+    read   = "test_passthrough/read.sh"
+    update = "test_passthrough/create.sh"
+    delete = "test_passthrough/delete.sh"
+  }
+  expected_output_keys = ["name"]
+  input = {
+    name = %q
+  }
+}
+`, name)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("first"),
+			},
+			{
+				Config: config("second"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("customcrud.test_expected", tfjsonpath.New("output").AtMapKey("id"), knownvalue.StringExact("test-passthrough")),
+						plancheck.ExpectUnknownValue("customcrud.test_expected", tfjsonpath.New("output").AtMapKey("name")),
+					},
+				},
+				Check: resource.TestCheckResourceAttr("customcrud.test_expected", "output.name", "second"),
+			},
+		},
+	})
+}
+
+func TestUnitExpectedOutput(t *testing.T) {
+	ctx := context.Background()
+	state := customCrudResourceModel{
+		Output:          toDynamic(t, map[string]interface{}{"id": "vm-1", "status": "stopped"}),
+		OutputSensitive: toDynamic(t, map[string]interface{}{"token": "s3cr3t"}),
+	}
+
+	var diags diag.Diagnostics
+	output, ok := expectedOutput(ctx, &state, []string{"status", "ip", "token"}, &diags)
+	if diags.HasError() || !ok {
+		t.Fatalf("Expected a planned output, got diagnostics: %v", diags)
+	}
+	attrs := output.UnderlyingValue().(types.Object).Attributes()
+	if !attrs["id"].Equal(types.StringValue("vm-1")) {
+		t.Errorf("Expected keys that aren't listed to keep their prior values, got %v", attrs)
+	}
+	if !attrs["status"].IsUnknown() || !attrs["ip"].IsUnknown() {
+		t.Errorf("Expected listed keys to be unknown, got %v", attrs)
+	}
+	if _, exists := attrs["token"]; exists {
+		t.Errorf("Expected sensitive keys to be left to output_sensitive, got %v", attrs)
+	}
+
+	// A create may print keys that aren't listed, so its output is left unknown
+	if _, ok := expectedOutput(ctx, nil, []string{"id"}, &diags); ok {
+		t.Error("Expected the output of a create to be left unknown")
+	}
+}
+
+func TestAccResourcePlanHook(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "customcrud" "test_plan" {
+  hooks {
+    create = "test_passthrough/create.sh"
+    read   = "test_passthrough/read.sh"
+    delete = "test_passthrough/delete.sh"
+    plan   = "test_passthrough/create.sh"
+  }
+  input = {
+    name = "planned"
+  }
+}
+`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("customcrud.test_plan", tfjsonpath.New("output").AtMapKey("name"), knownvalue.StringExact("planned")),
+					},
+				},
+				Check: resource.TestCheckResourceAttr("customcrud.test_plan", "output.name", "planned"),
+			},
+		},
+	})
+}
+
+func TestUnitPlanHook(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+		utils.Plan:   "test_passthrough/create.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	model := customCrudResourceModel{
+		Id:    types.StringUnknown(),
+		Hooks: hooks,
+		Input: toDynamic(t, map[string]interface{}{"name": "planned"}),
+
+		InputWO:                types.DynamicNull(),
+		Output:                 types.DynamicUnknown(),
+		SensitiveOutput:        types.BoolNull(),
+		OutputSensitive:        types.DynamicUnknown(),
+		SortOutputLists:        types.ListNull(types.StringType),
+		StableOutputKeys:       types.ListNull(types.StringType),
+		ExpectedOutputKeys:     types.ListNull(types.StringType),
+		SharedReadKey:          types.StringNull(),
+		IgnoreOutputKeys:       types.ListNull(types.StringType),
+		ComputedInputKeys:      types.ListNull(types.StringType),
+		OutputSchema:           types.StringNull(),
+		InputSchema:            types.StringNull(),
+		OutputValidationSchema: types.StringNull(),
+		ReplaceOnChange:        types.ListNull(types.StringType),
+		Triggers:               types.MapNull(types.StringType),
+		MergeStrategy:          types.StringNull(),
+		ReadMode:               types.StringNull(),
+		PostCreateReadDelay:    types.Int64Null(),
+		PostCreateReadRetries:  types.Int64Null(),
+		SkipOperations:         types.ListNull(types.StringType),
+		SkipAction:             types.StringNull(),
+		HooksRef:               types.StringNull(),
+		UpdateStrategy:         types.StringNull(),
+		TransactionGroup:       types.StringNull(),
+		HooksDir:               types.StringNull(),
+		HooksFile:              types.StringNull(),
+		Hook:                   types.ObjectNull(hookAttributeType().AttrTypes),
+		ScriptHash:             types.StringUnknown(),
+		HashHookFiles:          types.BoolNull(),
+		ScriptChange:           types.StringNull(),
+		Wait:                   types.ListNull(waitBlockType()),
+	}
+	plan := tfsdk.Plan{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	if diags := plan.Set(ctx, &model); diags.HasError() {
+		t.Fatalf("Failed to build plan: %v", diags)
+	}
+
+	req := fwresource.ModifyPlanRequest{
+		Config: tfsdk.Config{Schema: plan.Schema, Raw: plan.Raw},
+		Plan:   plan,
+		State:  tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)},
+	}
+	resp := &fwresource.ModifyPlanResponse{Plan: plan}
+	r.ModifyPlan(ctx, req, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var planned customCrudResourceModel
+	if diags := resp.Plan.Get(ctx, &planned); diags.HasError() {
+		t.Fatalf("Failed to read plan: %v", diags)
+	}
+	output, ok := utils.AttrValueToInterface(planned.Output.UnderlyingValue()).(map[string]interface{})
+	if !ok || output["name"] != "planned" || output["id"] != "test-passthrough" {
+		t.Errorf("Expected the plan hook output to be planned, got %v", planned.Output)
+	}
+	if !planned.OutputSensitive.IsNull() {
+		t.Errorf("Expected output_sensitive to be planned as null, got %v", planned.OutputSensitive)
+	}
+}
+
+// planUpdate runs ModifyPlan for an update of a resource managed by hooks from
+// the prior input, which is also its output, to the planned input. The
+// optional update funcs change the prior and planned models further.
+func planUpdate(t *testing.T, hooks map[string]string, prior, planned map[string]interface{}, update ...func(prior, planned *customCrudResourceModel)) *fwresource.ModifyPlanResponse {
+	t.Helper()
+	r, req := planUpdateRequest(t, hooks, prior, planned, update...)
+	resp := &fwresource.ModifyPlanResponse{Plan: req.Plan}
+	r.ModifyPlan(context.Background(), req, resp)
+	return resp
+}
+
+// planUpdateRequest builds the ModifyPlan request run by planUpdate.
+func planUpdateRequest(t *testing.T, hooks map[string]string, prior, planned map[string]interface{}, update ...func(prior, planned *customCrudResourceModel)) (*customCrudResource, fwresource.ModifyPlanRequest) {
+	t.Helper()
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	hooksList, diags := importHooks(ctx, schemaResp.Schema, hooks)
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	model := customCrudResourceModel{
+		Id:    types.StringValue("test-passthrough"),
+		Hooks: hooksList,
+		Input: toDynamic(t, prior),
+
+		InputWO:                types.DynamicNull(),
+		Output:                 toDynamic(t, prior),
+		SensitiveOutput:        types.BoolNull(),
+		OutputSensitive:        types.DynamicNull(),
+		SortOutputLists:        types.ListNull(types.StringType),
+		StableOutputKeys:       types.ListNull(types.StringType),
+		ExpectedOutputKeys:     types.ListNull(types.StringType),
+		SharedReadKey:          types.StringNull(),
+		IgnoreOutputKeys:       types.ListNull(types.StringType),
+		ComputedInputKeys:      types.ListNull(types.StringType),
+		OutputSchema:           types.StringNull(),
+		InputSchema:            types.StringNull(),
+		OutputValidationSchema: types.StringNull(),
+		ReplaceOnChange:        types.ListNull(types.StringType),
+		Triggers:               types.MapNull(types.StringType),
+		MergeStrategy:          types.StringNull(),
+		ReadMode:               types.StringNull(),
+		PostCreateReadDelay:    types.Int64Null(),
+		PostCreateReadRetries:  types.Int64Null(),
+		SkipOperations:         types.ListNull(types.StringType),
+		SkipAction:             types.StringNull(),
+		HooksRef:               types.StringNull(),
+		UpdateStrategy:         types.StringNull(),
+		TransactionGroup:       types.StringNull(),
+		HooksDir:               types.StringNull(),
+		HooksFile:              types.StringNull(),
+		Hook:                   types.ObjectNull(hookAttributeType().AttrTypes),
+		ScriptHash:             types.StringNull(),
+		HashHookFiles:          types.BoolNull(),
+		ScriptChange:           types.StringNull(),
+		Wait:                   types.ListNull(waitBlockType()),
+	}
+	plannedModel := model
+	plannedModel.Input = toDynamic(t, planned)
+	for _, f := range update {
+		f(&model, &plannedModel)
+	}
+	state := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	if diags := state.Set(ctx, &model); diags.HasError() {
+		t.Fatalf("Failed to build state: %v", diags)
+	}
+
+	plan := tfsdk.Plan{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	if diags := plan.Set(ctx, &plannedModel); diags.HasError() {
+		t.Fatalf("Failed to build plan: %v", diags)
+	}
+	return r, fwresource.ModifyPlanRequest{
+		Config: tfsdk.Config{Schema: plan.Schema, Raw: plan.Raw},
+		Plan:   plan,
+		State:  state,
+	}
+}
+
+func TestUnitDeferredPlan(t *testing.T) {
+	ctx := context.Background()
+	hooks := map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Update: "test_passthrough/create.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+	}
+	prior := map[string]interface{}{"name": "first"}
+	unknownInput := func(prior, planned *customCrudResourceModel) {
+		planned.Input = types.DynamicUnknown()
+	}
+
+	r, req := planUpdateRequest(t, hooks, prior, prior, unknownInput)
+	req.ClientCapabilities.DeferralAllowed = true
+	resp := &fwresource.ModifyPlanResponse{Plan: req.Plan}
+	r.ModifyPlan(ctx, req, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	if resp.Deferred == nil || resp.Deferred.Reason != fwresource.DeferredReasonResourceConfigUnknown {
+		t.Errorf("Expected the plan to be deferred, got %v", resp.Deferred)
+	}
+
+	// Without client support the plan goes on as before
+	req.ClientCapabilities.DeferralAllowed = false
+	resp = &fwresource.ModifyPlanResponse{Plan: req.Plan}
+	r.ModifyPlan(ctx, req, resp)
+	if resp.Deferred != nil {
+		t.Errorf("Expected no deferral without client support, got %v", resp.Deferred)
+	}
+
+	// Known hooks and input are planned even when deferral is allowed
+	r, req = planUpdateRequest(t, hooks, prior, prior)
+	req.ClientCapabilities.DeferralAllowed = true
+	resp = &fwresource.ModifyPlanResponse{Plan: req.Plan}
+	r.ModifyPlan(ctx, req, resp)
+	if resp.Deferred != nil {
+		t.Errorf("Expected a known config not to be deferred, got %v", resp.Deferred)
+	}
+}
+
+func TestUnitOutputUnknownOnInputChange(t *testing.T) {
+	ctx := context.Background()
+	hooks := map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Update: "test_passthrough/create.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+	}
+	prior := map[string]interface{}{"name": "first"}
+
+	var output types.Dynamic
+	resp := planUpdate(t, hooks, prior, map[string]interface{}{"name": "second"})
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("output"), &output)...)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	if !output.IsUnknown() {
+		t.Errorf("Expected the output to be unknown after an input change, got %v", output)
+	}
+
+	// Unchanged input doesn't run a hook, so the output is left alone
+	resp = planUpdate(t, hooks, prior, prior)
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("output"), &output)...)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	if output.IsUnknown() {
+		t.Error("Expected the output to stay known without an input change")
+	}
+}
+
+func TestUnitPlanHookUnknownSentinel(t *testing.T) {
+	ctx := context.Background()
+	hooks := map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Update: "test_passthrough/create.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+		utils.Plan:   `jq -c '{id: "test-passthrough", name: .input.name, ip: "__unknown__", ports: [80, "__unknown__"]}'`,
+	}
+	prior := map[string]interface{}{"name": "first", "ip": "10.0.0.1", "ports": []interface{}{80, 443}}
+	planned := map[string]interface{}{"name": "second"}
+	// The framework plans computed values as unknown when the config changes
+	unknownOutput := func(prior, planned *customCrudResourceModel) {
+		planned.Output = types.DynamicUnknown()
+		planned.OutputSensitive = types.DynamicUnknown()
+	}
+
+	var output types.Dynamic
+	resp := planUpdate(t, hooks, prior, planned, unknownOutput)
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("output"), &output)...)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	attrs := output.UnderlyingValue().(types.Object).Attributes()
+	if !attrs["name"].Equal(types.StringValue("second")) || !attrs["ip"].IsUnknown() {
+		t.Errorf("Expected the sentinel to plan ip as unknown, got %v", attrs)
+	}
+	if ports := attrs["ports"].(types.Tuple).Elements(); ports[0].IsUnknown() || !ports[1].IsUnknown() {
+		t.Errorf("Expected the sentinel to plan the second port as unknown, got %v", ports)
+	}
+
+	// With output_schema the unknown values keep their declared type
+	resp = planUpdate(t, hooks, prior, planned, unknownOutput, func(prior, planned *customCrudResourceModel) {
+		planned.OutputSchema = types.StringValue("object({id = string, name = string, ip = string, ports = list(number)})")
+	})
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("output"), &output)...)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	attrs = output.UnderlyingValue().(types.Object).Attributes()
+	if !attrs["ip"].Equal(types.StringUnknown()) {
+		t.Errorf("Expected ip to be an unknown string, got %v", attrs["ip"])
+	}
+	if ports := attrs["ports"].(types.List).Elements(); !ports[1].Equal(types.NumberUnknown()) {
+		t.Errorf("Expected the second port to be an unknown number, got %v", ports)
+	}
+}
+
+func TestUnitDiffHook(t *testing.T) {
+	hooks := map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Update: "test_passthrough/create.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+		utils.Diff:   "test_diff/diff.sh",
+	}
+	prior := map[string]interface{}{"name": "planned"}
+
+	diags := planUpdate(t, hooks, prior, map[string]interface{}{"name": "renamed"}).Diagnostics
+	if diags.HasError() || diags.WarningsCount() != 1 {
+		t.Fatalf("Expected a single warning, got %v", diags)
+	}
+	if detail := diags.Warnings()[0].Detail(); detail != `name: "planned" -> "renamed"` {
+		t.Errorf("Expected the diff hook description, got %q", detail)
+	}
+
+	// The diff hook isn't run when the input is unchanged
+	if diags := planUpdate(t, hooks, prior, prior).Diagnostics; len(diags) != 0 {
+		t.Errorf("Expected no diagnostics without an input change, got %v", diags)
+	}
+
+	// Hooks run while planning are told so
+	hooks[utils.Diff] = "jq -r .phase"
+	diags = planUpdate(t, hooks, prior, map[string]interface{}{"name": "renamed"}).Diagnostics
+	if diags.WarningsCount() != 1 || diags.Warnings()[0].Detail() != utils.PhasePlan {
+		t.Errorf("Expected the diff hook to run in the plan phase, got %v", diags)
+	}
+}
+
+func TestAccResourceReplaceOnChange(t *testing.T) {
+	config := func(name, region string) string {
+		return fmt.Sprintf(`
+resource "customcrud" "test_replace_on_change" {
+  hooks {
+    create = "test_passthrough/create.sh"
+    read   = "test_passthrough/read.sh"
+    update = "test_passthrough/create.sh"
+    delete = "test_passthrough/delete.sh"
+  }
+  replace_on_change = ["network.region"]
+  input = {
+    name = %q
+    network = {
+      region = %q
+    }
+  }
+}
+`, name, region)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("first", "eu"),
+			},
+			{
+				Config: config("second", "eu"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("customcrud.test_replace_on_change", plancheck.ResourceActionUpdate),
+					},
+				},
+			},
+			{
+				Config: config("second", "us"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("customcrud.test_replace_on_change", plancheck.ResourceActionDestroyBeforeCreate),
+					},
+				},
+				Check: resource.TestCheckResourceAttr("customcrud.test_replace_on_change", "output.network.region", "us"),
+			},
+		},
+	})
+}
+
+func TestUnitChangedInputPaths(t *testing.T) {
+	prior := toDynamic(t, map[string]interface{}{"name": "a", "network": map[string]interface{}{"region": "eu"}})
+	keyPaths := []string{"name", "network.region", "missing"}
+
+	if changed := changedInputPaths(prior, prior, keyPaths); len(changed) != 0 {
+		t.Errorf("Expected no changed paths for the same input, got %v", changed)
+	}
+
+	planned := toDynamic(t, map[string]interface{}{"name": "a", "network": map[string]interface{}{"region": "us"}, "missing": "set"})
+	changed := changedInputPaths(prior, planned, keyPaths)
+	if strings.Join(changed, ",") != "network.region,missing" {
+		t.Errorf("Expected network.region and missing to change, got %v", changed)
+	}
+
+	// Input that isn't known yet may change
+	if changed := changedInputPaths(prior, types.DynamicUnknown(), keyPaths); strings.Join(changed, ",") != "name,network.region" {
+		t.Errorf("Expected unknown input to count as changed, got %v", changed)
+	}
+}
+
+func TestUnitRequiresReplaceHook(t *testing.T) {
+	hooks := map[string]string{
+		utils.Create:          "test_passthrough/create.sh",
+		utils.Read:            "test_passthrough/read.sh",
+		utils.Update:          "test_passthrough/create.sh",
+		utils.Delete:          "test_passthrough/delete.sh",
+		utils.RequiresReplace: `sh -c 'jq -e ".input.region == .prior_input.region" >/dev/null || exit 10'`,
+	}
+	prior := map[string]interface{}{"name": "a", "region": "eu"}
+
+	resp := planUpdate(t, hooks, prior, map[string]interface{}{"name": "b", "region": "eu"})
+	if resp.Diagnostics.HasError() || len(resp.RequiresReplace) != 0 {
+		t.Errorf("Expected an update, got replace %v with %v", resp.RequiresReplace, resp.Diagnostics)
+	}
+
+	resp = planUpdate(t, hooks, prior, map[string]interface{}{"name": "a", "region": "us"})
+	if resp.Diagnostics.HasError() || !resp.RequiresReplace.Contains(path.Root("input")) {
+		t.Errorf("Expected a replacement, got replace %v with %v", resp.RequiresReplace, resp.Diagnostics)
+	}
+
+	// Other exit codes are hook failures
+	hooks[utils.RequiresReplace] = "false"
+	resp = planUpdate(t, hooks, prior, map[string]interface{}{"name": "b", "region": "eu"})
+	if !resp.Diagnostics.HasError() {
+		t.Error("Expected a failing requires_replace hook to fail the plan")
+	}
+}
+
+func TestUnitValidateHook(t *testing.T) {
+	hooks := map[string]string{
+		utils.Create:   "test_passthrough/create.sh",
+		utils.Read:     "test_passthrough/read.sh",
+		utils.Update:   "test_passthrough/create.sh",
+		utils.Delete:   "test_passthrough/delete.sh",
+		utils.Validate: "test_validate/validate.sh",
+	}
+	prior := map[string]interface{}{"name": "valid", "size": 1}
+
+	if diags := planUpdate(t, hooks, prior, map[string]interface{}{"name": "renamed", "size": 2}).Diagnostics; diags.HasError() {
+		t.Errorf("Expected valid input to plan, got %v", diags)
+	}
+
+	diags := planUpdate(t, hooks, prior, map[string]interface{}{"name": "", "size": 0}).Diagnostics
+	if diags.ErrorsCount() != 2 {
+		t.Fatalf("Expected an error per invalid key, got %v", diags)
+	}
+	expected := map[string]string{"Missing Name": `input.name`, utils.DefaultValidationSummary: `input.size`}
+	for _, d := range diags.Errors() {
+		withPath, ok := d.(diag.DiagnosticWithPath)
+		if !ok || withPath.Path().String() != expected[d.Summary()] {
+			t.Errorf("Expected the error %q on %s, got %v", d.Summary(), expected[d.Summary()], d)
+		}
+	}
+
+	// Output that isn't JSON is reported on the whole input
+	hooks[utils.Validate] = "sh -c 'echo not valid; exit 3'"
+	diags = planUpdate(t, hooks, prior, map[string]interface{}{"name": "renamed"}).Diagnostics
+	if diags.ErrorsCount() != 1 || diags.Errors()[0].Detail() != "not valid" {
+		t.Fatalf("Expected the hook output as error, got %v", diags)
+	}
+	if withPath, ok := diags.Errors()[0].(diag.DiagnosticWithPath); !ok || withPath.Path().String() != "input" {
+		t.Errorf("Expected the error on the input, got %v", diags.Errors()[0])
+	}
+
+	// The validate hook isn't run when the input is unchanged
+	if diags := planUpdate(t, hooks, prior, prior).Diagnostics; len(diags) != 0 {
+		t.Errorf("Expected no diagnostics without an input change, got %v", diags)
+	}
+}
+
+func TestAccResourceTriggers(t *testing.T) {
+	config := func(hash string) string {
+		return fmt.Sprintf(`
+resource "customcrud" "test_triggers" {
+  hooks {
+    create = "test_passthrough/create.sh"
+    read   = "test_passthrough/read.sh"
+    update = "test_passthrough/create.sh"
+    delete = "test_passthrough/delete.sh"
+  }
+  triggers = {
+    script_hash = %q
+  }
+  input = {
+    name = "triggered"
+  }
+}
+`, hash)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("abc"),
+			},
+			{
+				Config: config("def"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("customcrud.test_triggers", plancheck.ResourceActionDestroyBeforeCreate),
+					},
+				},
+				Check: resource.TestCheckResourceAttr("customcrud.test_triggers", "triggers.script_hash", "def"),
+			},
+		},
+	})
+}
+
+func TestUnitTriggers(t *testing.T) {
+	hooks := map[string]string{
+		utils.Create: "test_passthrough/create.sh",
+		utils.Read:   "test_passthrough/read.sh",
+		utils.Update: "test_passthrough/create.sh",
+		utils.Delete: "test_passthrough/delete.sh",
+	}
+	input := map[string]interface{}{"name": "triggered"}
+	withTriggers := func(before, after string) func(prior, planned *customCrudResourceModel) {
+		return func(prior, planned *customCrudResourceModel) {
+			prior.Triggers = types.MapValueMust(types.StringType, map[string]attr.Value{"hash": types.StringValue(before)})
+			planned.Triggers = types.MapValueMust(types.StringType, map[string]attr.Value{"hash": types.StringValue(after)})
+		}
+	}
+
+	resp := planUpdate(t, hooks, input, input, withTriggers("abc", "abc"))
+	if resp.Diagnostics.HasError() || len(resp.RequiresReplace) != 0 {
+		t.Errorf("Expected no replacement for unchanged triggers, got %v with %v", resp.RequiresReplace, resp.Diagnostics)
+	}
+
+	resp = planUpdate(t, hooks, input, input, withTriggers("abc", "def"))
+	if resp.Diagnostics.HasError() || !resp.RequiresReplace.Contains(path.Root("triggers")) {
+		t.Errorf("Expected changed triggers to force replacement, got %v with %v", resp.RequiresReplace, resp.Diagnostics)
+	}
+}
+
+func TestUnitOutputLimits(t *testing.T) {
+	ctx := context.Background()
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.MaxOutputDepth = 3
+	config.MaxOutputNodes = 5
+	run := func(output string) error {
+		_, err := utils.Execute(ctx, config, []string{"echo", output}, utils.ExecutionPayload{})
+		return err
+	}
+
+	if err := run(`{"a": {"b": [1, 2]}}`); err != nil {
+		t.Errorf("Unexpected error within the limits: %v", err)
+	}
+	if err := run(`{"a": {"b": [{"c": 1}]}}`); err == nil || !strings.Contains(err.Error(), "key path a.b[0]") {
+		t.Errorf("Expected the depth limit to name the key path, got %v", err)
+	}
+	if err := run(`{"a": [1, 2, 3, 4, 5]}`); err == nil || !strings.Contains(err.Error(), "more than 5 values") {
+		t.Errorf("Expected the node limit to be reported, got %v", err)
+	}
+
+	config.MaxOutputDepth, config.MaxOutputNodes = 0, 0
+	if err := run(`{"a": {"b": [{"c": [1, 2, 3, 4, 5]}]}}`); err != nil {
+		t.Errorf("Unexpected error with limits disabled: %v", err)
+	}
+}
+
+func TestUnitMaxCaptureBytes(t *testing.T) {
+	ctx := context.Background()
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.MaxCaptureBytes = 16
+
+	result, err := utils.Execute(ctx, config, []string{"sh", "-c", `echo '{"id": "1"}'; printf '%040d' 0 >&2`}, utils.ExecutionPayload{})
+	if err != nil {
+		t.Fatalf("Unexpected error with a truncated stderr: %v", err)
+	}
+	if result.Result["id"] != "1" {
+		t.Errorf("Expected the output to be parsed, got %v", result.Result)
+	}
+	file := regexp.MustCompile(`full output saved to (\S+)\]`).FindStringSubmatch(result.Stderr)
+	if file == nil {
+		t.Fatalf("Expected the truncated stderr to name the spill file, got %q", result.Stderr)
+	}
+	defer os.Remove(file[1])
+	if content, err := os.ReadFile(file[1]); err != nil || len(content) != 40 {
+		t.Errorf("Expected the spill file to hold the full stderr, got %q (%v)", content, err)
+	}
+
+	result, err = utils.Execute(ctx, config, []string{"sh", "-c", `echo '{"id": "1", "padding": "0000000000"}'`}, utils.ExecutionPayload{})
+	if err == nil || !strings.Contains(err.Error(), "stdout exceeded 16 bytes") {
+		t.Fatalf("Expected a truncated stdout to fail the hook, got %v", err)
+	}
+	if file := regexp.MustCompile(`saved to (\S+)$`).FindStringSubmatch(err.Error()); file != nil {
+		os.Remove(file[1])
+	}
+	if !strings.HasPrefix(result.Stdout, `{"id": "1", "pad`) {
+		t.Errorf("Expected the captured stdout to be kept, got %q", result.Stdout)
+	}
+}
+
+func TestUnitInputSocket(t *testing.T) {
+	ctx := context.Background()
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.InputSocketThreshold = 64
+	cmd := []string{"sh", "-c", `socket=$(jq -r .input_socket) && curl -sf --unix-socket "$socket" http://customcrud/ | jq -c '{id: .id, size: (.input.data | length)}'`}
+	payload := utils.ExecutionPayload{Id: "blob-1", Input: map[string]interface{}{"data": strings.Repeat("x", 1000)}}
+
+	result, err := utils.Execute(ctx, config, cmd, payload)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v (stderr %q)", err, result.Stderr)
+	}
+	if result.Result["id"] != "blob-1" || fmt.Sprint(result.Result["size"]) != "1000" {
+		t.Errorf("Expected the hook to fetch the full payload from the socket, got %v", result.Result)
+	}
+
+	// Smaller payloads are written to stdin as usual
+	result, err = utils.Execute(ctx, config, []string{"jq", "-c", "{id: .id}"}, utils.ExecutionPayload{Id: "small"})
+	if err != nil || result.Result["id"] != "small" {
+		t.Errorf("Expected a small payload on stdin, got %v (%v)", result.Result, err)
+	}
+}
+
+func TestUnitMergeStrategy(t *testing.T) {
+	r := &customCrudResource{}
+	input := toDynamic(t, map[string]interface{}{
+		"name":     "app",
+		"settings": map[string]interface{}{"region": "EU", "size": "small"},
+	})
+	output := map[string]interface{}{
+		"name":     "app",
+		"settings": map[string]interface{}{"region": "eu", "size": "small", "created": "today"},
+		"id":       "1",
+	}
+
+	// The shallow merge takes the whole nested object from the output
+	merged, diags := r.mergeInputWithOutput(input, output, mergeStrategyShallow)
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	settings := utils.AttrValueToInterface(merged.UnderlyingValue()).(map[string]interface{})["settings"].(map[string]interface{})
+	if settings["region"] != "eu" || settings["created"] != "today" {
+		t.Errorf("Expected the shallow merge to replace settings, got %v", settings)
+	}
+
+	// The deep merge only updates the nested keys set in input
+	merged, diags = r.mergeInputWithOutput(input, output, mergeStrategyDeep)
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	value := utils.AttrValueToInterface(merged.UnderlyingValue()).(map[string]interface{})
+	settings = value["settings"].(map[string]interface{})
+	if settings["region"] != "eu" || settings["size"] != "small" {
+		t.Errorf("Expected the deep merge to update nested input keys, got %v", settings)
+	}
+	if _, ok := settings["created"]; ok {
+		t.Errorf("Expected the deep merge to skip nested keys missing from input, got %v", settings)
+	}
+	if _, ok := value["id"]; ok {
+		t.Errorf("Expected top-level keys missing from input to be skipped, got %v", value)
+	}
+
+	// Encrypted input values keep their ciphertext
+	input = toDynamic(t, map[string]interface{}{"password": testAgeMessage, "db": testSOPSDocument})
+	merged, diags = r.mergeInputWithOutput(input, map[string]interface{}{"password": `s3cr3t "pass"`, "db": "s3cr3t-sops"}, mergeStrategyShallow)
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	if !merged.Equal(input) {
+		t.Errorf("Expected the plaintext output to stay out of input, got %v", merged)
+	}
+}
+
+func TestUnitOutputSchema(t *testing.T) {
+	ctx := context.Background()
+	data := &customCrudResourceModel{
+		OutputSchema: types.StringValue(`object({name = string, count = number, ports = list(number), token = optional(string), enabled = optional(bool)})`),
+	}
+	store := func(output map[string]interface{}, sensitive ...string) diag.Diagnostics {
+		return data.storeOutput(output, sensitive, utils.CollectionTypingTuple)
+	}
+
+	if diags := store(map[string]interface{}{"name": float64(5), "count": "3", "ports": []interface{}{}, "extra": true}); diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	first := data.Output.UnderlyingValue().Type(ctx)
+	value := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{})
+	if value["name"] != "5" || fmt.Sprint(value["count"]) != "3" || value["enabled"] != nil {
+		t.Errorf("Expected the output to be coerced, got %v", value)
+	}
+	if _, ok := value["extra"]; ok {
+		t.Errorf("Expected attributes missing from the schema to be dropped, got %v", value)
+	}
+
+	// Different values keep the same type
+	if diags := store(map[string]interface{}{"name": "app", "count": float64(1), "ports": []interface{}{float64(80), "443"}, "enabled": "true"}); diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	if second := data.Output.UnderlyingValue().Type(ctx); !second.Equal(first) {
+		t.Errorf("Expected a stable output type, got %s and %s", first, second)
+	}
+
+	// Sensitive values are split out of the typed output
+	if diags := store(map[string]interface{}{"name": "app", "count": float64(1), "ports": []interface{}{}, "token": "secret"}, "token"); diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	if value := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{}); value["token"] != nil {
+		t.Errorf("Expected the sensitive value to be null in output, got %v", value)
+	}
+	if value := utils.AttrValueToInterface(data.OutputSensitive.UnderlyingValue()).(map[string]interface{}); value["token"] != "secret" {
+		t.Errorf("Expected the sensitive value in output_sensitive, got %v", value)
+	}
+
+	if diags := store(map[string]interface{}{"count": float64(1), "ports": []interface{}{"x"}}); !diags.HasError() || !strings.Contains(summaryOf(diags), "name") || !strings.Contains(summaryOf(diags), "ports[0]") {
+		t.Errorf("Expected missing and invalid values to be reported, got %v", diags)
+	}
+
+	data.OutputSchema = types.StringValue(`["map", "string"]`)
+	if diags := store(map[string]interface{}{"a": float64(1), "b": true}); diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	if _, ok := data.Output.UnderlyingValue().(types.Map); !ok {
+		t.Errorf("Expected a map output, got %T", data.Output.UnderlyingValue())
+	}
+
+	for _, invalid := range []string{"list(string)", "object({", `["object"]`} {
+		if _, err := utils.ParseOutputSchema(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func summaryOf(diags diag.Diagnostics) string {
+	var details []string
+	for _, d := range diags {
+		details = append(details, d.Detail())
+	}
+	return strings.Join(details, "\n")
+}
+
+func TestUnitComputedInputKeys(t *testing.T) {
+	r := &customCrudResource{}
+	data := &customCrudResourceModel{
+		Input: toDynamic(t, map[string]interface{}{
+			"name":    "App",
+			"version": "1.2",
+			"removed": "gone",
+		}),
+		ComputedInputKeys: types.ListValueMust(types.StringType, []attr.Value{
+			types.StringValue("version"),
+			types.StringValue("removed"),
+		}),
+	}
+	output := map[string]interface{}{"id": "1", "name": "app", "version": "1.2.3"}
+
+	// Applying the hook output keeps the planned input
+	if diags := r.applyResult(data, output, nil); diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	value := utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if value["version"] != "1.2" || value["name"] != "App" {
+		t.Errorf("Expected the planned input to be kept, got %v", value)
+	}
+
+	// A refresh syncs the computed keys
+	if diags := r.syncComputedInput(data, output); diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	value = utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if value["version"] != "1.2.3" {
+		t.Errorf("Expected the computed key to be synced, got %v", value)
+	}
+	if value["name"] != "App" {
+		t.Errorf("Expected other keys to keep their configured value, got %v", value)
+	}
+
+	// An authoritative read only drops computed keys
+	pruned, diags := pruneInput(data.Input, output, false, data.computedInputKeys())
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	value = utils.AttrValueToInterface(pruned.UnderlyingValue()).(map[string]interface{})
+	if _, ok := value["removed"]; ok || value["name"] != "App" {
+		t.Errorf("Expected only computed keys missing from the output to be dropped, got %v", value)
+	}
+}
+
+func TestAccResourceComputedInputKeys(t *testing.T) {
+	config := func(version string) string {
+		return fmt.Sprintf(`
+resource "customcrud" "test" {
+  hooks {
+    create = "test_computed_input/create.sh"
+    read   = "test_computed_input/read.sh"
+    update = "test_computed_input/create.sh"
+    delete = "test_computed_input/delete.sh"
+  }
+  input = {
+    name    = "app"
+    version = %q
+  }
+  computed_input_keys = ["version"]
+}
+`, version)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The normalized version the refresh syncs into input isn't drift
+			{
+				Config: config("1.2"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test", "input.version", "1.2"),
+					resource.TestCheckResourceAttr("customcrud.test", "output.version", "1.2.0"),
+				),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PostApplyPostRefresh: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+			},
+			// A changed version still runs the update
+			{
+				Config: config("1.3"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("customcrud.test", plancheck.ResourceActionUpdate),
+					},
+					PostApplyPostRefresh: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+				Check: resource.TestCheckResourceAttr("customcrud.test", "output.version", "1.3.0"),
+			},
+		},
+	})
+}
+
+func TestUnitComputedInputKeysDrift(t *testing.T) {
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	(&customCrudResource{}).Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Read:   "./read.sh",
+		utils.Update: "./update.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	var ran []string
+	server := protocolServer(t, &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		ran = append(ran, req.Command[0])
+		// The backend normalizes the version
+		return &utils.ExecResponse{Stdout: []byte(`{"id": "app-1", "name": "app", "version": "1.2.0"}`)}, nil
+	}})
+	model := nullResourceModel()
+	model.Hooks = hooks
+	model.Input = toDynamic(t, map[string]interface{}{"name": "app", "version": "1.2"})
+	model.ComputedInputKeys = types.ListValueMust(types.StringType, []attr.Value{types.StringValue("version")})
+	inputOf := func(state tftypes.Value) map[string]tftypes.Value {
+		var attrs, input map[string]tftypes.Value
+		_ = state.As(&attrs)
+		_ = attrs["input"].As(&input)
+		return input
+	}
+
+	// The create stores the configured input
+	_, resp, state := applyChange(t, server, tftypes.Value{}, nil, model)
+	if diagsHaveError(resp.Diagnostics) {
+		t.Fatalf("Unexpected diagnostics: %s", protoDiags(resp.Diagnostics))
+	}
+	if version := inputOf(state)["version"]; !version.Equal(tftypes.NewValue(tftypes.String, "1.2")) {
+		t.Errorf("Expected the configured version in state after create, got %v", version)
+	}
+
+	// A refresh syncs the normalized version
+	readResp, state := readResource(t, server, state, resp.Private)
+	if version := inputOf(state)["version"]; !version.Equal(tftypes.NewValue(tftypes.String, "1.2.0")) {
+		t.Errorf("Expected the refresh to sync the normalized version, got %v", version)
+	}
+
+	// and planning the same configuration keeps the prior input
+	planResp, _, _ := planChange(t, server, state, readResp.Private, model)
+	if diagsHaveError(planResp.Diagnostics) {
+		t.Fatalf("Unexpected diagnostics: %s", protoDiags(planResp.Diagnostics))
+	}
+	planned, _ := planResp.PlannedState.Unmarshal(state.Type())
+	if !planned.Equal(state) || len(planResp.RequiresReplace) != 0 {
+		t.Errorf("Expected an empty plan after drift on a computed key, got %v", planned)
+	}
+
+	// Changing the configured version still plans an update
+	changed := model
+	changed.Input = toDynamic(t, map[string]interface{}{"name": "app", "version": "1.3"})
+	planResp, _, _ = planChange(t, server, state, readResp.Private, changed)
+	planned, _ = planResp.PlannedState.Unmarshal(state.Type())
+	if version := inputOf(planned)["version"]; !version.Equal(tftypes.NewValue(tftypes.String, "1.3")) {
+		t.Errorf("Expected the changed version to be planned, got %v", version)
+	}
+}
+
+func TestUnitPruneInput(t *testing.T) {
+	input := toDynamic(t, map[string]interface{}{
+		"name":     "app",
+		"removed":  "gone",
+		"settings": map[string]interface{}{"region": "eu", "size": "small"},
+	})
+	output := map[string]interface{}{
+		"name":     "app",
+		"settings": map[string]interface{}{"region": "eu"},
+	}
+
+	// A shallow prune only drops the top-level keys missing from the output
+	pruned, diags := pruneInput(input, output, false, nil)
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	value := utils.AttrValueToInterface(pruned.UnderlyingValue()).(map[string]interface{})
+	if _, ok := value["removed"]; ok {
+		t.Errorf("Expected keys missing from the output to be dropped, got %v", value)
+	}
+	if settings := value["settings"].(map[string]interface{}); settings["size"] != "small" {
+		t.Errorf("Expected the shallow prune to keep nested keys, got %v", settings)
+	}
+
+	// A deep prune also drops the nested keys missing from the output
+	pruned, diags = pruneInput(input, output, true, nil)
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	value = utils.AttrValueToInterface(pruned.UnderlyingValue()).(map[string]interface{})
+	if settings := value["settings"].(map[string]interface{}); len(settings) != 1 || settings["region"] != "eu" {
+		t.Errorf("Expected the deep prune to drop nested keys, got %v", settings)
+	}
+	if value["name"] != "app" {
+		t.Errorf("Expected keys found in the output to be kept, got %v", value)
+	}
+}
+
+func TestUnitOutputHash(t *testing.T) {
+	output := map[string]interface{}{"id": "1", "nested": map[string]interface{}{"a": float64(1), "b": "x"}}
+	same := map[string]interface{}{"nested": map[string]interface{}{"b": "x", "a": float64(1)}, "id": "1"}
+	data := &customCrudResourceModel{}
+
+	hash := outputHash(data, output, nil)
+	if hash == nil || !json.Valid(hash) {
+		t.Fatalf("Expected the hash to be valid private state JSON, got %s", hash)
+	}
+	if !bytes.Equal(hash, outputHash(data, same, nil)) {
+		t.Error("Expected the same output to hash the same regardless of key order")
+	}
+	if bytes.Equal(hash, outputHash(data, map[string]interface{}{"id": "2", "nested": output["nested"]}, nil)) {
+		t.Error("Expected a changed output to change the hash")
+	}
+	if bytes.Equal(hash, outputHash(data, output, []string{"nested.b"})) {
+		t.Error("Expected changed sensitive key paths to change the hash")
+	}
+	if bytes.Equal(hash, outputHash(&customCrudResourceModel{ReadMode: types.StringValue(readModeReplace)}, output, nil)) {
+		t.Error("Expected a changed read_mode to change the hash")
+	}
+}
+
+func TestUnitTransactionGroup(t *testing.T) {
+	ctx := context.Background()
+	deleted := filepath.Join(t.TempDir(), "deleted")
+	groups := utils.NewTransactionGroups()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: `jq -c '{id: .input.name}'`,
+		utils.Read:   `jq -c '{id: .id}'`,
+		utils.Delete: fmt.Sprintf(`sh -c 'jq -r "[.id, .phase, .private.token] | @tsv" >> %s'`, deleted),
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	member := func(id string) utils.Rollback {
+		data := nullResourceModel()
+		data.Id = types.StringValue(id)
+		data.Hooks = hooks
+		data.Input = toDynamic(t, map[string]interface{}{"name": id})
+		data.Output = toDynamic(t, map[string]interface{}{"id": id})
+		data.OutputSensitive = types.DynamicNull()
+		return r.rollbackCreate(&data, map[string]interface{}{"token": id + "-token"}, nil)
+	}
+
+	for _, id := range []string{"network", "disk"} {
+		if !groups.Join("vm", member(id)) {
+			t.Fatalf("Expected %s to join the group", id)
+		}
+	}
+	groups.Join("other", member("dns"))
+	if groups.Failed("vm") {
+		t.Fatal("Expected the group not to fail before a create fails")
+	}
+
+	// A failed create rolls back the members of its group, newest first
+	for _, rollback := range groups.Fail("vm") {
+		rollback(ctx, &diags)
+	}
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	content, err := os.ReadFile(deleted)
+	if err != nil || string(content) != "disk\tdestroy\tdisk-token\nnetwork\tdestroy\tnetwork-token\n" {
+		t.Errorf("Expected the delete hooks of the vm group members, got %q (%v)", content, err)
+	}
+	if len(groups.Fail("vm")) != 0 {
+		t.Error("Expected the members to be rolled back once")
+	}
+
+	// Members created after the failure roll back themselves
+	if !groups.Failed("vm") || groups.Join("vm", member("nic")) {
+		t.Error("Expected the failed group to refuse new members")
+	}
+	if groups.Failed("other") || !groups.Join("other", member("mail")) {
+		t.Error("Expected other groups to be unaffected")
+	}
+}
+
+func TestUnitHookRuntimes(t *testing.T) {
+	ctx := context.Background()
+	runtime := func(options map[string]string) tftypes.Value {
+		values := map[string]tftypes.Value{}
+		for k, v := range options {
+			values[k] = tftypes.NewValue(tftypes.String, v)
+		}
+		return tftypes.NewValue(tftypes.Map{ElementType: tftypes.String}, values)
+	}
+	p := configureProvider(t, map[string]tftypes.Value{
+		"executor":         tftypes.NewValue(tftypes.String, "mock"),
+		"executor_options": runtime(map[string]string{"stdout": `{"via": "default"}`}),
+		"runtimes": tftypes.NewValue(tftypes.Map{ElementType: tftypes.Map{ElementType: tftypes.String}}, map[string]tftypes.Value{
+			"fast": runtime(map[string]string{"executor": "mock", "stdout": `{"via": "fast"}`}),
+		}),
+	})
+	if _, ok := p.config.Runtimes["fast"].(*utils.MockExecutor); !ok {
+		t.Fatalf("Expected the fast runtime to be a mock executor, got %T", p.config.Runtimes["fast"])
+	}
+
+	r := &customCrudResource{config: p.config}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "create.sh",
+		utils.Read:   "read.sh",
+		utils.Update: "update.sh",
+		utils.Delete: "delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	hooksObj := hooks.Elements()[0].(types.Object)
+	attrs := hooksObj.Attributes()
+	attrs[utils.Runtimes] = types.MapValueMust(types.StringType, map[string]attr.Value{
+		utils.Read:   types.StringValue("fast"),
+		utils.Delete: types.StringValue("appliance"),
+	})
+	data := nullResourceModel()
+	data.Hooks = types.ListValueMust(hooksObj.Type(ctx), []attr.Value{types.ObjectValueMust(hooksObj.AttributeTypes(ctx), attrs)})
+
+	tests := []struct {
+		op  utils.CrudOp
+		via string
+	}{
+		{utils.CrudRead, "fast"},
+		{utils.CrudUpdate, "default"},
+	}
+	for _, tt := range tests {
+		var diags diag.Diagnostics
+		result, ok := utils.RunCrudScript(ctx, r.config, &data, utils.ExecutionPayload{Id: "1"}, &diags, tt.op)
+		if !ok {
+			t.Fatalf("Failed to run the %v hook: %v", tt.op, diags)
+		}
+		if result.Result["via"] != tt.via {
+			t.Errorf("Expected the %v hook to run via %s, got %v", tt.op, tt.via, result.Result)
+		}
+	}
+
+	diags = nil
+	if _, ok := utils.RunCrudScript(ctx, r.config, &data, utils.ExecutionPayload{Id: "1"}, &diags, utils.CrudDelete); ok || diags.Errors()[0].Summary() != "Unknown Runtime" {
+		t.Errorf("Expected an unknown runtime to fail, got: %v", diags)
+	}
+}
+
+func TestUnitPlatformHooks(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Read:   "./read.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	hooksObj := hooks.Elements()[0].(types.Object)
+	withPlatforms := func(platforms map[string]map[string]string) *customCrudResourceModel {
+		elems := map[string]attr.Value{}
+		for goos, overrides := range platforms {
+			values := map[string]attr.Value{}
+			for name, value := range overrides {
+				values[name] = types.StringValue(value)
+			}
+			elems[goos] = types.MapValueMust(types.StringType, values)
+		}
+		attrs := hooksObj.Attributes()
+		attrs[utils.Platforms] = types.MapValueMust(types.MapType{ElemType: types.StringType}, elems)
+		data := nullResourceModel()
+		data.Hooks = types.ListValueMust(hooksObj.Type(ctx), []attr.Value{types.ObjectValueMust(hooksObj.AttributeTypes(ctx), attrs)})
+		return &data
+	}
+
+	data := withPlatforms(map[string]map[string]string{
+		"windows": {utils.Create: "pwsh -File create.ps1", utils.Update: "pwsh -File update.ps1"},
+	})
+	attrs := data.Hooks.Elements()[0].(types.Object).Attributes()
+	for goos, expected := range map[string][2]string{
+		"windows": {"pwsh -File create.ps1", "pwsh -File update.ps1"},
+		"linux":   {"./create.sh", ""},
+	} {
+		selected, err := utils.PlatformAttributes(attrs, goos)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if create := selected[utils.Create].(types.String).ValueString(); create != expected[0] {
+			t.Errorf("%s: expected create %q, got %q", goos, expected[0], create)
+		}
+		if update := selected[utils.Update].(types.String).ValueString(); update != expected[1] {
+			t.Errorf("%s: expected update %q, got %q", goos, expected[1], update)
+		}
+		if read := selected[utils.Read].(types.String).ValueString(); read != "./read.sh" {
+			t.Errorf("%s: expected the default read hook, got %q", goos, read)
+		}
+	}
+
+	// The commands of the operating system the provider runs on are used
+	crud, err := getCrudCommands(withPlatforms(map[string]map[string]string{runtime.GOOS: {utils.Delete: "./native-delete"}}), runtime.GOOS)
+	if err != nil || crud.Delete.ValueString() != "./native-delete" || crud.Create.ValueString() != "./create.sh" {
+		t.Errorf("Expected the %s delete hook, got %v (%v)", runtime.GOOS, crud, err)
+	}
+
+	for _, name := range []string{utils.Runtimes, "deploy"} {
+		if _, err := getCrudCommands(withPlatforms(map[string]map[string]string{runtime.GOOS: {name: "x"}}), runtime.GOOS); err == nil {
+			t.Errorf("Expected a platform override of %s to fail", name)
+		}
+	}
+
+	// Remote executors run the commands of their own system, whatever the
+	// system the provider runs on. The fake ssh prints the remote command.
+	ssh := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(ssh, []byte("#!/bin/sh\nfor arg; do last=$arg; done\nprintf '{\"id\": \"1\", \"command\": \"%s\"}' \"$last\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	remote, err := utils.NewExecutor("ssh", map[string]string{"host": "runner", "binary": ssh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = withPlatforms(map[string]map[string]string{
+		"linux":   {utils.Create: "./linux-create"},
+		"windows": {utils.Create: "./windows-create"},
+	})
+	for name, config := range map[string]utils.CustomCRUDProviderConfig{
+		"executor": {Executor: remote},
+		"runtime":  {Executor: &utils.MockExecutor{}, Runtimes: map[string]utils.Executor{"runner": remote}},
+	} {
+		hookData := *data
+		if name == "runtime" {
+			attrs := hookData.Hooks.Elements()[0].(types.Object).Attributes()
+			attrs[utils.Runtimes] = types.MapValueMust(types.StringType, map[string]attr.Value{utils.Create: types.StringValue("runner")})
+			hookData.Hooks = types.ListValueMust(hooksObj.Type(ctx), []attr.Value{types.ObjectValueMust(hooksObj.AttributeTypes(ctx), attrs)})
+		}
+		var diags diag.Diagnostics
+		result, ok := utils.RunCrudScript(ctx, config, &hookData, utils.ExecutionPayload{}, &diags, utils.CrudCreate)
+		if !ok || result.Result["command"] != "./linux-create" {
+			t.Errorf("%s: expected the linux create hook to run remotely, got %v (%v)", name, result, diags)
+		}
+	}
+}
+
+func TestUnitHookAttribute(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Read:   "./read.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	empty, _ := emptyHooks(ctx, schemaResp.Schema)
+
+	data := nullResourceModel()
+	data.Hooks = empty
+	data.Hook = hooks.Elements()[0].(types.Object)
+	if data.withoutHooks() {
+		t.Error("Expected the hook attribute to count as hooks")
+	}
+	crud, err := getCrudCommands(&data, runtime.GOOS)
+	if err != nil {
+		t.Fatalf("Failed to get CRUD commands: %v", err)
+	}
+	if crud.Create.ValueString() != "./create.sh" || !crud.Update.IsNull() {
+		t.Errorf("Expected the hooks of the hook attribute, got %v and %v", crud.Create, crud.Update)
+	}
+	if identity := identityFor(&data); identity.Read.ValueString() != "./read.sh" {
+		t.Errorf("Expected the identity to carry the hook attribute, got %v", identity.Read)
+	}
+
+	validate := func(data customCrudResourceModel) diag.Diagnostics {
+		config := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+		if diags := config.Set(ctx, &data); diags.HasError() {
+			t.Fatalf("Failed to build config: %v", diags)
+		}
+		resp := &fwresource.ValidateConfigResponse{}
+		r.ValidateConfig(ctx, fwresource.ValidateConfigRequest{Config: tfsdk.Config{Schema: config.Schema, Raw: config.Raw}}, resp)
+		return resp.Diagnostics
+	}
+	if diags := validate(data); diags.HasError() {
+		t.Errorf("Unexpected diagnostics: %v", diags)
+	}
+	// A hooks block and the hook attribute exclude each other
+	data.Hooks = hooks
+	if diags := validate(data); diags.ErrorsCount() != 1 || diags.Errors()[0].Summary() != "Conflicting Hooks" || !strings.Contains(diags.Errors()[0].Detail(), "hook can't be combined with a hooks block") {
+		t.Errorf("Expected hooks and hook to conflict, got %v", diags)
+	}
+}
+
+func TestUnitHookArgv(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Read:   "./read.sh 'two words'",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	obj := hooks.Elements()[0].(types.Object)
+	attrs := obj.Attributes()
+	attrs[utils.Argv] = types.MapValueMust(types.ListType{ElemType: types.StringType}, map[string]attr.Value{
+		utils.Create: types.ListValueMust(types.StringType, []attr.Value{
+			types.StringValue("/no/such/python3"), types.StringValue("manage.py"), types.StringValue("create resource"),
+		}),
+	})
+	data := nullResourceModel()
+	data.Hooks = types.ListValueMust(obj.Type(ctx), []attr.Value{types.ObjectValueMust(obj.AttributeTypes(ctx), attrs)})
+
+	var commands [][]string
+	config := r.config
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		commands = append(commands, req.Command)
+		return &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1"}`)}, nil
+	}}
+	for _, op := range []utils.CrudOp{utils.CrudCreate, utils.CrudRead} {
+		if _, ok := utils.RunCrudScript(ctx, config, &data, utils.ExecutionPayload{}, &diags, op); !ok {
+			t.Fatalf("%v: unexpected diagnostics: %v", op, diags)
+		}
+	}
+	expected := [][]string{{"/no/such/python3", "manage.py", "create resource"}, {"./read.sh", "two words"}}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected the create argv verbatim and the read command split, got %q", commands)
+	}
+
+	crud, err := getCrudCommands(&data, runtime.GOOS)
+	if err != nil {
+		t.Fatalf("Failed to get CRUD commands: %v", err)
+	}
+	if !crud.defines(utils.Create) || crud.defines(utils.Update) {
+		t.Errorf("Expected only create to be defined by argv, got %v", crud.Argv)
+	}
+	if identity := identityFor(&data); !identity.Create.IsNull() || !identity.Read.IsNull() {
+		t.Errorf("Expected the identity to carry no hooks, got %v", identity)
+	}
+	diags = nil
+	verifyHooks(crud, t.TempDir(), &diags)
+	expectedPath := path.Root("hooks").AtListIndex(0).AtName(utils.Argv).AtMapKey(utils.Create)
+	if len(diags.Errors()) == 0 || !strings.Contains(diags.Errors()[0].Detail(), "/no/such/python3 does not exist") {
+		t.Errorf("Expected the create argv program to be verified, got %v", diags)
+	} else if withPath, ok := diags.Errors()[0].(diag.DiagnosticWithPath); !ok || !withPath.Path().Equal(expectedPath) {
+		t.Errorf("Expected the error on %s, got %v", expectedPath, diags.Errors()[0])
+	}
+}
+
+func TestUnitInlineScripts(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create + utils.ScriptSuffix: "\n#!/bin/sh\r\necho '{\"id\": \"inline\"}'\r\n",
+		utils.Read + utils.ScriptSuffix:   "echo '{\"id\": \"read\"}'\n",
+		utils.Delete:                      "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	data := nullResourceModel()
+	data.Hooks = hooks
+
+	for op, id := range map[utils.CrudOp]string{utils.CrudCreate: "inline", utils.CrudRead: "read"} {
+		result, ok := utils.RunCrudScript(ctx, r.config, &data, utils.ExecutionPayload{}, &diags, op)
+		if !ok || result.Result["id"] != id {
+			t.Fatalf("%v: expected the inline script to print the id %s, got %v (%v)", op, id, result, diags)
+		}
+	}
+
+	// The script file only exists while the hook runs
+	var file string
+	config := r.config
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		file = req.Command[len(req.Command)-1]
+		content, err := os.ReadFile(file)
+		if err != nil || !strings.HasPrefix(string(content), "#!/bin/sh\n") {
+			t.Errorf("Expected the script without leading blank lines or carriage returns, got %q (%v)", content, err)
+		}
+		return &utils.ExecResponse{Stdout: []byte(`{"id": "mock"}`)}, nil
+	}}
+	if _, ok := utils.RunCrudScript(ctx, config, &data, utils.ExecutionPayload{}, &diags, utils.CrudCreate); !ok {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("Expected the script file %s to be removed, got %v", file, err)
+	}
+
+	crud, err := getCrudCommands(&data, runtime.GOOS)
+	if err != nil {
+		t.Fatalf("Failed to get CRUD commands: %v", err)
+	}
+	if !crud.defines(utils.Create) || crud.defines(utils.Update) {
+		t.Errorf("Expected create to be defined by its script, got %v", crud.Scripts)
+	}
+
+	config.Executor, err = utils.NewExecutor("ssh", map[string]string{"host": "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags = nil
+	if _, ok := utils.RunCrudScript(ctx, config, &data, utils.ExecutionPayload{}, &diags, utils.CrudCreate); ok || diags.Errors()[0].Summary() != "Invalid create Script" {
+		t.Errorf("Expected inline scripts to need the local executor, got %v", diags)
+	}
+
+	for _, tt := range []struct {
+		goos, script string
+		expected     []string
+	}{
+		{"linux", "#!/usr/bin/env python3\n", []string{"/tmp/s"}},
+		{"linux", "echo hi\n", []string{"sh", "/tmp/s"}},
+		{"windows", "#!/usr/bin/env python3\n", []string{"python3", "/tmp/s"}},
+		{"windows", "#!/bin/bash -e\n", []string{"bash", "-e", "/tmp/s"}},
+		{"windows", "echo hi\r\n", []string{"cmd", "/c", "/tmp/s"}},
+	} {
+		if got := utils.ScriptCommandOn("/tmp/s", tt.script, tt.goos); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s %q: expected %q, got %q", tt.goos, tt.script, tt.expected, got)
+		}
+	}
+}
+
+func TestUnitScriptHash(t *testing.T) {
+	ctx := context.Background()
+	hooks := map[string]string{
+		utils.Create + utils.ScriptSuffix: "echo '{\"id\": \"inline\"}'\n",
+		utils.Read:                        "test_passthrough/read.sh",
+		utils.Update:                      "test_passthrough/create.sh",
+		utils.Delete:                      "test_passthrough/delete.sh",
+	}
+	prior := map[string]interface{}{"name": "first"}
+	scriptHash := func(t *testing.T, resp *fwresource.ModifyPlanResponse) types.String {
+		t.Helper()
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+		}
+		var hash types.String
+		resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("script_hash"), &hash)...)
+		return hash
+	}
+
+	resp := planUpdate(t, hooks, prior, prior)
+	hash := scriptHash(t, resp)
+	if hash.IsNull() || hash.IsUnknown() {
+		t.Fatalf("Expected the inline script to be hashed, got %v", hash)
+	}
+	if len(resp.RequiresReplace) > 0 {
+		t.Errorf("Expected a first script_hash not to replace the resource, got %v", resp.RequiresReplace)
+	}
+
+	// Hook files are only hashed when asked to
+	withFiles := func(prior, planned *customCrudResourceModel) {
+		planned.HashHookFiles = types.BoolValue(true)
+	}
+	if got := scriptHash(t, planUpdate(t, hooks, prior, prior, withFiles)); got.Equal(hash) {
+		t.Errorf("Expected hash_hook_files to change the hash, got %v", got)
+	}
+	delete(hooks, utils.Create+utils.ScriptSuffix)
+	hooks[utils.Create] = "test_passthrough/create.sh"
+	if got := scriptHash(t, planUpdate(t, hooks, prior, prior)); !got.IsNull() {
+		t.Errorf("Expected no hash without inline scripts, got %v", got)
+	}
+	if got := scriptHash(t, planUpdate(t, hooks, prior, prior, withFiles)); got.IsNull() {
+		t.Errorf("Expected the hook files to be hashed, got %v", got)
+	}
+	hooks[utils.Create+utils.ScriptSuffix] = "echo '{\"id\": \"inline\"}'\n"
+	delete(hooks, utils.Create)
+
+	changed := func(change string) func(prior, planned *customCrudResourceModel) {
+		return func(prior, planned *customCrudResourceModel) {
+			prior.ScriptHash = types.StringValue("previous")
+			planned.ScriptHash = types.StringUnknown()
+			planned.ScriptChange = types.StringNull()
+			if change != "" {
+				planned.ScriptChange = types.StringValue(change)
+			}
+		}
+	}
+
+	// By default a changed script only shows up as a script_hash change
+	resp = planUpdate(t, hooks, prior, prior, changed(""))
+	if got := scriptHash(t, resp); !got.Equal(hash) || len(resp.RequiresReplace) > 0 {
+		t.Errorf("Expected the new hash without replacement, got %v, %v", got, resp.RequiresReplace)
+	}
+	var output types.Dynamic
+	resp.Plan.GetAttribute(ctx, path.Root("output"), &output)
+	if output.IsUnknown() {
+		t.Error("Expected the output to be kept when the script change runs no hook")
+	}
+
+	resp = planUpdate(t, hooks, prior, prior, changed(scriptChangeReplace))
+	if scriptHash(t, resp); !resp.RequiresReplace.Contains(path.Root("script_hash")) {
+		t.Errorf("Expected script_change replace to replace the resource, got %v", resp.RequiresReplace)
+	}
+
+	resp = planUpdate(t, hooks, prior, prior, changed(scriptChangeUpdate))
+	if scriptHash(t, resp); len(resp.RequiresReplace) > 0 {
+		t.Errorf("Expected script_change update to update in place, got %v", resp.RequiresReplace)
+	}
+	resp.Plan.GetAttribute(ctx, path.Root("output"), &output)
+	if !output.IsUnknown() {
+		t.Error("Expected the output to be unknown when the update hook runs again")
+	}
+
+	delete(hooks, utils.Update)
+	resp = planUpdate(t, hooks, prior, prior, changed(scriptChangeUpdate))
+	if scriptHash(t, resp); !resp.RequiresReplace.Contains(path.Root("script_hash")) {
+		t.Errorf("Expected script_change update without an update hook to replace the resource, got %v", resp.RequiresReplace)
+	}
+
+	state := nullResourceModel()
+	state.ScriptHash = types.StringValue("previous")
+	plan := nullResourceModel()
+	plan.ScriptHash = hash
+	if scriptRerun(&state, &plan) {
+		t.Error("Expected no rerun without script_change update")
+	}
+	plan.ScriptChange = types.StringValue(scriptChangeUpdate)
+	if !scriptRerun(&state, &plan) {
+		t.Error("Expected a changed hash to rerun update")
+	}
+	state.ScriptHash = types.StringNull()
+	if scriptRerun(&state, &plan) {
+		t.Error("Expected no rerun for state without a prior hash")
+	}
+}
+
+func TestUnitOptionalRead(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		t.Errorf("Expected no hook to run, got %v", req.Command)
+		return &utils.ExecResponse{Stdout: []byte(`{}`)}, nil
+	}}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks := map[string]string{utils.Create: "test_passthrough/create.sh", utils.Delete: "test_passthrough/delete.sh"}
+	hooksList, diags := importHooks(ctx, schemaResp.Schema, hooks)
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	data := nullResourceModel()
+	data.Id = types.StringValue("sent")
+	data.Hooks = hooksList
+	data.Input = toDynamic(t, map[string]interface{}{"message": "hello"})
+	data.InputWO = types.DynamicNull()
+	data.Output = toDynamic(t, map[string]interface{}{"message": "hello"})
+	data.SensitiveOutput = types.BoolNull()
+	data.OutputSensitive = types.DynamicNull()
+	state := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+	if diags := state.Set(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to build state: %v", diags)
+	}
+
+	// Without a read hook the state is authoritative
+	resp := &fwresource.ReadResponse{State: state}
+	r.Read(ctx, fwresource.ReadRequest{State: state}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	if !resp.State.Raw.Equal(state.Raw) {
+		t.Errorf("Expected the state to be kept as is, got %v", resp.State.Raw)
+	}
+
+	// Nothing can look the resource up for an import
+	id, _ := json.Marshal(map[string]interface{}{"id": "sent", "hooks": hooks})
+	importResp := &fwresource.ImportStateResponse{State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}}
+	r.ImportState(ctx, fwresource.ImportStateRequest{ID: string(id)}, importResp)
+	if importResp.Diagnostics.ErrorsCount() != 1 || importResp.Diagnostics.Errors()[0].Summary() != "Import Not Supported" {
+		t.Errorf("Expected an import without read or import hook to fail, got %v", importResp.Diagnostics)
+	}
+}
+
+func TestUnitOptionalDelete(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		t.Errorf("Expected no hook to run, got %v", req.Command)
+		return &utils.ExecResponse{Stdout: []byte(`{}`)}, nil
+	}}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	for name, hooks := range map[string]map[string]string{
+		"omitted": {utils.Create: "test_passthrough/create.sh"},
+		"noop":    {utils.Create: "test_passthrough/create.sh", utils.Delete: " noop "},
+	} {
+		hooksList, diags := importHooks(ctx, schemaResp.Schema, hooks)
+		if diags.HasError() {
+			t.Fatalf("Unexpected diagnostics: %v", diags)
+		}
+		data := nullResourceModel()
+		data.Id = types.StringValue("migrated")
+		data.Hooks = hooksList
+		data.Input = types.DynamicNull()
+		data.InputWO = types.DynamicNull()
+		data.Output = types.DynamicNull()
+		data.SensitiveOutput = types.BoolNull()
+		data.OutputSensitive = types.DynamicNull()
+
+		crud, err := getCrudCommands(&data, runtime.GOOS)
+		if err != nil {
+			t.Fatalf("%s: failed to get CRUD commands: %v", name, err)
+		}
+		if crud.defines(utils.Delete) {
+			t.Errorf("%s: expected no delete hook, got %v", name, crud.Delete)
+		}
+		if verifyHooks(crud, "", &diags); diags.HasError() {
+			t.Errorf("%s: expected noop not to be verified as an executable, got %v", name, diags)
+		}
+
+		state := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+		if diags := state.Set(ctx, &data); diags.HasError() {
+			t.Fatalf("Failed to build state: %v", diags)
+		}
+		resp := &fwresource.DeleteResponse{State: state}
+		r.Delete(ctx, fwresource.DeleteRequest{State: state}, resp)
+		if resp.Diagnostics.HasError() {
+			t.Errorf("%s: unexpected diagnostics: %v", name, resp.Diagnostics)
+		}
+	}
+}
+
+func TestUnitApplyHooks(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Apply:   "make converge",
+		utils.Destroy: "make clean",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	data := nullResourceModel()
+	data.Hooks = hooks
+
+	crud, err := getCrudCommands(&data, runtime.GOOS)
+	if err != nil {
+		t.Fatalf("Failed to get CRUD commands: %v", err)
+	}
+	for hook, command := range map[string]types.String{utils.Create: crud.Create, utils.Update: crud.Update, utils.Read: crud.Read} {
+		if command.ValueString() != "make converge" {
+			t.Errorf("Expected apply to run for %s, got %v", hook, command)
+		}
+	}
+	if crud.Delete.ValueString() != "make clean" {
+		t.Errorf("Expected destroy to run for delete, got %v", crud.Delete)
+	}
+
+	// A read hook of its own and argv entries of apply are kept
+	obj := hooks.Elements()[0].(types.Object)
+	attrs := obj.Attributes()
+	attrs[utils.Apply] = types.StringNull()
+	attrs[utils.Read] = types.StringValue("./read.sh")
+	attrs[utils.Argv] = types.MapValueMust(types.ListType{ElemType: types.StringType}, map[string]attr.Value{
+		utils.Apply: types.ListValueMust(types.StringType, []attr.Value{types.StringValue("ansible-playbook"), types.StringValue("site.yml")}),
+	})
+	data.Hooks = types.ListValueMust(obj.Type(ctx), []attr.Value{types.ObjectValueMust(obj.AttributeTypes(ctx), attrs)})
+
+	var commands [][]string
+	config := r.config
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		commands = append(commands, req.Command)
+		return &utils.ExecResponse{Stdout: []byte(`{"id": "site"}`)}, nil
+	}}
+	for _, op := range []utils.CrudOp{utils.CrudCreate, utils.CrudUpdate, utils.CrudRead} {
+		if _, ok := utils.RunCrudScript(ctx, config, &data, utils.ExecutionPayload{}, &diags, op); !ok {
+			t.Fatalf("%v: unexpected diagnostics: %v", op, diags)
+		}
+	}
+	expected := [][]string{{"ansible-playbook", "site.yml"}, {"ansible-playbook", "site.yml"}, {"./read.sh"}}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected the apply argv for create and update and the read hook for read, got %q", commands)
+	}
+
+	if err := checkHookSet(map[string]string{utils.Apply: "make converge"}); err != nil {
+		t.Errorf("Expected a hook set with only apply to be valid, got %v", err)
+	}
+}
+
+func TestUnitExistsHook(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Exists: "./exists.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	plan := nullResourceModel()
+	plan.Hooks = hooks
+	payload := utils.ExecutionPayload{Input: map[string]interface{}{"name": "web"}, Phase: utils.PhaseApply}
+
+	for _, tt := range []struct {
+		name    string
+		resp    *utils.ExecResponse
+		adopted bool
+		failed  bool
+	}{
+		{"found", &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1", "name": "web"}`)}, true, false},
+		{"missing exit code", &utils.ExecResponse{ExitCode: 22}, false, false},
+		{"no output", &utils.ExecResponse{}, false, false},
+		{"no id", &utils.ExecResponse{Stdout: []byte(`{}`)}, false, false},
+		{"failure", &utils.ExecResponse{ExitCode: 1}, false, true},
+	} {
+		r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			if req.Command[0] != "./exists.sh" {
+				t.Errorf("%s: expected only the exists hook to run, got %v", tt.name, req.Command)
+			}
+			if tt.resp.ExitCode != 0 {
+				return tt.resp, fmt.Errorf("exit status %d", tt.resp.ExitCode)
+			}
+			return tt.resp, nil
+		}}
+		var diags diag.Diagnostics
+		result, adopted := r.findExisting(ctx, &plan, payload, &diags)
+		if adopted != tt.adopted || diags.HasError() != tt.failed {
+			t.Errorf("%s: expected adopted %v and failed %v, got %v and %v", tt.name, tt.adopted, tt.failed, adopted, diags)
+		}
+		if adopted && result.Result["id"] != "vm-1" {
+			t.Errorf("%s: expected the existing object as the result, got %v", tt.name, result.Result)
+		}
+	}
+
+	// Without an exists hook nothing runs
+	hooks, _ = importHooks(ctx, schemaResp.Schema, map[string]string{utils.Create: "./create.sh"})
+	plan.Hooks = hooks
+	if _, adopted := r.findExisting(ctx, &plan, payload, &diags); adopted {
+		t.Error("Expected nothing to be adopted without an exists hook")
+	}
+}
+
+func TestUnitStatusPolling(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	for _, tt := range []struct {
+		name     string
+		timeout  string
+		statuses []string
+		failed   string
+	}{
+		{"done", "", []string{`{"status": "pending"}`, `{"status": "done", "ip": "10.0.0.1"}`}, ""},
+		{"failed", "", []string{`{"status": "failed", "error": "quota exceeded"}`}, "quota exceeded"},
+		{"timeout", "0s", []string{`{"status": "pending"}`}, "status_timeout"},
+		{"invalid status", "", []string{`{"status": "running"}`}, "must print a status"},
+	} {
+		hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+			utils.Create:         "./create.sh",
+			utils.Status:         "./status.sh",
+			utils.StatusInterval: "0s",
+			utils.StatusTimeout:  tt.timeout,
+		})
+		if diags.HasError() {
+			t.Fatalf("Unexpected diagnostics: %v", diags)
+		}
+		data := nullResourceModel()
+		data.Hooks = hooks
+		var polls []utils.OperationInfo
+		r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			if req.Command[0] == "./create.sh" {
+				return &utils.ExecResponse{Stdout: []byte(`{"operation_id": "op-1", "id": "vm-1"}`)}, nil
+			}
+			var payload utils.ExecutionPayload
+			if err := json.Unmarshal(req.Stdin, &payload); err != nil || payload.Operation == nil {
+				t.Fatalf("%s: expected the status hook to get the operation, got %s", tt.name, req.Stdin)
+			}
+			polls = append(polls, *payload.Operation)
+			return &utils.ExecResponse{Stdout: []byte(tt.statuses[min(len(polls), len(tt.statuses))-1])}, nil
+		}}
+
+		result, ok := utils.RunCrudScript(ctx, r.config, &data, utils.ExecutionPayload{}, &diags, utils.CrudCreate)
+		if tt.failed != "" {
+			if ok || !diags.HasError() || !strings.Contains(diags.Errors()[0].Detail(), tt.failed) {
+				t.Errorf("%s: expected an error mentioning %q, got %v", tt.name, tt.failed, diags)
+			}
+			continue
+		}
+		if !ok {
+			t.Fatalf("%s: unexpected diagnostics: %v", tt.name, diags)
+		}
+		if len(polls) != 2 || polls[1] != (utils.OperationInfo{Id: "op-1", Hook: "create", Attempt: 2}) {
+			t.Errorf("%s: expected two polls of op-1, got %v", tt.name, polls)
+		}
+		expected := map[string]interface{}{"id": "vm-1", "ip": "10.0.0.1"}
+		if !reflect.DeepEqual(result.Result, expected) {
+			t.Errorf("%s: expected result %v, got %v", tt.name, expected, result.Result)
+		}
+	}
+}
+
+func TestUnitWaitFor(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create:  "./create.sh",
+		utils.WaitFor: "./ready.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	result := &utils.ExecutionResult{Result: map[string]interface{}{"id": "vm-1", "ip": "10.0.0.1"}}
+	payload := utils.ExecutionPayload{Input: map[string]interface{}{"name": "web"}, Phase: utils.PhaseApply}
+
+	for _, tt := range []struct {
+		name     string
+		timeout  string
+		failures int
+		ready    bool
+	}{
+		{"ready at once", "", 0, true},
+		{"ready after retries", "1m", 2, true},
+		{"timeout", "0s", 1, false},
+	} {
+		plan := nullResourceModel()
+		plan.Id = types.StringValue("vm-1")
+		plan.Hooks = hooks
+		plan.Wait = types.ListValueMust(waitBlockType(), []attr.Value{types.ObjectValueMust(waitBlockType().AttrTypes, map[string]attr.Value{
+			"timeout":        types.StringValue(tt.timeout),
+			"interval":       types.StringValue("0s"),
+			"delete_timeout": types.StringNull(),
+		})})
+		polls := 0
+		r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			var received utils.ExecutionPayload
+			if err := json.Unmarshal(req.Stdin, &received); err != nil || received.Id != "vm-1" {
+				t.Errorf("%s: expected the payload of vm-1, got %s", tt.name, req.Stdin)
+			}
+			if output, _ := received.Output.(map[string]interface{}); output["ip"] != "10.0.0.1" {
+				t.Errorf("%s: expected the create output in the payload, got %v", tt.name, received.Output)
+			}
+			polls++
+			if polls <= tt.failures {
+				return &utils.ExecResponse{ExitCode: 1, Stderr: []byte("not ready")}, fmt.Errorf("exit status 1")
+			}
+			return &utils.ExecResponse{}, nil
+		}}
+		var diags diag.Diagnostics
+		ready := r.waitReady(ctx, &plan, payload, result, &diags)
+		if ready != tt.ready || diags.HasError() == tt.ready {
+			t.Errorf("%s: expected ready %v, got %v with %v", tt.name, tt.ready, ready, diags)
+		}
+		if tt.ready && polls != tt.failures+1 {
+			t.Errorf("%s: expected %d polls, got %d", tt.name, tt.failures+1, polls)
+		}
+		if !tt.ready && diags.Errors()[0].Summary() != "Wait For Timeout" {
+			t.Errorf("%s: expected a timeout, got %v", tt.name, diags)
+		}
+	}
+}
+
+func TestUnitVerifyDeleted(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Read:   "./read.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	payload := utils.ExecutionPayload{Id: "vm-1", Phase: utils.PhaseDestroy}
+
+	for _, tt := range []struct {
+		name    string
+		timeout string
+		found   int
+		failed  bool
+	}{
+		{"gone at once", "1m", 0, false},
+		{"gone after polls", "1m", 2, false},
+		{"still found", "0s", 1, true},
+	} {
+		data := nullResourceModel()
+		data.Id = types.StringValue("vm-1")
+		data.Hooks = hooks
+		data.Wait = types.ListValueMust(waitBlockType(), []attr.Value{types.ObjectValueMust(waitBlockType().AttrTypes, map[string]attr.Value{
+			"timeout":        types.StringNull(),
+			"interval":       types.StringValue("0s"),
+			"delete_timeout": types.StringValue(tt.timeout),
+		})})
+		reads := 0
+		r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			if req.Command[0] != "./read.sh" {
+				t.Errorf("%s: expected only the read hook to run, got %v", tt.name, req.Command)
+			}
+			reads++
+			if reads <= tt.found {
+				return &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1"}`)}, nil
+			}
+			return &utils.ExecResponse{ExitCode: 22}, fmt.Errorf("exit status 22")
+		}}
+		var diags diag.Diagnostics
+		r.verifyDeleted(ctx, &data, payload, &diags)
+		if diags.HasError() != tt.failed {
+			t.Errorf("%s: expected failed %v, got %v", tt.name, tt.failed, diags)
+		}
+		if !tt.failed && reads != tt.found+1 {
+			t.Errorf("%s: expected %d reads, got %d", tt.name, tt.found+1, reads)
+		}
+	}
+
+	// Without a read hook the delete can't be verified
+	hooks, _ = importHooks(ctx, schemaResp.Schema, map[string]string{utils.Create: "./create.sh", utils.Delete: "./delete.sh"})
+	data := nullResourceModel()
+	data.Hooks = hooks
+	r.verifyDeleted(ctx, &data, payload, &diags)
+	if diags.HasError() || len(diags.Warnings()) != 1 {
+		t.Errorf("Expected a warning without a read hook, got %v", diags)
+	}
+
+	// The apply hook standing in for read would create the object again
+	hooks, _ = importHooks(ctx, schemaResp.Schema, map[string]string{utils.Apply: "./apply.sh", utils.Destroy: "./destroy.sh"})
+	data.Hooks = hooks
+	r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		t.Errorf("Expected no hook to run, got %v", req.Command)
+		return &utils.ExecResponse{}, nil
+	}}
+	diags = nil
+	r.verifyDeleted(ctx, &data, payload, &diags)
+	if diags.HasError() || len(diags.Warnings()) != 1 {
+		t.Errorf("Expected a warning with only an apply hook, got %v", diags)
+	}
+}
+
+func TestUnitAlreadyDeleted(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Delete: "./delete.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	data := nullResourceModel()
+	data.Hooks = hooks
+	r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		return &utils.ExecResponse{ExitCode: 22, Stderr: []byte("no such object")}, fmt.Errorf("exit status 22")
+	}}
+
+	for _, tt := range []struct {
+		exitCode int
+		ok       bool
+	}{
+		{22, true},
+		{-1, false},
+		{44, false},
+	} {
+		r.config.AlreadyDeletedExitCode = tt.exitCode
+		var diags diag.Diagnostics
+		if _, ok := utils.RunCrudScript(ctx, r.config, &data, utils.ExecutionPayload{Id: "vm-1"}, &diags, utils.CrudDelete); ok != tt.ok || diags.HasError() == tt.ok {
+			t.Errorf("already_deleted_exit_code %d: expected ok %v, got %v with %v", tt.exitCode, tt.ok, ok, diags)
+		}
+	}
+}
+
+func TestUnitOnFailure(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create:    "./create.sh",
+		utils.OnFailure: "./cleanup.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	plan := nullResourceModel()
+	plan.Hooks = hooks
+	payload := utils.ExecutionPayload{Input: map[string]interface{}{"name": "web"}, Phase: utils.PhaseApply}
+
+	var received utils.ExecutionPayload
+	cleanupExit := 0
+	r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		if req.Command[0] == "./create.sh" {
+			return &utils.ExecResponse{ExitCode: 3, Stderr: []byte("quota exceeded")}, fmt.Errorf("exit status 3")
+		}
+		if err := json.Unmarshal(req.Stdin, &received); err != nil {
+			t.Fatalf("Failed to parse the on_failure payload: %v", err)
+		}
+		if cleanupExit != 0 {
+			return &utils.ExecResponse{ExitCode: cleanupExit}, fmt.Errorf("exit status %d", cleanupExit)
+		}
+		return &utils.ExecResponse{}, nil
+	}}
+
+	for _, cleanupExit = range []int{0, 1} {
+		var diags diag.Diagnostics
+		result, _ := utils.RunCrudScript(ctx, r.config, &plan, payload, &diags, utils.CrudCreate)
+		r.onFailure(ctx, &plan, payload, utils.CrudCreate, result, &diags)
+		expected := utils.FailureInfo{Hook: "create", ExitCode: 3, Stderr: "quota exceeded"}
+		if received.Failure == nil || *received.Failure != expected || received.Input.(map[string]interface{})["name"] != "web" {
+			t.Errorf("Expected the create payload with failure %v, got %+v", expected, received)
+		}
+		if len(diags.Errors()) != 1 || len(diags.Warnings()) != cleanupExit {
+			t.Errorf("Expected the create error and %d warnings, got %v", cleanupExit, diags)
+		}
+	}
+}
+
+func TestUnitOnFailureCreate(t *testing.T) {
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	(&customCrudResource{}).Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	created := &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1"}`)}
+	failed := &utils.ExecResponse{ExitCode: 1, Stderr: []byte("not converged")}
+
+	for _, tt := range []struct {
+		name   string
+		hook   string
+		result *utils.ExecResponse
+		mutate func(model *customCrudResourceModel)
+	}{
+		{"failed create", "", &utils.ExecResponse{ExitCode: 3, Stderr: []byte("quota exceeded")}, nil},
+		{"partial create", "", &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1", "partial": true}`)}, nil},
+		{"invalid output", "", created, func(model *customCrudResourceModel) {
+			model.OutputValidationSchema = types.StringValue(`{"type": "object", "required": ["ip"]}`)
+		}},
+		{"failed verification", utils.Verify, created, nil},
+		{"not ready", utils.WaitFor, created, func(model *customCrudResourceModel) {
+			model.Wait = types.ListValueMust(waitBlockType(), []attr.Value{types.ObjectValueMust(waitBlockType().AttrTypes, map[string]attr.Value{
+				"timeout":        types.StringValue("0s"),
+				"interval":       types.StringValue("0s"),
+				"delete_timeout": types.StringNull(),
+			})})
+		}},
+	} {
+		commands := map[string]string{
+			utils.Create:    "./create.sh",
+			utils.Delete:    "./delete.sh",
+			utils.OnFailure: "./cleanup.sh",
+		}
+		if tt.hook != "" {
+			commands[tt.hook] = "./check.sh"
+		}
+		hooks, diags := importHooks(ctx, schemaResp.Schema, commands)
+		if diags.HasError() {
+			t.Fatalf("Unexpected diagnostics: %v", diags)
+		}
+		model := nullResourceModel()
+		model.Hooks = hooks
+		if tt.mutate != nil {
+			tt.mutate(&model)
+		}
+		var received *utils.ExecutionPayload
+		resp, _ := applyCreate(t, &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			switch req.Command[0] {
+			case "./create.sh":
+				if tt.result.ExitCode != 0 {
+					return tt.result, fmt.Errorf("exit status %d", tt.result.ExitCode)
+				}
+				return tt.result, nil
+			case "./check.sh":
+				return failed, fmt.Errorf("exit status 1")
+			}
+			received = &utils.ExecutionPayload{}
+			if err := json.Unmarshal(req.Stdin, received); err != nil {
+				t.Fatalf("%s: failed to parse the on_failure payload: %v", tt.name, err)
+			}
+			return &utils.ExecResponse{}, nil
+		}}, model)
+		if !diagsHaveError(resp.Diagnostics) {
+			t.Errorf("%s: expected the create to fail", tt.name)
+		}
+		if received == nil || received.Failure == nil || received.Failure.Hook != utils.Create {
+			t.Errorf("%s: expected on_failure to run for the create, got %+v", tt.name, received)
+		}
+	}
+}
+
+func TestUnitVerifyHook(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	hooks, diags := importHooks(ctx, schemaResp.Schema, map[string]string{
+		utils.Create: "./create.sh",
+		utils.Verify: "./verify.sh",
+	})
+	if diags.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", diags)
+	}
+	plan := nullResourceModel()
+	plan.Id = types.StringValue("vm-1")
+	plan.Hooks = hooks
+	payload := utils.ExecutionPayload{Input: map[string]interface{}{"replicas": 3}, Phase: utils.PhaseApply}
+	result := &utils.ExecutionResult{Result: map[string]interface{}{"id": "vm-1", "replicas": 2}}
+
+	for _, tt := range []struct {
+		name    string
+		resp    *utils.ExecResponse
+		summary string
+		path    path.Path
+	}{
+		{"converged", &utils.ExecResponse{}, "", path.Empty()},
+		{"structured", &utils.ExecResponse{ExitCode: 1, Stdout: []byte(`{"path": "replicas", "detail": "wanted 3, got 2"}`)}, "Verification Failed", path.Root("output").AtName("replicas")},
+		{"summary", &utils.ExecResponse{ExitCode: 1, Stdout: []byte(`{"errors": [{"summary": "Not Converged", "detail": "still scaling"}]}`)}, "Not Converged", path.Root("output")},
+		{"stderr", &utils.ExecResponse{ExitCode: 1, Stderr: []byte("replicas differ")}, "Verification Failed", path.Root("output")},
+	} {
+		r.config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			var received utils.ExecutionPayload
+			if err := json.Unmarshal(req.Stdin, &received); err != nil || received.Id != "vm-1" {
+				t.Errorf("%s: expected the payload of vm-1, got %s", tt.name, req.Stdin)
+			}
+			if output, _ := received.Output.(map[string]interface{}); fmt.Sprint(output["replicas"]) != "2" {
+				t.Errorf("%s: expected the create output in the payload, got %v", tt.name, received.Output)
+			}
+			if tt.resp.ExitCode != 0 {
+				return tt.resp, fmt.Errorf("exit status %d", tt.resp.ExitCode)
+			}
+			return tt.resp, nil
+		}}
+		var diags diag.Diagnostics
+		verified := r.verifyOutput(ctx, &plan, payload, result, &diags)
+		if verified != (tt.summary == "") {
+			t.Errorf("%s: expected verified %v, got %v with %v", tt.name, tt.summary == "", verified, diags)
+			continue
+		}
+		if verified {
+			continue
+		}
+		withPath, ok := diags.Errors()[0].(diag.DiagnosticWithPath)
+		if len(diags.Errors()) != 1 || diags.Errors()[0].Summary() != tt.summary || !ok || !withPath.Path().Equal(tt.path) {
+			t.Errorf("%s: expected %q on %s, got %v", tt.name, tt.summary, tt.path, diags)
+		}
+	}
+}
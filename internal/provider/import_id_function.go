@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ function.Function = &importIdFunction{}
+
+// importIdFunction builds the JSON import ID of a resource, which is easy to
+// get wrong when escaped by hand.
+type importIdFunction struct{}
+
+func NewImportIdFunction() function.Function {
+	return &importIdFunction{}
+}
+
+func (f *importIdFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "import_id"
+}
+
+func (f *importIdFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Builds the JSON import ID of a customcrud resource",
+		Description: "Returns the JSON import ID holding id, hooks and, when not null, input and output, for the id argument of import blocks or terraform import. hooks is either an object of hooks block attributes or the name of a provider hook_sets entry, which is imported as hooks_ref.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "id",
+				Description: "ID of the object to import, as passed to the read or import hook",
+			},
+			function.DynamicParameter{
+				Name:        "hooks",
+				Description: "Hooks block attributes, e.g. { create = \"./create.sh\", ... }, or the name of a provider hook set",
+			},
+			function.DynamicParameter{
+				Name:           "input",
+				Description:    "Input of the resource, or null to derive it from the read output",
+				AllowNullValue: true,
+			},
+			function.DynamicParameter{
+				Name:           "output",
+				Description:    "Output to seed state with before the read hook refreshes it, or null",
+				AllowNullValue: true,
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *importIdFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var id string
+	var hooks, input, output types.Dynamic
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &id, &hooks, &input, &output))
+	if resp.Error != nil {
+		return
+	}
+	if id == "" {
+		resp.Error = function.NewArgumentFuncError(0, "The id cannot be empty")
+		return
+	}
+
+	importID := map[string]interface{}{"id": id}
+	switch value := utils.AttrValueToInterface(hooks).(type) {
+	case string:
+		importID["hooks_ref"] = value
+	case map[string]interface{}:
+		for name, command := range value {
+			if _, ok := command.(string); !ok && command != nil {
+				resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("The hook %s must be a string", name))
+				return
+			}
+		}
+		importID["hooks"] = value
+	default:
+		resp.Error = function.NewArgumentFuncError(1, "The hooks must be an object of hook commands or the name of a provider hook set")
+		return
+	}
+	for i, arg := range []struct {
+		name  string
+		value types.Dynamic
+	}{{"input", input}, {"output", output}} {
+		if arg.value.IsNull() || arg.value.IsUnderlyingValueNull() {
+			continue
+		}
+		value, ok := utils.AttrValueToInterface(arg.value).(map[string]interface{})
+		if !ok {
+			resp.Error = function.NewArgumentFuncError(int64(i+2), fmt.Sprintf("The %s must be an object", arg.name))
+			return
+		}
+		importID[arg.name] = value
+	}
+
+	raw, err := json.Marshal(importID)
+	if err != nil {
+		resp.Error = function.NewFuncError(fmt.Sprintf("Failed to encode the import ID: %v", err))
+		return
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, string(raw)))
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// runImportId calls the import_id function and decodes the import ID.
+func runImportId(t *testing.T, id string, hooks, input, output attr.Value) (importStateData, *function.FuncError) {
+	t.Helper()
+	resp := &function.RunResponse{Result: function.NewResultData(types.StringUnknown())}
+	NewImportIdFunction().Run(context.Background(), function.RunRequest{
+		Arguments: function.NewArgumentsData([]attr.Value{
+			types.StringValue(id),
+			types.DynamicValue(hooks),
+			types.DynamicValue(input),
+			types.DynamicValue(output),
+		}),
+	}, resp)
+	var data importStateData
+	if resp.Error != nil {
+		return data, resp.Error
+	}
+	if err := utils.DecodeJSON([]byte(resp.Result.Value().(types.String).ValueString()), &data); err != nil {
+		t.Fatalf("Expected a JSON import ID, got %v: %v", resp.Result.Value(), err)
+	}
+	return data, nil
+}
+
+func TestUnitImportIdFunction(t *testing.T) {
+	hooks := types.ObjectValueMust(
+		map[string]attr.Type{"read": types.StringType, "delete": types.StringType},
+		map[string]attr.Value{"read": types.StringValue(`jq -c '{id: .id}'`), "delete": types.StringValue("./delete.sh")},
+	)
+	input := types.ObjectValueMust(map[string]attr.Type{"name": types.StringType}, map[string]attr.Value{"name": types.StringValue(`web "1"`)})
+
+	data, err := runImportId(t, "vm-1", hooks, input, types.DynamicNull())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.Id != "vm-1" || data.Hooks["read"] != `jq -c '{id: .id}'` || data.Input["name"] != `web "1"` || data.Output != nil {
+		t.Errorf("Expected the arguments in the import ID, got %+v", data)
+	}
+
+	data, err = runImportId(t, "vm-1", types.StringValue("vm"), types.DynamicNull(), input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.HooksRef != "vm" || data.Hooks != nil || data.Input != nil || data.Output["name"] != `web "1"` {
+		t.Errorf("Expected a hook set name as hooks_ref, got %+v", data)
+	}
+
+	if _, err := runImportId(t, "", hooks, types.DynamicNull(), types.DynamicNull()); err == nil {
+		t.Error("Expected an empty id to fail")
+	}
+	if _, err := runImportId(t, "vm-1", types.BoolValue(true), types.DynamicNull(), types.DynamicNull()); err == nil {
+		t.Error("Expected invalid hooks to fail")
+	}
+	if _, err := runImportId(t, "vm-1", hooks, types.StringValue("web"), types.DynamicNull()); err == nil {
+		t.Error("Expected an input that isn't an object to fail")
+	}
+}
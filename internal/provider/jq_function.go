@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/itchyny/gojq"
+)
+
+var _ function.Function = &jqFunction{}
+
+// jqFunction evaluates a jq expression on a value, for reshaping hook output
+// inside the configuration without external tooling.
+type jqFunction struct{}
+
+func NewJqFunction() function.Function {
+	return &jqFunction{}
+}
+
+func (f *jqFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "jq"
+}
+
+func (f *jqFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Evaluates a jq expression on a value",
+		Description: "Evaluates the jq expression expr on value, such as the output of a customcrud resource, and returns its result. Expressions yielding several results fail, wrap them in [...] to collect them in a list. An expression yielding nothing returns null. Expressions are evaluated by gojq, which doesn't read files or the environment.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "expr",
+				Description: "jq expression, e.g. .items | map(.name)",
+			},
+			function.DynamicParameter{
+				Name:           "value",
+				Description:    "Value the expression is evaluated on",
+				AllowNullValue: true,
+			},
+		},
+		Return: function.DynamicReturn{},
+	}
+}
+
+func (f *jqFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var expr string
+	var value types.Dynamic
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &expr, &value))
+	if resp.Error != nil {
+		return
+	}
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Invalid jq expression: %v", err))
+		return
+	}
+	code, err := gojq.Compile(query, gojq.WithEnvironLoader(func() []string { return nil }))
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Invalid jq expression: %v", err))
+		return
+	}
+
+	var results []interface{}
+	iter := code.RunWithContext(ctx, utils.AttrValueToInterface(value))
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			if err, ok := err.(*gojq.HaltError); ok && err.Value() == nil {
+				break
+			}
+			resp.Error = function.NewFuncError(fmt.Sprintf("The jq expression failed: %v", err))
+			return
+		}
+		if results = append(results, v); len(results) > 1 {
+			resp.Error = function.NewArgumentFuncError(0, "The jq expression yields several results, wrap it in [...] to collect them in a list")
+			return
+		}
+	}
+
+	var result interface{}
+	if len(results) == 1 {
+		// Round-trip through JSON for the number types gojq returns
+		raw, err := gojq.Marshal(results[0])
+		if err == nil {
+			err = utils.DecodeJSON(raw, &result)
+		}
+		if err != nil {
+			resp.Error = function.NewFuncError(fmt.Sprintf("Failed to convert the jq result: %v", err))
+			return
+		}
+	}
+	output, diags := utils.MapToDynamic(result)
+	if diags.HasError() {
+		resp.Error = function.FuncErrorFromDiags(ctx, diags)
+		return
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, output))
+}
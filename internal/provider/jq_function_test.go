@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// runJq calls the jq function with expr on the JSON value.
+func runJq(t *testing.T, expr, value string) (string, *function.FuncError) {
+	t.Helper()
+	var decoded interface{}
+	if err := utils.DecodeJSON([]byte(value), &decoded); err != nil {
+		t.Fatalf("Invalid test value: %v", err)
+	}
+	input, diags := utils.MapToDynamic(decoded)
+	if diags.HasError() {
+		t.Fatalf("Failed to convert the test value: %v", diags)
+	}
+	resp := &function.RunResponse{Result: function.NewResultData(types.DynamicUnknown())}
+	NewJqFunction().Run(context.Background(), function.RunRequest{
+		Arguments: function.NewArgumentsData([]attr.Value{types.StringValue(expr), input}),
+	}, resp)
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	result, err := json.Marshal(utils.AttrValueToInterface(resp.Result.Value()))
+	if err != nil {
+		t.Fatalf("Failed to encode the result: %v", err)
+	}
+	return string(result), nil
+}
+
+func TestUnitJqFunction(t *testing.T) {
+	output := `{"items": [{"name": "web", "size": 2}, {"name": "db", "size": 9007199254740993}]}`
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{`.items | map(.name)`, `["web","db"]`},
+		{`.items | map({(.name): .size}) | add`, `{"db":9007199254740993,"web":2}`},
+		{`[.items[] | select(.size > 2) | .name]`, `["db"]`},
+		{`.items[0].name | ascii_upcase`, `"WEB"`},
+		{`.items[] | select(.name == "cache")`, `null`},
+	}
+	for _, tt := range tests {
+		if got, err := runJq(t, tt.expr, output); err != nil || got != tt.expected {
+			t.Errorf("%s: expected %s, got %s (%v)", tt.expr, tt.expected, got, err)
+		}
+	}
+
+	if _, err := runJq(t, `.items[].name`, output); err == nil {
+		t.Error("Expected several results to fail")
+	}
+	if _, err := runJq(t, `.items[`, output); err == nil {
+		t.Error("Expected an invalid expression to fail")
+	}
+	if _, err := runJq(t, `error("bad")`, output); err == nil {
+		t.Error("Expected a jq error to fail")
+	}
+}
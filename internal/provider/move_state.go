@@ -0,0 +1,209 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var _ resource.ResourceWithMoveState = &customCrudResource{}
+
+// nullProviderSuffix ends the address of the hashicorp/null provider.
+const nullProviderSuffix = "/hashicorp/null"
+
+// shellProviderSuffix ends the address of the scottwinkler/shell provider,
+// whatever registry it was installed from.
+const shellProviderSuffix = "/scottwinkler/shell"
+
+// shellScriptState is the part of the state of a shell_script resource that
+// carries over to customcrud.
+type shellScriptState struct {
+	Id                string            `json:"id"`
+	LifecycleCommands []shellCommands   `json:"lifecycle_commands"`
+	Environment       map[string]string `json:"environment"`
+	Interpreter       []string          `json:"interpreter"`
+	WorkingDirectory  string            `json:"working_directory"`
+	Output            map[string]string `json:"output"`
+	Triggers          map[string]string `json:"triggers"`
+}
+
+type shellCommands struct {
+	Create string `json:"create"`
+	Read   string `json:"read"`
+	Update string `json:"update"`
+	Delete string `json:"delete"`
+}
+
+// MoveState lets moved blocks migrate resources of other providers that run
+// scripts, or stand in for them, to customcrud.
+func (r *customCrudResource) MoveState(ctx context.Context) []resource.StateMover {
+	return []resource.StateMover{
+		{StateMover: r.moveShellScript},
+		{StateMover: r.moveNullResource},
+	}
+}
+
+// moveShellScript moves a shell_script resource of the scottwinkler/shell
+// provider. Its environment becomes the input and its output the output, and
+// each lifecycle command becomes a hook running the script with the
+// interpreter and environment it had, so that the resource refreshes as
+// before until the configuration gives it new hooks.
+func (r *customCrudResource) moveShellScript(ctx context.Context, req resource.MoveStateRequest, resp *resource.MoveStateResponse) {
+	if req.SourceTypeName != "shell_script" || !strings.HasSuffix(req.SourceProviderAddress, shellProviderSuffix) {
+		return
+	}
+	if req.SourceRawState == nil || req.SourceRawState.JSON == nil {
+		resp.Diagnostics.AddError("Invalid Source State", "The shell_script state isn't stored as JSON, refresh it with a recent version of the shell provider before moving it")
+		return
+	}
+	var source shellScriptState
+	if err := json.Unmarshal(req.SourceRawState.JSON, &source); err != nil {
+		resp.Diagnostics.AddError("Invalid Source State", fmt.Sprintf("Failed to parse the shell_script state: %v", err))
+		return
+	}
+	if source.Id == "" || len(source.LifecycleCommands) == 0 {
+		resp.Diagnostics.AddError("Invalid Source State", "The shell_script state must have an id and lifecycle_commands")
+		return
+	}
+
+	commands := source.LifecycleCommands[0]
+	hooks := map[string]string{}
+	for name, script := range map[string]string{
+		utils.Create: commands.Create,
+		utils.Read:   commands.Read,
+		utils.Update: commands.Update,
+		utils.Delete: commands.Delete,
+	} {
+		if strings.TrimSpace(script) == "" {
+			continue
+		}
+		hook, err := shellScriptHook(source.Interpreter, source.Environment, script)
+		if err != nil {
+			resp.Diagnostics.AddError("Invalid Source State", fmt.Sprintf("Failed to convert the %s command: %v", name, err))
+			return
+		}
+		hooks[name] = hook
+	}
+	if source.WorkingDirectory != "" {
+		hooks[utils.WorkingDirectory] = source.WorkingDirectory
+	}
+	hooksList, diags := importHooks(ctx, resp.TargetState.Schema, hooks)
+	resp.Diagnostics.Append(diags...)
+
+	data := nullResourceModel()
+	data.Id = types.StringValue(source.Id)
+	data.Hooks = hooksList
+	if source.Environment != nil {
+		data.Input, diags = utils.MapToDynamic(stringMap(source.Environment))
+		resp.Diagnostics.Append(diags...)
+	}
+	if source.Output != nil {
+		data.Output, diags = utils.MapToDynamicWithTyping(stringMap(source.Output), r.config.CollectionTyping)
+		resp.Diagnostics.Append(diags...)
+	}
+	if source.Triggers != nil {
+		data.Triggers, diags = types.MapValueFrom(ctx, types.StringType, source.Triggers)
+		resp.Diagnostics.Append(diags...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.TargetState.Set(ctx, &data)...)
+	setIdentity(ctx, resp.TargetIdentity, &data, &resp.Diagnostics)
+}
+
+// nullResourceState is the state of a null_resource.
+type nullResourceState struct {
+	Id       string            `json:"id"`
+	Triggers map[string]string `json:"triggers"`
+}
+
+// moveNullResource moves a null_resource of the hashicorp/null provider, such
+// as one running local-exec provisioners. Its triggers become the input and
+// the output stays empty until the first read. A null_resource has no hooks,
+// so the moved state has none: it isn't read or deleted until the
+// configuration gives it hooks.
+func (r *customCrudResource) moveNullResource(ctx context.Context, req resource.MoveStateRequest, resp *resource.MoveStateResponse) {
+	if req.SourceTypeName != "null_resource" || !strings.HasSuffix(req.SourceProviderAddress, nullProviderSuffix) {
+		return
+	}
+	if req.SourceRawState == nil || req.SourceRawState.JSON == nil {
+		resp.Diagnostics.AddError("Invalid Source State", "The null_resource state isn't stored as JSON, refresh it with a recent version of the null provider before moving it")
+		return
+	}
+	var source nullResourceState
+	if err := json.Unmarshal(req.SourceRawState.JSON, &source); err != nil {
+		resp.Diagnostics.AddError("Invalid Source State", fmt.Sprintf("Failed to parse the null_resource state: %v", err))
+		return
+	}
+	if source.Id == "" {
+		resp.Diagnostics.AddError("Invalid Source State", "The null_resource state must have an id")
+		return
+	}
+
+	hooksList, diags := emptyHooks(ctx, resp.TargetState.Schema)
+	resp.Diagnostics.Append(diags...)
+	data := nullResourceModel()
+	data.Id = types.StringValue(source.Id)
+	data.Hooks = hooksList
+	data.Input, diags = utils.MapToDynamic(stringMap(source.Triggers))
+	resp.Diagnostics.Append(diags...)
+	data.Output, diags = utils.MapToDynamic(map[string]interface{}{})
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.TargetState.Set(ctx, &data)...)
+	setIdentity(ctx, resp.TargetIdentity, &data, &resp.Diagnostics)
+}
+
+// shellScriptHook returns the hook command running script like the shell
+// provider does: with interpreter, /bin/sh -c by default, and the variables
+// of environment set.
+func shellScriptHook(interpreter []string, environment map[string]string, script string) (string, error) {
+	if len(interpreter) == 0 {
+		interpreter = []string{"/bin/sh", "-c"}
+	}
+	var args []string
+	if len(environment) > 0 {
+		names := make([]string, 0, len(environment))
+		for name := range environment {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		args = append(args, "env")
+		for _, name := range names {
+			args = append(args, name+"="+environment[name])
+		}
+	}
+	args = append(args, interpreter...)
+	args = append(args, script)
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		q, err := syntax.Quote(arg, syntax.LangPOSIX)
+		if err != nil {
+			return "", err
+		}
+		quoted[i] = q
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// stringMap converts a map of strings to the generic form hook output takes.
+func stringMap(m map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
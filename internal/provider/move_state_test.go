@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// moveState runs the state movers of r on a source state until one of them
+// sets the target state, like the framework does.
+func moveState(t *testing.T, r *customCrudResource, typeName, providerAddress, rawState string) (*fwresource.MoveStateResponse, bool) {
+	t.Helper()
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	req := fwresource.MoveStateRequest{
+		SourceTypeName:        typeName,
+		SourceProviderAddress: providerAddress,
+		SourceRawState:        &tfprotov6.RawState{JSON: []byte(rawState)},
+	}
+	for _, mover := range r.MoveState(ctx) {
+		resp := &fwresource.MoveStateResponse{
+			TargetState: tfsdk.State{
+				Schema: schemaResp.Schema,
+				Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+			},
+		}
+		mover.StateMover(ctx, req, resp)
+		if resp.Diagnostics.HasError() || !resp.TargetState.Raw.IsNull() {
+			return resp, true
+		}
+	}
+	return nil, false
+}
+
+func TestUnitMoveStateShellScript(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+
+	resp, ok := moveState(t, r, "shell_script", "registry.terraform.io/scottwinkler/shell", `{
+		"id": "c0ffee",
+		"lifecycle_commands": [{
+			"create": "echo '{\"name\": \"'$NAME'\"}'",
+			"read": "echo \"{\\\"name\\\": \\\"$NAME\\\"}\"",
+			"update": "",
+			"delete": "true"
+		}],
+		"environment": {"NAME": "it's web"},
+		"interpreter": null,
+		"working_directory": ".",
+		"output": {"name": "it's web"},
+		"triggers": {"version": "1"},
+		"dirty": false
+	}`)
+	if !ok {
+		t.Fatal("Expected the shell_script state to be moved")
+	}
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var data customCrudResourceModel
+	if diags := resp.TargetState.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to read moved state: %v", diags)
+	}
+	if data.Id.ValueString() != "c0ffee" {
+		t.Errorf("Expected id c0ffee, got %v", data.Id)
+	}
+	input, _ := utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if input["NAME"] != "it's web" {
+		t.Errorf("Expected the environment as input, got %v", data.Input)
+	}
+	if data.Triggers.Elements()["version"].String() != `"1"` {
+		t.Errorf("Expected the triggers to be kept, got %v", data.Triggers)
+	}
+	crud, err := getCrudCommands(&data, runtime.GOOS)
+	if err != nil {
+		t.Fatalf("Failed to get CRUD commands: %v", err)
+	}
+	if !crud.Update.IsNull() {
+		t.Errorf("Expected no update hook, got %v", crud.Update)
+	}
+
+	// The moved read hook runs the script with its environment
+	result, ok := utils.RunCrudScript(ctx, r.config, &data, utils.ExecutionPayload{Id: "c0ffee"}, &resp.Diagnostics, utils.CrudRead)
+	if !ok {
+		t.Fatalf("Failed to run the moved read hook: %v", resp.Diagnostics)
+	}
+	if result.Result["name"] != "it's web" {
+		t.Errorf("Expected the read hook to see the environment, got %v", result.Result)
+	}
+}
+
+func TestUnitMoveStateUnknownSource(t *testing.T) {
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+	if _, ok := moveState(t, r, "shell_script", "registry.terraform.io/other/shell", `{"id": "1"}`); ok {
+		t.Error("Expected a shell_script of another provider not to be moved")
+	}
+	if _, ok := moveState(t, r, "local_file", "registry.terraform.io/scottwinkler/shell", `{"id": "1"}`); ok {
+		t.Error("Expected another resource type not to be moved")
+	}
+	resp, ok := moveState(t, r, "shell_script", "registry.terraform.io/scottwinkler/shell", `{"id": "1"}`)
+	if !ok || !resp.Diagnostics.HasError() {
+		t.Error("Expected a shell_script without lifecycle_commands to fail")
+	}
+}
+
+func TestUnitMoveStateNullResource(t *testing.T) {
+	ctx := context.Background()
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+
+	resp, ok := moveState(t, r, "null_resource", "registry.terraform.io/hashicorp/null", `{"id": "4596203896356419493", "triggers": {"version": "2"}}`)
+	if !ok {
+		t.Fatal("Expected the null_resource state to be moved")
+	}
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	var data customCrudResourceModel
+	if diags := resp.TargetState.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to read moved state: %v", diags)
+	}
+	if data.Id.ValueString() != "4596203896356419493" {
+		t.Errorf("Expected the null_resource id, got %v", data.Id)
+	}
+	input, _ := utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if len(input) != 1 || input["version"] != "2" {
+		t.Errorf("Expected the triggers as input, got %v", data.Input)
+	}
+	output, ok := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{})
+	if !ok || len(output) != 0 {
+		t.Errorf("Expected an empty output, got %v", data.Output)
+	}
+	if !data.withoutHooks() {
+		t.Errorf("Expected the moved state to have no hooks, got %v", data.Hooks)
+	}
+
+	// Without hooks the read keeps the moved state
+	readResp := &fwresource.ReadResponse{State: resp.TargetState}
+	r.Read(ctx, fwresource.ReadRequest{State: resp.TargetState}, readResp)
+	if readResp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected read diagnostics: %v", readResp.Diagnostics)
+	}
+	if !readResp.State.Raw.Equal(resp.TargetState.Raw) {
+		t.Error("Expected the read to keep the moved state")
+	}
+}
@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/joho/godotenv"
+)
+
+var _ function.Function = &parseEnvFunction{}
+
+// parseEnvFunction parses env-file text into a map, for consuming the output
+// of hooks that can only print KEY=VALUE lines.
+type parseEnvFunction struct{}
+
+func NewParseEnvFunction() function.Function {
+	return &parseEnvFunction{}
+}
+
+func (f *parseEnvFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "parse_env"
+}
+
+func (f *parseEnvFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Parses KEY=VALUE lines into a map",
+		Description: "Parses text in the env-file format, one KEY=VALUE pair per line, into a map of strings, the way github.com/joho/godotenv does. Blank lines, comments starting with # and an export prefix are ignored. Values may be single-quoted, taken literally, or double-quoted, where escapes such as \\n are unescaped, and quoted values may span lines. Unquoted and double-quoted values expand ${KEY} to the value of a key given earlier. A key given several times keeps its last value.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "text",
+				Description: "Env-file text, such as output.raw of a hooks block with raw_output",
+			},
+		},
+		Return: function.MapReturn{ElementType: types.StringType},
+	}
+}
+
+func (f *parseEnvFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var text string
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &text))
+	if resp.Error != nil {
+		return
+	}
+	values, err := godotenv.Unmarshal(text)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to parse the env file: %v", err))
+		return
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, values))
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// runParse calls the parse function f on text, decoding its map result of
+// elemType into target.
+func runParse(t *testing.T, f function.Function, text string, elemType attr.Type, target interface{}) *function.FuncError {
+	t.Helper()
+	ctx := context.Background()
+	resp := &function.RunResponse{Result: function.NewResultData(types.MapUnknown(elemType))}
+	f.Run(ctx, function.RunRequest{
+		Arguments: function.NewArgumentsData([]attr.Value{types.StringValue(text)}),
+	}, resp)
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if diags := resp.Result.Value().(types.Map).ElementsAs(ctx, target, false); diags.HasError() {
+		t.Fatalf("Failed to decode the result: %v", diags)
+	}
+	return nil
+}
+
+func TestUnitParseEnvFunction(t *testing.T) {
+	text := `# written by create.sh
+DB_HOST=db1.internal
+export DB_PORT = 5432
+DB_PASSWORD='p#ss w\rd'
+GREETING="hello\n\"world\" \$HOME"
+EMPTY=
+URL=https://example.com/a#b # the endpoint
+DB_HOST=db2.internal
+DSN="postgres://${DB_HOST}:${DB_PORT}"
+CERT="line one
+line two"
+`
+	var got map[string]string
+	if err := runParse(t, NewParseEnvFunction(), text, types.StringType, &got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"DB_HOST":     "db2.internal",
+		"DB_PORT":     "5432",
+		"DB_PASSWORD": `p#ss w\rd`,
+		"GREETING":    "hello\n\"world\" $HOME",
+		"EMPTY":       "",
+		"URL":         "https://example.com/a#b",
+		"DSN":         "postgres://db2.internal:5432",
+		"CERT":        "line one\nline two",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	for _, text := range []string{`TOKEN="abc`, "DB-HOST=db1"} {
+		var got map[string]string
+		if err := runParse(t, NewParseEnvFunction(), text, types.StringType, &got); err == nil {
+			t.Errorf("Expected %q to fail", text)
+		}
+	}
+}
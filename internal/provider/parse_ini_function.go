@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"gopkg.in/ini.v1"
+)
+
+var _ function.Function = &parseIniFunction{}
+
+// parseIniFunction parses INI text into a map of sections, for consuming
+// the output of hooks that print configuration files.
+type parseIniFunction struct{}
+
+func NewParseIniFunction() function.Function {
+	return &parseIniFunction{}
+}
+
+func (f *parseIniFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "parse_ini"
+}
+
+func (f *parseIniFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Parses INI text into a map of sections",
+		Description: "Parses INI text into a map of sections, each a map of strings, the way gopkg.in/ini.v1 does. Keys are separated from values by = or :, and keys before the first [section] header go in the section named \"\". Blank lines and comments starting with ; or # are ignored, quoted values are unquoted, and a section given several times is merged. A key given several times in a section keeps its last value.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "text",
+				Description: "INI text, such as output.raw of a hooks block with raw_output",
+			},
+		},
+		Return: function.MapReturn{ElementType: types.MapType{ElemType: types.StringType}},
+	}
+}
+
+func (f *parseIniFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var text string
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &text))
+	if resp.Error != nil {
+		return
+	}
+	// Comments only start a value when a space precedes them, so that URLs
+	// and passwords holding # or ; survive
+	file, err := ini.LoadSources(ini.LoadOptions{SpaceBeforeInlineComment: true}, []byte(text))
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to parse the INI text: %v", err))
+		return
+	}
+	sections := map[string]map[string]string{}
+	for _, section := range file.Sections() {
+		name := section.Name()
+		if name == ini.DefaultSection {
+			if len(section.Keys()) == 0 {
+				continue
+			}
+			name = ""
+		}
+		sections[name] = section.KeysHash()
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, sections))
+}
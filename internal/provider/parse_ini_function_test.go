@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestUnitParseIniFunction(t *testing.T) {
+	text := `; written by read.sh
+region = eu-west-1
+
+[database]
+host = db1.internal
+port: 5432
+password = "p;ss"
+url = https://example.com/a#b ; the endpoint
+
+[empty]
+[database]
+host = db2.internal
+`
+	resultType := types.MapType{ElemType: types.StringType}
+	var got map[string]map[string]string
+	if err := runParse(t, NewParseIniFunction(), text, resultType, &got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]map[string]string{
+		"":         {"region": "eu-west-1"},
+		"database": {"host": "db2.internal", "port": "5432", "password": "p;ss", "url": "https://example.com/a#b"},
+		"empty":    {},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	for _, text := range []string{"[database", "no separator", "= value"} {
+		var got map[string]map[string]string
+		if err := runParse(t, NewParseIniFunction(), text, resultType, &got); err == nil {
+			t.Errorf("Expected %q to fail", text)
+		}
+	}
+}
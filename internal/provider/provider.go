@@ -5,14 +5,24 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/list"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
@@ -20,6 +30,7 @@ import (
 var _ provider.Provider = &CustomCRUDProvider{}
 var _ provider.ProviderWithFunctions = &CustomCRUDProvider{}
 var _ provider.ProviderWithEphemeralResources = &CustomCRUDProvider{}
+var _ provider.ProviderWithListResources = &CustomCRUDProvider{}
 
 // CustomCRUDProvider defines the provider implementation.
 type CustomCRUDProvider struct {
@@ -28,13 +39,57 @@ type CustomCRUDProvider struct {
 	// testing.
 	version string
 	config  utils.CustomCRUDProviderConfig
+	// kindSemaphores holds the semaphores of the kinds of objects with their
+	// own parallelism limit, nil meaning unlimited.
+	kindSemaphores map[string]chan struct{}
 }
 
+// defaultProfileFile is the file profile timings are appended to by default.
+const defaultProfileFile = "customcrud-profile.jsonl"
+
+// Kinds of objects whose parallelism can be limited separately.
+const (
+	resourceKind   = "resource"
+	dataSourceKind = "data_source"
+	ephemeralKind  = "ephemeral"
+)
+
 type CustomCRUDProviderModel struct {
 	Parallelism             types.Int64   `tfsdk:"parallelism"`
 	HighPrecisionNumbers    types.Bool    `tfsdk:"high_precision_numbers"`
 	DefaultInputs           types.Dynamic `tfsdk:"default_inputs"`
 	MissingResourceExitCode types.Int64   `tfsdk:"missing_resource_exit_code"`
+	RequiresReplaceExitCode types.Int64   `tfsdk:"requires_replace_exit_code"`
+	AlreadyDeletedExitCode  types.Int64   `tfsdk:"already_deleted_exit_code"`
+	WorkingDirectory        types.String  `tfsdk:"working_directory"`
+	Executor                types.String  `tfsdk:"executor"`
+	ExecutorOptions         types.Map     `tfsdk:"executor_options"`
+	Runtimes                types.Map     `tfsdk:"runtimes"`
+	VerifyHooks             types.Bool    `tfsdk:"verify_hooks"`
+	Profile                 types.Bool    `tfsdk:"profile"`
+	ProfileFile             types.String  `tfsdk:"profile_file"`
+	ExecutionWindows        types.List    `tfsdk:"execution_windows"`
+	ExecutionWindowWait     types.Int64   `tfsdk:"execution_window_wait_minutes"`
+	SortOutputLists         types.Bool    `tfsdk:"sort_output_lists"`
+	CollectionTyping        types.String  `tfsdk:"collection_typing"`
+	CompatibilityMode       types.String  `tfsdk:"compatibility_mode"`
+	SensitiveKeys           types.List    `tfsdk:"sensitive_keys"`
+	HookPathRewrites        types.Map     `tfsdk:"hook_path_rewrites"`
+	HookSets                types.Map     `tfsdk:"hook_sets"`
+	ResourceParallelism     types.Int64   `tfsdk:"resource_parallelism"`
+	DataSourceParallelism   types.Int64   `tfsdk:"data_source_parallelism"`
+	EphemeralParallelism    types.Int64   `tfsdk:"ephemeral_parallelism"`
+	MaxSubprocesses         types.Int64   `tfsdk:"max_subprocesses"`
+	MaxOutputDepth          types.Int64   `tfsdk:"max_output_depth"`
+	MaxOutputNodes          types.Int64   `tfsdk:"max_output_nodes"`
+	MaxCaptureBytes         types.Int64   `tfsdk:"max_capture_bytes"`
+	InputSocketThreshold    types.Int64   `tfsdk:"input_socket_threshold"`
+	KeepTempFiles           types.Bool    `tfsdk:"keep_temp_files"`
+	TempFileMaxAgeHours     types.Int64   `tfsdk:"temp_file_max_age_hours"`
+	OnShutdown              types.String  `tfsdk:"on_shutdown"`
+	CredentialHelper        types.String  `tfsdk:"credential_helper"`
+	AgeIdentity             types.String  `tfsdk:"age_identity"`
+	AgeIdentityFile         types.String  `tfsdk:"age_identity_file"`
 }
 
 func (p *CustomCRUDProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -48,11 +103,92 @@ func (p *CustomCRUDProvider) Schema(ctx context.Context, req provider.SchemaRequ
 		Attributes: map[string]schema.Attribute{
 			"parallelism": schema.Int64Attribute{
 				Optional:            true,
-				MarkdownDescription: "Maximum number of scripts to execute in parallel. 0 means unlimited (default).",
+				MarkdownDescription: "Maximum number of scripts to execute in parallel. Resources waiting between hooks, such as during post_create_read_delay or while polling status, wait_for or a delete, don't count against it. 0 means unlimited (default).",
+			},
+			"max_subprocesses": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Failsafe limit on the total number of hook processes launched during a single Terraform operation, independent of `parallelism`. Hooks fail with an error once it is reached. 0 means unlimited (default).",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"max_output_depth": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum nesting depth of objects and lists in hook output. Deeper output fails the hook instead of being converted. Defaults to 100, 0 means unlimited.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"max_capture_bytes": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of bytes of stdout and stderr kept in memory per hook execution. The full output of a hook exceeding it is saved to a temporary file named in diagnostics, and a truncated stdout fails the hook. Defaults to 67108864 (64 MiB), 0 means unlimited.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"input_socket_threshold": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Payload size in bytes above which local hooks get `{\"input_socket\": ..., \"input_bytes\": ...}` on stdin instead of the payload, and fetch the payload with an HTTP GET over that Unix socket, e.g. `curl -s --unix-socket \"$socket\" http://customcrud/`. Suits huge inputs that some interpreters mishandle on stdin. 0 (default) always writes the payload to stdin.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"keep_temp_files": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Keep the temporary files holding the output of hooks exceeding `max_capture_bytes` after the provider exits, for debugging. By default they are removed when the provider shuts down, as hook output may hold secrets.",
+			},
+			"temp_file_max_age_hours": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Age in hours past which temporary files left behind by provider processes that crashed or were killed are removed when the provider is configured. Defaults to 24, 0 disables the sweep.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"max_output_nodes": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of values, counting every nested object, list and scalar, in hook output. Larger output fails the hook instead of being converted. Defaults to 1000000, 0 means unlimited.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"resource_parallelism": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of resource and list resource scripts to execute in parallel, instead of sharing the `parallelism` limit. 0 means unlimited.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"data_source_parallelism": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of data source scripts to execute in parallel, instead of sharing the `parallelism` limit. 0 means unlimited.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"ephemeral_parallelism": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum number of ephemeral resource scripts to execute in parallel, instead of sharing the `parallelism` limit. 0 means unlimited.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"collection_typing": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "How arrays in hook output are typed: `tuple` (default) converts every array to a tuple, `list-when-homogeneous` converts arrays whose elements share a single type to lists. Applies to the output of resources, data sources and ephemeral resources alike. Arrays merged into `input` keep the type of the configured value.",
+				Validators: []validator.String{
+					stringvalidator.OneOf(utils.CollectionTypingTuple, utils.CollectionTypingList),
+				},
+			},
+			"compatibility_mode": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Hook protocol version the scripts are written against, so the provider can be upgraded before the scripts are migrated. `v2` (default) is the current protocol. `v1` freezes the original one: the payload only holds `id`, `input` and `output`, and the output is a single JSON object whose keys, including `private` and `__sensitive`, are all stored, with numbers parsed as 64-bit floats.",
+				Validators: []validator.String{
+					stringvalidator.OneOf(utils.CompatibilityV1, utils.CompatibilityV2),
+				},
 			},
 			"high_precision_numbers": schema.BoolAttribute{
 				Optional:            true,
-				MarkdownDescription: "Enable high precision for floating point numbers. This will cause the json parsing for outputs to use 512-bit floats instead of the default 64-bit.",
+				MarkdownDescription: "Enable high precision for floating point numbers. This will cause the json parsing for outputs to use 512-bit floats instead of the default 64-bit for numbers with a fraction or exponent. Integers are always parsed exactly.",
 			},
 			"default_inputs": schema.DynamicAttribute{
 				Optional:            true,
@@ -62,6 +198,95 @@ func (p *CustomCRUDProvider) Schema(ctx context.Context, req provider.SchemaRequ
 				Optional:            true,
 				MarkdownDescription: "Exit code that indicates a resource no longer exists on the remote. Defaults to 22. Set to -1 to disable this feature.",
 			},
+			"requires_replace_exit_code": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Exit code of the resource `requires_replace` hook that forces replacement instead of an update. Defaults to 10.",
+			},
+			"already_deleted_exit_code": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Exit code of the resource `delete` hook that indicates the resource was already gone, which counts as a successful destroy. Defaults to 22. Set to -1 to disable this feature.",
+			},
+			"working_directory": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Default working directory for hook execution. Relative hook paths are resolved against it. Can be overridden per hooks block, defaults to the directory Terraform launched the provider from.",
+			},
+			"age_identity": schema.StringAttribute{
+				Optional:            true,
+				Sensitive:           true,
+				MarkdownDescription: "age identities (`AGE-SECRET-KEY-1...`, one per line) decrypting input values that are ASCII-armored age messages before they are passed to hooks. Conflicts with `age_identity_file`.",
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(path.MatchRoot("age_identity_file")),
+				},
+			},
+			"age_identity_file": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Path to an age identity file, as written by `age-keygen`, decrypting input values that are ASCII-armored age messages before they are passed to hooks.",
+			},
+			"credential_helper": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Command run before every hook, receiving the hook `id` and `phase` as JSON on stdin. It prints a JSON object whose keys and values are added to the environment of that hook only, for short-lived per-operation credentials. Its output is never logged and the values are masked in hook output.",
+			},
+			"on_shutdown": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Command run once when the provider shuts down, including when Terraform interrupts an operation, to clean up long-lived helpers such as daemons or tunnels started by hooks. It runs like a hook with an empty payload and its output is ignored.",
+			},
+			"executor": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Backend used to run hooks: `local` (default), `docker`, `ssh`, `http` or `mock`. Configure it with `executor_options`.",
+			},
+			"executor_options": schema.MapAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "Options for the selected executor. `docker` requires `image` and accepts `args` and `binary`; `ssh` requires `host` and accepts `port`, `identity_file`, `args` and `binary`; `http` requires `url`; `mock` accepts `stdout`, `stderr`, `exit_code` and `echo`, which prints the prior output overlaid with the input and id of each hook payload instead of `stdout`.",
+			},
+			"runtimes": schema.MapAttribute{
+				ElementType:         types.MapType{ElemType: types.StringType},
+				Optional:            true,
+				MarkdownDescription: "Named executors that the `runtimes` attribute of hooks blocks selects per hook, e.g. `{ builder = { executor = \"docker\", image = \"builder:1\" }, appliance = { executor = \"ssh\", host = \"lb1\" } }`. Each runtime sets `executor` to one of the `executor` backends and the rest of its keys are the `executor_options` of that backend. Hooks without a runtime use the provider `executor`.",
+			},
+			"verify_hooks": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Check while planning that the executable of every resource hook exists and is executable, so that a missing or non-executable script fails the plan with an error on the hook instead of the apply. Commands without a path are looked up in `PATH`. Only applies to the `local` executor.",
+			},
+			"profile": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Record the timing breakdown of every resource, data source and ephemeral resource operation in `profile_file`: the time spent waiting on parallelism limits, building the payload, running hooks, parsing their output and converting it into state. Use it to tell slow scripts from provider overhead.",
+			},
+			"profile_file": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "File the `profile` timings are appended to, one JSON object per operation. Defaults to `" + defaultProfileFile + "` in the directory Terraform runs in.",
+			},
+			"execution_windows": schema.ListAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "Cron expressions of the minutes in which create, update and delete hooks may run, e.g. `[\"* 22-23 * * 1-5\"]` for weekday evenings. Each has five fields, minute hour day-of-month month day-of-week, which take names such as `JAN` and `MON-FRI`, or is a macro such as `@daily`, matched in UTC unless prefixed with a time zone, e.g. `TZ=Europe/Berlin * 9-16 * * 1-4`. Outside of them these operations fail, or wait for `execution_window_wait_minutes`, which enforces change freezes for the whole provider. Plans and reads run at any time.",
+			},
+			"execution_window_wait_minutes": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "How long a create, update or delete outside the `execution_windows` waits for a window to open before failing. Defaults to 0, failing right away.",
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"sort_output_lists": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Sort every list of strings, numbers or booleans in hook output before storing it, to avoid order-only diffs from backends that return collections in nondeterministic order. Use the resource `sort_output_lists` attribute to sort only selected keys.",
+			},
+			"hook_path_rewrites": schema.MapAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "Leading paths of the hook programs stored in state to replace, e.g. `{ \"./scripts/\" = \"./hooks/v2/\" }`, for when scripts move to a new directory layout. Refreshing rewrites the stored hooks of every resource in place and destroying uses the rewritten hooks, so existing resources run the scripts at their new paths without being updated or replaced. Only hook commands and argv entries starting with a key, and a working_directory starting with one, are rewritten, and values already starting with its replacement are left alone. Longer keys are tried first.",
+			},
+			"hook_sets": schema.MapAttribute{
+				ElementType:         types.MapType{ElemType: types.StringType},
+				Optional:            true,
+				MarkdownDescription: "Named sets of resource hooks, e.g. `{ vm = { create = \"./scripts/vm/create.sh\", read = \"./scripts/vm/read.sh\", delete = \"./scripts/vm/delete.sh\" } }`, that resources run by setting `hooks_ref` to the name instead of a `hooks` block. Each set maps the string attributes of the `hooks` block to their values and must set `create` or `apply`, the other hooks being optional like in a `hooks` block. Only the name is stored in state, so changing the commands of a set never shows up as a resource diff.",
+			},
+			"sensitive_keys": schema.ListAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "Input and output keys (e.g. `password`, or dot-separated paths such as `db.password`) whose values are masked in logs and error diagnostics of every hook, wherever they appear in payloads, stdout or stderr.",
+			},
 		},
 	}
 }
@@ -84,6 +309,62 @@ func (p *CustomCRUDProvider) Configure(ctx context.Context, req provider.Configu
 		p.config.Semaphore = make(chan struct{}, p.config.Parallelism)
 	}
 
+	if !data.MaxOutputDepth.IsNull() && !data.MaxOutputDepth.IsUnknown() {
+		p.config.MaxOutputDepth = int(data.MaxOutputDepth.ValueInt64())
+	}
+
+	if !data.MaxOutputNodes.IsNull() && !data.MaxOutputNodes.IsUnknown() {
+		p.config.MaxOutputNodes = int(data.MaxOutputNodes.ValueInt64())
+	}
+
+	if !data.MaxCaptureBytes.IsNull() && !data.MaxCaptureBytes.IsUnknown() {
+		p.config.MaxCaptureBytes = int(data.MaxCaptureBytes.ValueInt64())
+	}
+
+	if !data.InputSocketThreshold.IsNull() && !data.InputSocketThreshold.IsUnknown() {
+		p.config.InputSocketThreshold = int(data.InputSocketThreshold.ValueInt64())
+	}
+
+	p.config.ReadCache = utils.NewReadCache()
+	p.config.TransactionGroups = utils.NewTransactionGroups()
+
+	utils.KeepTempFiles(data.KeepTempFiles.ValueBool())
+	maxAge := utils.DefaultTempFileMaxAge
+	if !data.TempFileMaxAgeHours.IsNull() && !data.TempFileMaxAgeHours.IsUnknown() {
+		maxAge = time.Duration(data.TempFileMaxAgeHours.ValueInt64()) * time.Hour
+	}
+	if maxAge > 0 {
+		sweepTempFiles(ctx, maxAge)
+	}
+
+	if !data.MaxSubprocesses.IsNull() && !data.MaxSubprocesses.IsUnknown() {
+		p.config.SubprocessBudget = utils.NewSubprocessBudget(int(data.MaxSubprocesses.ValueInt64()))
+	}
+
+	p.kindSemaphores = map[string]chan struct{}{}
+	for kind, parallelism := range map[string]types.Int64{
+		resourceKind:   data.ResourceParallelism,
+		dataSourceKind: data.DataSourceParallelism,
+		ephemeralKind:  data.EphemeralParallelism,
+	} {
+		if parallelism.IsNull() || parallelism.IsUnknown() {
+			continue
+		}
+		var sem chan struct{}
+		if n := parallelism.ValueInt64(); n > 0 {
+			sem = make(chan struct{}, n)
+		}
+		p.kindSemaphores[kind] = sem
+	}
+
+	if !data.CompatibilityMode.IsNull() && !data.CompatibilityMode.IsUnknown() {
+		p.config.CompatibilityMode = data.CompatibilityMode.ValueString()
+	}
+
+	if !data.CollectionTyping.IsNull() && !data.CollectionTyping.IsUnknown() {
+		p.config.CollectionTyping = data.CollectionTyping.ValueString()
+	}
+
 	if !data.HighPrecisionNumbers.IsNull() {
 		p.config.HighPrecisionNumbers = data.HighPrecisionNumbers.ValueBool()
 	}
@@ -96,9 +377,194 @@ func (p *CustomCRUDProvider) Configure(ctx context.Context, req provider.Configu
 		p.config.MissingResourceExitCode = int(data.MissingResourceExitCode.ValueInt64())
 	}
 
+	if !data.RequiresReplaceExitCode.IsNull() && !data.RequiresReplaceExitCode.IsUnknown() {
+		p.config.RequiresReplaceExitCode = int(data.RequiresReplaceExitCode.ValueInt64())
+	}
+
+	if !data.AlreadyDeletedExitCode.IsNull() && !data.AlreadyDeletedExitCode.IsUnknown() {
+		p.config.AlreadyDeletedExitCode = int(data.AlreadyDeletedExitCode.ValueInt64())
+	}
+
+	if !data.WorkingDirectory.IsNull() && !data.WorkingDirectory.IsUnknown() {
+		p.config.WorkingDirectory = data.WorkingDirectory.ValueString()
+	}
+
+	executorOptions := map[string]string{}
+	if !data.ExecutorOptions.IsNull() && !data.ExecutorOptions.IsUnknown() {
+		resp.Diagnostics.Append(data.ExecutorOptions.ElementsAs(ctx, &executorOptions, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	executor, err := utils.NewExecutor(data.Executor.ValueString(), executorOptions)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("executor"), "Invalid Executor", err.Error())
+		return
+	}
+	p.config.Executor = executor
+
+	if !data.Runtimes.IsNull() && !data.Runtimes.IsUnknown() {
+		var runtimes map[string]map[string]string
+		resp.Diagnostics.Append(data.Runtimes.ElementsAs(ctx, &runtimes, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		p.config.Runtimes = make(map[string]utils.Executor, len(runtimes))
+		for name, options := range runtimes {
+			backend := options["executor"]
+			delete(options, "executor")
+			if p.config.Runtimes[name], err = utils.NewExecutor(backend, options); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("runtimes").AtMapKey(name), "Invalid Runtime", err.Error())
+				return
+			}
+		}
+	}
+
+	if data.VerifyHooks.ValueBool() {
+		if name := data.Executor.ValueString(); name != "" && name != utils.LocalExecutor {
+			resp.Diagnostics.AddAttributeWarning(path.Root("verify_hooks"), "Hooks Not Verified",
+				fmt.Sprintf("verify_hooks only checks the executables of the local executor, the hooks run by the %s executor aren't verified.", name))
+		} else {
+			p.config.VerifyHooks = true
+		}
+	}
+
+	if data.Profile.ValueBool() {
+		file := data.ProfileFile.ValueString()
+		if file == "" {
+			file = defaultProfileFile
+		}
+		p.config.Profiler = utils.NewProfiler(file)
+	}
+
+	if !data.ExecutionWindows.IsNull() && !data.ExecutionWindows.IsUnknown() {
+		var exprs []string
+		resp.Diagnostics.Append(data.ExecutionWindows.ElementsAs(ctx, &exprs, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		wait := time.Duration(data.ExecutionWindowWait.ValueInt64()) * time.Minute
+		p.config.ExecutionWindows, err = utils.ParseExecutionWindows(exprs, wait)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("execution_windows"), "Invalid Execution Window", err.Error())
+			return
+		}
+	}
+
+	if identity := data.AgeIdentity.ValueString(); identity != "" {
+		p.config.AgeIdentities, err = utils.ParseAgeIdentities(identity)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("age_identity"), "Invalid age Identity", err.Error())
+			return
+		}
+	}
+	if file := data.AgeIdentityFile.ValueString(); file != "" {
+		p.config.AgeIdentities, err = utils.ReadAgeIdentities(file)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("age_identity_file"), "Invalid age Identity File", err.Error())
+			return
+		}
+	}
+
+	if command := data.CredentialHelper.ValueString(); command != "" {
+		p.config.CredentialHelper, err = utils.SplitCommand(command)
+		if err != nil || len(p.config.CredentialHelper) == 0 {
+			resp.Diagnostics.AddAttributeError(path.Root("credential_helper"), "Invalid credential_helper Command", fmt.Sprintf("failed to parse credential_helper command: %v", err))
+			return
+		}
+	}
+
+	var shutdownCmd []string
+	if command := data.OnShutdown.ValueString(); command != "" {
+		shutdownCmd, err = utils.SplitCommand(command)
+		if err != nil || len(shutdownCmd) == 0 {
+			resp.Diagnostics.AddAttributeError(path.Root("on_shutdown"), "Invalid on_shutdown Command", fmt.Sprintf("failed to parse on_shutdown command: %v", err))
+			return
+		}
+	}
+	registerShutdown(p, shutdownCmd)
+
+	if !data.SortOutputLists.IsNull() {
+		p.config.SortOutputLists = data.SortOutputLists.ValueBool()
+	}
+
+	if !data.HookPathRewrites.IsNull() && !data.HookPathRewrites.IsUnknown() {
+		rewrites := map[string]string{}
+		resp.Diagnostics.Append(data.HookPathRewrites.ElementsAs(ctx, &rewrites, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		p.config.HookRewrites = utils.NewHookRewrites(rewrites)
+	}
+
+	if !data.HookSets.IsNull() && !data.HookSets.IsUnknown() {
+		resp.Diagnostics.Append(data.HookSets.ElementsAs(ctx, &p.config.HookSets, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		if err := checkHookSets(p.config.HookSets); err != nil {
+			resp.Diagnostics.AddAttributeError(path.Root("hook_sets"), "Invalid Hook Set", err.Error())
+			return
+		}
+	}
+
+	if !data.SensitiveKeys.IsNull() && !data.SensitiveKeys.IsUnknown() {
+		resp.Diagnostics.Append(data.SensitiveKeys.ElementsAs(ctx, &p.config.SensitiveKeys, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
 	resp.ResourceData = p
 	resp.DataSourceData = p
 	resp.EphemeralResourceData = p
+	resp.ListResourceData = p
+}
+
+// hookSetAttributes are the hooks block attributes a hook set may hold.
+var hookSetAttributes = []string{
+	utils.Create, utils.Read, utils.Update, utils.Delete, utils.Apply, utils.Destroy, utils.Plan, utils.Diff, utils.Validate,
+	utils.Import, utils.Upgrade, utils.Exists, utils.Status, utils.WaitFor, utils.Verify, utils.OnFailure, utils.RequiresReplace, utils.WorkingDirectory, utils.OutputFormat,
+	utils.StatusInterval, utils.StatusTimeout,
+}
+
+// checkHookSets returns an error if a hook set isn't valid.
+func checkHookSets(sets map[string]map[string]string) error {
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checkHookSet(sets[name]); err != nil {
+			return fmt.Errorf("hook set %q %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkHookSet returns an error if hooks hold an attribute the hooks block
+// doesn't have or lack both the create and apply hooks.
+func checkHookSet(hooks map[string]string) error {
+	for attribute := range hooks {
+		if !slices.Contains(hookSetAttributes, attribute) {
+			return fmt.Errorf("has unsupported attribute %q, expected one of %s", attribute, strings.Join(hookSetAttributes, ", "))
+		}
+	}
+	if strings.TrimSpace(hooks[utils.Create]) == "" && strings.TrimSpace(hooks[utils.Apply]) == "" {
+		return fmt.Errorf("must set the %s or %s hook", utils.Create, utils.Apply)
+	}
+	return nil
+}
+
+// kindConfig returns the provider config for the given kind of object, which
+// holds the kind's own semaphore when its parallelism is set.
+func (p *CustomCRUDProvider) kindConfig(kind string) utils.CustomCRUDProviderConfig {
+	config := p.config
+	if sem, ok := p.kindSemaphores[kind]; ok {
+		config.Semaphore = sem
+	}
+	return config
 }
 
 func (p *CustomCRUDProvider) Resources(ctx context.Context) []func() resource.Resource {
@@ -113,6 +579,12 @@ func (p *CustomCRUDProvider) EphemeralResources(ctx context.Context) []func() ep
 	}
 }
 
+func (p *CustomCRUDProvider) ListResources(ctx context.Context) []func() list.ListResource {
+	return []func() list.ListResource{
+		NewCustomCrudListResource,
+	}
+}
+
 func (p *CustomCRUDProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
 		NewCustomCrudDataSource,
@@ -120,7 +592,14 @@ func (p *CustomCRUDProvider) DataSources(ctx context.Context) []func() datasourc
 }
 
 func (p *CustomCRUDProvider) Functions(ctx context.Context) []func() function.Function {
-	return []func() function.Function{}
+	return []func() function.Function{
+		NewB64fileFunction,
+		NewRunFunction,
+		NewJqFunction,
+		NewImportIdFunction,
+		NewParseEnvFunction,
+		NewParseIniFunction,
+	}
 }
 
 func New(version string) func() provider.Provider {
@@ -4,12 +4,31 @@
 package provider
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 )
 
 // testAccProtoV6ProviderFactories is used to instantiate a provider during acceptance testing.
@@ -44,3 +63,910 @@ func testAccPreCheck(t *testing.T) {
 	t.Setenv("TF_LOG", "DEBUG")
 	t.Setenv("TF_LOG_PATH", logFile)
 }
+
+func TestUnitExecutors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("mock executor", func(t *testing.T) {
+		executor, err := utils.NewExecutor("mock", map[string]string{"stdout": `{"id": "mocked"}`})
+		if err != nil {
+			t.Fatalf("Failed to build mock executor: %v", err)
+		}
+		config := utils.CustomCRUDProviderConfigDefaults()
+		config.Executor = executor
+		result, err := utils.Execute(ctx, config, []string{"does-not-exist"}, utils.ExecutionPayload{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Result["id"] != "mocked" {
+			t.Errorf("Expected id mocked, got %v", result.Result["id"])
+		}
+	})
+
+	t.Run("mock executor echo", func(t *testing.T) {
+		executor, err := utils.NewExecutor("mock", map[string]string{"echo": "true"})
+		if err != nil {
+			t.Fatalf("Failed to build mock executor: %v", err)
+		}
+		config := utils.CustomCRUDProviderConfigDefaults()
+		config.Executor = executor
+		payload := utils.ExecutionPayload{
+			Input:  map[string]interface{}{"name": "web"},
+			Output: map[string]interface{}{"name": "old", "status": "running"},
+		}
+		result, err := utils.Execute(ctx, config, []string{"create"}, payload)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Result["id"] != "mock" || result.Result["name"] != "web" || result.Result["status"] != "running" {
+			t.Errorf("Expected the payload echoed, got %v", result.Result)
+		}
+
+		payload.Id = "vm-1"
+		if result, err = utils.Execute(ctx, config, []string{"read"}, payload); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Result["id"] != "vm-1" {
+			t.Errorf("Expected the payload id echoed, got %v", result.Result["id"])
+		}
+
+		if _, err := utils.NewExecutor("mock", map[string]string{"echo": "sometimes"}); err == nil {
+			t.Error("Expected an invalid echo option to fail")
+		}
+	})
+
+	t.Run("http executor", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Command []string `json:"command"`
+				Stdin   string   `json:"stdin"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			stdout, _ := json.Marshal(map[string]interface{}{"command": req.Command[0], "stdin": req.Stdin})
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"stdout": string(stdout), "exit_code": 0})
+		}))
+		defer server.Close()
+
+		executor, err := utils.NewExecutor("http", map[string]string{"url": server.URL})
+		if err != nil {
+			t.Fatalf("Failed to build http executor: %v", err)
+		}
+		config := utils.CustomCRUDProviderConfigDefaults()
+		config.Executor = executor
+		result, err := utils.Execute(ctx, config, []string{"remote-hook"}, utils.ExecutionPayload{Id: "abc"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Result["command"] != "remote-hook" || result.Result["stdin"] != `{"id":"abc"}` {
+			t.Errorf("Unexpected http executor result: %v", result.Result)
+		}
+	})
+
+	t.Run("custom executor", func(t *testing.T) {
+		utils.RegisterExecutor("test-custom", func(options map[string]string) (utils.Executor, error) {
+			return &utils.MockExecutor{Stderr: "boom", ExitCode: 3}, nil
+		})
+		executor, err := utils.NewExecutor("test-custom", nil)
+		if err != nil {
+			t.Fatalf("Failed to build custom executor: %v", err)
+		}
+		config := utils.CustomCRUDProviderConfigDefaults()
+		config.Executor = executor
+		result, err := utils.Execute(ctx, config, []string{"anything"}, utils.ExecutionPayload{})
+		if err == nil {
+			t.Fatal("Expected execution to fail")
+		}
+		if result.ExitCode != 3 || result.Stderr != "boom" {
+			t.Errorf("Unexpected custom executor result: %+v", result)
+		}
+	})
+
+	t.Run("unknown executor", func(t *testing.T) {
+		if _, err := utils.NewExecutor("nope", nil); err == nil {
+			t.Error("Expected unknown executor to fail")
+		}
+	})
+}
+
+func TestAccProviderMockExecutor(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+provider "customcrud" {
+  executor = "mock"
+  executor_options = {
+    stdout = "{\"id\": \"mocked\", \"name\": \"from-mock\"}"
+  }
+}
+
+resource "customcrud" "test" {
+  hooks {
+    create = "create"
+    read   = "read"
+    delete = "delete"
+  }
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("customcrud.test", "id", "mocked"),
+					resource.TestCheckResourceAttr("customcrud.test", "output.name", "from-mock"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitProviderSensitiveKeys(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.SensitiveKeys = []string{"api_key", "password"}
+	config.Executor = &utils.MockExecutor{
+		Stdout:   `{"id": "1", "db": {"password": "hunter2"}}`,
+		Stderr:   "login with abc123 failed",
+		ExitCode: 1,
+	}
+	payload := utils.ExecutionPayload{Input: map[string]interface{}{"api_key": "abc123", "name": "app"}}
+	result, err := utils.Execute(ctx, config, []string{"create"}, payload)
+	if err == nil {
+		t.Fatal("Expected execution to fail")
+	}
+
+	if !strings.Contains(logs.String(), "Script execution failed") {
+		t.Fatalf("Expected the execution to be logged, got: %s", logs.String())
+	}
+	masked := strings.Join([]string{result.Mask(result.Payload), result.Mask(result.Stdout), result.Mask(result.Stderr), logs.String()}, "\n")
+	for _, secret := range []string{"abc123", "hunter2"} {
+		if strings.Contains(masked, secret) {
+			t.Errorf("Expected %q to be masked, got:\n%s", secret, masked)
+		}
+	}
+	if !strings.Contains(result.Mask(result.Payload), `"name":"app"`) {
+		t.Errorf("Expected other values to be left alone, got: %s", result.Mask(result.Payload))
+	}
+}
+
+// configureProvider configures a provider with the given attributes set,
+// leaving every other attribute null.
+func configureProvider(t *testing.T, values map[string]tftypes.Value) *CustomCRUDProvider {
+	t.Helper()
+	ctx := context.Background()
+	p := New("test")().(*CustomCRUDProvider)
+	schemaResp := &provider.SchemaResponse{}
+	p.Schema(ctx, provider.SchemaRequest{}, schemaResp)
+
+	objType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	attrs := map[string]tftypes.Value{}
+	for name, attrType := range objType.AttributeTypes {
+		attrs[name] = tftypes.NewValue(attrType, nil)
+		if value, ok := values[name]; ok {
+			attrs[name] = value
+		}
+	}
+	req := provider.ConfigureRequest{Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: tftypes.NewValue(objType, attrs)}}
+	resp := &provider.ConfigureResponse{}
+	p.Configure(ctx, req, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Failed to configure provider: %v", resp.Diagnostics)
+	}
+	return p
+}
+
+// mockProvider is the provider running the hooks of its resources with
+// executor, for tests driving it through the plugin protocol.
+type mockProvider struct {
+	*CustomCRUDProvider
+	executor utils.Executor
+}
+
+func (p mockProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	p.CustomCRUDProvider.Configure(ctx, req, resp)
+	p.config.Executor = p.executor
+}
+
+// applyCreate plans and applies the create of a customcrud resource with the
+// configuration of model through the plugin protocol, running its hooks with
+// executor, and returns the apply response with the new state, null when
+// nothing was saved.
+func applyCreate(t *testing.T, executor utils.Executor, model customCrudResourceModel) (*tfprotov6.ApplyResourceChangeResponse, tftypes.Value) {
+	t.Helper()
+	_, applyResp, state := applyChange(t, protocolServer(t, executor), tftypes.Value{}, nil, model)
+	return applyResp, state
+}
+
+// protocolServer returns the protocol server of a configured provider
+// running hooks with executor.
+func protocolServer(t *testing.T, executor utils.Executor) tfprotov6.ProviderServer {
+	t.Helper()
+	ctx := context.Background()
+	p := New("test")().(*CustomCRUDProvider)
+	server := providerserver.NewProtocol6(mockProvider{p, executor})()
+
+	providerSchema := &provider.SchemaResponse{}
+	p.Schema(ctx, provider.SchemaRequest{}, providerSchema)
+	providerType := providerSchema.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	providerAttrs := map[string]tftypes.Value{}
+	for name, attrType := range providerType.AttributeTypes {
+		providerAttrs[name] = tftypes.NewValue(attrType, nil)
+	}
+	providerConfig, err := tfprotov6.NewDynamicValue(providerType, tftypes.NewValue(providerType, providerAttrs))
+	if err != nil {
+		t.Fatalf("Failed to encode the provider config: %v", err)
+	}
+	configureResp, err := server.ConfigureProvider(ctx, &tfprotov6.ConfigureProviderRequest{Config: &providerConfig})
+	if err != nil || diagsHaveError(configureResp.Diagnostics) {
+		t.Fatalf("Failed to configure the provider: %v %s", err, protoDiags(configureResp.Diagnostics))
+	}
+	return server
+}
+
+// applyChange plans and applies the configuration of model over the prior
+// state and private state of a customcrud resource, a create when prior is
+// the zero value, and returns the plan and apply responses with the new
+// state, null when nothing was saved.
+func applyChange(t *testing.T, server tfprotov6.ProviderServer, prior tftypes.Value, priorPrivate []byte, model customCrudResourceModel) (*tfprotov6.PlanResourceChangeResponse, *tfprotov6.ApplyResourceChangeResponse, tftypes.Value) {
+	t.Helper()
+	ctx := context.Background()
+	planResp, priorValue, configValue := planChange(t, server, prior, priorPrivate, model)
+	if diagsHaveError(planResp.Diagnostics) {
+		t.Fatalf("Failed to plan the change: %s", protoDiags(planResp.Diagnostics))
+	}
+	applyResp, err := server.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{
+		TypeName:       "customcrud",
+		PriorState:     priorValue,
+		PlannedState:   planResp.PlannedState,
+		Config:         configValue,
+		PlannedPrivate: planResp.PlannedPrivate,
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply the change: %v", err)
+	}
+	state := tftypes.NewValue(resourceType(), nil)
+	if applyResp.NewState != nil {
+		if state, err = applyResp.NewState.Unmarshal(resourceType()); err != nil {
+			t.Fatalf("Failed to decode the new state: %v", err)
+		}
+	}
+	return planResp, applyResp, state
+}
+
+// planChange plans the configuration of model over the prior state and
+// private state like applyChange, and returns the plan response with the
+// encoded prior state and config. Computed attributes the configuration
+// leaves null keep their prior value in the proposed new state, like
+// Terraform proposes them.
+func planChange(t *testing.T, server tfprotov6.ProviderServer, prior tftypes.Value, priorPrivate []byte, model customCrudResourceModel) (*tfprotov6.PlanResourceChangeResponse, *tfprotov6.DynamicValue, *tfprotov6.DynamicValue) {
+	t.Helper()
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	(&customCrudResource{}).Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	resourceType := schemaResp.Schema.Type().TerraformType(ctx)
+	config := tfsdk.Plan{Schema: schemaResp.Schema, Raw: tftypes.NewValue(resourceType, nil)}
+	if diags := config.Set(ctx, &model); diags.HasError() {
+		t.Fatalf("Failed to build the config: %v", diags)
+	}
+	configValue, err := tfprotov6.NewDynamicValue(resourceType, config.Raw)
+	if err != nil {
+		t.Fatalf("Failed to encode the config: %v", err)
+	}
+	proposed := config.Raw
+	if prior.Type() == nil {
+		prior = tftypes.NewValue(resourceType, nil)
+	} else {
+		var configAttrs, priorAttrs map[string]tftypes.Value
+		_ = config.Raw.As(&configAttrs)
+		_ = prior.As(&priorAttrs)
+		for name, attribute := range schemaResp.Schema.Attributes {
+			if attribute.IsComputed() && configAttrs[name].IsNull() {
+				configAttrs[name] = priorAttrs[name]
+			}
+		}
+		proposed = tftypes.NewValue(resourceType, configAttrs)
+	}
+	priorValue, _ := tfprotov6.NewDynamicValue(resourceType, prior)
+	proposedValue, _ := tfprotov6.NewDynamicValue(resourceType, proposed)
+
+	planResp, err := server.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{
+		TypeName:         "customcrud",
+		PriorState:       &priorValue,
+		PriorPrivate:     priorPrivate,
+		ProposedNewState: &proposedValue,
+		Config:           &configValue,
+	})
+	if err != nil {
+		t.Fatalf("Failed to plan the change: %v", err)
+	}
+	return planResp, &priorValue, &configValue
+}
+
+// resourceType returns the Terraform type of the customcrud resource.
+func resourceType() tftypes.Type {
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	(&customCrudResource{}).Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+	return schemaResp.Schema.Type().TerraformType(ctx)
+}
+
+// readResource refreshes state through server and returns the response
+// with the refreshed state.
+func readResource(t *testing.T, server tfprotov6.ProviderServer, state tftypes.Value, private []byte) (*tfprotov6.ReadResourceResponse, tftypes.Value) {
+	t.Helper()
+	current, err := tfprotov6.NewDynamicValue(resourceType(), state)
+	if err != nil {
+		t.Fatalf("Failed to encode the state: %v", err)
+	}
+	readResp, err := server.ReadResource(context.Background(), &tfprotov6.ReadResourceRequest{
+		TypeName:     "customcrud",
+		CurrentState: &current,
+		Private:      private,
+	})
+	if err != nil || diagsHaveError(readResp.Diagnostics) {
+		t.Fatalf("Failed to read the resource: %v %s", err, protoDiags(readResp.Diagnostics))
+	}
+	refreshed := tftypes.NewValue(resourceType(), nil)
+	if readResp.NewState != nil {
+		if refreshed, err = readResp.NewState.Unmarshal(resourceType()); err != nil {
+			t.Fatalf("Failed to decode the refreshed state: %v", err)
+		}
+	}
+	return readResp, refreshed
+}
+
+// protoDiags formats diags for test failures.
+func protoDiags(diags []*tfprotov6.Diagnostic) string {
+	var b strings.Builder
+	for _, d := range diags {
+		fmt.Fprintf(&b, "%s: %s: %s\n", d.Severity, d.Summary, d.Detail)
+	}
+	return b.String()
+}
+
+// diagsHaveError reports whether diags hold an error.
+func diagsHaveError(diags []*tfprotov6.Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUnitProviderKindParallelism(t *testing.T) {
+	p := configureProvider(t, map[string]tftypes.Value{
+		"parallelism":           tftypes.NewValue(tftypes.Number, 4),
+		"resource_parallelism":  tftypes.NewValue(tftypes.Number, 1),
+		"ephemeral_parallelism": tftypes.NewValue(tftypes.Number, 0),
+	})
+
+	if sem := p.kindConfig(resourceKind).Semaphore; cap(sem) != 1 || sem == p.config.Semaphore {
+		t.Errorf("Expected resources to get their own semaphore of size 1, got size %d", cap(sem))
+	}
+	if sem := p.kindConfig(ephemeralKind).Semaphore; sem != nil {
+		t.Errorf("Expected ephemeral resources to be unlimited, got size %d", cap(sem))
+	}
+	if sem := p.kindConfig(dataSourceKind).Semaphore; sem != p.config.Semaphore || cap(sem) != 4 {
+		t.Errorf("Expected data sources to share the provider semaphore, got size %d", cap(sem))
+	}
+}
+
+func TestUnitProviderMaxSubprocesses(t *testing.T) {
+	p := configureProvider(t, map[string]tftypes.Value{
+		"max_subprocesses": tftypes.NewValue(tftypes.Number, 2),
+	})
+
+	// Every kind draws from the same budget
+	ctx := context.Background()
+	for _, kind := range []string{resourceKind, dataSourceKind} {
+		if _, err := utils.Execute(ctx, p.kindConfig(kind), []string{"true"}, utils.ExecutionPayload{}); err != nil {
+			t.Fatalf("Unexpected error for %s hook: %v", kind, err)
+		}
+	}
+	result, err := utils.Execute(ctx, p.kindConfig(ephemeralKind), []string{"true"}, utils.ExecutionPayload{})
+	if err == nil || !strings.Contains(err.Error(), "max_subprocesses") {
+		t.Fatalf("Expected the third hook to exceed max_subprocesses, got %v", err)
+	}
+	if result.ExitCode != -1 {
+		t.Errorf("Expected exit code -1 for a hook that wasn't launched, got %d", result.ExitCode)
+	}
+}
+
+func TestUnitProviderOnShutdown(t *testing.T) {
+	ctx := context.Background()
+	marker := filepath.Join(t.TempDir(), "stopped")
+	p := configureProvider(t, map[string]tftypes.Value{
+		"on_shutdown":      tftypes.NewValue(tftypes.String, "touch "+marker),
+		"max_subprocesses": tftypes.NewValue(tftypes.Number, 1),
+	})
+	// Cleanup still runs once the budget is used up
+	if _, err := utils.Execute(ctx, p.config, []string{"true"}, utils.ExecutionPayload{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	server := NewProtocol6WithShutdown(func() provider.Provider { return p })()
+	if _, ok := server.(tfprotov6.ProviderServerWithListResource); !ok {
+		t.Error("Expected the server to keep serving list resources")
+	}
+	if _, err := server.StopProvider(ctx, &tfprotov6.StopProviderRequest{}); err != nil {
+		t.Fatalf("Unexpected error stopping the provider: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("Expected stopping the provider to run on_shutdown: %v", err)
+	}
+
+	// The command only runs once
+	os.Remove(marker)
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Unexpected shutdown error: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("Expected on_shutdown to run only once, got %v", err)
+	}
+
+	configureProvider(t, map[string]tftypes.Value{
+		"on_shutdown": tftypes.NewValue(tftypes.String, "false"),
+	})
+	if err := Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "on_shutdown command failed") {
+		t.Errorf("Expected a failing on_shutdown command to be reported, got %v", err)
+	}
+}
+
+func TestUnitProviderTempFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	t.Cleanup(func() { utils.KeepTempFiles(false) })
+	spill := func(config utils.CustomCRUDProviderConfig) string {
+		t.Helper()
+		result, err := utils.Execute(ctx, config, []string{"sh", "-c", `echo '{}'; printf '%040d' 0 >&2`}, utils.ExecutionPayload{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		file := regexp.MustCompile(`full output saved to (\S+)\]`).FindStringSubmatch(result.Stderr)
+		if file == nil || filepath.Dir(file[1]) != dir {
+			t.Fatalf("Expected stderr to be saved to a file in %s, got %q", dir, result.Stderr)
+		}
+		return file[1]
+	}
+
+	p := configureProvider(t, map[string]tftypes.Value{
+		"max_capture_bytes": tftypes.NewValue(tftypes.Number, 16),
+	})
+	file := spill(p.config)
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Unexpected shutdown error: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("Expected the spill file to be removed on shutdown, got %v", err)
+	}
+
+	p = configureProvider(t, map[string]tftypes.Value{
+		"max_capture_bytes": tftypes.NewValue(tftypes.Number, 16),
+		"keep_temp_files":   tftypes.NewValue(tftypes.Bool, true),
+	})
+	file = spill(p.config)
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Unexpected shutdown error: %v", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("Expected keep_temp_files to keep the spill file: %v", err)
+	}
+
+	// Only the provider's own files past the age limit are swept
+	old := utils.Now().Add(-48 * time.Hour)
+	for _, name := range []string{utils.TempPrefix + "stale.log", utils.TempPrefix + "fresh.log", "other.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if name != utils.TempPrefix+"fresh.log" {
+			if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	removed, err := utils.SweepTempFiles(dir, utils.DefaultTempFileMaxAge)
+	if err != nil {
+		t.Fatalf("Unexpected sweep error: %v", err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != utils.TempPrefix+"stale.log" {
+		t.Errorf("Expected only the stale file to be swept, got %v", removed)
+	}
+}
+
+// testAgeIdentity decrypts testAgeMessage, which holds `s3cr3t "pass"`.
+const (
+	testAgeIdentity = "AGE-SECRET-KEY-1X8GX0Q2R8RLF4SJJWC6JTX4YG2ESKT3ZZMRXJ9EGDDXAKAW2YF6S097ZS8"
+	testAgeMessage  = `-----BEGIN AGE ENCRYPTED FILE-----
+YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBXQ1RSODdXUWZJYWwrWFhK
+OUdwMkI2VUVTbWR2VHRtbnRZZ1NJTnhFTW04CmgzcFZzbktXdGpwR0JCRW4vQWE0
+ZWQrQjlFQnhwbDBlS0h3Uk54TWtpNWsKLS0tIE1ydXc2M3AwM01UWFBUTEI1d0Fh
+OE9Dblp2cWROMHVOeGJ1WHludmRYWkkKa4Ogzen1hdbQiX85FzV1yo3PLOaNMSV2
+X8PgWf0i0nViEFDrGUKRXXYDdCNI
+-----END AGE ENCRYPTED FILE-----
+`
+)
+
+func TestUnitProviderAgeIdentity(t *testing.T) {
+	ctx := context.Background()
+	payload := utils.ExecutionPayload{Input: map[string]interface{}{
+		"user":     "admin",
+		"password": testAgeMessage,
+	}}
+	echo := []string{"sh", "-c", `p="$(cat)"; echo "$p" | jq -c '{password: .input.password}'; echo "$p" | jq -c .input >&2; exit 1`}
+
+	p := configureProvider(t, map[string]tftypes.Value{
+		"age_identity": tftypes.NewValue(tftypes.String, "# created: today\n"+testAgeIdentity+"\n"),
+	})
+	result, err := utils.Execute(ctx, p.config, echo, payload)
+	if err == nil {
+		t.Fatal("Expected the hook to fail")
+	}
+	if !strings.Contains(result.Stdout, `s3cr3t \"pass\"`) {
+		t.Errorf("Expected the hook to receive the decrypted value, got %q", result.Stdout)
+	}
+	if masked := result.Mask(result.Stderr); strings.Contains(masked, "s3cr3t") || !strings.Contains(masked, "admin") {
+		t.Errorf("Expected the decrypted value to be masked, got %q", masked)
+	}
+
+	// The identity file holds another key
+	identityFile := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(identityFile, []byte("AGE-SECRET-KEY-1P2XZDJWFSWQ55KD4W77QWVHTLAL0LVC8TKA6TDQCFDVUJ4CXR7VSA8NNHS\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p = configureProvider(t, map[string]tftypes.Value{
+		"age_identity_file": tftypes.NewValue(tftypes.String, identityFile),
+	})
+	if _, err := utils.Execute(ctx, p.config, echo, payload); err == nil || !strings.Contains(err.Error(), "identity did not match any of the recipients") {
+		t.Errorf("Expected decryption with the wrong identity to fail, got %v", err)
+	}
+
+	if _, err := utils.Execute(ctx, utils.CustomCRUDProviderConfigDefaults(), echo, payload); err == nil || !strings.Contains(err.Error(), "password is age encrypted") {
+		t.Errorf("Expected an encrypted value without identity to fail, got %v", err)
+	}
+}
+
+// testSOPSDocument is a SOPS YAML document encrypted to the recipient of
+// testAgeIdentity, holding username admin and password s3cr3t-sops.
+const testSOPSDocument = `username: ENC[AES256_GCM,data:Rd728rI=,iv:vc4t1yQewhYkSYBiZMYZF0bUsK+BfQidecJyexHSMeM=,tag:pY4AIpoAD1auFysAaUCsjA==,type:str]
+password: ENC[AES256_GCM,data:j72xqEdY3XwlKDw=,iv:9du2jX2PyHQGd2kEWisNKc44Zj3pXWe9obUbKJoWvAw=,tag:GX0vdCW7AwKaBKRiuYFylw==,type:str]
+sops:
+    age:
+        - enc: |
+            -----BEGIN AGE ENCRYPTED FILE-----
+            YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBQaC9OSzZlb1Z6WHZYOUJL
+            RWJFZm5KK2J3ejAvek51eEpnNFpJQXZ0SndBCmx0anRXQWN6OTkxMnJsYVVxNVgv
+            bEJPZmFNRFZpU0J5czRDdmtRalc0bTQKLS0tIFlkWmRuUnBWRFE0dGRiNmR5RGNM
+            QnZLTVRiYmQ3ak8zUStyR2t3Q3pNVUkKQcHGgrjNIAH9WvkzB1X5W8Md/lozQF6J
+            3hhgguTyIPUPl1Fh6YvT3OIo8JYZ2WaW8VjoBglKYu6qfz2VIq/uTw==
+            -----END AGE ENCRYPTED FILE-----
+          recipient: age1eldp2lcf0ynclfuca4d39267tzd254e7pa0kzz9mqpj03x7vtcssger949
+    lastmodified: "2026-10-16T17:42:19Z"
+    mac: ENC[AES256_GCM,data:sh4RfqX6pbDhVF4PzBB/70RNoeoMAyJkKBw9I0TIDsQVemM4+rXlMh0tIvhOgr+RTxdaskTkIqTCkSSzK0h8u5aTojZ+9jgRudLwpKpTOfZzSPJ6AOZ0Vb51x7FfIHWPu7m2ZZSUR3VLQgdrjucTkUXnkFCYVawWRcZaUMF2MHc=,iv:x/MaJpIVzvKmiIXWNk3j7KBXO0NR9Q8Z+yeRPM6MXYs=,tag:CxKeQ6Mt9B0gxlWejm6THw==,type:str]
+    unencrypted_suffix: _unencrypted
+    version: 3.13.3
+`
+
+func TestUnitSOPSInput(t *testing.T) {
+	var stdin []byte
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		stdin = req.Stdin
+		return &utils.ExecResponse{Stdout: []byte(`{}`), Stderr: []byte("connecting with s3cr3t-sops")}, nil
+	}}
+	payload := utils.ExecutionPayload{Input: map[string]interface{}{"db": testSOPSDocument}}
+
+	t.Setenv("SOPS_AGE_KEY", testAgeIdentity)
+	result, err := utils.Execute(context.Background(), config, []string{"./read.sh"}, payload)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var received struct {
+		Input map[string]map[string]interface{} `json:"input"`
+	}
+	if err := json.Unmarshal(stdin, &received); err != nil || received.Input["db"]["password"] != "s3cr3t-sops" {
+		t.Errorf("Expected the hook to receive the decrypted document, got %s", stdin)
+	}
+	if masked := result.Mask(result.Stderr); strings.Contains(masked, "s3cr3t-sops") {
+		t.Errorf("Expected the decrypted values to be masked, got %q", masked)
+	}
+
+	t.Setenv("SOPS_AGE_KEY", "AGE-SECRET-KEY-1P2XZDJWFSWQ55KD4W77QWVHTLAL0LVC8TKA6TDQCFDVUJ4CXR7VSA8NNHS")
+	if _, err := utils.Execute(context.Background(), config, []string{"./read.sh"}, payload); err == nil || !strings.Contains(err.Error(), "failed to decrypt db") {
+		t.Errorf("Expected decryption without the key to fail, got %v", err)
+	}
+}
+
+func TestUnitProviderCredentialHelper(t *testing.T) {
+	ctx := context.Background()
+	helper := filepath.Join(t.TempDir(), "helper.sh")
+	script := "#!/bin/sh\nphase=$(jq -r .phase)\necho \"{\\\"TOKEN\\\": \\\"tok-$phase\\\", \\\"TTL\\\": 60}\"\n"
+	if err := os.WriteFile(helper, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p := configureProvider(t, map[string]tftypes.Value{
+		"credential_helper": tftypes.NewValue(tftypes.String, helper),
+	})
+
+	hook := []string{"sh", "-c", `echo "{\"token\": \"$TOKEN\", \"ttl\": \"$TTL\"}"; echo "using $TOKEN" >&2`}
+	result, err := utils.Execute(ctx, p.config, hook, utils.ExecutionPayload{Phase: utils.PhaseApply})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Result["token"] != "tok-apply" || result.Result["ttl"] != "60" {
+		t.Errorf("Expected the helper output in the hook environment, got %v", result.Result)
+	}
+	if masked := result.Mask(result.Stderr); strings.Contains(masked, "tok-apply") {
+		t.Errorf("Expected the credentials to be masked, got %q", masked)
+	}
+	if _, ok := os.LookupEnv("TOKEN"); ok {
+		t.Error("Expected the credentials to stay out of the provider environment")
+	}
+
+	// Credentials are read whole rather than spilled to a file
+	token := strings.Repeat("t", 4096)
+	if err := os.WriteFile(helper, []byte("#!/bin/sh\necho '{\"TOKEN\": \""+token+"\"}'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := p.config
+	config.MaxCaptureBytes = 64
+	result, err = utils.Execute(ctx, config, []string{"sh", "-c", `echo "{\"length\": \"${#TOKEN}\"}"`}, utils.ExecutionPayload{})
+	if err != nil || result.Result["length"] != "4096" {
+		t.Errorf("Expected the whole token in the hook environment, got %v (%v)", result, err)
+	}
+
+	if err := os.WriteFile(helper, []byte("#!/bin/sh\necho '[1]'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.Execute(ctx, p.config, hook, utils.ExecutionPayload{}); err == nil || !strings.Contains(err.Error(), "JSON object of environment variables") {
+		t.Errorf("Expected invalid helper output to fail the hook, got %v", err)
+	}
+}
+
+func TestUnitProviderCompatibilityMode(t *testing.T) {
+	const stdout = `{"id": 9007199254740993, "private": {"token": "t"}, "__sensitive": ["name"], "name": "app"}`
+	run := func(config utils.CustomCRUDProviderConfig) (map[string]interface{}, *utils.ExecutionResult) {
+		t.Helper()
+		var stdin []byte
+		config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+			stdin = req.Stdin
+			return &utils.ExecResponse{Stdout: []byte(stdout)}, nil
+		}}
+		payload := utils.ExecutionPayload{
+			Id:         "1",
+			Input:      map[string]interface{}{"name": "app"},
+			Private:    map[string]interface{}{"token": "t"},
+			PriorInput: map[string]interface{}{"name": "old"},
+			Phase:      utils.PhaseApply,
+		}
+		result, err := utils.Execute(context.Background(), config, []string{"update"}, payload)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var sent map[string]interface{}
+		if err := json.Unmarshal(stdin, &sent); err != nil {
+			t.Fatalf("Failed to parse payload: %v", err)
+		}
+		return sent, result
+	}
+
+	p := configureProvider(t, map[string]tftypes.Value{
+		"compatibility_mode": tftypes.NewValue(tftypes.String, utils.CompatibilityV1),
+	})
+	sent, result := run(p.kindConfig(resourceKind))
+	for _, key := range []string{"private", "prior_input", "phase"} {
+		if _, ok := sent[key]; ok {
+			t.Errorf("Expected v1 payloads to omit %s, got %v", key, sent)
+		}
+	}
+	if _, ok := result.Result["id"].(float64); !ok {
+		t.Errorf("Expected v1 numbers to be float64, got %T", result.Result["id"])
+	}
+	if _, ok := result.Result[utils.SensitiveKey]; !ok || len(result.Sensitive) != 0 {
+		t.Errorf("Expected v1 to keep __sensitive as a regular key, got %v and %v", result.Result, result.Sensitive)
+	}
+
+	sent, result = run(utils.CustomCRUDProviderConfigDefaults())
+	if sent["phase"] != utils.PhaseApply || sent["prior_input"] == nil || sent["private"] == nil {
+		t.Errorf("Expected v2 payloads to carry every field, got %v", sent)
+	}
+	if _, ok := result.Result["id"].(json.Number); !ok {
+		t.Errorf("Expected v2 integers to be exact, got %T", result.Result["id"])
+	}
+	if len(result.Sensitive) != 1 || result.Sensitive[0] != "name" {
+		t.Errorf("Expected v2 to read __sensitive, got %v", result.Sensitive)
+	}
+}
+
+func TestUnitProviderReadCache(t *testing.T) {
+	p := configureProvider(t, map[string]tftypes.Value{})
+	config := p.kindConfig(resourceKind)
+	var runs atomic.Int64
+	fail := false
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		runs.Add(1)
+		if fail {
+			return &utils.ExecResponse{ExitCode: 1}, errors.New("exit status 1")
+		}
+		return &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1", "tags": ["a"]}`)}, nil
+	}}
+	read := func() (*utils.ExecutionResult, bool) {
+		result, err := utils.Execute(context.Background(), config, []string{"read"}, utils.ExecutionPayload{Id: "vm-1"})
+		return result, err == nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, ok := config.ReadCache.Read("vm-1", read); !ok || result.Result["id"] != "vm-1" {
+				t.Errorf("Expected the shared read to succeed, got %v", result)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("Expected reads of the same key to share one execution, got %d", n)
+	}
+
+	// Every reader gets its own copy of the output
+	first, _ := config.ReadCache.Read("vm-1", read)
+	first.Result["tags"].([]interface{})[0] = "changed"
+	if second, _ := config.ReadCache.Read("vm-1", read); second.Result["tags"].([]interface{})[0] != "a" {
+		t.Errorf("Expected cached output to be copied, got %v", second.Result)
+	}
+
+	config.ReadCache.Read("", read)
+	config.ReadCache.Read("vm-2", read)
+	if n := runs.Load(); n != 3 {
+		t.Errorf("Expected other keys and reads without a key to run, got %d executions", n)
+	}
+
+	// Changes made by a hook drop the cached result, and failures aren't cached
+	config.ReadCache.Forget("vm-1")
+	fail = true
+	if _, ok := config.ReadCache.Read("vm-1", read); ok {
+		t.Fatal("Expected the read to fail")
+	}
+	fail = false
+	if _, ok := config.ReadCache.Read("vm-1", read); !ok {
+		t.Fatal("Expected the read to succeed")
+	}
+	if n := runs.Load(); n != 5 {
+		t.Errorf("Expected forgotten and failed reads to run again, got %d executions", n)
+	}
+}
+
+func TestUnitProviderProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "profile.jsonl")
+	p := configureProvider(t, map[string]tftypes.Value{
+		"profile":      tftypes.NewValue(tftypes.Bool, true),
+		"profile_file": tftypes.NewValue(tftypes.String, file),
+	})
+	config := p.kindConfig(resourceKind)
+	config.Executor = &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &utils.ExecResponse{Stdout: []byte(`{"id": "vm-1"}`)}, nil
+	}}
+
+	for i := 0; i < 2; i++ {
+		ctx, profile := config.Profiler.Start(context.Background(), resourceKind, utils.Read)
+		profile.Mark(utils.ProfileWait)
+		if _, err := utils.Execute(ctx, config, []string{"read.sh"}, utils.ExecutionPayload{Id: "vm-1"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		profile.Finish()
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Failed to read the profile: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a line per operation, got %q", content)
+	}
+	var record struct {
+		Kind      string             `json:"kind"`
+		Operation string             `json:"operation"`
+		Id        string             `json:"id"`
+		Command   string             `json:"command"`
+		TotalMs   float64            `json:"total_ms"`
+		PhasesMs  map[string]float64 `json:"phases_ms"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Failed to parse the profile line: %v", err)
+	}
+	if record.Kind != resourceKind || record.Operation != utils.Read || record.Id != "vm-1" || record.Command != "read.sh" {
+		t.Errorf("Expected the operation and hook to be recorded, got %+v", record)
+	}
+	for _, phase := range []string{utils.ProfileWait, utils.ProfilePayload, utils.ProfileExec, utils.ProfileParse, utils.ProfileState} {
+		if _, ok := record.PhasesMs[phase]; !ok {
+			t.Errorf("Expected the %s phase to be timed, got %v", phase, record.PhasesMs)
+		}
+	}
+	if record.PhasesMs[utils.ProfileExec] < 20 || record.TotalMs < record.PhasesMs[utils.ProfileExec] {
+		t.Errorf("Expected the hook run time in the exec phase, got %v", record)
+	}
+
+	// Without profile no profile is recorded
+	unprofiled := configureProvider(t, map[string]tftypes.Value{}).kindConfig(resourceKind)
+	if _, profile := unprofiled.Profiler.Start(context.Background(), resourceKind, utils.Read); profile != nil {
+		t.Error("Expected no profile without the profile attribute")
+	}
+}
+
+func TestUnitProviderExecutionWindows(t *testing.T) {
+	ctx := context.Background()
+	// Friday 2026-10-16 17:30 UTC
+	clock := utils.NewFakeClock(time.Date(2026, 10, 16, 17, 30, 0, 0, time.UTC))
+	defer utils.SetClock(clock)()
+
+	windows := tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{
+		tftypes.NewValue(tftypes.String, "* 18-21 * * 1-5"),
+		tftypes.NewValue(tftypes.String, "TZ=Asia/Tokyo * 9 * * SAT"),
+		tftypes.NewValue(tftypes.String, "@monthly"),
+	})
+	p := configureProvider(t, map[string]tftypes.Value{"execution_windows": windows})
+	w := p.config.ExecutionWindows
+	tests := []struct {
+		at   time.Time
+		open bool
+	}{
+		{time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 10, 16, 21, 59, 0, 0, time.UTC), true},
+		{time.Date(2026, 10, 16, 22, 0, 0, 0, time.UTC), false},
+		// Saturday 09:15 in Tokyo
+		{time.Date(2026, 10, 17, 0, 15, 0, 0, time.UTC), true},
+		{time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC), false},
+		// The first minute of the month
+		{time.Date(2026, 11, 1, 0, 0, 30, 0, time.UTC), true},
+		{time.Date(2026, 11, 1, 0, 1, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := w.Open(tt.at); got != tt.open {
+			t.Errorf("Expected Open(%s) to be %v", tt.at, tt.open)
+		}
+	}
+
+	err := w.Await(ctx, utils.Create)
+	if err == nil || !strings.Contains(err.Error(), "the next execution window opens at 2026-10-16T18:00:00Z") {
+		t.Errorf("Expected an operation outside the windows to fail, got %v", err)
+	}
+
+	// With a wait, the operation runs once the window opens, without holding
+	// its parallelism slot meanwhile
+	w.Wait = 45 * time.Minute
+	sem := make(chan struct{}, 1)
+	done := make(chan error)
+	go utils.WithSemaphore(ctx, sem, func(ctx context.Context) { done <- w.Await(ctx, utils.Update) })
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if len(sem) != 0 {
+		t.Error("Expected the semaphore to be released while waiting for a window")
+	}
+	clock.Advance(30 * time.Minute)
+	if err := <-done; err != nil {
+		t.Errorf("Expected the operation to run once the window opened, got %v", err)
+	}
+	w.Wait = 10 * time.Minute
+	clock.Advance(4 * time.Hour)
+	if err := w.Await(ctx, utils.Delete); err == nil || !strings.Contains(err.Error(), "after the 10m0s it may wait") {
+		t.Errorf("Expected a window opening after the wait to fail, got %v", err)
+	}
+}
+
+func TestUnitProviderExecutionWindowsInvalid(t *testing.T) {
+	for _, expr := range []string{"* 9-17 * *", "* 25 * * *", "*/0 * * * *", "TZ=Nowhere/City * * * * *", "@every 1h"} {
+		if _, err := utils.ParseExecutionWindows([]string{expr}, 0); err == nil {
+			t.Errorf("Expected %q to be invalid", expr)
+		}
+	}
+}
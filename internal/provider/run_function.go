@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ function.Function = &runFunction{}
+
+// runFunction runs a command on a JSON input and returns its JSON output, for
+// read-only transformations that don't warrant a data source block.
+type runFunction struct{}
+
+func NewRunFunction() function.Function {
+	return &runFunction{}
+}
+
+func (f *runFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "run"
+}
+
+func (f *runFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Runs a command with a JSON input and returns its parsed JSON output",
+		Description: "Runs command like a hook, with the payload {\"input\": input} on stdin, and returns the JSON value it prints, such as an object, a list or a string, or null when it prints nothing. Terraform calls functions whenever it evaluates the configuration, so the command must be read-only and return the same output for the same input. Provider settings such as the executor and working_directory don't apply, the command runs locally in the directory Terraform runs in.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "command",
+				Description: "Command to run, split into arguments like hook commands",
+			},
+			function.DynamicParameter{
+				Name:           "input",
+				Description:    "Value passed as the input field of the payload",
+				AllowNullValue: true,
+			},
+		},
+		Return: function.DynamicReturn{},
+	}
+}
+
+func (f *runFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var command string
+	var input types.Dynamic
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &command, &input))
+	if resp.Error != nil {
+		return
+	}
+	cmd, err := utils.SplitCommand(command)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Failed to parse the command: %v", err))
+		return
+	}
+	if len(cmd) == 0 {
+		resp.Error = function.NewArgumentFuncError(0, "The command cannot be empty")
+		return
+	}
+
+	// The output can be any JSON value, not only the object hooks print
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.RawOutput = true
+	payload := utils.ExecutionPayload{Input: utils.AttrValueToInterface(input)}
+	result, err := utils.Execute(ctx, config, cmd, payload)
+	if err != nil {
+		stderr := ""
+		if result != nil {
+			stderr = result.Stderr
+		}
+		resp.Error = function.NewFuncError(fmt.Sprintf("The command failed: %v\nStderr: %s", err, stderr))
+		return
+	}
+	var value interface{}
+	if stdout := strings.TrimSpace(result.Stdout); stdout != "" {
+		if err := utils.DecodeJSON([]byte(stdout), &value); err != nil {
+			resp.Error = function.NewFuncError(fmt.Sprintf("Failed to parse the command output as JSON: %v", err))
+			return
+		}
+	}
+	if err := utils.CheckLimits(value, config.MaxOutputDepth, config.MaxOutputNodes); err != nil {
+		resp.Error = function.NewFuncError(err.Error())
+		return
+	}
+	output, diags := utils.MapToDynamic(value)
+	if diags.HasError() {
+		resp.Error = function.FuncErrorFromDiags(ctx, diags)
+		return
+	}
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, output))
+}
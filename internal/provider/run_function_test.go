@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// runRun calls the run function with command and input.
+func runRun(command string, input attr.Value) (interface{}, *function.FuncError) {
+	ctx := context.Background()
+	resp := &function.RunResponse{Result: function.NewResultData(types.DynamicUnknown())}
+	NewRunFunction().Run(ctx, function.RunRequest{
+		Arguments: function.NewArgumentsData([]attr.Value{types.StringValue(command), types.DynamicValue(input)}),
+	}, resp)
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return utils.AttrValueToInterface(resp.Result.Value()), nil
+}
+
+func TestUnitRunFunction(t *testing.T) {
+	input := types.ObjectValueMust(
+		map[string]attr.Type{"names": types.TupleType{ElemTypes: []attr.Type{types.StringType, types.StringType}}},
+		map[string]attr.Value{"names": types.TupleValueMust([]attr.Type{types.StringType, types.StringType}, []attr.Value{types.StringValue("web"), types.StringValue("db")})},
+	)
+	got, err := runRun(`jq -c '{hosts: [.input.names[] | . + ".internal"]}'`, input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hosts, _ := got.(map[string]interface{})["hosts"].([]interface{})
+	if len(hosts) != 2 || hosts[0] != "web.internal" || hosts[1] != "db.internal" {
+		t.Errorf("Expected the transformed input, got %v", got)
+	}
+
+	// Any JSON value can be returned
+	for command, expected := range map[string]interface{}{
+		`jq -c '[.input.names[] | length]'`: "[3 2]",
+		`jq '.input.names[0]'`:              "web",
+		`true`:                              "<nil>",
+	} {
+		got, err := runRun(command, input)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", command, err)
+		}
+		if fmt.Sprint(got) != expected {
+			t.Errorf("Expected %s to return %v, got %v", command, expected, got)
+		}
+	}
+	if _, err := runRun(`echo not json`, types.StringNull()); err == nil || !strings.Contains(err.Text, "JSON") {
+		t.Errorf("Expected output that isn't JSON to fail, got %v", err)
+	}
+
+	if _, err := runRun(`sh -c 'echo broken >&2; exit 1'`, types.StringNull()); err == nil || !strings.Contains(err.Text, "broken") {
+		t.Errorf("Expected a failing command to report its stderr, got %v", err)
+	}
+	if _, err := runRun(`  `, types.StringNull()); err == nil {
+		t.Error("Expected an empty command to fail")
+	}
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+var (
+	// sweepOnce limits the sweep of stale temporary files to the first
+	// provider configured by the process.
+	sweepOnce sync.Once
+
+	shutdownMu sync.Mutex
+	// shutdownCommands holds the on_shutdown command of every configured
+	// provider that hasn't run it yet.
+	shutdownCommands = map[*CustomCRUDProvider][]string{}
+)
+
+// registerShutdown makes Shutdown run cmd with the config of p, replacing the
+// command of an earlier configuration. A nil cmd unregisters p.
+func registerShutdown(p *CustomCRUDProvider, cmd []string) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	if cmd == nil {
+		delete(shutdownCommands, p)
+		return
+	}
+	shutdownCommands[p] = cmd
+}
+
+// sweepTempFiles removes the temporary files older than maxAge that earlier
+// provider processes didn't get to remove, once per process.
+func sweepTempFiles(ctx context.Context, maxAge time.Duration) {
+	sweepOnce.Do(func() {
+		removed, err := utils.SweepTempFiles(os.TempDir(), maxAge)
+		if len(removed) > 0 {
+			tflog.Info(ctx, "Removed stale temporary files", map[string]interface{}{"files": removed})
+		}
+		if err != nil {
+			tflog.Warn(ctx, "Failed to remove stale temporary files", map[string]interface{}{"error": err.Error()})
+		}
+	})
+}
+
+// Shutdown runs the on_shutdown command of every configured provider that
+// hasn't run it yet and removes the temporary files of the provider,
+// returning the errors of the commands that failed and of the removal.
+func Shutdown(ctx context.Context) error {
+	shutdownMu.Lock()
+	pending := shutdownCommands
+	shutdownCommands = map[*CustomCRUDProvider][]string{}
+	shutdownMu.Unlock()
+
+	var errs []error
+	for p, cmd := range pending {
+		config := p.config
+		// Cleanup runs even when the operation used up its subprocess budget,
+		// and whatever it prints is only logged
+		config.SubprocessBudget = nil
+		config.RawOutput = true
+		result, err := utils.Execute(ctx, config, cmd, utils.ExecutionPayload{})
+		if err != nil {
+			if result != nil {
+				err = fmt.Errorf("%w\nStdout: %s\nStderr: %s", err, result.Mask(result.Stdout), result.Mask(result.Stderr))
+			}
+			errs = append(errs, fmt.Errorf("on_shutdown command failed: %w", err))
+		}
+	}
+	if err := utils.RemoveTempFiles(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to remove temporary files: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// NewProtocol6WithShutdown returns a provider server factory like
+// providerserver.NewProtocol6 whose servers run Shutdown when Terraform stops
+// the provider, which usually means the operation was interrupted.
+func NewProtocol6WithShutdown(p func() provider.Provider) func() tfprotov6.ProviderServer {
+	return func() tfprotov6.ProviderServer {
+		server := providerserver.NewProtocol6(p())()
+		// List resources are only served when the framework server supports
+		// them
+		if listServer, ok := server.(tfprotov6.ProviderServerWithListResource); ok {
+			return shutdownListServer{listServer}
+		}
+		return shutdownServer{server}
+	}
+}
+
+type shutdownServer struct {
+	tfprotov6.ProviderServer
+}
+
+func (s shutdownServer) StopProvider(ctx context.Context, req *tfprotov6.StopProviderRequest) (*tfprotov6.StopProviderResponse, error) {
+	resp, err := s.ProviderServer.StopProvider(ctx, req)
+	if shutdownErr := Shutdown(ctx); shutdownErr != nil {
+		tflog.Warn(ctx, "Shutdown failed", map[string]interface{}{"error": shutdownErr.Error()})
+	}
+	return resp, err
+}
+
+// shutdownListServer is a shutdownServer that also serves list resources.
+type shutdownListServer struct {
+	tfprotov6.ProviderServerWithListResource
+}
+
+func (s shutdownListServer) StopProvider(ctx context.Context, req *tfprotov6.StopProviderRequest) (*tfprotov6.StopProviderResponse, error) {
+	return shutdownServer{s.ProviderServerWithListResource}.StopProvider(ctx, req)
+}
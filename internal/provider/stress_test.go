@@ -0,0 +1,187 @@
+//go:build stress
+
+package provider
+
+// Stress tests run hundreds of concurrent fake hook executions through the
+// parallelism, budget and registry layers. Run them with the race detector:
+//
+//	go test -race -tags stress -run Stress ./internal/provider/
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+)
+
+const stressWorkers = 500
+
+// countingExecutor returns a fake executor recording the number of hooks
+// running at once.
+func countingExecutor(running, peak *atomic.Int64) *utils.MockExecutor {
+	return &utils.MockExecutor{Func: func(ctx context.Context, req utils.ExecRequest) (*utils.ExecResponse, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return &utils.ExecResponse{Stdout: []byte(fmt.Sprintf(`{"id": %q}`, req.Command[0]))}, nil
+	}}
+}
+
+func TestStressParallelism(t *testing.T) {
+	var running, peak atomic.Int64
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.Parallelism = 8
+	config.Semaphore = make(chan struct{}, config.Parallelism)
+	config.Executor = countingExecutor(&running, &peak)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, stressWorkers)
+	for i := 0; i < stressWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			utils.WithSemaphore(context.Background(), config.Semaphore, func(ctx context.Context) {
+				id := fmt.Sprintf("hook-%d", i)
+				result, err := utils.Execute(context.Background(), config, []string{id}, utils.ExecutionPayload{Id: id})
+				if err == nil && result.Result["id"] != id {
+					err = fmt.Errorf("expected id %s, got %v", id, result.Result["id"])
+				}
+				if err != nil {
+					errs <- err
+				}
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if p := peak.Load(); p > int64(config.Parallelism) {
+		t.Errorf("Expected at most %d hooks at once, got %d", config.Parallelism, p)
+	}
+}
+
+func TestStressSubprocessBudget(t *testing.T) {
+	var running, peak atomic.Int64
+	const limit = 300
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.SubprocessBudget = utils.NewSubprocessBudget(limit)
+	config.Executor = countingExecutor(&running, &peak)
+
+	var wg sync.WaitGroup
+	var succeeded atomic.Int64
+	for i := 0; i < stressWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := utils.Execute(context.Background(), config, []string{"hook"}, utils.ExecutionPayload{}); err == nil {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := succeeded.Load(); n != limit {
+		t.Errorf("Expected exactly %d hooks to run, got %d", limit, n)
+	}
+}
+
+func TestStressExecutorRegistry(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < stressWorkers; i++ {
+		wg.Add(2)
+		name := fmt.Sprintf("stress-%d", i%10)
+		go func() {
+			defer wg.Done()
+			utils.RegisterExecutor(name, func(map[string]string) (utils.Executor, error) {
+				return &utils.MockExecutor{Stdout: "{}"}, nil
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			// The executor may not be registered yet, only races matter here
+			_, _ = utils.NewExecutor(name, nil)
+			_ = utils.ExecutorNames()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStressFakeClock(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer utils.SetClock(clock)()
+
+	var wg sync.WaitGroup
+	var elapsed atomic.Int64
+	for i := 0; i < stressWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if utils.Sleep(context.Background(), 10*time.Second) {
+				elapsed.Add(1)
+			}
+		}()
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for clock.Waiters() < stressWorkers {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d waiters, got %d", stressWorkers, clock.Waiters())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(5 * time.Second)
+	if n := elapsed.Load(); n != 0 {
+		t.Fatalf("Expected no delay to elapse halfway, got %d", n)
+	}
+	clock.Advance(5 * time.Second)
+	wg.Wait()
+	if n := elapsed.Load(); n != stressWorkers {
+		t.Errorf("Expected every delay to elapse, got %d", n)
+	}
+}
+
+func TestStressReadCache(t *testing.T) {
+	var running, peak atomic.Int64
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.ReadCache = utils.NewReadCache()
+	config.Executor = countingExecutor(&running, &peak)
+
+	var wg sync.WaitGroup
+	var runs atomic.Int64
+	for i := 0; i < stressWorkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("object-%d", i%10)
+			if i%50 == 0 {
+				config.ReadCache.Forget(key)
+			}
+			result, ok := config.ReadCache.Read(key, func() (*utils.ExecutionResult, bool) {
+				runs.Add(1)
+				result, err := utils.Execute(context.Background(), config, []string{key}, utils.ExecutionPayload{})
+				return result, err == nil
+			})
+			if !ok || result.Result["id"] != key {
+				t.Errorf("Expected the read of %s to succeed, got %v", key, result)
+				return
+			}
+			// Readers own their copy of the output
+			result.Result["id"] = "changed"
+		}(i)
+	}
+	wg.Wait()
+	if n := runs.Load(); n < 10 || n > 20 {
+		t.Errorf("Expected one read per key plus one per forget, got %d", n)
+	}
+}
@@ -0,0 +1,8 @@
+#!/usr/bin/env bash
+# Echoes input fields as output like a backend that normalizes a version
+# such as 1.2 to 1.2.0.
+input=$(cat)
+echo "$input" | jq '
+  {id: "test-computed-input"} + (.input // {})
+  | if (.version | type) == "string" and (.version | split(".") | length) == 2 then .version += ".0" else . end
+'
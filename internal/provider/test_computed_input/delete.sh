@@ -0,0 +1 @@
+../test_edgecases/delete.sh
\ No newline at end of file
@@ -0,0 +1 @@
+create.sh
\ No newline at end of file
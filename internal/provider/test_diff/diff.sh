@@ -0,0 +1,8 @@
+#!/usr/bin/env bash
+# Prints a line per input key whose value differs from the prior output.
+jq -r '
+  (.output // {}) as $prior
+  | .input // {} | to_entries[]
+  | select($prior[.key] != .value)
+  | "\(.key): \($prior[.key] // "(unset)" | tojson) -> \(.value | tojson)"
+'
@@ -0,0 +1,7 @@
+#!/usr/bin/env bash
+# Returns a new etag and last_seen_at on every call, like a backend stamping
+# its responses with server metadata.
+input="$(cat)"
+name="$(echo "$input" | jq -r '.input.name')"
+jq -n --arg name "$name" --arg etag "$RANDOM$RANDOM" --arg seen "$(date +%s%N)" \
+  '{id: $name, name: $name, etag: $etag, metadata: {owner: "ops", last_seen_at: $seen}}'
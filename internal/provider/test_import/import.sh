@@ -0,0 +1,8 @@
+#!/usr/bin/env bash
+# Resolves an import id naming the object, e.g. "web", to its id "vm-web",
+# printing the input and output to seed state with.
+input=$(cat)
+echo "$input" | jq '
+  .id as $name
+  | {id: ("vm-" + $name), input: {name: $name}, output: {name: $name, status: "running"}}
+'
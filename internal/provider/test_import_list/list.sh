@@ -0,0 +1,5 @@
+#!/usr/bin/env bash
+# Lists every object of the fleet named in the input.
+input="$(cat)"
+fleet="$(echo "$input" | jq -r '.input.fleet')"
+jq -n --arg fleet "$fleet" '[range(3) | {id: "\($fleet)-\(.)", input: {name: "\($fleet)-\(.)"}, output: {index: .}}]'
@@ -0,0 +1,3 @@
+#!/usr/bin/env bash
+# Generates a key that is kept in private state instead of output.
+jq '{id: "test-private", name: .input.name, private: {key: "generated-secret"}}'
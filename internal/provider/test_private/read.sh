@@ -0,0 +1,3 @@
+#!/usr/bin/env bash
+# Reports whether the private key was passed back, without printing it.
+jq '{id: .id, name: .input.name, has_key: (.private.key == "generated-secret")}'
@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+# Creates an object that only becomes visible after a number of reads.
+input="$(cat)"
+dir="$(echo "$input" | jq -r '.input.dir')"
+echo "$(echo "$input" | jq -r '.input.invisible_reads')" > "$dir/invisible_reads"
+jq -n --arg dir "$dir" '{id: "eventual", dir: $dir}'
@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+# Reports the object as missing until the configured number of reads has passed.
+input="$(cat)"
+dir="$(echo "$input" | jq -r '.input.dir')"
+remaining="$(cat "$dir/invisible_reads")"
+if [ "$remaining" -gt 0 ]; then
+  echo $((remaining - 1)) > "$dir/invisible_reads"
+  exit 22
+fi
+jq -n --arg dir "$dir" '{id: "eventual", dir: $dir}'
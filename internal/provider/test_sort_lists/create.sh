@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+# Returns lists in a different order on every call, like a backend that
+# builds them from an unordered set.
+input="$(cat)"
+name="$(echo "$input" | jq -r '.input.name')"
+if [ $((RANDOM % 2)) -eq 0 ]; then
+  jq -n --arg name "$name" '{id: $name, tags: ["web", "app", "db"], network: {ips: ["10.0.0.3", "10.0.0.1", "10.0.0.2"], ports: [443, 80, 8080]}}'
+else
+  jq -n --arg name "$name" '{id: $name, tags: ["db", "web", "app"], network: {ips: ["10.0.0.2", "10.0.0.3", "10.0.0.1"], ports: [8080, 443, 80]}}'
+fi
@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+# Reports progress as NDJSON state events before printing the final result.
+cat >/dev/null
+echo '{"state": {"id": "state-events", "status": "allocating"}}'
+echo '{"state": {"id": "state-events", "status": "configuring"}}'
+jq -n '{id: "state-events", status: "ready"}'
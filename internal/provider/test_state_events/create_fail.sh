@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+# Allocates an id, reports it as a state event, then fails.
+cat >/dev/null
+echo '{"state": {"id": "state-events", "status": "allocating"}}'
+echo "Failed to finish provisioning" >&2
+exit 1
@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+# Prints its output with an id, then fails.
+cat >/dev/null
+jq -n '{id: "state-events", status: "allocating"}'
+echo "Failed to finish provisioning" >&2
+exit 1
@@ -0,0 +1,4 @@
+#!/usr/bin/env bash
+# Allocates an id but reports the create as only partly done.
+cat >/dev/null
+jq -n '{id: "state-events", status: "allocating", partial: true, error: "Disk attach failed"}'
@@ -0,0 +1,7 @@
+#!/usr/bin/env bash
+# Renames the name key of the input and output to hostname.
+input=$(cat)
+echo "$input" | jq '{
+  input: (.input | .hostname = .name | del(.name)),
+  output: (.output | .hostname = .name | del(.name))
+}'
@@ -0,0 +1,13 @@
+#!/usr/bin/env bash
+# Rejects an empty name and a size below 1, printing an error per invalid key.
+input=$(cat)
+errors=$(echo "$input" | jq -c '
+  [
+    (if (.input.name // "") == "" then {path: "name", summary: "Missing Name", detail: "name must not be empty"} else empty end),
+    (if (.input.size // 1) < 1 then {path: "size", detail: "size must be at least 1"} else empty end)
+  ]
+')
+if [ "$errors" != "[]" ]; then
+  echo "{\"errors\": $errors}"
+  exit 1
+fi
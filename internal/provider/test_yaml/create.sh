@@ -0,0 +1,12 @@
+#!/usr/bin/env bash
+# Prints the resource as YAML instead of JSON.
+input="$(cat)"
+name="$(echo "$input" | jq -r '.input.name')"
+cat <<YAML
+id: yaml-$name
+name: $name
+replicas: 3
+labels:
+  - app
+  - web
+YAML
@@ -0,0 +1,11 @@
+#!/usr/bin/env bash
+input="$(cat)"
+name="$(echo "$input" | jq -r '.input.name')"
+cat <<YAML
+id: yaml-$name
+name: $name
+replicas: 3
+labels:
+  - app
+  - web
+YAML
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+var _ resource.ResourceWithUpgradeState = &customCrudResource{}
+
+// resourceSchemaVersion is the version of the resource schema. Bump it when
+// the state changes shape, with an upgrader from the prior version.
+const resourceSchemaVersion = 1
+
+// UpgradeState upgrades state written with older schema versions. Version 0
+// states only lack the attributes added since, so every version is read with
+// the current schema before the upgrade hook gets to reshape it.
+func (r *customCrudResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {StateUpgrader: r.upgradeState},
+	}
+}
+
+// upgradeState reads the prior state with the current schema, the attributes
+// it lacks being null, and runs the upgrade hook, if any, which prints the id,
+// input and output to store instead of the prior ones.
+func (r *customCrudResource) upgradeState(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+	raw, err := req.RawState.UnmarshalWithOpts(resp.State.Schema.Type().TerraformType(ctx), tfprotov6.UnmarshalOpts{
+		ValueFromJSONOpts: tftypes.ValueFromJSONOpts{IgnoreUndefinedAttributes: true},
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to Read Prior State", fmt.Sprintf("Failed to read the state written with an older schema version: %v", err))
+		return
+	}
+	prior := tfsdk.State{Schema: resp.State.Schema, Raw: raw}
+	data, ok := extractModel[customCrudResourceModel](ctx, prior.Get, &resp.Diagnostics)
+	if !ok || !r.resolveHooksRef(ctx, resp.State.Schema, data, &resp.Diagnostics) {
+		return
+	}
+	if crud, err := getCrudCommands(data, r.targetOS()); err != nil || !crud.defines(utils.Upgrade) {
+		resp.State.Raw = raw
+		return
+	}
+
+	payload := utils.ExecutionPayload{
+		Id:        data.Id.ValueString(),
+		Input:     utils.AttrValueToInterface(data.Input.UnderlyingValue()),
+		Output:    data.storedOutput(),
+		Phase:     utils.PhaseUpgrade,
+		Sensitive: data.sensitivePaths(),
+	}
+	var result *utils.ExecutionResult
+	utils.WithSemaphore(ctx, hooksSemaphore(ctx, r.config, prior.GetAttribute), func(ctx context.Context) {
+		result, ok = utils.RunCrudScript(ctx, r.configFor(ctx, data), data, payload, &resp.Diagnostics, utils.CrudUpgrade)
+	})
+	if !ok {
+		return
+	}
+	output, ok := seedState(data, result.Result, utils.Upgrade, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	resp.Diagnostics.Append(data.storeOutput(output, result.Sensitive, r.config.CollectionTyping)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, data)...)
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+)
+
+// upgradeState runs the upgrader of version 0 on a raw state.
+func upgradeState(t *testing.T, r *customCrudResource, rawState string) customCrudResourceModel {
+	t.Helper()
+	ctx := context.Background()
+	schemaResp := &fwresource.SchemaResponse{}
+	r.Schema(ctx, fwresource.SchemaRequest{}, schemaResp)
+
+	upgrader, ok := r.UpgradeState(ctx)[0]
+	if !ok {
+		t.Fatal("Expected an upgrader from version 0")
+	}
+	resp := &fwresource.UpgradeStateResponse{State: tfsdk.State{Schema: schemaResp.Schema}}
+	upgrader.StateUpgrader(ctx, fwresource.UpgradeStateRequest{RawState: &tfprotov6.RawState{JSON: []byte(rawState)}}, resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	var data customCrudResourceModel
+	if diags := resp.State.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Failed to read upgraded state: %v", diags)
+	}
+	return data
+}
+
+func TestUnitUpgradeState(t *testing.T) {
+	r := &customCrudResource{config: utils.CustomCRUDProviderConfigDefaults()}
+
+	// Without an upgrade hook the state is kept as is
+	data := upgradeState(t, r, `{
+		"id": "vm-1",
+		"hooks": [{"create": "create.sh", "read": "read.sh", "delete": "delete.sh"}],
+		"input": {"value": {"name": "web"}, "type": ["object", {"name": "string"}]},
+		"output": {"value": {"id": "vm-1", "name": "web"}, "type": ["object", {"id": "string", "name": "string"}]},
+		"removed_attribute": true
+	}`)
+	input, _ := utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if data.Id.ValueString() != "vm-1" || input["name"] != "web" {
+		t.Errorf("Expected the prior state, got id %v and input %v", data.Id, data.Input)
+	}
+	if !data.SkipAction.IsNull() {
+		t.Errorf("Expected attributes missing from the prior state to be null, got %v", data.SkipAction)
+	}
+
+	// Prior states can't hold an upgrade hook, the hook set they refer to
+	// is looked up again
+	r.config.HookSets = map[string]map[string]string{
+		"vm": {"create": "create.sh", "read": "read.sh", "delete": "delete.sh", "upgrade": "test_upgrade/upgrade.sh"},
+	}
+	data = upgradeState(t, r, `{
+		"id": "vm-1",
+		"hooks_ref": "vm",
+		"input": {"value": {"name": "web"}, "type": ["object", {"name": "string"}]},
+		"output": {"value": {"id": "vm-1", "name": "web"}, "type": ["object", {"id": "string", "name": "string"}]}
+	}`)
+	input, _ = utils.AttrValueToInterface(data.Input.UnderlyingValue()).(map[string]interface{})
+	if len(input) != 1 || input["hostname"] != "web" {
+		t.Errorf("Expected the input reshaped by the upgrade hook, got %v", data.Input)
+	}
+	output, _ := utils.AttrValueToInterface(data.Output.UnderlyingValue()).(map[string]interface{})
+	if output["hostname"] != "web" || output["name"] != nil {
+		t.Errorf("Expected the output reshaped by the upgrade hook, got %v", data.Output)
+	}
+	if data.Id.ValueString() != "vm-1" {
+		t.Errorf("Expected the id to be kept, got %v", data.Id)
+	}
+}
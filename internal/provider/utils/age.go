@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// Input values can be kept encrypted in VCS as ASCII-armored age messages
+// (https://age-encryption.org/v1), as produced by `age -a -r age1...`, or as
+// SOPS documents. They are decrypted right before the payload is handed to a
+// hook, so the plaintext never reaches state.
+
+// ParseAgeIdentities parses identities in the age identity file format: one
+// AGE-SECRET-KEY-1... per line, ignoring blank lines and # comments.
+func ParseAgeIdentities(s string) ([]age.Identity, error) {
+	identities, err := age.ParseIdentities(strings.NewReader(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identities: %w", err)
+	}
+	return identities, nil
+}
+
+// ReadAgeIdentities parses the age identity file at path.
+func ReadAgeIdentities(path string) ([]age.Identity, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age identity file: %w", err)
+	}
+	return ParseAgeIdentities(string(content))
+}
+
+// IsAgeEncrypted reports whether s is an ASCII-armored age message.
+func IsAgeEncrypted(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), armor.Header)
+}
+
+// DecryptAge decrypts the ASCII-armored age message s with the first of
+// identities it was encrypted to.
+func DecryptAge(s string, identities []age.Identity) (string, error) {
+	r, err := age.Decrypt(armor.NewReader(strings.NewReader(strings.TrimSpace(s))), identities...)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// DecryptValues returns a copy of value where every string holding an age
+// message is replaced by its plaintext, and every string holding a SOPS
+// document by the decrypted document, together with the plaintext strings.
+func DecryptValues(value interface{}, identities []age.Identity) (interface{}, []string, error) {
+	var secrets []string
+	var walk func(p string, value interface{}) (interface{}, error)
+	walk = func(p string, value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			decrypted := make(map[string]interface{}, len(v))
+			for k, val := range v {
+				d, err := walk(joinKeyPath(p, k), val)
+				if err != nil {
+					return nil, err
+				}
+				decrypted[k] = d
+			}
+			return decrypted, nil
+		case []interface{}:
+			decrypted := make([]interface{}, len(v))
+			for i, val := range v {
+				d, err := walk(fmt.Sprintf("%s[%d]", p, i), val)
+				if err != nil {
+					return nil, err
+				}
+				decrypted[i] = d
+			}
+			return decrypted, nil
+		case string:
+			if IsSOPSEncrypted(v) {
+				document, plaintexts, err := DecryptSOPS(v)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decrypt %s: %w", p, err)
+				}
+				secrets = append(secrets, plaintexts...)
+				return document, nil
+			}
+			if !IsAgeEncrypted(v) {
+				return v, nil
+			}
+			if len(identities) == 0 {
+				return nil, fmt.Errorf("%s is age encrypted but the provider has no age identity configured", p)
+			}
+			plaintext, err := DecryptAge(v, identities)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s: %w", p, err)
+			}
+			if plaintext != "" {
+				secrets = append(secrets, plaintext)
+			}
+			return plaintext, nil
+		default:
+			return value, nil
+		}
+	}
+	decrypted, err := walk("", value)
+	return decrypted, secrets, err
+}
+
+// IsEncrypted reports whether s is an age message or a SOPS document.
+func IsEncrypted(s string) bool {
+	return IsAgeEncrypted(s) || IsSOPSEncrypted(s)
+}
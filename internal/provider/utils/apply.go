@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Apply is the hook of the declarative hooks shape that converges the
+// resource to its input, standing in for create, update and read.
+const Apply = "apply"
+
+// Destroy is the hook of the declarative hooks shape standing in for delete.
+const Destroy = "destroy"
+
+// applyHooks lists the hooks the apply and destroy hooks stand in for.
+var applyHooks = map[string][]string{
+	Apply:   {Create, Update, Read},
+	Destroy: {Delete},
+}
+
+// ApplyAttributes returns the attributes of a hooks block with its apply hook
+// in place of the create and update hooks, and of the read hook when there
+// is none, and its destroy hook in place of the delete hook. The argv and
+// runtimes entries of apply and destroy are used for those hooks too.
+func ApplyAttributes(attrs map[string]attr.Value) map[string]attr.Value {
+	argv, _ := attrs[Argv].(types.Map)
+	runtimes, _ := attrs[Runtimes].(types.Map)
+	defined := func(hook string) bool { return DefinesHook(attrs, hook) }
+
+	var result map[string]attr.Value
+	argvElements := map[string]attr.Value{}
+	runtimeElements := map[string]attr.Value{}
+	for _, from := range []string{Apply, Destroy} {
+		if !defined(from) {
+			continue
+		}
+		for _, hook := range applyHooks[from] {
+			if _, ok := attrs[hook].(types.String); !ok || defined(hook) {
+				continue
+			}
+			if result == nil {
+				result = make(map[string]attr.Value, len(attrs))
+				for name, value := range attrs {
+					result[name] = value
+				}
+				for name, value := range argv.Elements() {
+					argvElements[name] = value
+				}
+				for name, value := range runtimes.Elements() {
+					runtimeElements[name] = value
+				}
+			}
+			result[hook] = attrs[from]
+			if args, ok := argv.Elements()[from]; ok {
+				argvElements[hook] = args
+			}
+			if runtime, ok := runtimes.Elements()[from]; ok {
+				runtimeElements[hook] = runtime
+			}
+		}
+	}
+	if result == nil {
+		return attrs
+	}
+	if len(argvElements) > 0 {
+		result[Argv] = types.MapValueMust(argv.ElementType(context.Background()), argvElements)
+	}
+	if len(runtimeElements) > 0 {
+		result[Runtimes] = types.MapValueMust(runtimes.ElementType(context.Background()), runtimeElements)
+	}
+	return result
+}
+
+// DefinesHook reports whether the attributes of a hooks block set hook by its
+// command, its argv entry or its script.
+func DefinesHook(attrs map[string]attr.Value, hook string) bool {
+	argv, _ := attrs[Argv].(types.Map)
+	if args, ok := argv.Elements()[hook].(types.List); ok && !args.IsNull() {
+		return true
+	}
+	if script, ok := attrs[hook+ScriptSuffix].(types.String); ok && strings.TrimSpace(script.ValueString()) != "" {
+		return true
+	}
+	command, ok := attrs[hook].(types.String)
+	return ok && strings.TrimSpace(command.ValueString()) != ""
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// SubprocessBudget caps the number of hook processes a provider instance
+// launches. Terraform starts a provider instance per operation, so the cap
+// applies to a single plan or apply.
+type SubprocessBudget struct {
+	limit    int64
+	launched atomic.Int64
+}
+
+// NewSubprocessBudget returns a budget of limit launches, or nil, which never
+// runs out, when limit is zero.
+func NewSubprocessBudget(limit int) *SubprocessBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &SubprocessBudget{limit: int64(limit)}
+}
+
+// take records a launch, failing once the budget is spent.
+func (b *SubprocessBudget) take() error {
+	if b == nil {
+		return nil
+	}
+	if launched := b.launched.Add(1); launched > b.limit {
+		return fmt.Errorf("refusing to launch hook process %d: the provider max_subprocesses limit of %d hook processes per Terraform operation was reached. "+
+			"This usually means a module expanded into far more resources than intended; raise max_subprocesses if the count is expected", launched, b.limit)
+	}
+	return nil
+}
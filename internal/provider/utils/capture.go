@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+)
+
+// DefaultMaxCaptureBytes is the default amount of stdout and stderr kept in
+// memory per hook invocation.
+const DefaultMaxCaptureBytes = 64 << 20
+
+// spillWriter keeps the first limit bytes written to it in memory and writes
+// everything to a temporary file once that is exceeded, so that a runaway hook
+// can't exhaust the provider memory while its output is kept for debugging.
+// A limit of 0 keeps everything in memory.
+type spillWriter struct {
+	name  string
+	limit int
+	buf   bytes.Buffer
+	file  *os.File
+	err   error
+}
+
+func (w *spillWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.limit <= 0 {
+		return w.buf.Write(p)
+	}
+	if room := w.limit - w.buf.Len(); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		w.buf.Write(p[:room])
+		p = p[room:]
+	}
+	if len(p) == 0 || w.err != nil {
+		return n, nil
+	}
+	if w.file == nil {
+		w.file, w.err = CreateTempFile(w.name + "-*.log")
+		if w.err != nil {
+			return n, nil
+		}
+		_, w.err = w.file.Write(w.buf.Bytes())
+	}
+	if w.err == nil {
+		_, w.err = w.file.Write(p)
+	}
+	// Failing here would kill the hook with SIGPIPE, so the spill is best effort
+	return n, nil
+}
+
+// close closes the spill file and returns its path, or "" if the output fit
+// in memory or couldn't be spilled. The file is removed when the provider
+// shuts down.
+func (w *spillWriter) close() string {
+	if w.file == nil {
+		return ""
+	}
+	w.file.Close()
+	if w.err != nil {
+		_ = RemoveTempFile(w.file.Name())
+		return ""
+	}
+	return w.file.Name()
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"mvdan.cc/sh/v3/shell"
+)
+
+// Argv is the hooks block attribute that gives hook commands as argument
+// lists by hook name, e.g. { create = ["python3", "manage.py", "create resource"] }.
+const Argv = "argv"
+
+// cmdBuiltins are the commands cmd.exe implements itself, which have no
+// executable to run.
+var cmdBuiltins = map[string]bool{
+	"assoc": true, "break": true, "call": true, "cd": true, "chdir": true, "cls": true,
+	"color": true, "copy": true, "date": true, "del": true, "dir": true, "echo": true,
+	"endlocal": true, "erase": true, "for": true, "ftype": true, "goto": true, "if": true,
+	"md": true, "mkdir": true, "mklink": true, "move": true, "path": true, "pause": true,
+	"popd": true, "prompt": true, "pushd": true, "rd": true, "ren": true, "rename": true,
+	"rmdir": true, "set": true, "setlocal": true, "shift": true, "start": true, "time": true,
+	"title": true, "type": true, "ver": true, "verify": true, "vol": true,
+}
+
+// HookArgs returns the program and arguments running hook on goos: its argv
+// entry, which is used verbatim, or else command split with SplitCommandOn.
+// With templates the Go template actions of command are kept whole.
+func HookArgs(command types.String, argv types.Map, hook string, templates bool, goos string) ([]string, error) {
+	args, ok := argv.Elements()[hook].(types.List)
+	if !ok || args.IsNull() {
+		if templates {
+			return SplitTemplateCommandOn(command.ValueString(), goos)
+		}
+		return SplitCommandOn(command.ValueString(), goos)
+	}
+	fields := make([]string, 0, len(args.Elements()))
+	for i, arg := range args.Elements() {
+		s, ok := arg.(types.String)
+		if !ok || s.IsNull() || s.IsUnknown() {
+			return nil, fmt.Errorf("argument %d of the %s argv isn't a known string", i, hook)
+		}
+		fields = append(fields, s.ValueString())
+	}
+	return fields, nil
+}
+
+// HookDefined reports whether hook has a command or an argv entry.
+func HookDefined(command types.String, argv types.Map, hook string) bool {
+	if args, ok := argv.Elements()[hook].(types.List); ok && !args.IsNull() {
+		return true
+	}
+	return strings.TrimSpace(command.ValueString()) != ""
+}
+
+// SplitCommand splits a hook command into the program and arguments to run
+// on the operating system the provider runs on.
+func SplitCommand(command string) ([]string, error) {
+	return SplitCommandOn(command, runtime.GOOS)
+}
+
+// SplitCommandOn splits a hook command like SplitCommand does on goos.
+// Commands are split like a POSIX shell would, except on Windows, where
+// backslashes are path separators and only double quotes group arguments.
+// On Windows, scripts without an executable of their own run through their
+// interpreter: .ps1 scripts with powershell -File, and .bat and .cmd scripts
+// and cmd built-ins such as echo with cmd /c. Elsewhere scripts run by
+// themselves, like a shell would run them.
+func SplitCommandOn(command, goos string) ([]string, error) {
+	fields, err := commandFields(command, goos)
+	if err != nil || len(fields) == 0 {
+		return fields, err
+	}
+	return append(interpreter(fields[0], goos), fields...), nil
+}
+
+// templateAction matches the Go template actions of a command, which are
+// kept whole when it's split, so that {{ .input.name }} stays one argument.
+var templateAction = regexp.MustCompile(`{{.*?}}`)
+
+// templatePlaceholder stands in for the template action with its index while
+// a command is split.
+var templatePlaceholder = regexp.MustCompile(`__customcrud_template_(\d+)__`)
+
+// SplitTemplateCommandOn splits a hook command like SplitCommandOn does,
+// keeping each of its Go template actions within one argument.
+func SplitTemplateCommandOn(command, goos string) ([]string, error) {
+	actions := templateAction.FindAllString(command, -1)
+	if len(actions) == 0 {
+		return SplitCommandOn(command, goos)
+	}
+	i := 0
+	command = templateAction.ReplaceAllStringFunc(command, func(string) string {
+		i++
+		return fmt.Sprintf("__customcrud_template_%d__", i-1)
+	})
+	fields, err := SplitCommandOn(command, goos)
+	for i, field := range fields {
+		fields[i] = templatePlaceholder.ReplaceAllStringFunc(field, func(placeholder string) string {
+			n, _ := strconv.Atoi(templatePlaceholder.FindStringSubmatch(placeholder)[1])
+			return actions[n]
+		})
+	}
+	return fields, err
+}
+
+// commandFields splits command into its words, without an interpreter.
+func commandFields(command, goos string) ([]string, error) {
+	if goos != "windows" {
+		return shell.Fields(command, nil)
+	}
+	return windowsFields(command)
+}
+
+// interpreter returns the command running program on goos, or nil when it
+// runs by itself.
+func interpreter(program, goos string) []string {
+	if goos != "windows" {
+		return nil
+	}
+	ext := strings.ToLower(filepath.Ext(program))
+	switch {
+	case ext == ".ps1":
+		return []string{"powershell", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"}
+	case ext == ".bat" || ext == ".cmd" || cmdBuiltins[strings.ToLower(program)]:
+		return []string{"cmd", "/c"}
+	}
+	return nil
+}
+
+// windowsFields splits command like Windows programs split their command
+// line: words are separated by whitespace outside double quotes, and
+// backslashes are literal unless they precede a double quote, where each pair
+// stands for one backslash and an odd one escapes the quote.
+func windowsFields(command string) ([]string, error) {
+	var fields []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\':
+			n := 1
+			for i+n < len(command) && command[i+n] == '\\' {
+				n++
+			}
+			if i+n < len(command) && command[i+n] == '"' {
+				word.WriteString(strings.Repeat(`\`, n/2))
+				if n%2 == 1 {
+					word.WriteByte('"')
+					i += n
+				} else {
+					i += n - 1
+				}
+			} else {
+				word.WriteString(strings.Repeat(`\`, n))
+				i += n - 1
+			}
+			inWord = true
+		case c == '"':
+			quoted = !quoted
+			inWord = true
+		case (c == ' ' || c == '\t' || c == '\n' || c == '\r') && !quoted:
+			if inWord {
+				fields = append(fields, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated double quote in %s", command)
+	}
+	if inWord {
+		fields = append(fields, word.String())
+	}
+	return fields, nil
+}
@@ -5,25 +5,54 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"filippo.io/age"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
-	"mvdan.cc/sh/v3/shell"
 )
 
 // CrudHooks is a generic struct for CRUD command strings
 // (for resource: create, read, update, delete; for data source: just read;
 // for ephemeral resource: open, renew, close).
 type CrudHooks struct {
-	Create types.String
-	Read   types.String
-	Update types.String
-	Delete types.String
-	Open   types.String
-	Renew  types.String
-	Close  types.String
+	Create   types.String
+	Read     types.String
+	Update   types.String
+	Delete   types.String
+	Open     types.String
+	Renew    types.String
+	Close    types.String
+	Plan     types.String
+	Diff     types.String
+	Validate types.String
+	Import   types.String
+	Upgrade  types.String
+	Exists   types.String
+	Status   types.String
+	WaitFor  types.String
+	Verify   types.String
+
+	OnFailure types.String
+
+	RequiresReplace types.String
+
+	ImportList types.String
+
+	WorkingDirectory  types.String
+	OutputFormat      types.String
+	BypassParallelism types.Bool
+	RawOutput         types.Bool
+	Templates         types.Bool
+	Runtimes          types.Map
+	Argv              types.Map
+	StatusInterval    types.String
+	StatusTimeout     types.String
+
+	// Scripts holds the inline scripts of the hooks block by hook name.
+	Scripts map[string]string
 }
 
 // CrudModel is an interface for models that have a Hooks field (types.List).
@@ -31,8 +60,10 @@ type CrudModel interface {
 	GetHooks() types.List
 }
 
-// getCrudCommands extracts CRUD commands from a model implementing CrudModel.
-func GetCrudCommands(model CrudModel) (*CrudHooks, error) {
+// GetCrudCommands extracts CRUD commands from a model implementing
+// CrudModel, with the platforms overrides of goos, the system the hooks run
+// on.
+func GetCrudCommands(model CrudModel, goos string) (*CrudHooks, error) {
 	hooks := model.GetHooks()
 	if hooks.IsNull() || hooks.IsUnknown() {
 		return nil, fmt.Errorf("hooks block is null or unknown")
@@ -45,7 +76,11 @@ func GetCrudCommands(model CrudModel) (*CrudHooks, error) {
 	if !ok {
 		return nil, fmt.Errorf("hooks block element is not an object")
 	}
-	attrs := obj.Attributes()
+	attrs, err := PlatformAttributes(obj.Attributes(), goos)
+	if err != nil {
+		return nil, err
+	}
+	attrs = ApplyAttributes(attrs)
 	crud := &CrudHooks{}
 	if create, ok := attrs[Create].(types.String); ok {
 		crud.Create = create
@@ -68,9 +103,90 @@ func GetCrudCommands(model CrudModel) (*CrudHooks, error) {
 	if closeHook, ok := attrs[Close].(types.String); ok {
 		crud.Close = closeHook
 	}
+	if plan, ok := attrs[Plan].(types.String); ok {
+		crud.Plan = plan
+	}
+	if diff, ok := attrs[Diff].(types.String); ok {
+		crud.Diff = diff
+	}
+	if validate, ok := attrs[Validate].(types.String); ok {
+		crud.Validate = validate
+	}
+	if importHook, ok := attrs[Import].(types.String); ok {
+		crud.Import = importHook
+	}
+	if upgrade, ok := attrs[Upgrade].(types.String); ok {
+		crud.Upgrade = upgrade
+	}
+	if exists, ok := attrs[Exists].(types.String); ok {
+		crud.Exists = exists
+	}
+	if status, ok := attrs[Status].(types.String); ok {
+		crud.Status = status
+	}
+	if waitFor, ok := attrs[WaitFor].(types.String); ok {
+		crud.WaitFor = waitFor
+	}
+	if verify, ok := attrs[Verify].(types.String); ok {
+		crud.Verify = verify
+	}
+	if onFailure, ok := attrs[OnFailure].(types.String); ok {
+		crud.OnFailure = onFailure
+	}
+	if interval, ok := attrs[StatusInterval].(types.String); ok {
+		crud.StatusInterval = interval
+	}
+	if timeout, ok := attrs[StatusTimeout].(types.String); ok {
+		crud.StatusTimeout = timeout
+	}
+	if requiresReplace, ok := attrs[RequiresReplace].(types.String); ok {
+		crud.RequiresReplace = requiresReplace
+	}
+	if importList, ok := attrs[ImportList].(types.String); ok {
+		crud.ImportList = importList
+	}
+	if workingDirectory, ok := attrs[WorkingDirectory].(types.String); ok {
+		crud.WorkingDirectory = workingDirectory
+	}
+	if outputFormat, ok := attrs[OutputFormat].(types.String); ok {
+		crud.OutputFormat = outputFormat
+	}
+	if bypass, ok := attrs[BypassParallelism].(types.Bool); ok {
+		crud.BypassParallelism = bypass
+	}
+	if raw, ok := attrs[RawOutput].(types.Bool); ok {
+		crud.RawOutput = raw
+	}
+	if templates, ok := attrs[Templates].(types.Bool); ok {
+		crud.Templates = templates
+	}
+	if runtimes, ok := attrs[Runtimes].(types.Map); ok {
+		crud.Runtimes = runtimes
+	}
+	if argv, ok := attrs[Argv].(types.Map); ok {
+		crud.Argv = argv
+	}
+	crud.Scripts = Scripts(attrs)
 	return crud, nil
 }
 
+// hooksList adapts a bare hooks list to the CrudModel interface.
+type hooksList types.List
+
+func (h hooksList) GetHooks() types.List {
+	return types.List(h)
+}
+
+// SemaphoreFor returns the semaphore to hold while running the given hooks,
+// or nil when the hooks block opts out of the provider parallelism limit.
+func SemaphoreFor(config CustomCRUDProviderConfig, hooks types.List) chan struct{} {
+	crud, err := GetCrudCommands(hooksList(hooks), TargetOS(config.Executor))
+	if err == nil && crud.BypassParallelism.ValueBool() {
+		return nil
+	}
+	return config.Semaphore
+}
+
 type CrudOp int
 
 const Create = "create"
@@ -80,8 +196,57 @@ const Delete = "delete"
 const Open = "open"
 const Renew = "renew"
 const Close = "close"
+const Plan = "plan"
+const Diff = "diff"
+const Validate = "validate"
+const Import = "import"
+const Upgrade = "upgrade"
+const RequiresReplace = "requires_replace"
+const Exists = "exists"
+const OnFailure = "on_failure"
+const Verify = "verify"
 const Unknown = "unknown"
 
+// Noop is the delete command of resources whose destroy only removes them
+// from state.
+const Noop = "noop"
+
+// ImportList is the list resource hook that enumerates existing objects for bulk import.
+const ImportList = "import_list"
+
+// WorkingDirectory is the hooks block attribute that sets the cwd for hook execution.
+const WorkingDirectory = "working_directory"
+
+// OutputFormat is the hooks block attribute that selects how script output is parsed.
+const OutputFormat = "output_format"
+
+const OutputFormatJSON = "json"
+const OutputFormatYAML = "yaml"
+
+// BypassParallelism is the hooks block attribute that exempts hooks from the provider parallelism limit.
+const BypassParallelism = "bypass_parallelism"
+
+// RawOutput is the hooks block attribute that stores hook stdout verbatim instead of parsing it.
+const RawOutput = "raw_output"
+
+// Runtimes is the hooks block attribute that maps hook names to the provider
+// runtimes running them instead of the provider executor.
+const Runtimes = "runtimes"
+
+// OnRenewFailure is the ephemeral hooks block attribute that decides what a failed renew hook does.
+const OnRenewFailure = "on_renew_failure"
+
+const OnRenewFailureWarn = "warn"
+const OnRenewFailureError = "error"
+const OnRenewFailureReopen = "reopen"
+
+// Templates is the hooks block attribute that renders the Go template
+// placeholders of hook commands from the hook payload.
+const Templates = "templates"
+
+// RawOutputKey is the output key holding the verbatim stdout of raw_output hooks.
+const RawOutputKey = "raw"
+
 const (
 	CrudCreate CrudOp = iota
 	CrudRead
@@ -90,6 +255,17 @@ const (
 	CrudOpen
 	CrudRenew
 	CrudClose
+	CrudPlan
+	CrudDiff
+	CrudRequiresReplace
+	CrudValidate
+	CrudImport
+	CrudUpgrade
+	CrudExists
+	CrudStatus
+	CrudWaitFor
+	CrudOnFailure
+	CrudVerify
 )
 
 func (op CrudOp) String() string {
@@ -108,6 +284,28 @@ func (op CrudOp) String() string {
 		return Renew
 	case CrudClose:
 		return Close
+	case CrudPlan:
+		return Plan
+	case CrudDiff:
+		return Diff
+	case CrudRequiresReplace:
+		return RequiresReplace
+	case CrudValidate:
+		return Validate
+	case CrudImport:
+		return Import
+	case CrudUpgrade:
+		return Upgrade
+	case CrudExists:
+		return Exists
+	case CrudStatus:
+		return Status
+	case CrudWaitFor:
+		return WaitFor
+	case CrudOnFailure:
+		return OnFailure
+	case CrudVerify:
+		return Verify
 	default:
 		return Unknown
 	}
@@ -119,8 +317,79 @@ type CustomCRUDProviderConfig struct {
 	Semaphore               chan struct{}
 	DefaultInputs           interface{}
 	MissingResourceExitCode int
+	RequiresReplaceExitCode int
+	AlreadyDeletedExitCode  int
+	WorkingDirectory        string
+	Executor                Executor
+	OutputFormat            string
+	SortOutputLists         bool
+	SortOutputPaths         []string
+	// CompatibilityMode is the hook protocol version, one of the
+	// Compatibility constants.
+	CompatibilityMode string
+	// CollectionTyping is the policy for converting output arrays, one of
+	// CollectionTypingTuple and CollectionTypingList.
+	CollectionTyping string
+	// IgnoreOutputPaths lists the dot-separated output key paths dropped
+	// from hook output before it is stored.
+	IgnoreOutputPaths []string
+	RawOutput         bool
+	// Templates renders the Go template placeholders of the command from
+	// the payload, it's only set for the hooks of a block with templates.
+	Templates bool
+	// MaxOutputDepth and MaxOutputNodes limit the nesting and size of hook
+	// output, 0 disables a limit.
+	MaxOutputDepth int
+	MaxOutputNodes int
+	// MaxCaptureBytes caps the stdout and stderr of a hook kept in memory,
+	// the remainder is saved to a temporary file. 0 disables the cap.
+	MaxCaptureBytes int
+	// InputSocketThreshold is the payload size in bytes above which local
+	// hooks fetch the payload from a Unix socket instead of stdin. 0
+	// disables the socket.
+	InputSocketThreshold int
+	// SubprocessBudget caps the hook processes launched per Terraform
+	// operation, it is shared by every copy of the config.
+	SubprocessBudget *SubprocessBudget
+	// ReadCache shares read results between objects with the same
+	// shared_read_key, it is shared by every copy of the config.
+	ReadCache *ReadCache
+	// TransactionGroups tracks the members of every transaction_group, it
+	// is shared by every copy of the config.
+	TransactionGroups *TransactionGroups
+	// CredentialHelper is run before every hook, its JSON object output is
+	// added to the environment of that hook only.
+	CredentialHelper []string
+	// AgeIdentities decrypt the age encrypted input values of every hook.
+	AgeIdentities []age.Identity
+	// SensitiveKeys lists the payload and output key paths masked in logs
+	// and diagnostics of every hook.
+	SensitiveKeys []string
+	// VerifyHooks checks that the executables of resource hooks exist while
+	// planning.
+	VerifyHooks bool
+	// HookRewrites rewrites the hooks stored in state before they run.
+	HookRewrites *HookRewrites
+	// HookSets holds the named resource hooks that resources refer to with
+	// hooks_ref, by hooks block attribute.
+	HookSets map[string]map[string]string
+	// Profiler records the timing breakdown of every operation, when
+	// profiling is enabled.
+	Profiler *Profiler
+	// Runtimes holds the named executors that hooks block runtimes select
+	// per hook instead of Executor.
+	Runtimes map[string]Executor
+	// ExecutionWindows restricts create, update and delete hooks to the
+	// configured windows, nil allows them at any time.
+	ExecutionWindows *ExecutionWindows
 }
 
+// Default limits on the nesting and size of hook output.
+const (
+	DefaultMaxOutputDepth = 100
+	DefaultMaxOutputNodes = 1000000
+)
+
 func CustomCRUDProviderConfigDefaults() CustomCRUDProviderConfig {
 	return CustomCRUDProviderConfig{
 		Parallelism:             0,
@@ -128,17 +397,42 @@ func CustomCRUDProviderConfigDefaults() CustomCRUDProviderConfig {
 		Semaphore:               nil,
 		DefaultInputs:           nil,
 		MissingResourceExitCode: 22,
+		RequiresReplaceExitCode: 10,
+		AlreadyDeletedExitCode:  22,
+		MaxOutputDepth:          DefaultMaxOutputDepth,
+		MaxOutputNodes:          DefaultMaxOutputNodes,
+		MaxCaptureBytes:         DefaultMaxCaptureBytes,
+		WorkingDirectory:        "",
+		Executor:                localExecutor{},
+		OutputFormat:            OutputFormatJSON,
+		CollectionTyping:        CollectionTypingTuple,
+		CompatibilityMode:       CompatibilityV2,
 	}
 }
 
 // RunCrudScript runs the appropriate CRUD script for the given op (CrudCreate, CrudRead, CrudUpdate, CrudDelete)
 // and handles error/diagnostic reporting. The model must implement CrudModel.
 func RunCrudScript(ctx context.Context, config CustomCRUDProviderConfig, model CrudModel, payload ExecutionPayload, diagnostics *diag.Diagnostics, op CrudOp) (*ExecutionResult, bool) {
-	crud, err := GetCrudCommands(model)
+	crud, err := GetCrudCommands(model, TargetOS(config.Executor))
 	if err != nil {
 		diagnostics.AddError("Error getting CRUD commands", err.Error())
 		return nil, false
 	}
+	base := config
+	if runtime, ok := crud.Runtimes.Elements()[op.String()].(types.String); ok && !runtime.IsNull() {
+		executor, ok := config.Runtimes[runtime.ValueString()]
+		if !ok {
+			diagnostics.AddError("Unknown Runtime", fmt.Sprintf("The %v hook runs in the runtime %q, which the provider runtimes don't define", op, runtime.ValueString()))
+			return nil, false
+		}
+		config.Executor = executor
+		// The platforms overrides are those of the system the runtime runs
+		// the hook on
+		if crud, err = GetCrudCommands(model, TargetOS(executor)); err != nil {
+			diagnostics.AddError("Error getting CRUD commands", err.Error())
+			return nil, false
+		}
+	}
 	var commandStr string
 	switch op {
 	case CrudCreate:
@@ -155,36 +449,126 @@ func RunCrudScript(ctx context.Context, config CustomCRUDProviderConfig, model C
 		commandStr = crud.Renew.ValueString()
 	case CrudClose:
 		commandStr = crud.Close.ValueString()
+	case CrudPlan:
+		commandStr = crud.Plan.ValueString()
+	case CrudDiff:
+		commandStr = crud.Diff.ValueString()
+		// The diff hook prints a description rather than JSON
+		config.RawOutput = true
+	case CrudRequiresReplace:
+		commandStr = crud.RequiresReplace.ValueString()
+		// Only the exit code of the requires_replace hook matters
+		config.RawOutput = true
+	case CrudValidate:
+		commandStr = crud.Validate.ValueString()
+		// The validate hook prints errors only when it fails
+		config.RawOutput = true
+	case CrudImport:
+		commandStr = crud.Import.ValueString()
+	case CrudUpgrade:
+		commandStr = crud.Upgrade.ValueString()
+	case CrudExists:
+		commandStr = crud.Exists.ValueString()
+	case CrudStatus:
+		commandStr = crud.Status.ValueString()
+	case CrudWaitFor:
+		commandStr = crud.WaitFor.ValueString()
+	case CrudOnFailure:
+		commandStr = crud.OnFailure.ValueString()
+	case CrudVerify:
+		commandStr = crud.Verify.ValueString()
 	default:
 		diagnostics.AddError("Invalid Operation", fmt.Sprintf("Unknown operation: %v", op))
 		return nil, false
 	}
-	cmd, err := shell.Fields(commandStr, nil)
-	if err != nil {
-		diagnostics.AddError(fmt.Sprintf("Invalid %v Command", op), fmt.Sprintf("failed to parse %v command: %v", op, err))
-		return nil, false
+	// Inline scripts are written to a file just before they run
+	script, scripted := crud.Scripts[op.String()]
+	var cmd []string
+	if !scripted {
+		// Commands are split the way the system running them would
+		if cmd, err = HookArgs(types.StringValue(commandStr), crud.Argv, op.String(), crud.Templates.ValueBool(), TargetOS(config.Executor)); err != nil {
+			diagnostics.AddError(fmt.Sprintf("Invalid %v Command", op), fmt.Sprintf("failed to parse %v command: %v", op, err))
+			return nil, false
+		}
+		if len(cmd) == 0 {
+			diagnostics.AddError(fmt.Sprintf("Invalid %v Command", op), fmt.Sprintf("%v command cannot be empty", op))
+			return nil, false
+		}
 	}
-	if len(cmd) == 0 {
-		diagnostics.AddError(fmt.Sprintf("Invalid %v Command", op), fmt.Sprintf("%v command cannot be empty", op))
-		return nil, false
+	if dir := crud.WorkingDirectory.ValueString(); dir != "" {
+		config.WorkingDirectory = dir
+	}
+	if format := crud.OutputFormat.ValueString(); format != "" {
+		config.OutputFormat = format
+	}
+	if crud.RawOutput.ValueBool() {
+		config.RawOutput = true
+	}
+	if crud.Templates.ValueBool() {
+		config.Templates = true
+	}
+	if op == CrudCreate || op == CrudUpdate || op == CrudDelete {
+		if err := config.ExecutionWindows.Await(ctx, op.String()); err != nil {
+			diagnostics.AddError("Outside Execution Window", err.Error())
+			return nil, false
+		}
+	}
+	if scripted {
+		if isRemote(config.Executor) {
+			diagnostics.AddError(fmt.Sprintf("Invalid %v Script", op), fmt.Sprintf("The %v%s is written to a file on this machine, which the executor running the hook can't reach. Inline scripts need the local executor", op, ScriptSuffix))
+			return nil, false
+		}
+		var file string
+		if file, cmd, err = WriteScript(script); err != nil {
+			diagnostics.AddError(fmt.Sprintf("Invalid %v Script", op), err.Error())
+			return nil, false
+		}
+		defer func() { _ = RemoveTempFile(file) }()
 	}
 	result, err := Execute(ctx, config, cmd, payload)
 
 	title := cases.Title(language.English)
 	if err != nil {
-		// Special case: for Read operations with the configured missing resource exit code, don't add error diagnostic
-		if op == CrudRead && result != nil && config.MissingResourceExitCode != -1 && result.ExitCode == config.MissingResourceExitCode {
+		// Special case: for Read operations with the configured missing resource exit code, don't add error diagnostic,
+		// nor for the exists hook, which reports that there is no existing object with it
+		if (op == CrudRead || op == CrudExists) && result != nil && config.MissingResourceExitCode != -1 && result.ExitCode == config.MissingResourceExitCode {
+			return result, false
+		}
+		// The requires_replace hook reports a replacement with its exit code
+		if op == CrudRequiresReplace && result != nil && result.ExitCode == config.RequiresReplaceExitCode {
+			return result, false
+		}
+		// A delete hook finding the object already gone destroyed it all the same
+		if op == CrudDelete && result != nil && config.AlreadyDeletedExitCode != -1 && result.ExitCode == config.AlreadyDeletedExitCode {
+			tflog.Info(ctx, "Delete hook reported the object already gone", map[string]interface{}{
+				"exit_code": result.ExitCode,
+			})
+			return result, true
+		}
+		// The validate and verify hooks report invalid input and unconverged
+		// output with a non-zero exit code, the caller turns their output
+		// into diagnostics
+		if (op == CrudValidate || op == CrudVerify) && result != nil && result.ExitCode > 0 {
 			return result, false
 		}
 		payloadJSON, _ := json.Marshal(payload)
-		diagnostics.AddError(fmt.Sprintf("%v Script Failed", title.String(op.String())), fmt.Sprintf("%v\nExit Code: %d\nStdout: %s\nStderr: %s\nInput Payload: %s", err, result.ExitCode, result.Stdout, result.Stderr, string(payloadJSON)))
+		diagnostics.AddError(fmt.Sprintf("%v Script Failed", title.String(op.String())), fmt.Sprintf("%v\nExit Code: %d\nStdout: %s\nStderr: %s\nInput Payload: %s", err, result.ExitCode, result.Mask(result.Stdout), result.Mask(result.Stderr), result.Mask(string(payloadJSON))))
 		return result, false
 	}
-	// For delete operations, nil output is expected and should not be treated as an error
-	if result == nil || (result.Result == nil && op != CrudDelete) {
+	// For delete operations, nil output is expected and should not be treated as an error,
+	// while a plan hook prints nothing to leave the output unknown, an exists
+	// hook when there is no existing object and wait_for, on_failure and
+	// verify hooks at all
+	if result == nil || (result.Result == nil && op != CrudDelete && op != CrudPlan && op != CrudExists && op != CrudWaitFor && op != CrudOnFailure && op != CrudVerify) {
 		payloadJSON, _ := json.Marshal(payload)
-		diagnostics.AddError(fmt.Sprintf("%v Script Failed", title.String(op.String())), fmt.Sprintf("%v script returned nil output\nExit Code: %d\nStdout: %s\nStderr: %s\nInput Payload: %s", op, result.ExitCode, result.Stdout, result.Stderr, string(payloadJSON)))
+		diagnostics.AddError(fmt.Sprintf("%v Script Failed", title.String(op.String())), fmt.Sprintf("%v script returned nil output\nExit Code: %d\nStdout: %s\nStderr: %s\nInput Payload: %s", op, result.ExitCode, result.Mask(result.Stdout), result.Mask(result.Stderr), result.Mask(string(payloadJSON))))
 		return result, false
 	}
+	// Operations still running are polled with the status hook
+	if op == CrudCreate || op == CrudUpdate || op == CrudDelete {
+		if id, ok := operationId(result); ok && HookDefined(crud.Status, crud.Argv, Status) {
+			return pollStatus(ctx, base, crud, model, payload, diagnostics, op, result, id)
+		}
+	}
 	return result, true
 }
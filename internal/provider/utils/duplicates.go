@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DuplicateKeyError reports a key given twice in one object of hook output.
+// Go keeps the last value of a duplicate key while other JSON tools keep the
+// first, so the output is rejected rather than stored ambiguously.
+type DuplicateKeyError struct {
+	// Path is the location of the key, e.g. credentials.password or
+	// items[2].id.
+	Path string
+	// First and Second are the JSON values of the key, in output order.
+	First, Second string
+
+	// keyPath is Path without list indexes, as matched by sensitive keys.
+	keyPath string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key %s in script output, first with the value %s and then %s. Print each key once so that the stored value isn't left to the JSON decoder", e.Path, e.First, e.Second)
+}
+
+// mask hides both values when the key path is sensitive under keys, adding
+// them to the secrets of result so they're masked in its stdout too.
+func (e *DuplicateKeyError) mask(result *ExecutionResult, keys []string) {
+	sensitive := false
+	parts := strings.Split(e.keyPath, ".")
+	for i := range parts {
+		if matchesSensitive(strings.Join(parts[:i+1], "."), keys) {
+			sensitive = true
+			break
+		}
+	}
+	if !sensitive {
+		return
+	}
+	for _, raw := range []string{e.First, e.Second} {
+		var s string
+		if err := json.Unmarshal([]byte(raw), &s); err == nil && s != "" {
+			result.secrets = append(result.secrets, s)
+		}
+	}
+	e.First, e.Second = MaskedValue, MaskedValue
+}
+
+// findDuplicateKey returns the first duplicate key in the stream of JSON
+// values in data, or nil. Malformed JSON is left to the decoder reporting
+// it, so it isn't an error here. data is read in a single pass over its
+// tokens, so deeply nested output isn't decoded again at each level.
+func findDuplicateKey(data []byte) *DuplicateKeyError {
+	d := json.NewDecoder(bytes.NewReader(data))
+	var stack []*duplicateFrame
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return nil
+		}
+		var top *duplicateFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if token == json.Delim('}') || token == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].done(data, d.InputOffset())
+			}
+			continue
+		}
+		if top != nil && top.object && !top.hasKey {
+			key, _ := token.(string)
+			if first, ok := top.seen[key]; ok {
+				var second json.RawMessage
+				if err := d.Decode(&second); err != nil {
+					return nil
+				}
+				return &DuplicateKeyError{Path: joinKeyPath(top.path, key), First: compactJSON(first), Second: compactJSON(second), keyPath: joinKeyPath(top.keyPath, key)}
+			}
+			top.key, top.hasKey, top.start = key, true, d.InputOffset()
+			continue
+		}
+		if token == json.Delim('{') || token == json.Delim('[') {
+			child := &duplicateFrame{object: token == json.Delim('{'), seen: map[string]json.RawMessage{}}
+			if top != nil {
+				child.path, child.keyPath = top.childPath()
+			}
+			stack = append(stack, child)
+			continue
+		}
+		if top != nil {
+			top.done(data, d.InputOffset())
+		}
+	}
+}
+
+// duplicateFrame is an object or list being read by findDuplicateKey.
+type duplicateFrame struct {
+	object        bool
+	path, keyPath string
+	// seen holds the values of the keys read so far in an object.
+	seen map[string]json.RawMessage
+	// key is the key whose value is being read, starting at the offset
+	// start.
+	key    string
+	hasKey bool
+	start  int64
+	// index is the index of the element being read in a list.
+	index int
+}
+
+func (f *duplicateFrame) childPath() (string, string) {
+	if f.object {
+		return joinKeyPath(f.path, f.key), joinKeyPath(f.keyPath, f.key)
+	}
+	return fmt.Sprintf("%s[%d]", f.path, f.index), f.keyPath
+}
+
+// done records the end, at offset end of data, of the value being read.
+func (f *duplicateFrame) done(data []byte, end int64) {
+	if !f.object {
+		f.index++
+		return
+	}
+	// The value is read from just after its key, so the colon is trimmed
+	f.seen[f.key] = bytes.TrimLeft(data[f.start:end], " \t\r\n:")
+	f.hasKey = false
+}
+
+// compactJSON returns raw without insignificant whitespace, for reporting.
+func compactJSON(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
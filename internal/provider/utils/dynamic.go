@@ -5,18 +5,140 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-// MapToDynamic converts a Go value to a types.Dynamic value.
-func MapToDynamic(data interface{}) types.Dynamic {
-	return types.DynamicValue(InterfaceToAttrValue(data))
+// Policies for the type of JSON arrays converted to Terraform values.
+const (
+	// CollectionTypingTuple converts every array to a tuple.
+	CollectionTypingTuple = "tuple"
+	// CollectionTypingList converts arrays whose elements share a single
+	// type to lists, and any other array to a tuple.
+	CollectionTypingList = "list-when-homogeneous"
+)
+
+// UnknownSentinel is the string a plan hook prints in place of the output
+// values it can't predict, which are then known after apply.
+const UnknownSentinel = "__unknown__"
+
+// unknownValue stands for a value that is only known after apply, it is
+// converted to an unknown value of the type expected at its place.
+type unknownValue struct{}
+
+// MarkUnknown replaces the UnknownSentinel strings found anywhere in output
+// with values that convert to unknown values.
+func MarkUnknown(output map[string]interface{}) map[string]interface{} {
+	marked, _ := markUnknown(output).(map[string]interface{})
+	return marked
+}
+
+func markUnknown(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == UnknownSentinel {
+			return unknownValue{}
+		}
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = markUnknown(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = markUnknown(val)
+		}
+	}
+	return value
+}
+
+// MapToDynamic converts a Go value to a types.Dynamic value. Values that
+// can't be represented are reported with their key path.
+func MapToDynamic(data interface{}) (types.Dynamic, diag.Diagnostics) {
+	return MapToDynamicWithTyping(data, CollectionTypingTuple)
+}
+
+// MapToDynamicWithTyping is MapToDynamic converting arrays according to the
+// collection typing policy.
+func MapToDynamicWithTyping(data interface{}, typing string) (types.Dynamic, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	value := toAttrValue(path.Empty(), data, nil, typing == CollectionTypingList, &diags)
+	return types.DynamicValue(value), diags
 }
 
 // InterfaceToAttrValue converts a Go value to an attr.Value.
-func InterfaceToAttrValue(data interface{}) attr.Value {
+func InterfaceToAttrValue(data interface{}) (attr.Value, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	value := toAttrValue(path.Empty(), data, nil, false, &diags)
+	return value, diags
+}
+
+// InterfaceToAttrValueWithTypeHint converts a Go value to an attr.Value,
+// using typeHint to preserve collection types (Set, List or Tuple) when
+// available. Arrays without a hint are converted according to the
+// collection typing policy.
+func InterfaceToAttrValueWithTypeHint(data interface{}, typeHint attr.Value, typing string) (attr.Value, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	value := toAttrValue(path.Empty(), data, typeHint, typing == CollectionTypingList, &diags)
+	return value, diags
+}
+
+// CheckLimits reports an error when value, decoded from hook output, nests
+// objects and lists deeper than maxDepth or holds more than maxNodes values.
+// It runs before the value is converted to framework types, which gets slow
+// and memory hungry for runaway structures. A limit of 0 disables the check.
+func CheckLimits(value interface{}, maxDepth, maxNodes int) error {
+	nodes := 0
+	var walk func(p string, value interface{}, depth int) error
+	walk = func(p string, value interface{}, depth int) error {
+		nodes++
+		if maxNodes > 0 && nodes > maxNodes {
+			return fmt.Errorf("output holds more than %d values, raise the provider max_output_nodes if this is expected", maxNodes)
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			if maxDepth > 0 && depth > maxDepth {
+				return fmt.Errorf("output nests objects and lists deeper than %d levels at key path %s, raise the provider max_output_depth if this is expected", maxDepth, p)
+			}
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for k, val := range v {
+				if err := walk(joinKeyPath(p, k), val, depth+1); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for i, val := range v {
+				if err := walk(fmt.Sprintf("%s[%d]", p, i), val, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk("", value, 1)
+}
+
+// addConversionError reports a value that couldn't be converted, naming
+// the offending key path so malformed script output is easy to track down.
+func addConversionError(diags *diag.Diagnostics, p path.Path, detail string) {
+	location := "the top level"
+	if !p.Equal(path.Empty()) {
+		location = fmt.Sprintf("key path %s", p)
+	}
+	diags.AddError("Value Conversion Error", fmt.Sprintf("Failed to convert the value at %s: %s", location, detail))
+}
+
+// toAttrValue converts data found at path p, using typeHint (which may be
+// nil) to preserve collection types from a previous value. Arrays without a
+// list, set or tuple hint become lists when lists is set and their elements
+// share a single type.
+func toAttrValue(p path.Path, data interface{}, typeHint attr.Value, lists bool, diags *diag.Diagnostics) attr.Value {
 	switch v := data.(type) {
 	case string:
 		return types.StringValue(v)
@@ -24,45 +146,19 @@ func InterfaceToAttrValue(data interface{}) attr.Value {
 		return types.NumberValue(big.NewFloat(v))
 	// Only appears when high_precision_numbers set in provider config
 	case json.Number:
-		f, _, _ := big.ParseFloat(string(v), 10, 512, big.ToNearestEven)
+		f, _, err := big.ParseFloat(string(v), 10, 512, big.ToNearestEven)
+		if err != nil {
+			addConversionError(diags, p, fmt.Sprintf("invalid number %q: %v", string(v), err))
+			return types.NumberNull()
+		}
 		return types.NumberValue(f)
 	case int:
 		return types.NumberValue(big.NewFloat(float64(v)))
 	case bool:
 		return types.BoolValue(v)
+	case unknownValue:
+		return types.DynamicUnknown()
 	case []interface{}:
-		elements := make([]attr.Value, len(v))
-		for i, elem := range v {
-			elements[i] = InterfaceToAttrValue(elem)
-		}
-		tupleTypes := make([]attr.Type, len(elements))
-		for i, elem := range elements {
-			tupleTypes[i] = elem.Type(context.Background())
-		}
-		tupleVal, _ := types.TupleValue(tupleTypes, elements)
-		return tupleVal
-	case map[string]interface{}:
-		attrs := make(map[string]attr.Value)
-		attrTypes := make(map[string]attr.Type)
-		for k, val := range v {
-			attrs[k] = InterfaceToAttrValue(val)
-			attrTypes[k] = attrs[k].Type(context.Background())
-		}
-		objVal, _ := types.ObjectValue(attrTypes, attrs)
-		return objVal
-	case nil:
-		return types.DynamicNull()
-	default:
-		return types.StringValue(fmt.Sprintf("%v", v))
-	}
-}
-
-// InterfaceToAttrValueWithTypeHint converts a Go value to an attr.Value,
-// using typeHint to preserve collection types (Set vs Tuple) when available.
-func InterfaceToAttrValueWithTypeHint(data interface{}, typeHint attr.Value) attr.Value {
-	switch v := data.(type) {
-	case []interface{}:
-		elements := make([]attr.Value, len(v))
 		// Get element hints if available
 		var elementHints []attr.Value
 		switch hint := typeHint.(type) {
@@ -74,61 +170,109 @@ func InterfaceToAttrValueWithTypeHint(data interface{}, typeHint attr.Value) att
 			elementHints = hint.Elements()
 		}
 
+		elements := make([]attr.Value, len(v))
 		for i, elem := range v {
 			var elemHint attr.Value
 			if i < len(elementHints) {
 				elemHint = elementHints[i]
 			}
-			elements[i] = InterfaceToAttrValueWithTypeHint(elem, elemHint)
+			elements[i] = toAttrValue(p.AtListIndex(i), elem, elemHint, lists, diags)
 		}
 
 		// If the type hint is a Set, return a Set
 		if _, isSet := typeHint.(types.Set); isSet {
-			if len(elements) == 0 {
-				// For empty sets, use dynamic type
-				setVal, _ := types.SetValue(types.DynamicType, elements)
-				return setVal
+			// For empty sets, use dynamic type
+			elemType := attr.Type(types.DynamicType)
+			if len(elements) > 0 {
+				elemType = elements[0].Type(context.Background())
+			}
+			setVal, d := types.SetValue(elemType, elements)
+			if d.HasError() {
+				addConversionError(diags, p, fmt.Sprintf("the set elements don't share a single type: %s", summary(d)))
+				return types.SetNull(elemType)
 			}
-			elemType := elements[0].Type(context.Background())
-			setVal, _ := types.SetValue(elemType, elements)
 			return setVal
 		}
 
+		switch typeHint.(type) {
+		case types.Tuple:
+		case types.List:
+			if list, ok := homogeneousList(elements); ok {
+				return list
+			}
+		default:
+			if lists {
+				if list, ok := homogeneousList(elements); ok {
+					return list
+				}
+			}
+		}
+
 		// Default to Tuple
 		tupleTypes := make([]attr.Type, len(elements))
 		for i, elem := range elements {
 			tupleTypes[i] = elem.Type(context.Background())
 		}
-		tupleVal, _ := types.TupleValue(tupleTypes, elements)
+		tupleVal, d := types.TupleValue(tupleTypes, elements)
+		if d.HasError() {
+			addConversionError(diags, p, summary(d))
+			return types.TupleNull(tupleTypes)
+		}
 		return tupleVal
-
 	case map[string]interface{}:
-		attrs := make(map[string]attr.Value)
-		attrTypes := make(map[string]attr.Type)
-
 		// Get attribute hints if available
 		var attrHints map[string]attr.Value
 		if hint, ok := typeHint.(types.Object); ok {
 			attrHints = hint.Attributes()
 		}
 
+		attrs := make(map[string]attr.Value)
+		attrTypes := make(map[string]attr.Type)
 		for k, val := range v {
-			var elemHint attr.Value
-			if attrHints != nil {
-				elemHint = attrHints[k]
-			}
-			attrs[k] = InterfaceToAttrValueWithTypeHint(val, elemHint)
+			attrs[k] = toAttrValue(p.AtName(k), val, attrHints[k], lists, diags)
 			attrTypes[k] = attrs[k].Type(context.Background())
 		}
-		objVal, _ := types.ObjectValue(attrTypes, attrs)
+		objVal, d := types.ObjectValue(attrTypes, attrs)
+		if d.HasError() {
+			addConversionError(diags, p, summary(d))
+			return types.ObjectNull(attrTypes)
+		}
 		return objVal
-
+	case nil:
+		return types.DynamicNull()
 	default:
-		// For non-collection types, use the regular conversion
-		return InterfaceToAttrValue(data)
+		return types.StringValue(fmt.Sprintf("%v", v))
 	}
 }
 
+// homogeneousList returns elements as a list when they share a single type.
+// Empty arrays have no element type to go by and are left to be tuples.
+func homogeneousList(elements []attr.Value) (attr.Value, bool) {
+	if len(elements) == 0 {
+		return nil, false
+	}
+	elemType := elements[0].Type(context.Background())
+	for _, elem := range elements[1:] {
+		if !elem.Type(context.Background()).Equal(elemType) {
+			return nil, false
+		}
+	}
+	list, d := types.ListValue(elemType, elements)
+	if d.HasError() {
+		return nil, false
+	}
+	return list, true
+}
+
+// summary joins the details of the errors in d.
+func summary(d diag.Diagnostics) string {
+	details := make([]string, 0, len(d))
+	for _, e := range d.Errors() {
+		details = append(details, e.Detail())
+	}
+	return strings.Join(details, "; ")
+}
+
 // MergeDefaultInputs merges provider-level default inputs with resource/data source input.
 // Resource input takes priority over default inputs.
 func MergeDefaultInputs(config CustomCRUDProviderConfig, input interface{}) interface{} {
@@ -205,6 +349,16 @@ func AttrValueToInterface(val attr.Value) interface{} {
 			result[i] = AttrValueToInterface(elem)
 		}
 		return result
+	case types.Map:
+		if v.IsNull() {
+			return nil
+		}
+		elements := v.Elements()
+		result := make(map[string]interface{}, len(elements))
+		for k, elem := range elements {
+			result[k] = AttrValueToInterface(elem)
+		}
+		return result
 	case types.Object:
 		if v.IsNull() {
 			return nil
@@ -224,3 +378,142 @@ func AttrValueToInterface(val attr.Value) interface{} {
 		return nil
 	}
 }
+
+// SortOutputLists sorts homogeneous scalar lists in the output so that backends
+// returning collections in nondeterministic order don't cause order-only diffs.
+// When all is set every such list is sorted, otherwise only the lists found at
+// the given dot-separated key paths.
+func SortOutputLists(output map[string]interface{}, all bool, paths []string) map[string]interface{} {
+	if all {
+		sortAllLists(output)
+		return output
+	}
+	for _, p := range paths {
+		keys := strings.Split(p, ".")
+		parent := output
+		for _, k := range keys[:len(keys)-1] {
+			next, ok := parent[k].(map[string]interface{})
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = next
+		}
+		if parent == nil {
+			continue
+		}
+		if list, ok := parent[keys[len(keys)-1]].([]interface{}); ok {
+			sortScalarList(list)
+		}
+	}
+	return output
+}
+
+// DropOutputKeys removes the values at the given dot-separated key paths from
+// the output, for server metadata such as etags that changes on every read.
+func DropOutputKeys(output map[string]interface{}, paths []string) map[string]interface{} {
+	for _, p := range paths {
+		keys := strings.Split(p, ".")
+		parent := output
+		for _, k := range keys[:len(keys)-1] {
+			next, ok := parent[k].(map[string]interface{})
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = next
+		}
+		if parent != nil {
+			delete(parent, keys[len(keys)-1])
+		}
+	}
+	return output
+}
+
+func sortAllLists(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, val := range v {
+			sortAllLists(val)
+		}
+	case []interface{}:
+		for _, val := range v {
+			sortAllLists(val)
+		}
+		sortScalarList(v)
+	}
+}
+
+// sortScalarList sorts list in place when all of its elements are strings,
+// numbers or booleans. Mixed or nested lists are left untouched.
+func sortScalarList(list []interface{}) {
+	if len(list) < 2 {
+		return
+	}
+	switch list[0].(type) {
+	case string:
+		values := make([]string, len(list))
+		for i, elem := range list {
+			str, ok := elem.(string)
+			if !ok {
+				return
+			}
+			values[i] = str
+		}
+		sort.Strings(values)
+		for i, str := range values {
+			list[i] = str
+		}
+	case float64, json.Number, int:
+		numbers := make([]*big.Float, len(list))
+		for i, elem := range list {
+			n, ok := toBigFloat(elem)
+			if !ok {
+				return
+			}
+			numbers[i] = n
+		}
+		sort.Stable(numericList{values: list, numbers: numbers})
+	case bool:
+		falseCount := 0
+		for _, elem := range list {
+			b, ok := elem.(bool)
+			if !ok {
+				return
+			}
+			if !b {
+				falseCount++
+			}
+		}
+		for i := range list {
+			list[i] = i >= falseCount
+		}
+	}
+}
+
+func toBigFloat(value interface{}) (*big.Float, bool) {
+	switch v := value.(type) {
+	case float64:
+		return big.NewFloat(v), true
+	case int:
+		return big.NewFloat(float64(v)), true
+	case json.Number:
+		f, _, err := big.ParseFloat(string(v), 10, 512, big.ToNearestEven)
+		return f, err == nil
+	default:
+		return nil, false
+	}
+}
+
+// numericList sorts list values by their parsed numeric value.
+type numericList struct {
+	values  []interface{}
+	numbers []*big.Float
+}
+
+func (l numericList) Len() int           { return len(l.values) }
+func (l numericList) Less(i, j int) bool { return l.numbers[i].Cmp(l.numbers[j]) < 0 }
+func (l numericList) Swap(i, j int) {
+	l.values[i], l.values[j] = l.values[j], l.values[i]
+	l.numbers[i], l.numbers[j] = l.numbers[j], l.numbers[i]
+}
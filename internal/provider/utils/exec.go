@@ -5,23 +5,111 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"io"
+	"sort"
+	"strings"
+	"time"
 
+	"filippo.io/age"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"gopkg.in/yaml.v3"
 )
 
+// PrivateKey is the top-level key of resource hook output and payloads that
+// round-trips data through Terraform private state instead of output.
+const PrivateKey = "private"
+
+// PartialKey is the top-level key with which a create hook reports, with
+// "partial": true, that it allocated the object but didn't finish it.
+const PartialKey = "partial"
+
 type ExecutionPayload struct {
-	Id     string      `json:"id,omitempty"`
-	Input  interface{} `json:"input,omitempty"`
-	Output interface{} `json:"output,omitempty"`
+	Id      string      `json:"id,omitempty"`
+	Input   interface{} `json:"input,omitempty"`
+	Output  interface{} `json:"output,omitempty"`
+	Private interface{} `json:"private,omitempty"`
+	// PriorInput is the input held in state, given to hooks that compare it
+	// with the planned input.
+	PriorInput interface{} `json:"prior_input,omitempty"`
+	// Phase is the Terraform operation the hook runs in, one of the Phase
+	// constants, so that hooks can skip expensive checks while planning.
+	Phase string `json:"phase,omitempty"`
+	// Retry is set when the provider runs the hook again after a failed
+	// attempt, so that hooks don't retry blindly on their own.
+	Retry *RetryInfo `json:"retry,omitempty"`
+	// Operation is set for the status hook, naming the operation it looks up.
+	Operation *OperationInfo `json:"operation,omitempty"`
+	// Failure is set for the on_failure hook, describing the hook that failed.
+	Failure *FailureInfo `json:"failure,omitempty"`
+	// Sensitive lists the output key paths holding secrets, which are masked
+	// in logs and diagnostics.
+	Sensitive []string `json:"-"`
 }
 
+// RetryInfo tells a hook which retry of the provider it runs in.
+type RetryInfo struct {
+	// Attempt counts the retries, starting at 1 for the first one.
+	Attempt int `json:"attempt"`
+	// MaxAttempts is the number of retries the provider makes at most.
+	MaxAttempts int `json:"max_attempts"`
+	// BackoffSeconds is the time the provider waits between retries, which
+	// a hook retrying on its own should stay within.
+	BackoffSeconds float64 `json:"backoff_seconds"`
+}
+
+// FailureInfo tells the on_failure hook which hook failed and how.
+type FailureInfo struct {
+	// Hook is the failing hook, create or update.
+	Hook string `json:"hook"`
+	// ExitCode is its exit code, or -1 when it didn't run to completion.
+	ExitCode int `json:"exit_code"`
+	// Stderr is what it printed on stderr.
+	Stderr string `json:"stderr"`
+}
+
+// Hook protocol versions selected with the provider compatibility_mode
+// attribute, so that scripts can be migrated separately from provider
+// upgrades.
+const (
+	// CompatibilityV1 freezes the original protocol: the payload only holds
+	// id, input and output, and the output is a single JSON object whose
+	// keys are all stored, with numbers parsed as float64.
+	CompatibilityV1 = "v1"
+	// CompatibilityV2 is the current protocol, with the private, prior_input,
+	// phase and retry payload fields, state events, the private and __sensitive
+	// output keys and exact integers.
+	CompatibilityV2 = "v2"
+)
+
+// Terraform operations reported to hooks in the payload phase field.
+const (
+	PhasePlan    = "plan"
+	PhaseApply   = "apply"
+	PhaseDestroy = "destroy"
+	PhaseRefresh = "refresh"
+	// PhaseUpgrade is the phase of the upgrade hook, run when Terraform
+	// upgrades state written with an older resource schema version.
+	PhaseUpgrade = "upgrade"
+	// PhaseDescribe asks a read hook to print its self-description instead
+	// of reading anything, see the hookdocs package.
+	PhaseDescribe = "describe"
+)
+
 type ExecutionResult struct {
 	Payload  string
 	Result   map[string]interface{}
 	Stdout   string
 	Stderr   string
 	ExitCode int
+	// State is the last {"state": {...}} event emitted by the script, if any.
+	State map[string]interface{}
+	// Sensitive lists the output key paths the script marked as sensitive
+	// with the __sensitive key.
+	Sensitive []string
+
+	// masked and secrets are the key paths and values hidden by Mask.
+	masked  []string
+	secrets []string
 }
 
 // Execute runs the given command with the provided payload, returning the result and any error.
@@ -30,76 +118,540 @@ func Execute(ctx context.Context, config CustomCRUDProviderConfig, cmd []string,
 		return nil, fmt.Errorf("empty command")
 	}
 
+	secrets, err := decryptPayload(&payload, config.AgeIdentities)
+	if err != nil {
+		return nil, err
+	}
+	if config.CompatibilityMode == CompatibilityV1 {
+		payload = ExecutionPayload{Id: payload.Id, Input: payload.Input, Output: payload.Output, Sensitive: payload.Sensitive}
+	}
+
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
 	payloadStr := string(payloadBytes)
+	result := &ExecutionResult{Payload: payloadStr, secrets: secrets}
+	result.maskPayload(payload, config.SensitiveKeys)
+	if config.Templates {
+		if cmd, err = renderCommand(cmd, payloadBytes); err != nil {
+			result.ExitCode = -1
+			return result, err
+		}
+	}
 	tflog.Debug(ctx, "Executing script", map[string]interface{}{
-		"command": cmd,
-		"payload": payloadStr,
+		"command":           result.maskCommand(cmd),
+		"payload":           result.Mask(payloadStr),
+		"working_directory": config.WorkingDirectory,
 	})
 
-	execCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
-	execCmd.Stdin = bytes.NewReader(payloadBytes)
+	env, credentials, err := credentialEnv(ctx, config, payload)
+	if err != nil {
+		result.ExitCode = -1
+		return result, err
+	}
+	result.secrets = append(result.secrets, credentials...)
 
-	var stdout, stderr bytes.Buffer
-	execCmd.Stdout = &stdout
-	execCmd.Stderr = &stderr
+	if err := config.SubprocessBudget.take(); err != nil {
+		result.ExitCode = -1
+		return result, err
+	}
 
-	err = execCmd.Run()
-	result := &ExecutionResult{
-		Payload:  payloadStr,
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-		ExitCode: 0,
+	executor := config.Executor
+	if executor == nil {
+		executor = localExecutor{}
+	}
+	stdin := payloadBytes
+	// Only local hooks can reach the socket
+	if _, local := executor.(localExecutor); local && config.InputSocketThreshold > 0 && len(payloadBytes) > config.InputSocketThreshold {
+		socket, err := serveInputSocket(payloadBytes)
+		if err != nil {
+			result.ExitCode = -1
+			return result, fmt.Errorf("failed to serve the payload on a socket: %w", err)
+		}
+		defer socket.Close()
+		if stdin, err = json.Marshal(InputSocketPayload{InputSocket: socket.Path(), InputBytes: len(payloadBytes)}); err != nil {
+			result.ExitCode = -1
+			return result, fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		tflog.Debug(ctx, "Serving payload on a socket", map[string]interface{}{
+			"socket": socket.Path(),
+			"bytes":  len(payloadBytes),
+		})
+	}
+	profile := profileFrom(ctx)
+	profile.hook(cmd[0], payload.Id)
+	profile.Mark(ProfilePayload)
+	resp, err := executor.Run(ctx, ExecRequest{
+		Command:         cmd,
+		Stdin:           stdin,
+		Dir:             config.WorkingDirectory,
+		Env:             env,
+		MaxCaptureBytes: config.MaxCaptureBytes,
+	})
+	profile.Mark(ProfileExec)
+	defer profile.Mark(ProfileParse)
+	if resp == nil {
+		resp = &ExecResponse{}
+	}
+	trimmed, parsed := normalizeStdout(ctx, resp.Stdout)
+	stdout := bytes.NewBuffer(parsed)
+	result.Stdout = string(trimmed) + truncationNote(len(resp.Stdout), resp.StdoutFile)
+	result.Stderr = string(resp.Stderr) + truncationNote(len(resp.Stderr), resp.StderrFile)
+	result.ExitCode = resp.ExitCode
+	if err == nil && resp.StdoutFile != "" {
+		// Truncated output can't be parsed, so fail like a malformed output
+		err = fmt.Errorf("stdout exceeded %d bytes, full output saved to %s", len(resp.Stdout), resp.StdoutFile)
+		tflog.Debug(ctx, "Script output truncated", map[string]interface{}{
+			"stderr":   result.Mask(result.Stderr),
+			"exitCode": result.ExitCode,
+			"payload":  result.Mask(payloadStr),
+		})
+		return result, err
 	}
 
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
+		// Keep whatever state the script reported before it failed or was cancelled
+		if !config.RawOutput {
+			partial, _ := decodeOutput(ctx, config, stdout, result)
+			_ = result.markSensitive(config.CompatibilityMode != CompatibilityV1, partial, result.State)
+			// Output with an id printed before failing supersedes the reported state
+			if id, ok := partial["id"]; ok && id != nil && fmt.Sprintf("%v", id) != "" {
+				result.State = DropOutputKeys(partial, config.IgnoreOutputPaths)
+			}
 		}
 		tflog.Debug(ctx, "Script execution failed", map[string]interface{}{
-			"stdout":   result.Stdout,
-			"stderr":   result.Stderr,
+			"stdout":   result.Mask(result.Stdout),
+			"stderr":   result.Mask(result.Stderr),
 			"exitCode": result.ExitCode,
 			"error":    err.Error(),
-			"payload":  string(payloadBytes),
+			"payload":  result.Mask(payloadStr),
 		})
 		return result, fmt.Errorf("script execution failed with exit code %d: %w", result.ExitCode, err)
 	}
 
+	result.Result, err = parseOutput(ctx, config, stdout, result)
+	if err == nil {
+		err = result.markSensitive(config.CompatibilityMode != CompatibilityV1, result.Result, result.State)
+	}
 	tflog.Debug(ctx, "Script execution completed", map[string]interface{}{
-		"stdout":   result.Stdout,
-		"stderr":   result.Stderr,
+		"stdout":   result.Mask(result.Stdout),
+		"stderr":   result.Mask(result.Stderr),
 		"exitCode": result.ExitCode,
-		"payload":  string(payloadBytes),
+		"payload":  result.Mask(payloadStr),
 	})
+	return result, err
+}
+
+// credentialEnv runs the credential helper of config, if any, and returns the
+// environment variables it prints for the hook about to run payload, along
+// with their values so they can be masked. The helper output is never logged.
+func credentialEnv(ctx context.Context, config CustomCRUDProviderConfig, payload ExecutionPayload) ([]string, []string, error) {
+	if len(config.CredentialHelper) == 0 {
+		return nil, nil, nil
+	}
+	if err := config.SubprocessBudget.take(); err != nil {
+		return nil, nil, err
+	}
+	stdin, err := json.Marshal(ExecutionPayload{Id: payload.Id, Phase: payload.Phase})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal credential_helper payload: %w", err)
+	}
+	// Credentials are fetched where the provider runs, whatever the executor,
+	// and read from memory so that they are never spilled to a file
+	resp, err := localExecutor{}.Run(ctx, ExecRequest{
+		Command: config.CredentialHelper,
+		Stdin:   stdin,
+		Dir:     config.WorkingDirectory,
+	})
+	if err != nil {
+		stderr := ""
+		if resp != nil {
+			stderr = string(resp.Stderr)
+		}
+		return nil, nil, fmt.Errorf("credential_helper failed: %w\nStderr: %s", err, stderr)
+	}
+
+	var values map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(resp.Stdout))
+	d.UseNumber()
+	if err := d.Decode(&values); err != nil {
+		return nil, nil, fmt.Errorf("credential_helper must print a JSON object of environment variables: %w", err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return nil, nil, fmt.Errorf("credential_helper printed an invalid environment variable name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var env, secrets []string
+	for _, name := range names {
+		var value string
+		switch v := values[name].(type) {
+		case string:
+			value = v
+		case json.Number, bool:
+			value = fmt.Sprint(v)
+		default:
+			return nil, nil, fmt.Errorf("credential_helper value of %s must be a string, number or boolean", name)
+		}
+		env = append(env, name+"="+value)
+		if value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	return env, secrets, nil
+}
+
+// decryptPayload replaces the age and SOPS encrypted input values of payload
+// by their plaintext, returning the plaintexts in the forms they take in the payload
+// so they can be masked.
+func decryptPayload(payload *ExecutionPayload, identities []age.Identity) ([]string, error) {
+	var secrets []string
+	for _, value := range []*interface{}{&payload.Input, &payload.PriorInput} {
+		decrypted, plaintexts, err := DecryptValues(*value, identities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt input: %w", err)
+		}
+		*value = decrypted
+		for _, plaintext := range plaintexts {
+			secrets = append(secrets, plaintext)
+			if quoted, err := json.Marshal(plaintext); err == nil {
+				if escaped := string(quoted[1 : len(quoted)-1]); escaped != plaintext {
+					secrets = append(secrets, escaped)
+				}
+			}
+		}
+	}
+	return secrets, nil
+}
+
+// truncationNote returns the text appended to output truncated at size bytes
+// whose full content was saved to file, or "" if it wasn't truncated.
+func truncationNote(size int, file string) string {
+	if file == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n[output truncated at %d bytes, full output saved to %s]", size, file)
+}
+
+// utf8BOM is the byte order mark some Windows editors and shells write at the
+// start of UTF-8 text.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// normalizeStdout strips the UTF-8 byte order mark from stdout, and returns a
+// copy with LF line endings to parse, so that the output of Windows-authored
+// scripts parses like any other. The changes are logged as they usually point
+// at how the script was written.
+func normalizeStdout(ctx context.Context, stdout []byte) (trimmed, parsed []byte) {
+	trimmed = stdout
+	if bytes.HasPrefix(trimmed, utf8BOM) {
+		tflog.Debug(ctx, "Stripped the UTF-8 byte order mark from script output")
+		trimmed = trimmed[len(utf8BOM):]
+	}
+	parsed = trimmed
+	if n := bytes.Count(parsed, []byte("\r\n")); n > 0 {
+		tflog.Debug(ctx, "Converted CRLF line endings in script output", map[string]interface{}{
+			"lines": n,
+		})
+		parsed = bytes.ReplaceAll(parsed, []byte("\r\n"), []byte("\n"))
+	}
+	return trimmed, parsed
+}
+
+// checkEncoding returns an error describing output that isn't UTF-8 text,
+// such as the UTF-16 that Windows PowerShell writes by default, which would
+// otherwise fail with an invalid character error.
+func checkEncoding(stdout []byte) error {
+	if bytes.HasPrefix(stdout, []byte{0xFF, 0xFE}) || bytes.HasPrefix(stdout, []byte{0xFE, 0xFF}) {
+		return fmt.Errorf("script output is UTF-16 encoded, print UTF-8 instead, e.g. with [Console]::OutputEncoding = [Text.Encoding]::UTF8 in PowerShell")
+	}
+	return nil
+}
+
+// parseOutput decodes the stdout of a successful script into its result.
+func parseOutput(ctx context.Context, config CustomCRUDProviderConfig, stdout *bytes.Buffer, result *ExecutionResult) (map[string]interface{}, error) {
+	if config.RawOutput {
+		return map[string]interface{}{RawOutputKey: result.Stdout}, nil
+	}
 
 	if stdout.Len() == 0 {
 		tflog.Debug(ctx, "Script output is empty")
-		return result, nil
+		return nil, nil
 	}
+	if err := checkEncoding(stdout.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// YAML rejects duplicate keys itself
+	data := stdout.Bytes()
+	if config.OutputFormat == OutputFormatYAML {
+		data = nil
+		converted, err := yamlToJSON(stdout.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse script output as yaml: %w", err)
+		}
+		stdout = bytes.NewBuffer(converted)
+	}
+
+	jsonResult, err := decodeOutput(ctx, config, stdout, result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse script output: %w", err)
+	}
+	if dup := findDuplicateKey(data); dup != nil {
+		_ = result.markSensitive(config.CompatibilityMode != CompatibilityV1, jsonResult, result.State)
+		dup.mask(result, result.masked)
+		return nil, dup
+	}
+	jsonResult = DropOutputKeys(jsonResult, config.IgnoreOutputPaths)
+	return SortOutputLists(jsonResult, config.SortOutputLists, config.SortOutputPaths), nil
+}
+
+// ListItem is a single object returned by a hook that enumerates resources.
+type ListItem struct {
+	Id     string
+	Input  map[string]interface{}
+	Output map[string]interface{}
+	// Sensitive lists the output key paths the hook marked as sensitive.
+	Sensitive []string
+}
+
+// ExecuteList runs a hook that prints a JSON array of {id, input, output}
+// objects, as used to enumerate existing resources.
+func ExecuteList(ctx context.Context, config CustomCRUDProviderConfig, cmd []string, payload ExecutionPayload) ([]ListItem, *ExecutionResult, error) {
+	// The array is decoded here since regular hook output must be an object
+	config.RawOutput = true
+	result, err := Execute(ctx, config, cmd, payload)
+	if err != nil {
+		return nil, result, err
+	}
+	result.Result = nil
+	if len(bytes.TrimSpace([]byte(result.Stdout))) == 0 {
+		return nil, result, nil
+	}
+
+	if err := checkEncoding([]byte(result.Stdout)); err != nil {
+		return nil, result, err
+	}
+	d := json.NewDecoder(bytes.NewBufferString(result.Stdout))
+	d.UseNumber()
+	var values []map[string]interface{}
+	if err := d.Decode(&values); err != nil {
+		return nil, result, fmt.Errorf("failed to parse script output as a JSON array: %w", err)
+	}
+	if dup := findDuplicateKey([]byte(result.Stdout)); dup != nil {
+		var keys []string
+		for _, value := range values {
+			if output, ok := value["output"].(map[string]interface{}); ok && config.CompatibilityMode != CompatibilityV1 {
+				itemKeys, _ := sensitiveKeys(output)
+				keys = append(keys, itemKeys...)
+			}
+		}
+		dup.mask(result, keys)
+		return nil, result, dup
+	}
+	for i, value := range values {
+		normalizeNumbers(value, config)
+		if err := CheckLimits(value, config.MaxOutputDepth, config.MaxOutputNodes); err != nil {
+			return nil, result, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+
+	items := make([]ListItem, 0, len(values))
+	for i, value := range values {
+		id, ok := value["id"]
+		if !ok || id == nil || fmt.Sprintf("%v", id) == "" {
+			return nil, result, fmt.Errorf("item %d is missing the 'id' field", i)
+		}
+		item := ListItem{Id: fmt.Sprintf("%v", id)}
+		if input, ok := value["input"].(map[string]interface{}); ok {
+			item.Input = input
+		}
+		if output, ok := value["output"].(map[string]interface{}); ok {
+			var keys []string
+			if config.CompatibilityMode != CompatibilityV1 {
+				keys, err = sensitiveKeys(output)
+				if err != nil {
+					return nil, result, fmt.Errorf("item %d: %w", i, err)
+				}
+			}
+			output = DropOutputKeys(output, config.IgnoreOutputPaths)
+			item.Output = SortOutputLists(output, config.SortOutputLists, config.SortOutputPaths)
+			item.Sensitive = keys
+		}
+		items = append(items, item)
+	}
+	return items, result, nil
+}
+
+// decodeOutput reads the script output as a stream of JSON values. Leading
+// {"state": {...}} events are recorded on result.State (last one wins) and the
+// first other value is returned as the script result. A lone state event is
+// still treated as the result so single-object output keeps working.
+func decodeOutput(ctx context.Context, config CustomCRUDProviderConfig, r io.Reader, result *ExecutionResult) (map[string]interface{}, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	for {
+		var value map[string]interface{}
+		if err := d.Decode(&value); err != nil {
+			return nil, err
+		}
+		normalizeNumbers(value, config)
+		if err := CheckLimits(value, config.MaxOutputDepth, config.MaxOutputNodes); err != nil {
+			return nil, err
+		}
+		state, isEvent := stateEvent(value)
+		if !isEvent || config.CompatibilityMode == CompatibilityV1 {
+			return value, nil
+		}
+		tflog.Debug(ctx, "Script reported intermediate state", map[string]interface{}{
+			"state": reportedState(state),
+		})
+		result.State = DropOutputKeys(state, config.IgnoreOutputPaths)
+		if !d.More() {
+			return value, nil
+		}
+	}
+}
 
-	var jsonResult map[string]interface{}
-	d := json.NewDecoder(&stdout)
-	if config.HighPrecisionNumbers {
-		d.UseNumber()
+// normalizeNumbers prepares the json.Number values of decoded output in place.
+// Integers are kept as json.Number so that IDs beyond the 53 bits a float64
+// holds, such as 9007199254740993, aren't rounded or rendered as 1e+06.
+// Other numbers, and every number under compatibility_mode v1, become
+// float64 unless high_precision_numbers is set.
+func normalizeNumbers(value interface{}, config CustomCRUDProviderConfig) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = normalizeNumbers(val, config)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeNumbers(val, config)
+		}
+	case json.Number:
+		if config.HighPrecisionNumbers || isInteger(v) && config.CompatibilityMode != CompatibilityV1 {
+			return v
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
 	}
-	if err := d.Decode(&jsonResult); err != nil {
-		return result, fmt.Errorf("failed to parse script output: %w", err)
+	return value
+}
+
+// isInteger reports whether n is written without a fraction or exponent.
+func isInteger(n json.Number) bool {
+	return !strings.ContainsAny(string(n), ".eE")
+}
+
+// DecodeJSON decodes data into v like json.Unmarshal, keeping integers as
+// json.Number so they round-trip to hook payloads unchanged.
+func DecodeJSON(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return err
 	}
+	if d.More() {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
 
-	result.Result = jsonResult
-	return result, nil
+// reportedState returns state for logging, with the values it marks as
+// sensitive masked.
+func reportedState(state map[string]interface{}) interface{} {
+	var keys []string
+	if list, ok := state[SensitiveKey].([]interface{}); ok {
+		for _, elem := range list {
+			if key, ok := elem.(string); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return MaskSensitiveValues(state, keys)
 }
 
+// stateEvent reports whether value is a {"state": {...}} event and returns its state.
+func stateEvent(value map[string]interface{}) (map[string]interface{}, bool) {
+	if len(value) != 1 {
+		return nil, false
+	}
+	state, ok := value["state"].(map[string]interface{})
+	return state, ok
+}
+
+// semaphoreKey is the context key of the semaphore held by WithSemaphore.
+type semaphoreKey struct{}
+
 // WithSemaphore runs the given function with semaphore acquire/release if the semaphore is not nil.
-func WithSemaphore(sem chan struct{}, fn func()) {
+// The function gets a ctx carrying the semaphore, so that Sleep can release it while waiting.
+func WithSemaphore(ctx context.Context, sem chan struct{}, fn func(ctx context.Context)) {
 	if sem != nil {
 		sem <- struct{}{}
 		defer func() { <-sem }()
+		ctx = context.WithValue(ctx, semaphoreKey{}, sem)
+	}
+	fn(ctx)
+}
+
+// Sleep waits for d on the provider clock or until ctx is cancelled,
+// reporting whether the full duration elapsed. The semaphore WithSemaphore
+// holds for ctx is released meanwhile, so a delay or poll interval doesn't
+// keep other hooks from running.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	if sem, ok := ctx.Value(semaphoreKey{}).(chan struct{}); ok {
+		<-sem
+		defer func() { sem <- struct{}{} }()
+	}
+	select {
+	case <-After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// yamlToJSON converts a YAML document into JSON so it can be decoded the same
+// way as regular script output.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalizeYAML(value))
+}
+
+// normalizeYAML converts YAML-only types (non-string map keys, timestamps)
+// into their JSON equivalents.
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = normalizeYAML(val)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprintf("%v", k)] = normalizeYAML(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeYAML(val)
+		}
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return v
 	}
-	fn()
 }
@@ -0,0 +1,394 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mvdan.cc/sh/v3/shell"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ExecRequest describes a single hook invocation handed to an Executor.
+type ExecRequest struct {
+	Command []string
+	Stdin   []byte
+	Dir     string
+	// Env holds KEY=value variables added to the environment of the hook.
+	Env []string
+	// MaxCaptureBytes caps the stdout and stderr kept in memory, 0 means
+	// unlimited. Executors that honor it spill the remainder to a file.
+	MaxCaptureBytes int
+}
+
+// ExecResponse holds the captured output of a hook invocation.
+type ExecResponse struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	// StdoutFile and StderrFile hold the full output when it exceeded
+	// MaxCaptureBytes, in which case Stdout and Stderr are truncated.
+	StdoutFile string
+	StderrFile string
+}
+
+// Executor runs hook commands. Implementations must return a non-nil response
+// whenever the command was started, together with an error if it exited
+// non-zero so that the exit code and output can be reported.
+type Executor interface {
+	Run(ctx context.Context, req ExecRequest) (*ExecResponse, error)
+}
+
+// ExecutorFactory builds an Executor from the provider executor_options.
+type ExecutorFactory func(options map[string]string) (Executor, error)
+
+const LocalExecutor = "local"
+
+var (
+	executorsMu sync.RWMutex
+	executors   = map[string]ExecutorFactory{}
+)
+
+// RegisterExecutor makes an execution backend available to the provider
+// executor attribute under the given name, replacing any existing backend.
+func RegisterExecutor(name string, factory ExecutorFactory) {
+	executorsMu.Lock()
+	defer executorsMu.Unlock()
+	executors[name] = factory
+}
+
+// NewExecutor builds the registered executor with the given name.
+func NewExecutor(name string, options map[string]string) (Executor, error) {
+	if name == "" {
+		name = LocalExecutor
+	}
+	executorsMu.RLock()
+	factory, ok := executors[name]
+	executorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown executor %q, available executors: %s", name, strings.Join(ExecutorNames(), ", "))
+	}
+	return factory(options)
+}
+
+// ExecutorNames returns the sorted names of all registered executors.
+func ExecutorNames() []string {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	names := make([]string, 0, len(executors))
+	for name := range executors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterExecutor(LocalExecutor, func(map[string]string) (Executor, error) {
+		return localExecutor{}, nil
+	})
+	RegisterExecutor("docker", newDockerExecutor)
+	RegisterExecutor("ssh", newSSHExecutor)
+	RegisterExecutor("http", newHTTPExecutor)
+	RegisterExecutor("mock", newMockExecutor)
+}
+
+// envName returns the name of the KEY=value environment variable env.
+func envName(env string) string {
+	name, _, _ := strings.Cut(env, "=")
+	return name
+}
+
+// localExecutor runs hooks as subprocesses of the provider.
+type localExecutor struct{}
+
+func (localExecutor) Run(ctx context.Context, req ExecRequest) (*ExecResponse, error) {
+	execCmd := exec.CommandContext(ctx, req.Command[0], req.Command[1:]...)
+	execCmd.Stdin = bytes.NewReader(req.Stdin)
+	// An empty Dir runs the script in the provider's own working directory
+	execCmd.Dir = req.Dir
+	if len(req.Env) > 0 {
+		execCmd.Env = append(os.Environ(), req.Env...)
+	}
+
+	stdout := &spillWriter{name: "stdout", limit: req.MaxCaptureBytes}
+	stderr := &spillWriter{name: "stderr", limit: req.MaxCaptureBytes}
+	execCmd.Stdout = stdout
+	execCmd.Stderr = stderr
+
+	err := execCmd.Run()
+	resp := &ExecResponse{
+		Stdout:     stdout.buf.Bytes(),
+		Stderr:     stderr.buf.Bytes(),
+		StdoutFile: stdout.close(),
+		StderrFile: stderr.close(),
+	}
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			resp.ExitCode = exitErr.ExitCode()
+		}
+	}
+	return resp, err
+}
+
+// dockerExecutor runs hooks inside a throwaway container using the docker CLI.
+type dockerExecutor struct {
+	binary string
+	image  string
+	args   []string
+}
+
+func newDockerExecutor(options map[string]string) (Executor, error) {
+	e := &dockerExecutor{binary: "docker", image: options["image"]}
+	if e.image == "" {
+		return nil, fmt.Errorf("docker executor requires the image option")
+	}
+	if binary := options["binary"]; binary != "" {
+		e.binary = binary
+	}
+	args, err := shell.Fields(options["args"], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse docker args option: %w", err)
+	}
+	e.args = args
+	return e, nil
+}
+
+func (e *dockerExecutor) Run(ctx context.Context, req ExecRequest) (*ExecResponse, error) {
+	cmd := []string{e.binary, "run", "--rm", "-i"}
+	if req.Dir != "" {
+		cmd = append(cmd, "-w", req.Dir)
+	}
+	// Naming the variables without values copies them from the docker CLI
+	// environment, keeping them out of the process list
+	for _, env := range req.Env {
+		cmd = append(cmd, "-e", envName(env))
+	}
+	cmd = append(cmd, e.args...)
+	cmd = append(cmd, e.image)
+	cmd = append(cmd, req.Command...)
+	return localExecutor{}.Run(ctx, ExecRequest{Command: cmd, Stdin: req.Stdin, Env: req.Env, MaxCaptureBytes: req.MaxCaptureBytes})
+}
+
+// sshExecutor runs hooks on a remote host using the ssh CLI.
+type sshExecutor struct {
+	binary string
+	host   string
+	args   []string
+}
+
+func newSSHExecutor(options map[string]string) (Executor, error) {
+	e := &sshExecutor{binary: "ssh", host: options["host"]}
+	if e.host == "" {
+		return nil, fmt.Errorf("ssh executor requires the host option")
+	}
+	if binary := options["binary"]; binary != "" {
+		e.binary = binary
+	}
+	if port := options["port"]; port != "" {
+		e.args = append(e.args, "-p", port)
+	}
+	if identity := options["identity_file"]; identity != "" {
+		e.args = append(e.args, "-i", identity)
+	}
+	args, err := shell.Fields(options["args"], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh args option: %w", err)
+	}
+	e.args = append(e.args, args...)
+	return e, nil
+}
+
+func (e *sshExecutor) Run(ctx context.Context, req ExecRequest) (*ExecResponse, error) {
+	// The remote side runs the command through a shell, so quote every argument
+	quoted := make([]string, len(req.Command))
+	for i, arg := range req.Command {
+		q, err := syntax.Quote(arg, syntax.LangPOSIX)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quote argument %q: %w", arg, err)
+		}
+		quoted[i] = q
+	}
+	remote := strings.Join(quoted, " ")
+	if req.Dir != "" {
+		dir, err := syntax.Quote(req.Dir, syntax.LangPOSIX)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quote working directory %q: %w", req.Dir, err)
+		}
+		remote = "cd " + dir + " && " + remote
+	}
+	cmd := []string{e.binary}
+	// The server must accept the variables with AcceptEnv
+	for _, env := range req.Env {
+		cmd = append(cmd, "-o", "SendEnv="+envName(env))
+	}
+	cmd = append(cmd, e.args...)
+	cmd = append(cmd, e.host, "--", remote)
+	return localExecutor{}.Run(ctx, ExecRequest{Command: cmd, Stdin: req.Stdin, Env: req.Env, MaxCaptureBytes: req.MaxCaptureBytes})
+}
+
+// httpExecutor posts hook invocations to a remote runner as JSON, with the
+// environment variables of the hook in env. The runner must answer with
+// {"stdout": "...", "stderr": "...", "exit_code": 0}.
+type httpExecutor struct {
+	url    string
+	client *http.Client
+}
+
+type httpExecRequest struct {
+	Command []string          `json:"command"`
+	Stdin   string            `json:"stdin"`
+	Dir     string            `json:"working_directory,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+type httpExecResponse struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+func newHTTPExecutor(options map[string]string) (Executor, error) {
+	if options["url"] == "" {
+		return nil, fmt.Errorf("http executor requires the url option")
+	}
+	return &httpExecutor{url: options["url"], client: http.DefaultClient}, nil
+}
+
+func (e *httpExecutor) Run(ctx context.Context, req ExecRequest) (*ExecResponse, error) {
+	var env map[string]string
+	for _, kv := range req.Env {
+		if env == nil {
+			env = map[string]string{}
+		}
+		env[envName(kv)] = strings.TrimPrefix(kv, envName(kv)+"=")
+	}
+	body, err := json.Marshal(httpExecRequest{
+		Command: req.Command,
+		Stdin:   string(req.Stdin),
+		Dir:     req.Dir,
+		Env:     env,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal http executor request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build http executor request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := e.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("http executor request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read http executor response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http executor returned status %d: %s", httpResp.StatusCode, string(respBody))
+	}
+	var decoded httpExecResponse
+	if err := json.Unmarshal(respBody, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to parse http executor response: %w", err)
+	}
+	resp := &ExecResponse{
+		Stdout:   []byte(decoded.Stdout),
+		Stderr:   []byte(decoded.Stderr),
+		ExitCode: decoded.ExitCode,
+	}
+	if resp.ExitCode != 0 {
+		return resp, fmt.Errorf("exit status %d", resp.ExitCode)
+	}
+	return resp, nil
+}
+
+// MockExecutor returns canned output without running anything, which is
+// useful for testing configurations and as a base for custom executors.
+type MockExecutor struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// Echo prints the prior output of the payload overlaid with its input
+	// and id instead of Stdout, so that every resource gets an output of its
+	// own derived from its configuration.
+	Echo bool
+	// Func, when set, overrides the canned output.
+	Func func(ctx context.Context, req ExecRequest) (*ExecResponse, error)
+}
+
+func newMockExecutor(options map[string]string) (Executor, error) {
+	e := &MockExecutor{Stdout: options["stdout"], Stderr: options["stderr"]}
+	if echo := options["echo"]; echo != "" {
+		var err error
+		if e.Echo, err = strconv.ParseBool(echo); err != nil {
+			return nil, fmt.Errorf("mock executor echo option must be a boolean: %w", err)
+		}
+	}
+	if code := options["exit_code"]; code != "" {
+		exitCode, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, fmt.Errorf("mock executor exit_code option must be an integer: %w", err)
+		}
+		e.ExitCode = exitCode
+	}
+	return e, nil
+}
+
+func (e *MockExecutor) Run(ctx context.Context, req ExecRequest) (*ExecResponse, error) {
+	if e.Func != nil {
+		return e.Func(ctx, req)
+	}
+	resp := &ExecResponse{
+		Stdout:   []byte(e.Stdout),
+		Stderr:   []byte(e.Stderr),
+		ExitCode: e.ExitCode,
+	}
+	if e.Echo {
+		stdout, err := echoPayload(req.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		resp.Stdout = stdout
+	}
+	if resp.ExitCode != 0 {
+		return resp, fmt.Errorf("exit status %d", resp.ExitCode)
+	}
+	return resp, nil
+}
+
+// echoPayload returns the output of a mock executor in echo mode for the
+// payload stdin: its output with its input on top and its id, "mock" until
+// the resource has one.
+func echoPayload(stdin []byte) ([]byte, error) {
+	var payload struct {
+		Id     string                 `json:"id"`
+		Input  map[string]interface{} `json:"input"`
+		Output map[string]interface{} `json:"output"`
+	}
+	if err := json.Unmarshal(stdin, &payload); err != nil {
+		return nil, fmt.Errorf("mock executor failed to parse the payload: %w", err)
+	}
+	result := map[string]interface{}{}
+	for k, v := range payload.Output {
+		result[k] = v
+	}
+	for k, v := range payload.Input {
+		result[k] = v
+	}
+	result["id"] = payload.Id
+	if payload.Id == "" {
+		result["id"] = "mock"
+	}
+	return json.Marshal(result)
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// hooksDirScripts lists the hooks discovered in a hooks_dir, and whether
+// each is required.
+var hooksDirScripts = []struct {
+	name     string
+	required bool
+}{
+	{Create, true},
+	{Read, false},
+	{Update, false},
+	{Delete, false},
+	{Upgrade, false},
+}
+
+// DiscoverHooks returns the hook commands of the <hook>.sh scripts in dir,
+// e.g. create.sh and read.sh, by hook name. Scripts are referred to by their
+// absolute path, so they run wherever the working directory of the hooks is.
+// create.sh is required, read.sh, update.sh, delete.sh and upgrade.sh are
+// optional.
+func DiscoverHooks(dir string) (map[string]string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	hooks := map[string]string{}
+	var missing []string
+	for _, script := range hooksDirScripts {
+		file := filepath.Join(abs, script.name+".sh")
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			if script.required {
+				missing = append(missing, script.name+".sh")
+			}
+			continue
+		}
+		command, err := syntax.Quote(file, syntax.LangPOSIX)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quote %s: %w", file, err)
+		}
+		hooks[script.name] = command
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s is missing the required %s", dir, strings.Join(missing, ", "))
+	}
+	return hooks, nil
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// LoadHooksFile reads the hook commands of a hooks_file by attribute name.
+// Files ending in .json hold a JSON object of strings, other files hold HCL
+// attributes such as create = "./create.sh", which may not refer to
+// variables or call functions.
+func LoadHooksFile(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		var values map[string]interface{}
+		if err := DecodeJSON(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s as a JSON object: %w", file, err)
+		}
+		hooks := make(map[string]string, len(values))
+		for name, value := range values {
+			switch v := value.(type) {
+			case string:
+				hooks[name] = v
+			case nil:
+			default:
+				return nil, fmt.Errorf("%s in %s must be a string, got %T", name, file, value)
+			}
+		}
+		return hooks, nil
+	}
+
+	f, diags := hclsyntax.ParseConfig(data, file, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	attrs, diags := f.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	hooks := make(map[string]string, len(attrs))
+	for name, attr := range attrs {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if value.IsNull() {
+			continue
+		}
+		if value.Type() != cty.String {
+			return nil, fmt.Errorf("%s in %s must be a string, got %s", name, file, value.Type().FriendlyName())
+		}
+		hooks[name] = value.AsString()
+	}
+	return hooks, nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+// InputSocketPayload is written to the stdin of a hook instead of a payload
+// larger than the provider input_socket_threshold. The hook fetches the full
+// payload with an HTTP GET of any path over the Unix socket, e.g.
+// curl --unix-socket "$socket" http://customcrud/.
+type InputSocketPayload struct {
+	InputSocket string `json:"input_socket"`
+	InputBytes  int    `json:"input_bytes"`
+}
+
+// inputSocket serves a payload over HTTP on a Unix socket in a temporary
+// directory only the provider user can enter.
+type inputSocket struct {
+	dir    string
+	server *http.Server
+}
+
+// serveInputSocket starts serving payload until Close is called.
+func serveInputSocket(payload []byte) (*inputSocket, error) {
+	dir, err := CreateTempDir("input-")
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", filepath.Join(dir, "input.sock"))
+	if err != nil {
+		_ = RemoveTempFile(dir)
+		return nil, err
+	}
+	s := &inputSocket{
+		dir: dir,
+		server: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			_, _ = w.Write(payload)
+		})},
+	}
+	// Serve closes the listener when the server is closed
+	go func() { _ = s.server.Serve(listener) }()
+	return s, nil
+}
+
+// Path returns the path of the Unix socket.
+func (s *inputSocket) Path() string {
+	return filepath.Join(s.dir, "input.sock")
+}
+
+// Close stops serving the payload and removes the socket.
+func (s *inputSocket) Close() error {
+	return errors.Join(s.server.Close(), RemoveTempFile(s.dir))
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// JSONSchema is a JSON Schema document that decoded JSON values are validated
+// against. Documents use draft 2020-12 unless their $schema names another
+// draft, and can only reference their own definitions.
+type JSONSchema struct {
+	schema *jsonschema.Schema
+}
+
+// SchemaViolation is a value that doesn't match a JSONSchema, at Path.
+type SchemaViolation struct {
+	Path    path.Path
+	Message string
+}
+
+// jsonSchemaURL is the location a parsed document is compiled at.
+const jsonSchemaURL = "schema.json"
+
+// ParseJSONSchema parses a JSON Schema document.
+func ParseJSONSchema(s string) (*JSONSchema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("references to other documents such as %s aren't supported", url)
+	}
+	if err := compiler.AddResource(jsonSchemaURL, strings.NewReader(s)); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	schema, err := compiler.Compile(jsonSchemaURL)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONSchema{schema: schema}, nil
+}
+
+// Validate returns the violations of value, decoded JSON found at p, in
+// path order. Values only known after apply aren't checked yet.
+func (s *JSONSchema) Validate(value interface{}, p path.Path) []SchemaViolation {
+	var unknown []string
+	value = withoutUnknown(value, "", &unknown)
+	err := s.schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []SchemaViolation{{Path: p, Message: err.Error()}}
+	}
+	var violations []SchemaViolation
+	collectViolations(validationErr, value, p, unknown, &violations)
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Path.String() < violations[j].Path.String()
+	})
+	return violations
+}
+
+// withoutUnknown returns value with the values only known after apply
+// replaced by null, recording their JSON pointers in unknown.
+func withoutUnknown(value interface{}, pointer string, unknown *[]string) interface{} {
+	switch v := value.(type) {
+	case unknownValue:
+		*unknown = append(*unknown, pointer)
+		return nil
+	case map[string]interface{}:
+		known := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			known[k] = withoutUnknown(val, pointer+"/"+escapePointer(k), unknown)
+		}
+		return known
+	case []interface{}:
+		known := make([]interface{}, len(v))
+		for i, val := range v {
+			known[i] = withoutUnknown(val, pointer+"/"+strconv.Itoa(i), unknown)
+		}
+		return known
+	default:
+		return value
+	}
+}
+
+// collectViolations adds the violations err reports to violations: the
+// failed keywords of err and its causes, except those of unknown values.
+// Missing and disallowed attributes are reported on the attributes
+// themselves.
+func collectViolations(err *jsonschema.ValidationError, value interface{}, p path.Path, unknown []string, violations *[]SchemaViolation) {
+	keyword := err.KeywordLocation[strings.LastIndexByte(err.KeywordLocation, '/')+1:]
+	switch keyword {
+	case "anyOf", "oneOf", "not":
+		// The causes only tell why each alternative failed
+		for _, u := range unknown {
+			if isPointerWithin(u, err.InstanceLocation) {
+				return
+			}
+		}
+	default:
+		if len(err.Causes) > 0 {
+			for _, cause := range err.Causes {
+				collectViolations(cause, value, p, unknown, violations)
+			}
+			return
+		}
+		for _, u := range unknown {
+			if isPointerWithin(err.InstanceLocation, u) {
+				return
+			}
+		}
+	}
+	at := pointerPath(value, err.InstanceLocation, p)
+	switch keyword {
+	case "required":
+		for _, name := range quotedNames(err.Message) {
+			*violations = append(*violations, SchemaViolation{Path: at.AtName(name), Message: "the attribute is required"})
+		}
+	case "additionalProperties":
+		for _, name := range quotedNames(err.Message) {
+			*violations = append(*violations, SchemaViolation{Path: at.AtName(name), Message: "the attribute isn't allowed"})
+		}
+	default:
+		*violations = append(*violations, SchemaViolation{Path: at, Message: err.Message})
+	}
+}
+
+// isPointerWithin reports whether the JSON pointer pointer is parent or
+// points into it.
+func isPointerWithin(pointer, parent string) bool {
+	return pointer == parent || strings.HasPrefix(pointer, parent+"/")
+}
+
+// pointerPath returns the attribute path below p of the JSON pointer into
+// value.
+func pointerPath(value interface{}, pointer string, p path.Path) path.Path {
+	if pointer == "" {
+		return p
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch v := value.(type) {
+		case []interface{}:
+			i, _ := strconv.Atoi(token)
+			p = p.AtListIndex(i)
+			if i < len(v) {
+				value = v[i]
+			}
+		case map[string]interface{}:
+			p = p.AtName(token)
+			value = v[token]
+		default:
+			p = p.AtName(token)
+		}
+	}
+	return p
+}
+
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// quotedName matches the attribute names the validator quotes in its
+// messages.
+var quotedName = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+
+// quotedNames returns the attribute names quoted in message.
+func quotedNames(message string) []string {
+	var names []string
+	for _, m := range quotedName.FindAllStringSubmatch(message, -1) {
+		quoted := strings.ReplaceAll(strings.ReplaceAll(m[1], `\'`, `'`), `"`, `\"`)
+		name, err := strconv.Unquote(`"` + quoted + `"`)
+		if err != nil {
+			name = m[1]
+		}
+		names = append(names, name)
+	}
+	return names
+}
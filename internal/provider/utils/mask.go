@@ -0,0 +1,288 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SensitiveKey is the output key a script sets to a list of output key paths
+// (e.g. ["private", "credentials.token"]) holding secrets. It is removed from
+// the output before it is stored.
+const SensitiveKey = "__sensitive"
+
+// MaskedValue replaces sensitive values in logs and diagnostics.
+const MaskedValue = "(sensitive value)"
+
+// matchesSensitive reports whether the dot-separated key path p is named by
+// one of keys, either in full or by its trailing segments, so that "token"
+// matches both "token" and "output.token".
+func matchesSensitive(p string, keys []string) bool {
+	for _, k := range keys {
+		if p == k || strings.HasSuffix(p, "."+k) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinKeyPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// MaskSensitiveValues returns a copy of value where every value whose key path
+// matches one of keys is replaced by MaskedValue.
+func MaskSensitiveValues(value interface{}, keys []string) interface{} {
+	if len(keys) == 0 {
+		return value
+	}
+	return maskValue("", value, keys)
+}
+
+func maskValue(p string, value interface{}, keys []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			kp := joinKeyPath(p, k)
+			if matchesSensitive(kp, keys) {
+				masked[k] = MaskedValue
+				continue
+			}
+			masked[k] = maskValue(kp, val, keys)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, val := range v {
+			masked[i] = maskValue(p, val, keys)
+		}
+		return masked
+	default:
+		return value
+	}
+}
+
+// MaskJSONString masks the sensitive values in s, which holds one or more JSON
+// values such as a payload or script output. Text that isn't JSON is returned
+// unchanged.
+func MaskJSONString(s string, keys []string) string {
+	if len(keys) == 0 || strings.TrimSpace(s) == "" {
+		return s
+	}
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	var values []string
+	for {
+		var value interface{}
+		if err := d.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			return s
+		}
+		masked, err := json.Marshal(MaskSensitiveValues(value, keys))
+		if err != nil {
+			return s
+		}
+		values = append(values, string(masked))
+	}
+	return strings.Join(values, "\n")
+}
+
+// sensitiveStrings returns the string values of value whose key path matches
+// one of keys, so they can also be hidden in output that isn't JSON.
+func sensitiveStrings(p string, value interface{}, keys []string, matched bool, secrets []string) []string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			kp := joinKeyPath(p, k)
+			secrets = sensitiveStrings(kp, val, keys, matched || matchesSensitive(kp, keys), secrets)
+		}
+	case []interface{}:
+		for _, val := range v {
+			secrets = sensitiveStrings(p, val, keys, matched, secrets)
+		}
+	case string:
+		if matched && v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
+
+// SplitSensitive moves the values of output whose key path matches one of keys
+// into a separate map, keeping their position. Both maps are nil when empty.
+func SplitSensitive(output map[string]interface{}, keys []string) (map[string]interface{}, map[string]interface{}) {
+	if len(keys) == 0 {
+		return output, nil
+	}
+	return splitSensitive("", output, keys)
+}
+
+func splitSensitive(p string, output map[string]interface{}, keys []string) (map[string]interface{}, map[string]interface{}) {
+	var public, sensitive map[string]interface{}
+	for k, v := range output {
+		kp := joinKeyPath(p, k)
+		if matchesSensitive(kp, keys) {
+			if sensitive == nil {
+				sensitive = map[string]interface{}{}
+			}
+			sensitive[k] = v
+			continue
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			nestedPublic, nestedSensitive := splitSensitive(kp, nested, keys)
+			if nestedSensitive != nil {
+				if sensitive == nil {
+					sensitive = map[string]interface{}{}
+				}
+				sensitive[k] = nestedSensitive
+				if nestedPublic == nil {
+					continue
+				}
+				v = nestedPublic
+			}
+		}
+		if public == nil {
+			public = map[string]interface{}{}
+		}
+		public[k] = v
+	}
+	return public, sensitive
+}
+
+// MergeSensitive is the inverse of SplitSensitive, merging the sensitive
+// values back into output.
+func MergeSensitive(output, sensitive map[string]interface{}) map[string]interface{} {
+	if output == nil {
+		return sensitive
+	}
+	for k, v := range sensitive {
+		nested, ok := v.(map[string]interface{})
+		existing, exists := output[k].(map[string]interface{})
+		if ok && exists {
+			output[k] = MergeSensitive(existing, nested)
+			continue
+		}
+		output[k] = v
+	}
+	return output
+}
+
+// LeafKeyPaths returns the sorted dot-separated key paths of the non-object
+// values in value.
+func LeafKeyPaths(value interface{}) []string {
+	var paths []string
+	var walk func(p string, value interface{})
+	walk = func(p string, value interface{}) {
+		m, ok := value.(map[string]interface{})
+		if !ok || len(m) == 0 {
+			if p != "" {
+				paths = append(paths, p)
+			}
+			return
+		}
+		for k, v := range m {
+			walk(joinKeyPath(p, k), v)
+		}
+	}
+	walk("", value)
+	sort.Strings(paths)
+	return paths
+}
+
+// sensitiveKeys removes the SensitiveKey metadata from value and returns the
+// key paths it lists.
+func sensitiveKeys(value map[string]interface{}) ([]string, error) {
+	raw, ok := value[SensitiveKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(value, SensitiveKey)
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of output key paths, got %T", SensitiveKey, raw)
+	}
+	keys := make([]string, 0, len(list))
+	for _, elem := range list {
+		key, ok := elem.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of output key paths, got element %v", SensitiveKey, elem)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Mask hides the sensitive values of the execution in s, which is usually
+// its payload, stdout or stderr.
+func (r *ExecutionResult) Mask(s string) string {
+	if r == nil || len(r.masked) == 0 && len(r.secrets) == 0 {
+		return s
+	}
+	s = MaskJSONString(s, r.masked)
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, MaskedValue)
+	}
+	return s
+}
+
+// maskCommand returns cmd with the secrets of the result masked, for logging
+// commands rendered from the payload.
+func (r *ExecutionResult) maskCommand(cmd []string) []string {
+	masked := make([]string, len(cmd))
+	for i, arg := range cmd {
+		for _, secret := range r.secrets {
+			arg = strings.ReplaceAll(arg, secret, MaskedValue)
+		}
+		masked[i] = arg
+	}
+	return masked
+}
+
+// maskPayload marks the payload values at the given key paths, and at the key
+// paths the payload lists itself, as sensitive.
+func (r *ExecutionResult) maskPayload(payload ExecutionPayload, keys []string) {
+	r.masked = append(append(r.masked, keys...), payload.Sensitive...)
+	if len(r.masked) == 0 {
+		return
+	}
+	if input, ok := payload.Input.(map[string]interface{}); ok {
+		r.secrets = sensitiveStrings("input", input, r.masked, false, r.secrets)
+	}
+	if output, ok := payload.Output.(map[string]interface{}); ok {
+		r.secrets = sensitiveStrings("output", output, r.masked, false, r.secrets)
+	}
+	if private, ok := payload.Private.(map[string]interface{}); ok {
+		r.secrets = sensitiveStrings(PrivateKey, private, r.masked, matchesSensitive(PrivateKey, r.masked), r.secrets)
+	}
+}
+
+// markSensitive records the key paths listed by the script in values on the
+// result, together with the secrets values holds at any masked key path.
+// Unless annotated is set the __sensitive key is a regular output key.
+func (r *ExecutionResult) markSensitive(annotated bool, values ...map[string]interface{}) error {
+	for _, value := range values {
+		if value == nil || !annotated {
+			continue
+		}
+		keys, err := sensitiveKeys(value)
+		if err != nil {
+			return err
+		}
+		r.Sensitive = append(r.Sensitive, keys...)
+	}
+	r.masked = append(r.masked, r.Sensitive...)
+	if len(r.masked) == 0 {
+		return nil
+	}
+	for _, value := range values {
+		r.secrets = sensitiveStrings("", value, r.masked, false, r.secrets)
+	}
+	return nil
+}
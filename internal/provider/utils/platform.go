@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Platforms is the hooks block attribute that overrides hook attributes per
+// operating system, e.g. { windows = { create = "pwsh -File create.ps1" } }.
+const Platforms = "platforms"
+
+// PlatformNames are the operating systems the platforms attribute may name,
+// as reported by runtime.GOOS.
+var PlatformNames = []string{
+	"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "js",
+	"linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows",
+}
+
+// PlatformAttributes returns the attributes of a hooks block with the string
+// attributes its platforms attribute sets for goos in place of their
+// defaults. It returns an error when they name an attribute the block
+// doesn't have as a string.
+func PlatformAttributes(attrs map[string]attr.Value, goos string) (map[string]attr.Value, error) {
+	platforms, ok := attrs[Platforms].(types.Map)
+	if !ok || platforms.IsNull() || platforms.IsUnknown() {
+		return attrs, nil
+	}
+	overrides, ok := platforms.Elements()[goos].(types.Map)
+	if !ok || overrides.IsNull() {
+		return attrs, nil
+	}
+	result := make(map[string]attr.Value, len(attrs))
+	for name, value := range attrs {
+		result[name] = value
+	}
+	for name, value := range overrides.Elements() {
+		if _, ok := attrs[name].(types.String); !ok {
+			return nil, fmt.Errorf("platforms entry %s sets %q, which isn't a hook command or string attribute of the hooks block", goos, name)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
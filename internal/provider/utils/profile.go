@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Phases of an operation timed by a Profile.
+const (
+	// ProfileWait is the time spent waiting on the parallelism limits.
+	ProfileWait = "wait"
+	// ProfilePayload is the time spent building the hook payload.
+	ProfilePayload = "payload"
+	// ProfileExec is the time the hook ran.
+	ProfileExec = "exec"
+	// ProfileParse is the time spent parsing the hook output.
+	ProfileParse = "parse"
+	// ProfileState is the time spent converting the output and setting
+	// state once the last hook returned.
+	ProfileState = "state"
+)
+
+// Profiler appends the timing breakdown of every operation to a file, one
+// JSON object per line, to tell slow scripts from slow conversions.
+type Profiler struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewProfiler returns a profiler appending to the file at path.
+func NewProfiler(path string) *Profiler {
+	return &Profiler{path: path}
+}
+
+// Profile times the phases of a single operation. Its methods do nothing on
+// a nil Profile, which is what a nil Profiler starts.
+type Profile struct {
+	profiler  *Profiler
+	kind      string
+	operation string
+
+	mu      sync.Mutex
+	id      string
+	command string
+	start   time.Time
+	last    time.Time
+	phases  map[string]time.Duration
+}
+
+type profileKey struct{}
+
+// Start starts profiling an operation of a kind of object and returns ctx
+// carrying the profile, so that Execute can time the hooks it runs.
+func (p *Profiler) Start(ctx context.Context, kind, operation string) (context.Context, *Profile) {
+	if p == nil {
+		return ctx, nil
+	}
+	now := Now()
+	profile := &Profile{
+		profiler:  p,
+		kind:      kind,
+		operation: operation,
+		start:     now,
+		last:      now,
+		phases:    map[string]time.Duration{},
+	}
+	return context.WithValue(ctx, profileKey{}, profile), profile
+}
+
+// profileFrom returns the profile carried by ctx, if any.
+func profileFrom(ctx context.Context) *Profile {
+	profile, _ := ctx.Value(profileKey{}).(*Profile)
+	return profile
+}
+
+// Mark adds the time since the previous mark to phase. Phases marked several
+// times, such as the exec phase of retried hooks, add up.
+func (p *Profile) Mark(phase string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := Now()
+	p.phases[phase] += now.Sub(p.last)
+	p.last = now
+}
+
+// hook records the hook the operation runs, the last one when it runs several.
+func (p *Profile) hook(command, id string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.command = command
+	if id != "" {
+		p.id = id
+	}
+}
+
+// profileRecord is a line of the profile file, with durations in milliseconds.
+type profileRecord struct {
+	Time      string             `json:"time"`
+	Kind      string             `json:"kind"`
+	Operation string             `json:"operation"`
+	Id        string             `json:"id,omitempty"`
+	Command   string             `json:"command,omitempty"`
+	TotalMs   float64            `json:"total_ms"`
+	PhasesMs  map[string]float64 `json:"phases_ms"`
+}
+
+// Finish marks the rest of the operation as the state phase and appends the
+// breakdown to the profile file. Failing to write it doesn't fail the
+// operation.
+func (p *Profile) Finish() {
+	if p == nil {
+		return
+	}
+	p.Mark(ProfileState)
+	p.mu.Lock()
+	record := profileRecord{
+		Time:      p.start.UTC().Format(time.RFC3339Nano),
+		Kind:      p.kind,
+		Operation: p.operation,
+		Id:        p.id,
+		Command:   p.command,
+		TotalMs:   milliseconds(p.last.Sub(p.start)),
+		PhasesMs:  make(map[string]float64, len(p.phases)),
+	}
+	for phase, d := range p.phases {
+		record.PhasesMs[phase] = milliseconds(d)
+	}
+	p.mu.Unlock()
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	p.profiler.mu.Lock()
+	defer p.profiler.mu.Unlock()
+	f, err := os.OpenFile(p.profiler.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.Write(append(line, '\n'))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
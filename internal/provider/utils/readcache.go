@@ -0,0 +1,108 @@
+package utils
+
+import "sync"
+
+// ReadCache shares read hook results between the resources and data sources
+// of a provider instance that declare the same shared_read_key. Terraform
+// starts a provider instance per operation, so a result is shared within a
+// single plan or apply.
+type ReadCache struct {
+	mu      sync.Mutex
+	entries map[string]*readEntry
+}
+
+type readEntry struct {
+	done   chan struct{}
+	result *ExecutionResult
+}
+
+// NewReadCache returns an empty ReadCache.
+func NewReadCache() *ReadCache {
+	return &ReadCache{entries: map[string]*readEntry{}}
+}
+
+// Read returns the result cached for key, running read when there is none.
+// Concurrent reads of the same key wait for the first one. Failed reads
+// aren't cached, the waiting reads then run read themselves. A nil cache or
+// an empty key always runs read.
+func (c *ReadCache) Read(key string, read func() (*ExecutionResult, bool)) (*ExecutionResult, bool) {
+	if c == nil || key == "" {
+		return read()
+	}
+	var entry *readEntry
+	for entry == nil {
+		c.mu.Lock()
+		existing, exists := c.entries[key]
+		if !exists {
+			entry = &readEntry{done: make(chan struct{})}
+			c.entries[key] = entry
+		}
+		c.mu.Unlock()
+		if exists {
+			<-existing.done
+			if existing.result != nil {
+				return existing.result.clone(), true
+			}
+		}
+	}
+
+	result, ok := read()
+	c.mu.Lock()
+	if ok && result != nil {
+		entry.result = result.clone()
+	} else {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+	return result, ok
+}
+
+// Forget drops the result cached for key, after a hook changed the object.
+func (c *ReadCache) Forget(key string) {
+	if c == nil || key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, exists := c.entries[key]; exists && entry.result != nil {
+		delete(c.entries, key)
+	}
+}
+
+// clone copies r so that callers may modify the result and its output.
+func (r *ExecutionResult) clone() *ExecutionResult {
+	copied := *r
+	copied.Result, _ = copyValue(r.Result).(map[string]interface{})
+	copied.State, _ = copyValue(r.State).(map[string]interface{})
+	copied.Sensitive = append([]string(nil), r.Sensitive...)
+	copied.masked = append([]string(nil), r.masked...)
+	copied.secrets = append([]string(nil), r.secrets...)
+	return &copied
+}
+
+// copyValue deep copies decoded JSON.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		copied := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			copied[k] = copyValue(val)
+		}
+		return copied
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		copied := make([]interface{}, len(v))
+		for i, val := range v {
+			copied[i] = copyValue(val)
+		}
+		return copied
+	default:
+		return value
+	}
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// HookRewrites replaces the leading path of the programs of the hooks stored
+// in state, such as the directory of scripts that moved, so that refreshing
+// and destroying existing resources runs the scripts at their new paths.
+type HookRewrites struct {
+	olds     []string
+	rewrites map[string]string
+}
+
+// NewHookRewrites returns the rewrites of the old to new path prefixes of
+// rewrites, or nil if there are none. Longer prefixes are tried first, so a
+// rewrite of a subdirectory wins over one of its parent.
+func NewHookRewrites(rewrites map[string]string) *HookRewrites {
+	olds := make([]string, 0, len(rewrites))
+	for old := range rewrites {
+		if old != "" {
+			olds = append(olds, old)
+		}
+	}
+	if len(olds) == 0 {
+		return nil
+	}
+	sort.Slice(olds, func(i, j int) bool {
+		if len(olds[i]) != len(olds[j]) {
+			return len(olds[i]) > len(olds[j])
+		}
+		return olds[i] < olds[j]
+	})
+	return &HookRewrites{olds: olds, rewrites: rewrites}
+}
+
+// rewrite returns value with the first of the old prefixes it starts with
+// replaced. Values already starting with the replacement are left alone, so
+// rewriting is idempotent even when the replacement starts with the prefix.
+func (h *HookRewrites) rewrite(value string) string {
+	for _, old := range h.olds {
+		if !strings.HasPrefix(value, old) {
+			continue
+		}
+		if strings.HasPrefix(value, h.rewrites[old]) {
+			return value
+		}
+		return h.rewrites[old] + strings.TrimPrefix(value, old)
+	}
+	return value
+}
+
+// Apply returns hooks with the rewrites applied to the hook commands, the
+// first argument of the argv entries and the working_directory of the hooks
+// block, and whether any of them changed. Inline scripts are left alone.
+func (h *HookRewrites) Apply(ctx context.Context, hooks types.List) (types.List, bool) {
+	if h == nil || hooks.IsNull() || hooks.IsUnknown() {
+		return hooks, false
+	}
+	changed := false
+	elements := hooks.Elements()
+	rewritten := make([]attr.Value, len(elements))
+	for i, element := range elements {
+		rewritten[i] = element
+		obj, ok := element.(types.Object)
+		if !ok || obj.IsNull() || obj.IsUnknown() {
+			continue
+		}
+		attrs := obj.Attributes()
+		newAttrs := make(map[string]attr.Value, len(attrs))
+		objChanged := false
+		for name, value := range attrs {
+			newAttrs[name] = value
+			if name == Argv {
+				if argv, ok := h.rewriteArgv(ctx, value); ok {
+					newAttrs[name] = argv
+					objChanged = true
+				}
+				continue
+			}
+			s, ok := value.(types.String)
+			if !ok || s.IsNull() || s.IsUnknown() || strings.HasSuffix(name, ScriptSuffix) {
+				continue
+			}
+			if replaced := h.rewrite(s.ValueString()); replaced != s.ValueString() {
+				newAttrs[name] = types.StringValue(replaced)
+				objChanged = true
+			}
+		}
+		if !objChanged {
+			continue
+		}
+		newObj, diags := types.ObjectValue(obj.AttributeTypes(ctx), newAttrs)
+		if diags.HasError() {
+			return hooks, false
+		}
+		rewritten[i] = newObj
+		changed = true
+	}
+	if !changed {
+		return hooks, false
+	}
+	list, diags := types.ListValue(hooks.ElementType(ctx), rewritten)
+	if diags.HasError() {
+		return hooks, false
+	}
+	return list, true
+}
+
+// rewriteArgv returns the argv map of a hooks block with the program of each
+// entry rewritten, and whether any of them changed.
+func (h *HookRewrites) rewriteArgv(ctx context.Context, value attr.Value) (attr.Value, bool) {
+	argv, ok := value.(types.Map)
+	if !ok || argv.IsNull() || argv.IsUnknown() {
+		return value, false
+	}
+	changed := false
+	entries := make(map[string]attr.Value, len(argv.Elements()))
+	for hook, entry := range argv.Elements() {
+		entries[hook] = entry
+		args, ok := entry.(types.List)
+		if !ok || args.IsNull() || args.IsUnknown() || len(args.Elements()) == 0 {
+			continue
+		}
+		program, ok := args.Elements()[0].(types.String)
+		if !ok || program.IsNull() || program.IsUnknown() {
+			continue
+		}
+		replaced := h.rewrite(program.ValueString())
+		if replaced == program.ValueString() {
+			continue
+		}
+		elements := append([]attr.Value{types.StringValue(replaced)}, args.Elements()[1:]...)
+		list, diags := types.ListValue(args.ElementType(ctx), elements)
+		if diags.HasError() {
+			return value, false
+		}
+		entries[hook] = list
+		changed = true
+	}
+	if !changed {
+		return value, false
+	}
+	rewritten, diags := types.MapValue(argv.ElementType(ctx), entries)
+	if diags.HasError() {
+		return value, false
+	}
+	return rewritten, true
+}
@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// OutputSchema is the Terraform type hook output is coerced to, so that its
+// representation doesn't depend on the values a run happens to return.
+type OutputSchema struct {
+	ty cty.Type
+}
+
+// ParseOutputSchema parses a type expression in HCL syntax, such as
+// object({name = string, ports = optional(list(number))}), or in the cty JSON
+// type syntax, such as ["object", {"name": "string"}]. Hook output is an
+// object, so the type must be an object or a map.
+func ParseOutputSchema(s string) (*OutputSchema, error) {
+	s = strings.TrimSpace(s)
+	var ty cty.Type
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, `"`) {
+		t, err := ctyjson.UnmarshalType([]byte(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON type: %w", err)
+		}
+		ty = t
+	} else {
+		expr, diags := hclsyntax.ParseExpression([]byte(s), "output_schema", hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid type expression: %s", diags.Error())
+		}
+		t, diags := typeexpr.TypeConstraint(expr)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid type expression: %s", diags.Error())
+		}
+		ty = t
+	}
+	if !ty.IsObjectType() && !ty.IsMapType() {
+		return nil, fmt.Errorf("the output type must be an object or a map, got %s", ty.FriendlyName())
+	}
+	return &OutputSchema{ty: ty}, nil
+}
+
+// Convert coerces output to the schema type. Object attributes missing from
+// output are null, unless the type requires them, and extra ones are dropped.
+// Strings, numbers and booleans are converted into each other where
+// Terraform would convert them.
+func (s *OutputSchema) Convert(output map[string]interface{}) (types.Dynamic, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	value := convertToType(path.Empty(), output, s.ty, false, &diags)
+	return types.DynamicValue(value), diags
+}
+
+// ConvertPart is Convert for a part of the output, such as its sensitive
+// values, where every object attribute may be missing.
+func (s *OutputSchema) ConvertPart(output map[string]interface{}) (types.Dynamic, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	value := convertToType(path.Empty(), output, s.ty, true, &diags)
+	return types.DynamicValue(value), diags
+}
+
+// frameworkType returns the framework type of ty.
+func frameworkType(ty cty.Type) attr.Type {
+	switch {
+	case ty == cty.String:
+		return types.StringType
+	case ty == cty.Number:
+		return types.NumberType
+	case ty == cty.Bool:
+		return types.BoolType
+	case ty.IsListType():
+		return types.ListType{ElemType: frameworkType(ty.ElementType())}
+	case ty.IsSetType():
+		return types.SetType{ElemType: frameworkType(ty.ElementType())}
+	case ty.IsMapType():
+		return types.MapType{ElemType: frameworkType(ty.ElementType())}
+	case ty.IsObjectType():
+		attrTypes := map[string]attr.Type{}
+		for name, attrType := range ty.AttributeTypes() {
+			attrTypes[name] = frameworkType(attrType)
+		}
+		return types.ObjectType{AttrTypes: attrTypes}
+	case ty.IsTupleType():
+		elemTypes := make([]attr.Type, len(ty.TupleElementTypes()))
+		for i, elemType := range ty.TupleElementTypes() {
+			elemTypes[i] = frameworkType(elemType)
+		}
+		return types.TupleType{ElemTypes: elemTypes}
+	default:
+		return types.DynamicType
+	}
+}
+
+// nullOf returns the null value of ty.
+func nullOf(ty cty.Type) attr.Value {
+	t := frameworkType(ty)
+	ctx := context.Background()
+	value, err := t.ValueFromTerraform(ctx, tftypes.NewValue(t.TerraformType(ctx), nil))
+	if err != nil {
+		return types.DynamicNull()
+	}
+	return value
+}
+
+// unknownOf returns the unknown value of ty.
+func unknownOf(ty cty.Type) attr.Value {
+	t := frameworkType(ty)
+	ctx := context.Background()
+	value, err := t.ValueFromTerraform(ctx, tftypes.NewValue(t.TerraformType(ctx), tftypes.UnknownValue))
+	if err != nil {
+		return types.DynamicUnknown()
+	}
+	return value
+}
+
+func convertToType(p path.Path, data interface{}, ty cty.Type, partial bool, diags *diag.Diagnostics) attr.Value {
+	if _, ok := data.(unknownValue); ok {
+		return unknownOf(ty)
+	}
+	if ty == cty.DynamicPseudoType {
+		return types.DynamicValue(toAttrValue(p, data, nil, false, diags))
+	}
+	if data == nil {
+		return nullOf(ty)
+	}
+	fail := func(detail string) attr.Value {
+		addConversionError(diags, p, detail)
+		return nullOf(ty)
+	}
+
+	switch {
+	case ty == cty.String:
+		switch v := data.(type) {
+		case string:
+			return types.StringValue(v)
+		case float64:
+			return types.StringValue(strconv.FormatFloat(v, 'f', -1, 64))
+		case json.Number, int, bool:
+			return types.StringValue(fmt.Sprint(v))
+		}
+		return fail(fmt.Sprintf("expected a string, got %s", describeValue(data)))
+	case ty == cty.Number:
+		switch v := data.(type) {
+		case float64, json.Number, int:
+			return toAttrValue(p, v, nil, false, diags)
+		case string:
+			f, _, err := big.ParseFloat(strings.TrimSpace(v), 10, 512, big.ToNearestEven)
+			if err != nil {
+				return fail(fmt.Sprintf("expected a number, got %q", v))
+			}
+			return types.NumberValue(f)
+		}
+		return fail(fmt.Sprintf("expected a number, got %s", describeValue(data)))
+	case ty == cty.Bool:
+		switch v := data.(type) {
+		case bool:
+			return types.BoolValue(v)
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil && (v == "true" || v == "false") {
+				return types.BoolValue(b)
+			}
+			return fail(fmt.Sprintf("expected a bool, got %q", v))
+		}
+		return fail(fmt.Sprintf("expected a bool, got %s", describeValue(data)))
+	case ty.IsListType(), ty.IsSetType():
+		list, ok := data.([]interface{})
+		if !ok {
+			return fail(fmt.Sprintf("expected a list, got %s", describeValue(data)))
+		}
+		elemType := frameworkType(ty.ElementType())
+		elements := make([]attr.Value, len(list))
+		for i, elem := range list {
+			elements[i] = convertToType(p.AtListIndex(i), elem, ty.ElementType(), partial, diags)
+		}
+		var value attr.Value
+		var d diag.Diagnostics
+		if ty.IsSetType() {
+			value, d = types.SetValue(elemType, elements)
+		} else {
+			value, d = types.ListValue(elemType, elements)
+		}
+		if d.HasError() {
+			return fail(summary(d))
+		}
+		return value
+	case ty.IsMapType():
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return fail(fmt.Sprintf("expected an object, got %s", describeValue(data)))
+		}
+		elements := make(map[string]attr.Value, len(object))
+		for k, v := range object {
+			elements[k] = convertToType(p.AtMapKey(k), v, ty.ElementType(), partial, diags)
+		}
+		value, d := types.MapValue(frameworkType(ty.ElementType()), elements)
+		if d.HasError() {
+			return fail(summary(d))
+		}
+		return value
+	case ty.IsObjectType():
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return fail(fmt.Sprintf("expected an object, got %s", describeValue(data)))
+		}
+		attrTypes := map[string]attr.Type{}
+		attrs := map[string]attr.Value{}
+		for name, attrType := range ty.AttributeTypes() {
+			attrTypes[name] = frameworkType(attrType)
+			v, exists := object[name]
+			if !exists && !partial && !ty.AttributeOptional(name) {
+				addConversionError(diags, p.AtName(name), "the attribute is required by output_schema")
+			}
+			attrs[name] = convertToType(p.AtName(name), v, attrType, partial, diags)
+		}
+		value, d := types.ObjectValue(attrTypes, attrs)
+		if d.HasError() {
+			return fail(summary(d))
+		}
+		return value
+	case ty.IsTupleType():
+		list, ok := data.([]interface{})
+		elemTypes := ty.TupleElementTypes()
+		if !ok || len(list) != len(elemTypes) {
+			return fail(fmt.Sprintf("expected a list of %d elements, got %s", len(elemTypes), describeValue(data)))
+		}
+		frameworkTypes := make([]attr.Type, len(elemTypes))
+		elements := make([]attr.Value, len(list))
+		for i, elem := range list {
+			frameworkTypes[i] = frameworkType(elemTypes[i])
+			elements[i] = convertToType(p.AtListIndex(i), elem, elemTypes[i], partial, diags)
+		}
+		value, d := types.TupleValue(frameworkTypes, elements)
+		if d.HasError() {
+			return fail(summary(d))
+		}
+		return value
+	}
+	return fail(fmt.Sprintf("unsupported type %s", ty.FriendlyName()))
+}
+
+// describeValue names the JSON type of a decoded value for error messages.
+func describeValue(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "a list"
+	case string:
+		return "a string"
+	case bool:
+		return "a bool"
+	default:
+		return "a number"
+	}
+}
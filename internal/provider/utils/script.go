@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// ScriptSuffix ends the names of the hooks block attributes holding inline
+// scripts, e.g. create_script.
+const ScriptSuffix = "_script"
+
+// Scripts returns the non-empty inline scripts among the attributes of a
+// hooks block, by hook name.
+func Scripts(attrs map[string]attr.Value) map[string]string {
+	scripts := map[string]string{}
+	for name, value := range attrs {
+		hook, ok := strings.CutSuffix(name, ScriptSuffix)
+		if s, isString := value.(types.String); ok && isString && strings.TrimSpace(s.ValueString()) != "" {
+			scripts[hook] = s.ValueString()
+		}
+	}
+	return scripts
+}
+
+// WriteScript writes an inline hook script to a temporary executable file and
+// returns the file with the command running it. The file is removed with
+// RemoveTempFile once the hook has run.
+func WriteScript(script string) (string, []string, error) {
+	script = normalizeScript(script, runtime.GOOS)
+	pattern := "script-*"
+	if runtime.GOOS == "windows" && !strings.HasPrefix(script, "#!") {
+		pattern += ".cmd"
+	}
+	f, err := CreateTempFile(pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create the script file: %w", err)
+	}
+	_, err = f.WriteString(script)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o700)
+	}
+	if err != nil {
+		_ = RemoveTempFile(f.Name())
+		return "", nil, fmt.Errorf("failed to write the script file: %w", err)
+	}
+	return f.Name(), ScriptCommandOn(f.Name(), script, runtime.GOOS), nil
+}
+
+// normalizeScript drops the blank lines before the shebang of script, which
+// heredocs easily start with, and outside Windows the carriage returns that
+// would end up in the interpreter name.
+func normalizeScript(script, goos string) string {
+	if goos != "windows" {
+		script = strings.ReplaceAll(script, "\r\n", "\n")
+	}
+	if trimmed := strings.TrimLeft(script, " \t\r\n"); strings.HasPrefix(trimmed, "#!") {
+		return trimmed
+	}
+	return script
+}
+
+// ScriptCommandOn returns the command running the script saved to file on
+// goos. Scripts with a shebang run by themselves, except on Windows, where
+// the interpreter it names is run with the file, e.g. python3 for
+// #!/usr/bin/env python3. Other scripts run with sh, or cmd /c on Windows.
+func ScriptCommandOn(file, script, goos string) []string {
+	if !strings.HasPrefix(script, "#!") {
+		if goos == "windows" {
+			return []string{"cmd", "/c", file}
+		}
+		return []string{"sh", file}
+	}
+	if goos != "windows" {
+		return []string{file}
+	}
+	line, _, _ := strings.Cut(script[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return []string{"cmd", "/c", file}
+	}
+	program := fields[0][strings.LastIndexAny(fields[0], `/\`)+1:]
+	if program == "env" && len(fields) > 1 {
+		fields = fields[1:]
+	} else {
+		fields[0] = program
+	}
+	return append(fields, file)
+}
+
+// isRemote reports whether executor runs hooks on another machine, which
+// can't see the files the provider writes.
+func isRemote(executor Executor) bool {
+	switch executor.(type) {
+	case *dockerExecutor, *sshExecutor, *httpExecutor:
+		return true
+	}
+	return false
+}
+
+// TargetOS returns the operating system executor runs hooks on, named like
+// runtime.GOOS. Remote executors are taken to run them on Linux, where
+// containers and ssh hosts usually run.
+func TargetOS(executor Executor) string {
+	if isRemote(executor) {
+		return "linux"
+	}
+	return runtime.GOOS
+}
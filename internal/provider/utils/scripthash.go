@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ScriptHash returns the hex SHA-256 of the inline scripts of the hooks and
+// of the files named by the arguments of commands, both by hook name, or ""
+// when there is nothing to hash. Relative files are looked up in dir. The
+// program is only hashed when it's given as a path, e.g. ./create.sh rather
+// than python3, and arguments that aren't existing regular files or hold
+// template actions are left out.
+func ScriptHash(scripts map[string]string, commands map[string][]string, dir string) string {
+	h := sha256.New()
+	hashed := false
+	for _, hook := range sortedKeys(scripts) {
+		writeHashEntry(h, hook+ScriptSuffix, []byte(scripts[hook]))
+		hashed = true
+	}
+	for _, hook := range sortedKeys(commands) {
+		for i, arg := range commands[hook] {
+			if (i == 0 && !strings.ContainsAny(arg, `/\`)) || strings.Contains(arg, "{{") {
+				continue
+			}
+			file := arg
+			if !filepath.IsAbs(file) && dir != "" {
+				file = filepath.Join(dir, file)
+			}
+			if info, err := os.Stat(file); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			writeHashEntry(h, hook+" "+arg, data)
+			hashed = true
+		}
+	}
+	if !hashed {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHashEntry adds the named content to h, length-prefixed so that
+// entries can't run into each other.
+func writeHashEntry(h hash.Hash, name string, content []byte) {
+	fmt.Fprintf(h, "%d:%s%d:", len(name), name, len(content))
+	h.Write(content)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
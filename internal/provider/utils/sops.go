@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/getsops/sops/v3/decrypt"
+)
+
+// sopsDocument returns the format of s when it's a JSON or YAML document
+// encrypted with SOPS (https://getsops.io), which carries its keys and MAC
+// under the top-level sops key.
+func sopsDocument(s string) (string, bool) {
+	if !strings.Contains(s, "sops") || !strings.Contains(s, "mac") {
+		return "", false
+	}
+	data, err := yamlToJSON([]byte(s))
+	if err != nil {
+		return "", false
+	}
+	var document map[string]interface{}
+	if err := DecodeJSON(data, &document); err != nil {
+		return "", false
+	}
+	metadata, ok := document["sops"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	if _, ok := metadata["mac"]; !ok {
+		return "", false
+	}
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return "json", true
+	}
+	return "yaml", true
+}
+
+// IsSOPSEncrypted reports whether s is a JSON or YAML document encrypted
+// with SOPS.
+func IsSOPSEncrypted(s string) bool {
+	_, ok := sopsDocument(s)
+	return ok
+}
+
+// DecryptSOPS decrypts the SOPS document s with the keys SOPS finds itself,
+// such as the age identities of SOPS_AGE_KEY_FILE or cloud KMS credentials,
+// and returns the decrypted document together with its strings.
+func DecryptSOPS(s string) (interface{}, []string, error) {
+	format, ok := sopsDocument(s)
+	if !ok {
+		return nil, nil, fmt.Errorf("not a SOPS document")
+	}
+	plaintext, err := decrypt.Data([]byte(s), format)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := yamlToJSON(plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the decrypted document: %w", err)
+	}
+	var document interface{}
+	if err := DecodeJSON(data, &document); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the decrypted document: %w", err)
+	}
+	var secrets []string
+	var collect func(value interface{})
+	collect = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, val := range v {
+				collect(val)
+			}
+		case []interface{}:
+			for _, val := range v {
+				collect(val)
+			}
+		case string:
+			if v != "" {
+				secrets = append(secrets, v)
+			}
+		}
+	}
+	collect(document)
+	return document, secrets, nil
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// Status is the hook polled for the outcome of an operation that create,
+// update or delete started without waiting for it.
+const Status = "status"
+
+// StatusInterval and StatusTimeout are the hooks block attributes setting how
+// often the status hook is polled and for how long, as durations like "30s".
+const (
+	StatusInterval = "status_interval"
+	StatusTimeout  = "status_timeout"
+)
+
+// Defaults of status_interval and status_timeout.
+const (
+	DefaultStatusInterval = 10 * time.Second
+	DefaultStatusTimeout  = 30 * time.Minute
+)
+
+// OperationIdKey is the output key with which create, update and delete hooks
+// report an operation still running, e.g. {"operation_id": "op-123"}.
+const OperationIdKey = "operation_id"
+
+// Values of the status key printed by the status hook.
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// OperationInfo tells the status hook which operation to look up.
+type OperationInfo struct {
+	// Id is the operation_id the hook starting the operation printed.
+	Id string `json:"id"`
+	// Hook is the hook that started it, create, update or delete.
+	Hook string `json:"hook"`
+	// Attempt counts the polls, starting at 1.
+	Attempt int `json:"attempt"`
+}
+
+// ParseDuration parses a duration attribute like "10s" or "5m", returning def
+// when it's null or empty.
+func ParseDuration(value types.String, def time.Duration) (time.Duration, error) {
+	if value.IsNull() || value.IsUnknown() || value.ValueString() == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value.ValueString())
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration %s is negative", value.ValueString())
+	}
+	return d, nil
+}
+
+// operationId returns the operation_id of result, if it reports one.
+func operationId(result *ExecutionResult) (string, bool) {
+	id, ok := result.Result[OperationIdKey]
+	if !ok || id == nil || fmt.Sprintf("%v", id) == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%v", id), true
+}
+
+// pollStatus polls the status hook for the operation started by op until it
+// reports it done or failed, or the status_timeout passes. The result of a
+// finished operation is the output of op without operation_id, overlaid with
+// the status hook output without status.
+func pollStatus(ctx context.Context, config CustomCRUDProviderConfig, crud *CrudHooks, model CrudModel, payload ExecutionPayload, diagnostics *diag.Diagnostics, op CrudOp, started *ExecutionResult, id string) (*ExecutionResult, bool) {
+	summary := fmt.Sprintf("%s Status Failed", cases.Title(language.English).String(op.String()))
+	interval, err := ParseDuration(crud.StatusInterval, DefaultStatusInterval)
+	timeout := DefaultStatusTimeout
+	if err == nil {
+		timeout, err = ParseDuration(crud.StatusTimeout, DefaultStatusTimeout)
+	}
+	if err != nil {
+		diagnostics.AddError(summary, fmt.Sprintf("Invalid status polling duration: %v", err))
+		return started, false
+	}
+	deadline := Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		tflog.Info(ctx, "Polling the status hook", map[string]interface{}{
+			"operation_id": id,
+			"hook":         op.String(),
+			"attempt":      attempt,
+		})
+		payload.Operation = &OperationInfo{Id: id, Hook: op.String(), Attempt: attempt}
+		result, ok := RunCrudScript(ctx, config, model, payload, diagnostics, CrudStatus)
+		if !ok {
+			return result, false
+		}
+		switch status := result.Result[Status]; status {
+		case StatusDone:
+			output := make(map[string]interface{}, len(started.Result)+len(result.Result))
+			for key, value := range started.Result {
+				if key != OperationIdKey {
+					output[key] = value
+				}
+			}
+			for key, value := range result.Result {
+				if key != Status {
+					output[key] = value
+				}
+			}
+			result.Result = output
+			result.Sensitive = append(append([]string{}, started.Sensitive...), result.Sensitive...)
+			return result, true
+		case StatusFailed:
+			message, _ := result.Result["error"].(string)
+			if message == "" {
+				message = "the status hook reported the operation failed"
+			}
+			diagnostics.AddError(summary, fmt.Sprintf("Operation %s of the %v hook failed: %s", id, op, result.Mask(message)))
+			return result, false
+		case StatusPending:
+		default:
+			diagnostics.AddError(summary, fmt.Sprintf("The status hook must print a status of %s, %s or %s, got %v", StatusPending, StatusDone, StatusFailed, status))
+			return result, false
+		}
+		if !Now().Add(interval).Before(deadline) {
+			diagnostics.AddError(summary, fmt.Sprintf("Operation %s of the %v hook didn't finish within the status_timeout of %s", id, op, timeout))
+			return result, false
+		}
+		if !Sleep(ctx, interval) {
+			diagnostics.AddError(summary, fmt.Sprintf("Context cancelled while waiting for operation %s of the %v hook", id, op))
+			return result, false
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TempPrefix starts the name of every temporary file and directory the
+// provider creates, so that stale ones can be found again.
+const TempPrefix = "customcrud-"
+
+// DefaultTempFileMaxAge is the age past which temporary files left behind by
+// earlier provider processes are removed.
+const DefaultTempFileMaxAge = 24 * time.Hour
+
+var (
+	tempMu sync.Mutex
+	// tempFiles holds the temporary files and directories the provider
+	// created that haven't been removed yet.
+	tempFiles = map[string]bool{}
+	// keepTempFiles leaves the tracked files in place for debugging.
+	keepTempFiles bool
+)
+
+// CreateTempFile creates a temporary file like os.CreateTemp in the default
+// directory, with the TempPrefix added to pattern. The file is removed by
+// RemoveTempFiles, as hook output saved to it may hold secrets.
+func CreateTempFile(pattern string) (*os.File, error) {
+	f, err := os.CreateTemp("", TempPrefix+pattern)
+	if err != nil {
+		return nil, err
+	}
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	tempFiles[f.Name()] = true
+	return f, nil
+}
+
+// CreateTempDir creates a temporary directory like os.MkdirTemp in the
+// default directory, with the TempPrefix added to pattern. It is removed by
+// RemoveTempFiles along with the temporary files.
+func CreateTempDir(pattern string) (string, error) {
+	dir, err := os.MkdirTemp("", TempPrefix+pattern)
+	if err != nil {
+		return "", err
+	}
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	tempFiles[dir] = true
+	return dir, nil
+}
+
+// RemoveTempFile removes a temporary file created by CreateTempFile or
+// CreateTempDir before the provider exits.
+func RemoveTempFile(name string) error {
+	tempMu.Lock()
+	delete(tempFiles, name)
+	tempMu.Unlock()
+	if err := os.RemoveAll(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// KeepTempFiles makes RemoveTempFiles leave the temporary files in place, so
+// that the output saved to them can be inspected after the run.
+func KeepTempFiles(keep bool) {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	keepTempFiles = keep
+}
+
+// RemoveTempFiles removes every temporary file the provider created, unless
+// they are kept. It runs when the provider shuts down.
+func RemoveTempFiles() error {
+	tempMu.Lock()
+	if keepTempFiles {
+		tempMu.Unlock()
+		return nil
+	}
+	pending := tempFiles
+	tempFiles = map[string]bool{}
+	tempMu.Unlock()
+
+	var errs []error
+	for name := range pending {
+		if err := os.RemoveAll(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SweepTempFiles removes the temporary files and directories of the provider
+// in dir last modified more than maxAge ago, left behind by processes that
+// crashed or were killed before cleaning up. It returns the paths removed.
+// Files tracked by this process are left alone.
+func SweepTempFiles(dir string, maxAge time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	cutoff := Now().Add(-maxAge)
+	var removed []string
+	var errs []error
+	for _, entry := range entries {
+		name := filepath.Join(dir, entry.Name())
+		if !strings.HasPrefix(entry.Name(), TempPrefix) || tempFiles[name] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, name)
+	}
+	return removed, errors.Join(errs...)
+}
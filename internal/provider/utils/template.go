@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// renderCommand renders the Go template placeholders in the arguments of cmd,
+// such as --bucket={{.input.bucket}}, from the JSON payload of the hook, so
+// that CLIs taking arguments rather than stdin can be called directly.
+// Arguments are rendered after the command is split, so a value with spaces
+// stays one argument. Keys missing from the payload are an error.
+func renderCommand(cmd []string, payload []byte) ([]string, error) {
+	var data map[string]interface{}
+	rendered := make([]string, len(cmd))
+	for i, arg := range cmd {
+		if !strings.Contains(arg, "{{") {
+			rendered[i] = arg
+			continue
+		}
+		if data == nil {
+			if err := DecodeJSON(payload, &data); err != nil {
+				return nil, fmt.Errorf("failed to decode payload: %w", err)
+			}
+		}
+		tmpl, err := template.New("command").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse argument %d of the command: %w", i, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("failed to render argument %d of the command: %w", i, err)
+		}
+		rendered[i] = b.String()
+	}
+	return rendered, nil
+}
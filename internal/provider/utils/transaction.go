@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// Rollback undoes the create of a transaction group member, reporting
+// failures to diagnostics.
+type Rollback func(ctx context.Context, diagnostics *diag.Diagnostics)
+
+// TransactionGroups tracks the resources created in each transaction_group
+// of a provider instance, so that they can be rolled back when a create of
+// their group fails. Terraform starts a provider instance per operation, so a
+// group spans a single apply.
+type TransactionGroups struct {
+	mu     sync.Mutex
+	groups map[string]*transactionGroup
+}
+
+type transactionGroup struct {
+	failed    bool
+	rollbacks []Rollback
+}
+
+// NewTransactionGroups returns TransactionGroups without members.
+func NewTransactionGroups() *TransactionGroups {
+	return &TransactionGroups{groups: map[string]*transactionGroup{}}
+}
+
+// group returns the group named name, creating it if needed. It must be
+// called with the lock held.
+func (g *TransactionGroups) group(name string) *transactionGroup {
+	group, ok := g.groups[name]
+	if !ok {
+		group = &transactionGroup{}
+		g.groups[name] = group
+	}
+	return group
+}
+
+// Failed reports whether a create of the group failed. A nil
+// TransactionGroups or an empty name never fails.
+func (g *TransactionGroups) Failed(name string) bool {
+	if g == nil || name == "" {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.group(name).failed
+}
+
+// Join records the rollback of a member created in the group. It reports
+// false without recording it when the group failed in the meantime, in which
+// case the caller rolls the member back itself.
+func (g *TransactionGroups) Join(name string, rollback Rollback) bool {
+	if g == nil || name == "" {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	group := g.group(name)
+	if group.failed {
+		return false
+	}
+	group.rollbacks = append(group.rollbacks, rollback)
+	return true
+}
+
+// Fail marks the group as failed and returns the rollbacks of its members in
+// reverse creation order. Each rollback is returned once, by the first Fail.
+func (g *TransactionGroups) Fail(name string) []Rollback {
+	if g == nil || name == "" {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	group := g.group(name)
+	group.failed = true
+	rollbacks := make([]Rollback, 0, len(group.rollbacks))
+	for i := len(group.rollbacks) - 1; i >= 0; i-- {
+		rollbacks = append(rollbacks, group.rollbacks[i])
+	}
+	group.rollbacks = nil
+	return rollbacks
+}
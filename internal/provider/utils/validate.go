@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ValidationError is an error reported by a validate hook. Path is the
+// dot-separated input key path the error is about, empty for the input as a
+// whole.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Summary string `json:"summary"`
+	Detail  string `json:"detail"`
+}
+
+// DefaultValidationSummary is the summary of validation errors that don't
+// set one.
+const DefaultValidationSummary = "Invalid Input"
+
+// DefaultVerificationSummary is the summary of verification errors that
+// don't set one.
+const DefaultVerificationSummary = "Verification Failed"
+
+// ValidationErrors returns the errors printed by a failed validate hook,
+// either a single error object or an object with an errors list, e.g.
+//
+//	{"errors": [{"path": "network.cidr", "summary": "Invalid CIDR", "detail": "..."}]}
+//
+// Output that isn't such JSON becomes one error on the whole input, detailed
+// with the output itself, or with fallback when the hook printed nothing.
+func ValidationErrors(stdout, fallback string) []ValidationError {
+	return hookErrors(stdout, fallback, DefaultValidationSummary)
+}
+
+// VerificationErrors returns the errors printed by a failed verify hook like
+// ValidationErrors does, their paths naming output keys.
+func VerificationErrors(stdout, fallback string) []ValidationError {
+	return hookErrors(stdout, fallback, DefaultVerificationSummary)
+}
+
+// hookErrors parses the errors printed by a failed hook, giving those without
+// a summary the summary given.
+func hookErrors(stdout, fallback, summary string) []ValidationError {
+	var body struct {
+		ValidationError
+		Errors []ValidationError `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &body); err != nil {
+		detail := strings.TrimSpace(stdout)
+		if detail == "" {
+			detail = strings.TrimSpace(fallback)
+		}
+		return []ValidationError{{Summary: summary, Detail: detail}}
+	}
+	errs := body.Errors
+	if len(errs) == 0 {
+		errs = []ValidationError{body.ValidationError}
+	}
+	for i := range errs {
+		if errs[i].Summary == "" {
+			errs[i].Summary = summary
+		}
+	}
+	return errs
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// VerifyCommand checks that the executable of a hook command exists and can
+// be run by the local executor, so that a typo fails the plan rather than the
+// apply. Executables without a path separator are looked up in PATH, relative
+// paths are resolved against dir like the local executor does. Scripts run
+// through an interpreter on Windows, such as .ps1 scripts, need the
+// interpreter in PATH instead of an executable bit.
+func VerifyCommand(command, dir string) error {
+	cmd, err := commandFields(command, runtime.GOOS)
+	if err != nil {
+		return fmt.Errorf("failed to parse command: %w", err)
+	}
+	if len(cmd) == 0 {
+		return nil
+	}
+	return verifyProgram(cmd[0], dir, interpreter(cmd[0], runtime.GOOS))
+}
+
+// VerifyArgs checks the program of a hook argv like VerifyCommand does. argv
+// runs verbatim, so its program needs to be executable itself.
+func VerifyArgs(args []string, dir string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return verifyProgram(args[0], dir, nil)
+}
+
+// verifyProgram checks that name can be run from dir, through interp when
+// it's set.
+func verifyProgram(name, dir string, interp []string) error {
+	// A program rendered from the payload is only known when the hook runs
+	if strings.Contains(name, "{{") {
+		return nil
+	}
+	if len(interp) > 0 {
+		if _, err := exec.LookPath(interp[0]); err != nil {
+			return fmt.Errorf("%s is run with %s, which was not found in PATH", name, interp[0])
+		}
+		if cmdBuiltins[strings.ToLower(name)] {
+			return nil
+		}
+	}
+	if !strings.ContainsRune(name, '/') && !strings.ContainsRune(name, filepath.Separator) {
+		if _, err := exec.LookPath(name); err != nil {
+			return fmt.Errorf("%s was not found in PATH", name)
+		}
+		return nil
+	}
+	file := name
+	if !filepath.IsAbs(file) && dir != "" {
+		file = filepath.Join(dir, file)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s does not exist", file)
+		}
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", file)
+	}
+	// Windows has no executable bit, any existing file may be run, and
+	// interpreters read their scripts
+	if runtime.GOOS != "windows" && len(interp) == 0 && info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("%s is not executable, run chmod +x %s", file, file)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// WaitFor is the hook polled after create and update until the object they
+// produced is ready, which it reports by exiting 0.
+const WaitFor = "wait_for"
+
+// Defaults of the timeout and interval of the wait block.
+const (
+	DefaultWaitTimeout  = 10 * time.Minute
+	DefaultWaitInterval = 10 * time.Second
+)
+
+// WaitReady polls the wait_for hook with payload every interval until it
+// succeeds, and reports whether it did before timeout passed. Failed polls
+// only count as not ready yet, the last one is reported on timeout.
+func WaitReady(ctx context.Context, config CustomCRUDProviderConfig, model CrudModel, payload ExecutionPayload, diagnostics *diag.Diagnostics, interval, timeout time.Duration) bool {
+	deadline := Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		tflog.Info(ctx, "Waiting for the object to be ready", map[string]interface{}{
+			"id":      payload.Id,
+			"attempt": attempt,
+		})
+		var attemptDiags diag.Diagnostics
+		if _, ok := RunCrudScript(ctx, config, model, payload, &attemptDiags, CrudWaitFor); ok {
+			diagnostics.Append(attemptDiags.Warnings()...)
+			return true
+		}
+		if !Now().Add(interval).Before(deadline) {
+			detail := fmt.Sprintf("The wait_for hook didn't succeed within the wait timeout of %s.", timeout)
+			if errs := attemptDiags.Errors(); len(errs) > 0 {
+				detail += fmt.Sprintf(" Its last attempt failed with: %s: %s", errs[0].Summary(), errs[0].Detail())
+			}
+			diagnostics.AddError("Wait For Timeout", detail)
+			return false
+		}
+		if !Sleep(ctx, interval) {
+			diagnostics.AddError("Wait For Timeout", "Context cancelled while waiting for the object to be ready")
+			return false
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ExecutionWindows restricts the hooks changing objects, create, update and
+// delete, to the minutes matched by cron expressions, enforcing change
+// freezes outside of them.
+type ExecutionWindows struct {
+	schedules []cron.Schedule
+	// Wait is how long an operation outside the windows waits for one to
+	// open. 0 fails it right away.
+	Wait time.Duration
+}
+
+// cronParser parses the standard five fields, minute hour day-of-month month
+// day-of-week, and macros such as @daily.
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseExecutionWindows parses cron expressions of five fields, minute hour
+// day-of-month month day-of-week, or macros such as @weekly, each optionally
+// prefixed with TZ=<zone>. Times are matched in UTC by default.
+func ParseExecutionWindows(exprs []string, wait time.Duration) (*ExecutionWindows, error) {
+	w := &ExecutionWindows{Wait: wait}
+	for _, expr := range exprs {
+		if !strings.HasPrefix(expr, "TZ=") && !strings.HasPrefix(expr, "CRON_TZ=") {
+			expr = "TZ=UTC " + expr
+		}
+		schedule, err := cronParser.Parse(expr)
+		if err == nil {
+			// @every describes intervals rather than the minutes of a window
+			if _, ok := schedule.(*cron.SpecSchedule); !ok {
+				err = fmt.Errorf("@every isn't supported")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid execution window %q: %w", strings.TrimPrefix(expr, "TZ=UTC "), err)
+		}
+		w.schedules = append(w.schedules, schedule)
+	}
+	return w, nil
+}
+
+// Open reports whether t falls in one of the windows.
+func (w *ExecutionWindows) Open(t time.Time) bool {
+	minute := t.Truncate(time.Minute)
+	for _, s := range w.schedules {
+		if s.Next(minute.Add(-time.Nanosecond)).Equal(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the start of the first open minute after t, if any opens in
+// the next five years.
+func (w *ExecutionWindows) next(t time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, s := range w.schedules {
+		if at := s.Next(t); !at.IsZero() && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Await returns once an execution window is open, waiting up to Wait for
+// one, or an error naming op when none opens in time. The semaphore held for
+// ctx is released while waiting. A nil ExecutionWindows is always open.
+func (w *ExecutionWindows) Await(ctx context.Context, op string) error {
+	if w == nil || len(w.schedules) == 0 {
+		return nil
+	}
+	now := Now()
+	if w.Open(now) {
+		return nil
+	}
+	next, found := w.next(now)
+	opens := "no execution window opens within five years"
+	if found {
+		opens = fmt.Sprintf("the next execution window opens at %s", next.UTC().Format(time.RFC3339))
+	}
+	if w.Wait == 0 {
+		return fmt.Errorf("the %s operation is outside the provider execution_windows, %s", op, opens)
+	}
+	if !found || next.Sub(now) > w.Wait {
+		return fmt.Errorf("the %s operation is outside the provider execution_windows and %s, after the %s it may wait", op, opens, w.Wait)
+	}
+	if !Sleep(ctx, next.Sub(now)) {
+		return fmt.Errorf("the %s operation was cancelled while waiting for an execution window: %w", op, ctx.Err())
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package selftest checks hook scripts for compliance with the customcrud
+// protocol without running Terraform.
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/customcrud/terraform-provider-customcrud/internal/provider/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the self-test config file, in YAML or JSON.
+type Config struct {
+	WorkingDirectory        string     `yaml:"working_directory"`
+	MissingResourceExitCode *int       `yaml:"missing_resource_exit_code"`
+	HighPrecisionNumbers    bool       `yaml:"high_precision_numbers"`
+	Resources               []Resource `yaml:"resources"`
+}
+
+// Resource is a set of hooks to exercise together with the synthetic input
+// they receive. Entries with only a read hook are checked as data sources.
+type Resource struct {
+	Name  string                 `yaml:"name"`
+	Hooks Hooks                  `yaml:"hooks"`
+	Input map[string]interface{} `yaml:"input"`
+}
+
+// Hooks mirrors the hooks block of the customcrud resource.
+type Hooks struct {
+	Create           string `yaml:"create"`
+	Read             string `yaml:"read"`
+	Update           string `yaml:"update"`
+	Delete           string `yaml:"delete"`
+	Apply            string `yaml:"apply"`
+	Destroy          string `yaml:"destroy"`
+	WorkingDirectory string `yaml:"working_directory"`
+	OutputFormat     string `yaml:"output_format"`
+}
+
+// withApply returns h with its apply hook in place of the create and update
+// hooks and its destroy hook in place of the delete hook, like the provider
+// runs them. Unlike the provider, apply isn't run to read the resource, as
+// reading it after the delete would create it again.
+func (h Hooks) withApply() Hooks {
+	if h.Apply != "" && h.Create == "" && h.Update == "" {
+		h.Create, h.Update = h.Apply, h.Apply
+	}
+	if h.Destroy != "" && h.Delete == "" {
+		h.Delete = h.Destroy
+	}
+	return h
+}
+
+// LoadConfig reads a self-test config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read self-test config: %w", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse self-test config: %w", err)
+	}
+	if len(config.Resources) == 0 {
+		return nil, fmt.Errorf("self-test config %s has no resources", path)
+	}
+	for i := range config.Resources {
+		config.Resources[i].Hooks = config.Resources[i].Hooks.withApply()
+	}
+	return &config, nil
+}
+
+// ProviderConfig returns the provider config the hooks of config run with.
+func (c *Config) ProviderConfig() utils.CustomCRUDProviderConfig {
+	config := utils.CustomCRUDProviderConfigDefaults()
+	config.WorkingDirectory = c.WorkingDirectory
+	config.HighPrecisionNumbers = c.HighPrecisionNumbers
+	if c.MissingResourceExitCode != nil {
+		config.MissingResourceExitCode = *c.MissingResourceExitCode
+	}
+	return config
+}
+
+// tester runs the checks for one config and writes a line per check to out.
+type tester struct {
+	out      io.Writer
+	config   utils.CustomCRUDProviderConfig
+	failures int
+}
+
+// Run exercises every resource in config, writing a PASS or FAIL line per
+// check to out. It returns the number of failed checks.
+func Run(ctx context.Context, config *Config, out io.Writer) int {
+	t := &tester{out: out, config: config.ProviderConfig()}
+	for i, res := range config.Resources {
+		if res.Name == "" {
+			res.Name = fmt.Sprintf("resources[%d]", i)
+		}
+		t.resource(ctx, res)
+	}
+	fmt.Fprintf(out, "%d check(s) failed\n", t.failures)
+	return t.failures
+}
+
+func (t *tester) pass(res Resource, check, detail string) {
+	fmt.Fprintf(t.out, "PASS %s %s: %s\n", res.Name, check, detail)
+}
+
+func (t *tester) fail(res Resource, check, detail string) {
+	t.failures++
+	fmt.Fprintf(t.out, "FAIL %s %s: %s\n", res.Name, check, detail)
+}
+
+// run executes a hook with the overrides of its hooks block applied.
+func (t *tester) run(ctx context.Context, res Resource, command string, payload utils.ExecutionPayload) (*utils.ExecutionResult, error) {
+	return res.Hooks.Run(ctx, t.config, command, payload)
+}
+
+// Run executes command, one of the hooks, with the overrides of the hooks
+// block applied to config.
+func (h Hooks) Run(ctx context.Context, config utils.CustomCRUDProviderConfig, command string, payload utils.ExecutionPayload) (*utils.ExecutionResult, error) {
+	cmd, err := utils.SplitCommand(command)
+	if err == nil && len(cmd) == 0 {
+		err = fmt.Errorf("command is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid command: %w", err)
+	}
+	if h.WorkingDirectory != "" {
+		config.WorkingDirectory = h.WorkingDirectory
+	}
+	if h.OutputFormat != "" {
+		config.OutputFormat = h.OutputFormat
+	}
+	return utils.Execute(ctx, config, cmd, payload)
+}
+
+func (t *tester) resource(ctx context.Context, res Resource) {
+	if res.Hooks.Create == "" && res.Hooks.Delete == "" {
+		if res.Hooks.Read == "" {
+			t.fail(res, "hooks", "a read hook is required")
+			return
+		}
+		t.dataSource(ctx, res)
+		return
+	}
+	if res.Hooks.Create == "" {
+		t.fail(res, "hooks", "resources need a create hook")
+		return
+	}
+
+	payload := utils.ExecutionPayload{Input: res.Input, Phase: utils.PhaseApply}
+	result, err := t.run(ctx, res, res.Hooks.Create, payload)
+	if err != nil {
+		t.fail(res, "create", describe(err, result))
+		return
+	}
+	id, ok := result.Result["id"]
+	if !ok || id == nil || fmt.Sprintf("%v", id) == "" {
+		t.fail(res, "create", fmt.Sprintf("output has no 'id' field: %s", result.Stdout))
+		return
+	}
+	payload.Id = fmt.Sprintf("%v", id)
+	payload.Output = result.Result
+	t.pass(res, "create", fmt.Sprintf("returned id %q", payload.Id))
+
+	// Without a read hook the state is authoritative, there is nothing to read back
+	if res.Hooks.Read != "" {
+		payload.Phase = utils.PhaseRefresh
+		result, err = t.run(ctx, res, res.Hooks.Read, payload)
+		if err != nil || result.Result == nil {
+			t.fail(res, "read", describe(err, result))
+		} else {
+			payload.Output = result.Result
+			t.pass(res, "read", "returned a JSON object")
+		}
+	}
+
+	if res.Hooks.Update != "" {
+		payload.Phase = utils.PhaseApply
+		result, err = t.run(ctx, res, res.Hooks.Update, payload)
+		if err != nil || result.Result == nil {
+			t.fail(res, "update", describe(err, result))
+		} else {
+			payload.Output = result.Result
+			t.pass(res, "update", "returned a JSON object")
+		}
+	}
+
+	// Without a delete hook destroying only removes the resource from state
+	if res.Hooks.Delete == "" || strings.TrimSpace(res.Hooks.Delete) == utils.Noop {
+		return
+	}
+	payload.Phase = utils.PhaseDestroy
+	result, err = t.run(ctx, res, res.Hooks.Delete, payload)
+	if err != nil {
+		t.fail(res, "delete", describe(err, result))
+		return
+	}
+	t.pass(res, "delete", "succeeded")
+
+	if t.config.MissingResourceExitCode == -1 || res.Hooks.Read == "" {
+		return
+	}
+	payload.Phase = utils.PhaseRefresh
+	result, _ = t.run(ctx, res, res.Hooks.Read, payload)
+	if result == nil || result.ExitCode != t.config.MissingResourceExitCode {
+		exitCode := -1
+		if result != nil {
+			exitCode = result.ExitCode
+		}
+		t.fail(res, "read after delete", fmt.Sprintf("expected exit code %d for a missing resource, got %d", t.config.MissingResourceExitCode, exitCode))
+		return
+	}
+	t.pass(res, "read after delete", fmt.Sprintf("exited with %d", result.ExitCode))
+}
+
+func (t *tester) dataSource(ctx context.Context, res Resource) {
+	result, err := t.run(ctx, res, res.Hooks.Read, utils.ExecutionPayload{Input: res.Input, Phase: utils.PhaseRefresh})
+	if err != nil || result.Result == nil {
+		t.fail(res, "read", describe(err, result))
+		return
+	}
+	t.pass(res, "read", "returned a JSON object")
+}
+
+// describe explains why a hook run failed.
+func describe(err error, result *utils.ExecutionResult) string {
+	if err == nil {
+		err = fmt.Errorf("output is not a JSON object")
+	}
+	if result == nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%v (exit code %d, stdout: %q, stderr: %q)", err, result.ExitCode, result.Stdout, result.Stderr)
+}
@@ -0,0 +1,125 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "selftest.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestUnitSelftest_CompliantHooks(t *testing.T) {
+	path := writeConfig(t, `
+working_directory: testdata
+resources:
+  - name: file
+    hooks:
+      create: ./create.sh
+      read: ./read.sh
+      update: ./read.sh
+      delete: ./delete.sh
+    input:
+      content: hello
+  - name: file_lookup
+    hooks:
+      read: ./read.sh
+    input:
+      path: create.sh
+`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	var out bytes.Buffer
+	if failures := Run(context.Background(), config, &out); failures != 0 {
+		t.Fatalf("Expected no failures, got %d:\n%s", failures, out.String())
+	}
+	for _, check := range []string{"PASS file create", "PASS file read:", "PASS file update", "PASS file delete", "PASS file read after delete", "PASS file_lookup read"} {
+		if !strings.Contains(out.String(), check) {
+			t.Errorf("Expected %q in report:\n%s", check, out.String())
+		}
+	}
+}
+
+func TestUnitSelftest_NonCompliantHooks(t *testing.T) {
+	// JSON configs are accepted too
+	path := writeConfig(t, `{
+  "resources": [
+    {"name": "no_id", "hooks": {"create": "echo '{\"name\": \"x\"}'", "read": "echo '{}'", "delete": "true"}},
+    {"name": "not_json", "hooks": {"read": "echo 'not json'"}},
+    {"name": "always_found", "hooks": {"create": "echo '{\"id\": \"x\"}'", "read": "echo '{\"id\": \"x\"}'", "delete": "true"}}
+  ]
+}`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	var out bytes.Buffer
+	if failures := Run(context.Background(), config, &out); failures != 3 {
+		t.Fatalf("Expected 3 failures, got %d:\n%s", failures, out.String())
+	}
+	for _, check := range []string{"FAIL no_id create", "FAIL not_json read", "FAIL always_found read after delete: expected exit code 22"} {
+		if !strings.Contains(out.String(), check) {
+			t.Errorf("Expected %q in report:\n%s", check, out.String())
+		}
+	}
+}
+
+func TestUnitSelftest_OptionalHooks(t *testing.T) {
+	path := writeConfig(t, `{
+  "resources": [
+    {"name": "notify", "hooks": {"create": "echo '{\"id\": \"x\"}'"}},
+    {"name": "migrate", "hooks": {"create": "echo '{\"id\": \"x\"}'", "delete": "noop"}}
+  ]
+}`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	var out bytes.Buffer
+	if failures := Run(context.Background(), config, &out); failures != 0 {
+		t.Fatalf("Expected no failures, got %d:\n%s", failures, out.String())
+	}
+	if !strings.Contains(out.String(), "PASS notify create") || strings.Contains(out.String(), "read") || strings.Contains(out.String(), "delete") {
+		t.Errorf("Expected only the create hooks to run:\n%s", out.String())
+	}
+}
+
+func TestUnitSelftest_ApplyHooks(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, `{"resources": [{"name": "converge", "hooks": {"apply": "echo '{\"id\": \"x\"}'", "destroy": "true"}}]}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	var out bytes.Buffer
+	if failures := Run(context.Background(), config, &out); failures != 0 {
+		t.Fatalf("Expected no failures, got %d:\n%s", failures, out.String())
+	}
+	for _, check := range []string{"PASS converge create", "PASS converge update", "PASS converge delete"} {
+		if !strings.Contains(out.String(), check) {
+			t.Errorf("Expected %q in report:\n%s", check, out.String())
+		}
+	}
+}
+
+func TestUnitSelftest_LoadConfigErrors(t *testing.T) {
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+	if _, err := LoadConfig(writeConfig(t, "resources: []\n")); err == nil {
+		t.Error("Expected an error for a config without resources")
+	}
+}
@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+set -e
+input="$(cat)"
+id="$(mktemp)"
+echo "$input" | jq -r '.input.content' > "$id"
+jq -n --arg id "$id" '{id: $id}'
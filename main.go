@@ -7,9 +7,12 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 
+	"github.com/customcrud/terraform-provider-customcrud/internal/hookdocs"
 	"github.com/customcrud/terraform-provider-customcrud/internal/provider"
-	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/customcrud/terraform-provider-customcrud/internal/selftest"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6/tf6server"
 )
 
 var (
@@ -23,19 +26,45 @@ var (
 
 func main() {
 	var debug bool
+	var selftestConfig string
+	var docsDir string
 
 	flag.BoolVar(&debug, "debug", false, "set to true to run the provider with support for debuggers like delve")
+	flag.StringVar(&selftestConfig, "selftest", "", "check the hooks listed in the given config file for protocol compliance instead of serving the provider")
+	flag.StringVar(&docsDir, "docs-dir", "", "with -selftest, write documentation for the hooks implementing the describe handshake to the given directory instead of checking them")
 	flag.Parse()
 
-	opts := providerserver.ServeOpts{
-		// TODO: Update this string with the published name of your provider.
-		// Also update the tfplugindocs generate command to either remove the
-		// -provider-name flag or set its value to the updated provider name.
-		Address: "registry.terraform.io/hashicorp/scaffolding",
-		Debug:   debug,
+	if selftestConfig != "" {
+		config, err := selftest.LoadConfig(selftestConfig)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		if docsDir != "" {
+			if _, err := hookdocs.Generate(context.Background(), config, docsDir, os.Stdout); err != nil {
+				log.Fatal(err.Error())
+			}
+			return
+		}
+		if failures := selftest.Run(context.Background(), config, os.Stdout); failures > 0 {
+			os.Exit(1)
+		}
+		return
 	}
 
-	err := providerserver.Serve(context.Background(), provider.New(version), opts)
+	var serveOpts []tf6server.ServeOpt
+	if debug {
+		serveOpts = append(serveOpts, tf6server.WithManagedDebug())
+	}
+
+	// TODO: Update this address with the published name of your provider.
+	// Also update the tfplugindocs generate command to either remove the
+	// -provider-name flag or set its value to the updated provider name.
+	err := tf6server.Serve("registry.terraform.io/hashicorp/scaffolding", provider.NewProtocol6WithShutdown(provider.New(version)), serveOpts...)
+
+	// Terraform is done with the provider, run the cleanup it didn't trigger
+	if shutdownErr := provider.Shutdown(context.Background()); shutdownErr != nil {
+		log.Print(shutdownErr.Error())
+	}
 
 	if err != nil {
 		log.Fatal(err.Error())